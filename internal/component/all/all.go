@@ -67,6 +67,7 @@ import (
 	_ "github.com/grafana/agent/internal/component/otelcol/auth/oauth2"                      // Import otelcol.auth.oauth2
 	_ "github.com/grafana/agent/internal/component/otelcol/auth/sigv4"                       // Import otelcol.auth.sigv4
 	_ "github.com/grafana/agent/internal/component/otelcol/connector/host_info"              // Import otelcol.connector.host_info
+	_ "github.com/grafana/agent/internal/component/otelcol/connector/routing"                // Import otelcol.connector.routing
 	_ "github.com/grafana/agent/internal/component/otelcol/connector/servicegraph"           // Import otelcol.connector.servicegraph
 	_ "github.com/grafana/agent/internal/component/otelcol/connector/spanlogs"               // Import otelcol.connector.spanlogs
 	_ "github.com/grafana/agent/internal/component/otelcol/connector/spanmetrics"            // Import otelcol.connector.spanmetrics
@@ -80,6 +81,7 @@ import (
 	_ "github.com/grafana/agent/internal/component/otelcol/extension/jaeger_remote_sampling" // Import otelcol.extension.jaeger_remote_sampling
 	_ "github.com/grafana/agent/internal/component/otelcol/processor/attributes"             // Import otelcol.processor.attributes
 	_ "github.com/grafana/agent/internal/component/otelcol/processor/batch"                  // Import otelcol.processor.batch
+	_ "github.com/grafana/agent/internal/component/otelcol/processor/deltatocumulative"      // Import otelcol.processor.deltatocumulative
 	_ "github.com/grafana/agent/internal/component/otelcol/processor/discovery"              // Import otelcol.processor.discovery
 	_ "github.com/grafana/agent/internal/component/otelcol/processor/filter"                 // Import otelcol.processor.filter
 	_ "github.com/grafana/agent/internal/component/otelcol/processor/k8sattributes"          // Import otelcol.processor.k8sattributes
@@ -127,7 +129,9 @@ import (
 	_ "github.com/grafana/agent/internal/component/prometheus/operator/podmonitors"          // Import prometheus.operator.podmonitors
 	_ "github.com/grafana/agent/internal/component/prometheus/operator/probes"               // Import prometheus.operator.probes
 	_ "github.com/grafana/agent/internal/component/prometheus/operator/servicemonitors"      // Import prometheus.operator.servicemonitors
+	_ "github.com/grafana/agent/internal/component/prometheus/receive_graphite"              // Import prometheus.receive_graphite
 	_ "github.com/grafana/agent/internal/component/prometheus/receive_http"                  // Import prometheus.receive_http
+	_ "github.com/grafana/agent/internal/component/prometheus/receive_influxdb"              // Import prometheus.receive_influxdb
 	_ "github.com/grafana/agent/internal/component/prometheus/relabel"                       // Import prometheus.relabel
 	_ "github.com/grafana/agent/internal/component/prometheus/remotewrite"                   // Import prometheus.remote_write
 	_ "github.com/grafana/agent/internal/component/prometheus/scrape"                        // Import prometheus.scrape
@@ -140,4 +144,5 @@ import (
 	_ "github.com/grafana/agent/internal/component/remote/kubernetes/secret"                 // Import remote.kubernetes.secret
 	_ "github.com/grafana/agent/internal/component/remote/s3"                                // Import remote.s3
 	_ "github.com/grafana/agent/internal/component/remote/vault"                             // Import remote.vault
+	_ "github.com/grafana/agent/internal/component/remotecfg/exports"                        // Import remotecfg.exports
 )
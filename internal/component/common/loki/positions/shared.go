@@ -0,0 +1,72 @@
+package positions
+
+import "strconv"
+
+// Store is a key/value store shared between processes, such as the key/value
+// store of the cluster service.
+type Store interface {
+	Get(key string) (value string, ok bool)
+	Set(key, value string)
+	Delete(key string)
+}
+
+// shared stores positions both in local Positions and in a Store, so that
+// another process can resume reading where the last one left off.
+type shared struct {
+	Positions
+
+	store  Store
+	prefix string // Prefix of the keys in store.
+}
+
+// NewShared returns Positions which are also written to store, under keys
+// prefixed with prefix. Positions are offsets or cursors which only grow, so
+// the greatest of the local and the shared position is used: the shared one
+// was written by the last process which read the same entries, unless the
+// local process got further before the store caught up.
+func NewShared(p Positions, store Store, prefix string) Positions {
+	return &shared{Positions: p, store: store, prefix: prefix}
+}
+
+func (s *shared) key(path, labels string) string {
+	return s.prefix + path + "/" + labels
+}
+
+func (s *shared) GetString(path, labels string) string {
+	local := s.Positions.GetString(path, labels)
+	shared, ok := s.store.Get(s.key(path, labels))
+	if !ok {
+		return local
+	}
+
+	localPos, err := strconv.ParseInt(local, 10, 64)
+	if err != nil {
+		return shared
+	}
+	if sharedPos, err := strconv.ParseInt(shared, 10, 64); err == nil && sharedPos > localPos {
+		return shared
+	}
+	return local
+}
+
+func (s *shared) Get(path, labels string) (int64, error) {
+	pos := s.GetString(path, labels)
+	if pos == "" {
+		return 0, nil
+	}
+	return strconv.ParseInt(pos, 10, 64)
+}
+
+func (s *shared) PutString(path, labels string, pos string) {
+	s.Positions.PutString(path, labels, pos)
+	s.store.Set(s.key(path, labels), pos)
+}
+
+func (s *shared) Put(path, labels string, pos int64) {
+	s.PutString(path, labels, strconv.FormatInt(pos, 10))
+}
+
+func (s *shared) Remove(path, labels string) {
+	s.Positions.Remove(path, labels)
+	s.store.Delete(s.key(path, labels))
+}
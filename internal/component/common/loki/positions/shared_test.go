@@ -0,0 +1,123 @@
+package positions
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+type mapStore map[string]string
+
+func (s mapStore) Get(key string) (string, bool) { v, ok := s[key]; return v, ok }
+func (s mapStore) Set(key, value string)         { s[key] = value }
+func (s mapStore) Delete(key string)             { delete(s, key) }
+
+func TestShared(t *testing.T) {
+	newPositions := func(t *testing.T) Positions {
+		p, err := New(log.NewNopLogger(), Config{
+			SyncPeriod:    10 * time.Second,
+			PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+		})
+		require.NoError(t, err)
+		t.Cleanup(p.Stop)
+		return p
+	}
+
+	var (
+		store   = mapStore{}
+		labels  = `{job="test"}`
+		local1  = newPositions(t)
+		local2  = newPositions(t)
+		node1   = NewShared(local1, store, "component.default/")
+		node2   = NewShared(local2, store, "component.default/")
+		another = NewShared(newPositions(t), store, "component.other/")
+	)
+
+	pos, err := node2.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Zero(t, pos)
+
+	// The position written by a process is read by the process which takes
+	// over.
+	node1.Put("/var/log/app.log", labels, 100)
+	pos, err = node2.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+
+	// Shared positions win over stale local ones.
+	local2.Put("/var/log/app.log", labels, 50)
+	pos, err = node2.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+
+	// The local position is used when it's ahead of the shared one.
+	local2.Put("/var/log/app.log", labels, 200)
+	pos, err = node2.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), pos)
+	local2.Put("/var/log/app.log", labels, 50)
+
+	// Components don't share positions.
+	pos, err = another.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Zero(t, pos)
+
+	// Local positions are used when the store doesn't have them, such as
+	// after the whole cluster restarted.
+	node1.Remove("/var/log/app.log", labels)
+	require.Empty(t, store)
+	pos, err = node2.Get("/var/log/app.log", labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(50), pos)
+	require.Equal(t, "", local1.GetString("/var/log/app.log", labels))
+}
+
+func TestShared_Cursor(t *testing.T) {
+	newPositions := func(t *testing.T) Positions {
+		p, err := New(log.NewNopLogger(), Config{
+			SyncPeriod:    10 * time.Second,
+			PositionsFile: filepath.Join(t.TempDir(), "positions.yml"),
+		})
+		require.NoError(t, err)
+		t.Cleanup(p.Stop)
+		return p
+	}
+
+	var (
+		store   = mapStore{}
+		cursor  = CursorKey("zone")
+		labels  = `{job="cloudflare"}`
+		local2  = newPositions(t)
+		node1   = NewShared(newPositions(t), store, "loki.source.cloudflare.default/")
+		node2   = NewShared(local2, store, "loki.source.cloudflare.default/")
+		another = NewShared(newPositions(t), store, "loki.source.cloudflare.other/")
+	)
+
+	pos, err := node2.Get(cursor, labels)
+	require.NoError(t, err)
+	require.Zero(t, pos)
+
+	// The cursor written by a node is read by the node which takes over.
+	node1.Put(cursor, labels, 100)
+	pos, err = node2.Get(cursor, labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(100), pos)
+
+	// The local cursor is used when it's ahead of the cluster.
+	local2.Put(cursor, labels, 200)
+	pos, err = node2.Get(cursor, labels)
+	require.NoError(t, err)
+	require.Equal(t, int64(200), pos)
+
+	// Components don't share cursors.
+	pos, err = another.Get(cursor, labels)
+	require.NoError(t, err)
+	require.Zero(t, pos)
+
+	node1.Remove(cursor, labels)
+	_, ok := store.Get("loki.source.cloudflare.default/" + cursor + "/" + labels)
+	require.False(t, ok)
+}
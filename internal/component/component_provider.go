@@ -96,6 +96,10 @@ type Info struct {
 	ComponentName string // Name of the component.
 	Health        Health // Current component health.
 
+	// SingletonOwner is the name of the node of the cluster running the
+	// component if it's a singleton component.
+	SingletonOwner string
+
 	Arguments Arguments   // Current arguments value of the component.
 	Exports   Exports     // Current exports value of the component.
 	DebugInfo interface{} // Current debug info of the component.
@@ -125,6 +129,7 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 			Exports          json.RawMessage      `json:"exports,omitempty"`
 			DebugInfo        json.RawMessage      `json:"debugInfo,omitempty"`
 			CreatedModuleIDs []string             `json:"createdModuleIDs,omitempty"`
+			SingletonOwner   string               `json:"singletonOwner,omitempty"`
 		}
 	)
 
@@ -173,6 +178,7 @@ func (info *Info) MarshalJSON() ([]byte, error) {
 		Exports:          exports,
 		DebugInfo:        debugInfo,
 		CreatedModuleIDs: info.ModuleIDs,
+		SingletonOwner:   info.SingletonOwner,
 	})
 }
 
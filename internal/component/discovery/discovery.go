@@ -2,6 +2,7 @@ package discovery
 
 import (
 	"context"
+	"errors"
 	"sort"
 	"strings"
 	"sync"
@@ -9,6 +10,7 @@ import (
 
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/service/cluster"
+	"github.com/grafana/ckit/peer"
 	"github.com/grafana/ckit/shard"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/discovery"
@@ -26,12 +28,58 @@ type DistributedTargets struct {
 	useClustering bool
 	cluster       cluster.Cluster
 	targets       []Target
+
+	// zoneLabel is the label holding the zone of targets with zone-aware
+	// sharding.
+	zoneLabel    string
+	zoneFallback cluster.ZoneFallback
+
+	// keyLabel is the label identifying targets in the cluster. Targets are
+	// identified by their non-meta labels if it's empty.
+	keyLabel string
 }
 
 // NewDistributedTargets creates the abstraction that allows components to
 // dynamically shard targets between components.
 func NewDistributedTargets(e bool, n cluster.Cluster, t []Target) DistributedTargets {
-	return DistributedTargets{e, n, t}
+	return DistributedTargets{useClustering: e, cluster: n, targets: t}
+}
+
+// WithZones returns a copy of t which assigns targets to the nodes in the
+// same zone as the target, read from the zoneLabel label of the target.
+// fallback decides which nodes own the targets of zones without nodes, and
+// targets without the label.
+func (t DistributedTargets) WithZones(zoneLabel string, fallback cluster.ZoneFallback) DistributedTargets {
+	t.zoneLabel = zoneLabel
+	t.zoneFallback = fallback
+	return t
+}
+
+// WithKeyLabel returns a copy of t which identifies targets in the cluster
+// by the value of their keyLabel label instead of their non-meta labels, so
+// that targets with the same value are owned by the same node. keyLabel may
+// be a meta label.
+func (t DistributedTargets) WithKeyLabel(keyLabel string) DistributedTargets {
+	t.keyLabel = keyLabel
+	return t
+}
+
+// key returns the key identifying tgt in the cluster.
+func (t *DistributedTargets) key(tgt Target) string {
+	if key, ok := tgt[t.keyLabel]; ok && t.keyLabel != "" {
+		return key
+	}
+	return tgt.NonMetaLabels().String()
+}
+
+// lookup returns the owners of tgt, whose key in the cluster is key.
+func (t *DistributedTargets) lookup(tgt Target, key string, replicationFactor int) ([]peer.Peer, error) {
+	if t.zoneLabel != "" {
+		if zones, ok := t.cluster.(cluster.Zones); ok {
+			return zones.LookupZone(shard.StringKey(key), tgt[t.zoneLabel], t.zoneFallback, replicationFactor, shard.OpReadWrite)
+		}
+	}
+	return t.cluster.Lookup(shard.StringKey(key), replicationFactor, shard.OpReadWrite)
 }
 
 // Get distributes discovery targets a clustered environment.
@@ -52,7 +100,10 @@ func (t *DistributedTargets) Get() []Target {
 	res := make([]Target, 0, resCap)
 
 	for _, tgt := range t.targets {
-		peers, err := t.cluster.Lookup(shard.StringKey(tgt.NonMetaLabels().String()), 1, shard.OpReadWrite)
+		peers, err := t.lookup(tgt, t.key(tgt), 1)
+		if errors.Is(err, cluster.ErrNoZoneOwner) {
+			continue
+		}
 		if err != nil {
 			// This can only fail in case we ask for more owners than the
 			// available peers. This will never happen, but in any case we fall
@@ -67,6 +118,94 @@ func (t *DistributedTargets) Get() []Target {
 	return res
 }
 
+// Owners returns the number of targets owned by each peer of the cluster,
+// by peer name, counting every target for its first owner. Targets without
+// an owner are counted under the empty name. Owners returns nil if
+// clustering is disabled.
+func (t *DistributedTargets) Owners() map[string]int {
+	if !t.useClustering || t.cluster == nil {
+		return nil
+	}
+
+	owners := make(map[string]int)
+	for _, tgt := range t.targets {
+		peers, err := t.lookup(tgt, t.key(tgt), 1)
+		if err != nil || len(peers) == 0 {
+			owners[""]++
+			continue
+		}
+		owners[peers[0].Name]++
+	}
+	return owners
+}
+
+// ReplicatedTarget is a target the local node is one of the owners of.
+type ReplicatedTarget struct {
+	Target Target
+	// Key identifies the target in the cluster.
+	Key string
+	// Leader is true if the local node is the first owner of the target.
+	Leader bool
+}
+
+// GetReplicated distributes discovery targets in a clustered environment
+// like Get, but assigns every target to up to replicationFactor nodes. The
+// nodes agree on the first owner of a target, which is reported as its
+// leader.
+//
+// Only nodes in the participant state own targets, so as soon as the leader
+// of a target starts terminating, or is removed from the cluster by the
+// failure detection of the gossip protocol, the next owner becomes the leader
+// of the target.
+//
+// If clustering is disabled, all targets are returned and the local node
+// leads all of them.
+func (t *DistributedTargets) GetReplicated(replicationFactor int) []ReplicatedTarget {
+	res := make([]ReplicatedTarget, 0, len(t.targets))
+
+	if !t.useClustering || t.cluster == nil {
+		for _, tgt := range t.targets {
+			res = append(res, ReplicatedTarget{Target: tgt, Key: t.key(tgt), Leader: true})
+		}
+		return res
+	}
+
+	// Terminating nodes are still peers, but can't own targets.
+	var participants int
+	for _, p := range t.cluster.Peers() {
+		if p.State == peer.StateParticipant {
+			participants++
+		}
+	}
+	if replicationFactor > participants {
+		replicationFactor = participants
+	}
+	if replicationFactor < 1 {
+		replicationFactor = 1
+	}
+
+	for _, tgt := range t.targets {
+		key := t.key(tgt)
+		peers, err := t.lookup(tgt, key, replicationFactor)
+		if errors.Is(err, cluster.ErrNoZoneOwner) {
+			continue
+		}
+		if err != nil || len(peers) == 0 {
+			// Fall back to owning the target ourselves, as in Get.
+			res = append(res, ReplicatedTarget{Target: tgt, Key: key, Leader: true})
+			continue
+		}
+		for i, p := range peers {
+			if p.Self {
+				res = append(res, ReplicatedTarget{Target: tgt, Key: key, Leader: i == 0})
+				break
+			}
+		}
+	}
+
+	return res
+}
+
 // Labels converts Target into a set of sorted labels.
 func (t Target) Labels() labels.Labels {
 	var lset labels.Labels
@@ -19,6 +19,8 @@ import (
 	cft "github.com/grafana/agent/internal/component/loki/source/cloudflare/internal/cloudflaretarget"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/cluster"
+	"github.com/grafana/ckit/shard"
 	"github.com/grafana/river/rivertypes"
 	"github.com/prometheus/common/model"
 )
@@ -46,6 +48,8 @@ type Arguments struct {
 	FieldsType       string              `river:"fields_type,attr,optional"`
 	AdditionalFields []string            `river:"additional_fields,attr,optional"`
 	ForwardTo        []loki.LogsReceiver `river:"forward_to,attr"`
+
+	Clustering cluster.ComponentBlock `river:"clustering,block,optional"`
 }
 
 // Convert returns a cloudflaretarget Config struct from the Arguments.
@@ -89,14 +93,24 @@ func (c *Arguments) Validate() error {
 	return nil
 }
 
+var (
+	_ component.Component = (*Component)(nil)
+	_ cluster.Component   = (*Component)(nil)
+)
+
 // Component implements the loki.source.cloudflare component.
 type Component struct {
 	opts    component.Options
 	metrics *cft.Metrics
+	cluster cluster.Cluster
+	kv      cluster.KV
+
+	updateMut sync.Mutex
 
 	mut    sync.RWMutex
+	args   Arguments
 	fanout []loki.LogsReceiver
-	target *cft.Target
+	target *cft.Target // nil while another node of the cluster pulls the logs.
 
 	posFile positions.Positions
 	handler loki.LogsReceiver
@@ -126,8 +140,20 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		posFile: positionsFile,
 	}
 
+	// The cluster service is only required when clustering is enabled.
+	if data, err := o.GetServiceData(cluster.ServiceName); err == nil {
+		cl, isCluster := data.(cluster.Cluster)
+		kv, isKV := data.(cluster.KV)
+		if !isCluster || !isKV {
+			positionsFile.Stop()
+			return nil, fmt.Errorf("unexpected data type %T for the cluster service", data)
+		}
+		c.cluster, c.kv = cl, kv
+	}
+
 	// Call to Update() to start readers and set receivers once at the start.
 	if err := c.Update(args); err != nil {
+		positionsFile.Stop()
 		return nil, err
 	}
 
@@ -139,7 +165,10 @@ func (c *Component) Run(ctx context.Context) error {
 	defer func() {
 		c.mut.RLock()
 		level.Info(c.opts.Logger).Log("msg", "loki.source.cloudflare component shutting down, stopping the target")
-		c.target.Stop()
+		if c.target != nil {
+			c.target.Stop()
+		}
+		c.posFile.Stop()
 		c.mut.RUnlock()
 	}()
 
@@ -159,18 +188,80 @@ func (c *Component) Run(ctx context.Context) error {
 
 // Update implements component.Component.
 func (c *Component) Update(args component.Arguments) error {
+	c.updateMut.Lock()
+	defer c.updateMut.Unlock()
+	return c.update(args.(Arguments))
+}
+
+// NotifyClusterChange implements cluster.Component.
+func (c *Component) NotifyClusterChange() {
+	c.updateMut.Lock()
+	defer c.updateMut.Unlock()
+
+	// c.args is only written while c.updateMut is held.
+	if !c.args.Clustering.Enabled {
+		return
+	}
+
+	c.mut.RLock()
+	running := c.target != nil
+	c.mut.RUnlock()
+
+	// Only restart pulling logs when the node running the component changed.
+	if running == c.owned(c.args) {
+		return
+	}
+	if err := c.update(c.args); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to update target after cluster change", "err", err)
+	}
+}
+
+// owned returns whether the local node pulls the logs of the zone. When
+// clustering is enabled, exactly one node of the cluster pulls them, so that
+// the logs aren't duplicated.
+func (c *Component) owned(args Arguments) bool {
+	if !args.Clustering.Enabled {
+		return true
+	}
+
+	peers, err := c.cluster.Lookup(shard.StringKey(c.opts.ID), 1, shard.OpReadWrite)
+	if err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to look up the node pulling logs", "err", err)
+		return false
+	}
+	return len(peers) > 0 && peers[0].Self
+}
+
+func (c *Component) update(newArgs Arguments) error {
+	if newArgs.Clustering.Enabled && (c.cluster == nil || c.kv == nil) {
+		return fmt.Errorf("clustering is enabled, but the cluster service isn't available")
+	}
+	owned := c.owned(newArgs)
+
 	c.mut.Lock()
 	defer c.mut.Unlock()
 
-	newArgs := args.(Arguments)
+	c.args = newArgs
 	c.fanout = newArgs.ForwardTo
 
 	if c.target != nil {
 		c.target.Stop()
+		c.target = nil
+	}
+	if !owned {
+		level.Debug(c.opts.Logger).Log("msg", "another node of the cluster pulls the logs", "zone_id", newArgs.ZoneID)
+		return nil
 	}
 	entryHandler := loki.NewEntryHandler(c.handler.Chan(), func() {})
 
-	t, err := cft.NewTarget(c.metrics, c.opts.Logger, entryHandler, c.posFile, newArgs.Convert())
+	// When clustering is enabled, the cursor is shared with the cluster so
+	// that the node which takes over the component resumes from it.
+	pos := c.posFile
+	if newArgs.Clustering.Enabled {
+		pos = positions.NewShared(c.posFile, c.kv, c.opts.ID+"/")
+	}
+
+	t, err := cft.NewTarget(c.metrics, c.opts.Logger, entryHandler, pos, newArgs.Convert())
 	if err != nil {
 		level.Error(c.opts.Logger).Log("msg", "failed to create cloudflare target with provided config", "err", err)
 		return err
@@ -185,6 +276,10 @@ func (c *Component) DebugInfo() interface{} {
 	c.mut.RLock()
 	defer c.mut.RUnlock()
 
+	if c.target == nil {
+		return targetDebugInfo{Details: map[string]string{"owner": "another node of the cluster"}}
+	}
+
 	lbls := make(map[string]string, len(c.target.Labels()))
 	for k, v := range c.target.Labels() {
 		lbls[string(k)] = string(v)
@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/tail/watch"
 	"github.com/prometheus/common/model"
 )
@@ -46,6 +47,8 @@ type Arguments struct {
 	FileWatch           FileWatch           `river:"file_watch,block,optional"`
 	TailFromEnd         bool                `river:"tail_from_end,attr,optional"`
 	LegacyPositionsFile string              `river:"legacy_positions_file,attr,optional"`
+
+	Clustering cluster.ComponentBlock `river:"clustering,block,optional"`
 }
 
 type FileWatch struct {
@@ -71,12 +74,18 @@ type DecompressionConfig struct {
 	Format       CompressionFormat `river:"format,attr"`
 }
 
-var _ component.Component = (*Component)(nil)
+var (
+	_ component.Component       = (*Component)(nil)
+	_ cluster.Component         = (*Component)(nil)
+	_ cluster.OwnershipReporter = (*Component)(nil)
+)
 
 // Component implements the loki.source.file component.
 type Component struct {
 	opts    component.Options
 	metrics *metrics
+	cluster cluster.Cluster
+	kv      cluster.KV
 
 	updateMut sync.Mutex
 
@@ -85,6 +94,7 @@ type Component struct {
 	handler   loki.LogsReceiver
 	receivers []loki.LogsReceiver
 	posFile   positions.Positions
+	positions positions.Positions // posFile, shared with the cluster when clustering is enabled.
 	readers   map[positions.Entry]reader
 }
 
@@ -116,6 +126,7 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		handler:   loki.NewLogsReceiver(),
 		receivers: args.ForwardTo,
 		posFile:   positionsFile,
+		positions: positionsFile,
 		readers:   make(map[positions.Entry]reader),
 	}
 
@@ -161,6 +172,42 @@ func (c *Component) Run(ctx context.Context) error {
 func (c *Component) Update(args component.Arguments) error {
 	c.updateMut.Lock()
 	defer c.updateMut.Unlock()
+	return c.update(args.(Arguments))
+}
+
+// NotifyClusterChange implements cluster.Component.
+func (c *Component) NotifyClusterChange() {
+	c.updateMut.Lock()
+	defer c.updateMut.Unlock()
+
+	// c.args is only written while c.updateMut is held.
+	if !c.args.Clustering.Enabled {
+		return
+	}
+	if err := c.update(c.args); err != nil {
+		level.Error(c.opts.Logger).Log("msg", "failed to redistribute files after cluster change", "err", err)
+	}
+}
+
+// TargetOwners implements cluster.OwnershipReporter.
+func (c *Component) TargetOwners(cl cluster.Cluster) map[string]int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	distTargets := discovery.NewDistributedTargets(c.args.Clustering.Enabled, cl, c.args.Targets).WithKeyLabel(pathLabel)
+	return distTargets.Owners()
+}
+
+// update recreates the readers of the files in newArgs which the local node
+// owns. update must only be called while c.updateMut is held.
+func (c *Component) update(newArgs Arguments) error {
+	cl, kv := c.cluster, c.kv
+	if newArgs.Clustering.Enabled && cl == nil {
+		var err error
+		if cl, kv, err = c.lookupCluster(); err != nil {
+			return err
+		}
+	}
 
 	// Stop all readers so we can recreate them below. This *must* be done before
 	// c.mut is held to avoid a race condition where stopping a reader is
@@ -184,30 +231,37 @@ func (c *Component) Update(args component.Arguments) error {
 	//   and c.stopTailingAndRemovePosition.
 	oldPaths := c.stopReaders()
 
-	newArgs := args.(Arguments)
-
 	c.mut.Lock()
 	defer c.mut.Unlock()
 	c.args = newArgs
 	c.receivers = newArgs.ForwardTo
+	c.cluster, c.kv = cl, kv
 
 	c.readers = make(map[positions.Entry]reader)
 
+	// With clustering, every file is read by a single node, which shares its
+	// position so that the next owner of the file resumes from it.
+	c.positions = c.posFile
+	if newArgs.Clustering.Enabled {
+		c.positions = positions.NewShared(c.posFile, c.kv, c.opts.ID+"/")
+	}
+
 	if len(newArgs.Targets) == 0 {
 		level.Debug(c.opts.Logger).Log("msg", "no files targets were passed, nothing will be tailed")
 		return nil
 	}
 
+	var (
+		distTargets = discovery.NewDistributedTargets(newArgs.Clustering.Enabled, c.cluster, newArgs.Targets).WithKeyLabel(pathLabel)
+		allEntries  = make(map[positions.Entry]struct{}, len(newArgs.Targets))
+	)
 	for _, target := range newArgs.Targets {
-		path := target[pathLabel]
+		allEntries[targetEntry(target)] = struct{}{}
+	}
 
-		labels := make(model.LabelSet)
-		for k, v := range target {
-			if strings.HasPrefix(k, model.ReservedLabelPrefix) {
-				continue
-			}
-			labels[model.LabelName(k)] = model.LabelValue(v)
-		}
+	for _, target := range distTargets.Get() {
+		path := target[pathLabel]
+		labels := targetLabels(target)
 
 		// Deduplicate targets which have the same public label set.
 		readersKey := positions.Entry{Path: path, Labels: labels.String()}
@@ -230,14 +284,33 @@ func (c *Component) Update(args component.Arguments) error {
 	}
 
 	// Remove from the positions file any entries that had a Reader before, but
-	// are no longer in the updated set of Targets.
+	// are no longer in the updated set of Targets. Entries now read by another
+	// node of the cluster keep their position for the new owner.
 	for r := range missing(c.readers, oldPaths) {
-		c.posFile.Remove(r.Path, r.Labels)
+		if _, ok := allEntries[r]; ok {
+			continue
+		}
+		c.positions.Remove(r.Path, r.Labels)
 	}
 
 	return nil
 }
 
+// lookupCluster returns the cluster service, which is only required when
+// clustering is enabled.
+func (c *Component) lookupCluster() (cluster.Cluster, cluster.KV, error) {
+	data, err := c.opts.GetServiceData(cluster.ServiceName)
+	if err != nil {
+		return nil, nil, fmt.Errorf("clustering is enabled, but the cluster service isn't available: %w", err)
+	}
+	cl, isCluster := data.(cluster.Cluster)
+	kv, isKV := data.(cluster.KV)
+	if !isCluster || !isKV {
+		return nil, nil, fmt.Errorf("unexpected data type %T for the cluster service", data)
+	}
+	return cl, kv, nil
+}
+
 // readerWithHandler combines a reader with an entry handler associated with
 // it. Closing the reader will also close the handler.
 type readerWithHandler struct {
@@ -270,9 +343,12 @@ func (c *Component) stopReaders() map[positions.Entry]struct{} {
 // TODO(@tpaschalis) Decorate with more debug information once it's made
 // available, such as the last time a log line was read.
 func (c *Component) DebugInfo() interface{} {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
 	var res readerDebugInfo
 	for e, reader := range c.readers {
-		offset, _ := c.posFile.Get(e.Path, e.Labels)
+		offset, _ := c.positions.Get(e.Path, e.Labels)
 		res.TargetsInfo = append(res.TargetsInfo, targetInfo{
 			Path:       e.Path,
 			Labels:     e.Labels,
@@ -294,6 +370,23 @@ type targetInfo struct {
 	ReadOffset int64  `river:"read_offset,attr"`
 }
 
+// targetLabels returns the labels of target, without its reserved labels.
+func targetLabels(target discovery.Target) model.LabelSet {
+	labels := make(model.LabelSet)
+	for k, v := range target {
+		if strings.HasPrefix(k, model.ReservedLabelPrefix) {
+			continue
+		}
+		labels[model.LabelName(k)] = model.LabelValue(v)
+	}
+	return labels
+}
+
+// targetEntry returns the positions entry of target.
+func targetEntry(target discovery.Target) positions.Entry {
+	return positions.Entry{Path: target[pathLabel], Labels: targetLabels(target).String()}
+}
+
 // Returns the elements from set b which are missing from set a
 func missing(as map[positions.Entry]reader, bs map[positions.Entry]struct{}) map[positions.Entry]struct{} {
 	c := map[positions.Entry]struct{}{}
@@ -329,7 +422,7 @@ func (c *Component) startTailing(path string, labels model.LabelSet, handler lok
 			c.metrics,
 			c.opts.Logger,
 			handler,
-			c.posFile,
+			c.positions,
 			path,
 			labels.String(),
 			c.args.Encoding,
@@ -350,7 +443,7 @@ func (c *Component) startTailing(path string, labels model.LabelSet, handler lok
 			c.metrics,
 			c.opts.Logger,
 			handler,
-			c.posFile,
+			c.positions,
 			path,
 			labels.String(),
 			c.args.Encoding,
@@ -8,6 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,7 +16,10 @@ import (
 	"github.com/grafana/agent/internal/component/common/loki"
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/model"
 	"github.com/stretchr/testify/require"
@@ -314,3 +318,105 @@ func TestEncoding(t *testing.T) {
 		"expected positions.yml file to be written eventually",
 	)
 }
+
+func TestClustering(t *testing.T) {
+	var (
+		kv    = cluster.Mock().(cluster.KV)
+		nodeA = &fakeCluster{KV: kv, name: "a"}
+		nodeB = &fakeCluster{KV: kv, name: "b"}
+	)
+
+	f, err := os.CreateTemp(t.TempDir(), "example")
+	require.NoError(t, err)
+	defer f.Close()
+
+	newComponent := func(t *testing.T, c *fakeCluster) (*Component, loki.LogsReceiver) {
+		opts := component.Options{
+			ID:            "loki.source.file.test",
+			Logger:        util.TestFlowLogger(t),
+			Registerer:    prometheus.NewRegistry(),
+			OnStateChange: func(e component.Exports) {},
+			DataPath:      t.TempDir(),
+			GetServiceData: func(name string) (interface{}, error) {
+				return c, nil
+			},
+		}
+
+		ch := loki.NewLogsReceiver()
+		args := DefaultArguments
+		args.Targets = []discovery.Target{{"__path__": f.Name(), "foo": "bar"}}
+		args.ForwardTo = []loki.LogsReceiver{ch}
+		args.Clustering.Enabled = true
+
+		comp, err := New(opts, args)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		t.Cleanup(cancel)
+		go comp.Run(ctx)
+		return comp, ch
+	}
+
+	expectLine := func(t *testing.T, ch loki.LogsReceiver, line string) {
+		select {
+		case logEntry := <-ch.Chan():
+			require.Equal(t, line, logEntry.Line)
+		case <-time.After(5 * time.Second):
+			require.FailNow(t, "failed waiting for log line", line)
+		}
+	}
+
+	nodeA.setOwner("a")
+	nodeB.setOwner("a")
+	compA, chA := newComponent(t, nodeA)
+	compB, chB := newComponent(t, nodeB)
+
+	// Only the owner of the file reads it.
+	require.Len(t, compA.DebugInfo().(readerDebugInfo).TargetsInfo, 1)
+	require.Empty(t, compB.DebugInfo().(readerDebugInfo).TargetsInfo)
+	require.Equal(t, map[string]int{"a": 1}, compB.TargetOwners(nodeB))
+
+	_, err = f.Write([]byte("first\n"))
+	require.NoError(t, err)
+	expectLine(t, chA, "first")
+
+	// The new owner resumes from the position of the previous owner.
+	nodeA.setOwner("b")
+	nodeB.setOwner("b")
+	compA.NotifyClusterChange()
+	compB.NotifyClusterChange()
+	require.Empty(t, compA.DebugInfo().(readerDebugInfo).TargetsInfo)
+	require.Len(t, compB.DebugInfo().(readerDebugInfo).TargetsInfo, 1)
+
+	_, err = f.Write([]byte("second\n"))
+	require.NoError(t, err)
+	expectLine(t, chB, "second")
+}
+
+// fakeCluster is a cluster of two nodes, where a single node owns all keys.
+type fakeCluster struct {
+	cluster.KV
+	name string
+
+	mut   sync.Mutex
+	owner string
+}
+
+func (c *fakeCluster) setOwner(owner string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.owner = owner
+}
+
+func (c *fakeCluster) Lookup(key shard.Key, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return []peer.Peer{{Name: c.owner, Self: c.owner == c.name, State: peer.StateParticipant}}, nil
+}
+
+func (c *fakeCluster) Peers() []peer.Peer {
+	return []peer.Peer{
+		{Name: "a", Self: c.name == "a", State: peer.StateParticipant},
+		{Name: "b", Self: c.name == "b", State: peer.StateParticipant},
+	}
+}
@@ -76,9 +76,10 @@ type Component struct {
 }
 
 var (
-	_ component.Component      = (*Component)(nil)
-	_ component.DebugComponent = (*Component)(nil)
-	_ cluster.Component        = (*Component)(nil)
+	_ component.Component       = (*Component)(nil)
+	_ component.DebugComponent  = (*Component)(nil)
+	_ cluster.Component         = (*Component)(nil)
+	_ cluster.OwnershipReporter = (*Component)(nil)
 )
 
 // New creates a new loki.source.kubernetes component.
@@ -209,6 +210,15 @@ func (c *Component) resyncTargets(targets []discovery.Target) {
 	_ = c.tailer.SyncTargets(context.Background(), tailTargets)
 }
 
+// TargetOwners implements cluster.OwnershipReporter.
+func (c *Component) TargetOwners(cl cluster.Cluster) map[string]int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	distTargets := discovery.NewDistributedTargets(c.args.Clustering.Enabled, cl, c.args.Targets)
+	return distTargets.Owners()
+}
+
 // NotifyClusterChange implements cluster.Component.
 func (c *Component) NotifyClusterChange() {
 	c.mut.Lock()
@@ -18,6 +18,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus"
 	otelcomponent "go.opentelemetry.io/collector/component"
 	otelconnector "go.opentelemetry.io/collector/connector"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
 	otelextension "go.opentelemetry.io/collector/extension"
 	sdkprometheus "go.opentelemetry.io/otel/exporters/prometheus"
 	"go.opentelemetry.io/otel/sdk/metric"
@@ -33,6 +34,11 @@ const (
 	ConnectorLogsToTraces
 	ConnectorLogsToMetrics
 	ConnectorLogsToLogs
+
+	// ConnectorRouter is the type of connectors which route each signal to
+	// pipelines of the same signal. Their Arguments must implement
+	// RouterArguments.
+	ConnectorRouter
 )
 
 // Arguments is an extension of component.Arguments which contains necessary
@@ -58,6 +64,17 @@ type Arguments interface {
 	ConnectorType() int
 }
 
+// RouterArguments is an extension of Arguments for connectors which route
+// telemetry between several pipelines, such as otelcol.connector.routing.
+type RouterArguments interface {
+	Arguments
+
+	// Pipelines returns the consumers to send data to for each of the pipelines
+	// referenced by the connector configuration. It is used instead of
+	// NextConsumers.
+	Pipelines() map[otelcomponent.ID]*otelcol.ConsumerArguments
+}
+
 // Connector is a Flow component shim which manages an OpenTelemetry Collector
 // connector component.
 type Connector struct {
@@ -190,6 +207,55 @@ func (p *Connector) Update(args component.Arguments) error {
 				components = append(components, tracesConnector)
 			}
 		}
+	case ConnectorRouter:
+		rargs, ok := pargs.(RouterArguments)
+		if !ok {
+			return errors.New("router connectors must implement RouterArguments")
+		}
+
+		var (
+			traces  = make(map[otelcomponent.ID]otelconsumer.Traces)
+			metrics = make(map[otelcomponent.ID]otelconsumer.Metrics)
+			logs    = make(map[otelcomponent.ID]otelconsumer.Logs)
+
+			hasTraces, hasMetrics, hasLogs bool
+		)
+		for id, next := range rargs.Pipelines() {
+			traces[id] = fanoutconsumer.Traces(next.Traces)
+			metrics[id] = fanoutconsumer.Metrics(next.Metrics)
+			logs[id] = fanoutconsumer.Logs(next.Logs)
+
+			hasTraces = hasTraces || len(next.Traces) > 0
+			hasMetrics = hasMetrics || len(next.Metrics) > 0
+			hasLogs = hasLogs || len(next.Logs) > 0
+		}
+
+		// Only create the connectors for the signals which are sent to at least
+		// one pipeline.
+		if hasTraces {
+			tracesConnector, err = p.factory.CreateTracesToTraces(p.ctx, settings, connectorConfig, otelconnector.NewTracesRouter(traces))
+			if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
+				return err
+			} else if tracesConnector != nil {
+				components = append(components, tracesConnector)
+			}
+		}
+		if hasMetrics {
+			metricsConnector, err = p.factory.CreateMetricsToMetrics(p.ctx, settings, connectorConfig, otelconnector.NewMetricsRouter(metrics))
+			if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
+				return err
+			} else if metricsConnector != nil {
+				components = append(components, metricsConnector)
+			}
+		}
+		if hasLogs {
+			logsConnector, err = p.factory.CreateLogsToLogs(p.ctx, settings, connectorConfig, otelconnector.NewLogsRouter(logs))
+			if err != nil && !errors.Is(err, otelcomponent.ErrDataTypeIsNotSupported) {
+				return err
+			} else if logsConnector != nil {
+				components = append(components, logsConnector)
+			}
+		}
 	default:
 		return errors.New("unsupported connector type")
 	}
@@ -0,0 +1,160 @@
+// Package routing provides an otelcol.connector.routing component.
+package routing
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/otelcol"
+	"github.com/grafana/agent/internal/component/otelcol/connector"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/river"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/routingconnector"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.connector.routing",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			fact := routingconnector.NewFactory()
+			return connector.New(opts, fact, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.connector.routing component.
+type Arguments struct {
+	// ErrorMode determines how the connector reacts to errors that occur while
+	// evaluating a condition.
+	ErrorMode ottl.ErrorMode `river:"error_mode,attr,optional"`
+
+	// MatchOnce determines whether data is only sent to the first matching
+	// route, instead of to all the matching routes.
+	MatchOnce bool `river:"match_once,attr,optional"`
+
+	// Routes is the routing table. Required.
+	Routes []Route `river:"route,block"`
+
+	// DefaultOutput configures where to send data which doesn't match any
+	// route. Data which doesn't match any route is dropped if DefaultOutput
+	// isn't set.
+	DefaultOutput *otelcol.ConsumerArguments `river:"default_output,block,optional"`
+}
+
+// Route is an entry of the routing table.
+type Route struct {
+	// Condition is the OTTL condition which resources must match to be sent to
+	// the route.
+	Condition string `river:"condition,attr"`
+
+	// Output configures where to send the data matching the route. Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+}
+
+var (
+	_ connector.RouterArguments = Arguments{}
+	_ river.Validator           = (*Arguments)(nil)
+	_ river.Defaulter           = (*Arguments)(nil)
+)
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	ErrorMode: ottl.PropagateError,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if len(args.Routes) == 0 {
+		return fmt.Errorf("at least one route must be configured")
+	}
+
+	conditions := make(map[string]struct{}, len(args.Routes))
+	for i, route := range args.Routes {
+		if route.Condition == "" {
+			return fmt.Errorf("route %d: condition must not be empty", i)
+		}
+		// Routes with the same condition are merged by the connector, so the
+		// outputs of all but one of them would be ignored.
+		if _, ok := conditions[route.Condition]; ok {
+			return fmt.Errorf("route %d: condition %q is used by another route", i, route.Condition)
+		}
+		conditions[route.Condition] = struct{}{}
+	}
+	return nil
+}
+
+// pipelineType is the type of the IDs of the pipelines the routes send data
+// to. These pipelines only exist within the component.
+var pipelineType = otelcomponent.MustNewType("routing")
+
+// defaultPipelineID is the ID of the pipeline of the default output.
+var defaultPipelineID = otelcomponent.NewIDWithName(pipelineType, "default")
+
+// routePipelineID returns the ID of the pipeline of the route at index i.
+func routePipelineID(i int) otelcomponent.ID {
+	return otelcomponent.NewIDWithName(pipelineType, "route_"+strconv.Itoa(i))
+}
+
+// Convert implements connector.Arguments.
+func (args Arguments) Convert() (otelcomponent.Config, error) {
+	cfg := &routingconnector.Config{
+		ErrorMode: args.ErrorMode,
+		MatchOnce: args.MatchOnce,
+	}
+	if args.DefaultOutput != nil {
+		cfg.DefaultPipelines = []otelcomponent.ID{defaultPipelineID}
+	}
+	for i, route := range args.Routes {
+		cfg.Table = append(cfg.Table, routingconnector.RoutingTableItem{
+			Statement: "route() where " + route.Condition,
+			Pipelines: []otelcomponent.ID{routePipelineID(i)},
+		})
+	}
+	return cfg, nil
+}
+
+// Extensions implements connector.Arguments.
+func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
+	return nil
+}
+
+// Exporters implements connector.Arguments.
+func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.ID]otelcomponent.Component {
+	return nil
+}
+
+// NextConsumers implements connector.Arguments. Data is sent to the outputs
+// of Pipelines instead.
+func (args Arguments) NextConsumers() *otelcol.ConsumerArguments {
+	return nil
+}
+
+// Pipelines implements connector.RouterArguments.
+func (args Arguments) Pipelines() map[otelcomponent.ID]*otelcol.ConsumerArguments {
+	pipelines := make(map[otelcomponent.ID]*otelcol.ConsumerArguments, len(args.Routes)+1)
+	if args.DefaultOutput != nil {
+		pipelines[defaultPipelineID] = args.DefaultOutput
+	}
+	for i, route := range args.Routes {
+		pipelines[routePipelineID(i)] = route.Output
+	}
+	return pipelines
+}
+
+// ConnectorType() int implements connector.Arguments.
+func (Arguments) ConnectorType() int {
+	return connector.ConnectorRouter
+}
@@ -0,0 +1,243 @@
+package routing_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component/otelcol"
+	"github.com/grafana/agent/internal/component/otelcol/connector/routing"
+	"github.com/grafana/agent/internal/component/otelcol/internal/fakeconsumer"
+	"github.com/grafana/agent/internal/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/routingconnector"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/pkg/ottl"
+	"github.com/stretchr/testify/require"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+func TestArguments_UnmarshalRiver(t *testing.T) {
+	tests := []struct {
+		testName string
+		cfg      string
+		expected routingconnector.Config
+		errorMsg string
+	}{
+		{
+			testName: "Defaults",
+			cfg: `
+				route {
+					condition = "attributes[\"tenant\"] == \"acme\""
+					output {}
+				}
+			`,
+			expected: routingconnector.Config{
+				ErrorMode: ottl.PropagateError,
+				Table: []routingconnector.RoutingTableItem{{
+					Statement: `route() where attributes["tenant"] == "acme"`,
+					Pipelines: []otelcomponent.ID{otelcomponent.MustNewIDWithName("routing", "route_0")},
+				}},
+			},
+		},
+		{
+			testName: "DefaultOutput",
+			cfg: `
+				error_mode = "ignore"
+				match_once = true
+
+				route {
+					condition = "attributes[\"tenant\"] == \"acme\""
+					output {}
+				}
+				route {
+					condition = "attributes[\"tenant\"] == \"globex\""
+					output {}
+				}
+				default_output {}
+			`,
+			expected: routingconnector.Config{
+				ErrorMode:        ottl.IgnoreError,
+				MatchOnce:        true,
+				DefaultPipelines: []otelcomponent.ID{otelcomponent.MustNewIDWithName("routing", "default")},
+				Table: []routingconnector.RoutingTableItem{{
+					Statement: `route() where attributes["tenant"] == "acme"`,
+					Pipelines: []otelcomponent.ID{otelcomponent.MustNewIDWithName("routing", "route_0")},
+				}, {
+					Statement: `route() where attributes["tenant"] == "globex"`,
+					Pipelines: []otelcomponent.ID{otelcomponent.MustNewIDWithName("routing", "route_1")},
+				}},
+			},
+		},
+		{
+			testName: "NoRoutes",
+			cfg: `
+				default_output {}
+			`,
+			errorMsg: "missing required block \"route\"",
+		},
+		{
+			testName: "DuplicateCondition",
+			cfg: `
+				route {
+					condition = "true"
+					output {}
+				}
+				route {
+					condition = "true"
+					output {}
+				}
+			`,
+			errorMsg: "route 1: condition \"true\" is used by another route",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			var args routing.Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.errorMsg != "" {
+				require.ErrorContains(t, err, tc.errorMsg)
+				return
+			}
+			require.NoError(t, err)
+
+			actualPtr, err := args.Convert()
+			require.NoError(t, err)
+
+			actual := actualPtr.(*routingconnector.Config)
+			require.NoError(t, actual.Validate())
+			require.Equal(t, tc.expected, *actual)
+		})
+	}
+}
+
+func Test_ComponentIO(t *testing.T) {
+	const inputTraces = `{
+		"resourceSpans": [{
+			"resource": {
+				"attributes": [{
+					"key": "tenant",
+					"value": { "stringValue": "acme" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+					"span_id": "086e83747d0e381e",
+					"name": "AcmeSpan"
+				}]
+			}]
+		},{
+			"resource": {
+				"attributes": [{
+					"key": "tenant",
+					"value": { "stringValue": "globex" }
+				}]
+			},
+			"scopeSpans": [{
+				"spans": [{
+					"trace_id": "7bba9f33312b3dbb8b2c2c62bb7abe2d",
+					"span_id": "086e83747d0e381b",
+					"name": "GlobexSpan"
+				}]
+			}]
+		}]
+	}`
+
+	const inputLogs = `{
+		"resourceLogs": [{
+			"resource": {
+				"attributes": [{
+					"key": "tenant",
+					"value": { "stringValue": "acme" }
+				}]
+			},
+			"scopeLogs": [{
+				"logRecords": [{
+					"body": { "stringValue": "acme log" }
+				}]
+			}]
+		}]
+	}`
+
+	ctx := componenttest.TestContext(t)
+	l := util.TestLogger(t)
+
+	ctrl, err := componenttest.NewControllerFromID(l, "otelcol.connector.routing")
+	require.NoError(t, err)
+
+	var args routing.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		route {
+			condition = "attributes[\"tenant\"] == \"acme\""
+			output {
+				// no-op: will be overridden by test code.
+			}
+		}
+		default_output {
+			// no-op: will be overridden by test code.
+		}
+	`), &args))
+
+	var (
+		acmeTraces    = make(chan ptrace.Traces, 1)
+		acmeLogs      = make(chan plog.Logs, 1)
+		defaultTraces = make(chan ptrace.Traces, 1)
+	)
+	args.Routes[0].Output = &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+				acmeTraces <- td
+				return nil
+			},
+		}},
+		Logs: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeLogsFunc: func(_ context.Context, ld plog.Logs) error {
+				acmeLogs <- ld
+				return nil
+			},
+		}},
+	}
+	args.DefaultOutput = &otelcol.ConsumerArguments{
+		Traces: []otelcol.Consumer{&fakeconsumer.Consumer{
+			ConsumeTracesFunc: func(_ context.Context, td ptrace.Traces) error {
+				defaultTraces <- td
+				return nil
+			},
+		}},
+	}
+
+	go func() {
+		require.NoError(t, ctrl.Run(ctx, args))
+	}()
+	require.NoError(t, ctrl.WaitRunning(time.Second), "component never started")
+	require.NoError(t, ctrl.WaitExports(time.Second), "component never exported anything")
+
+	exports := ctrl.Exports().(otelcol.ConsumerExports)
+	require.NoError(t, exports.Input.ConsumeTraces(ctx, processortest.CreateTestTraces(inputTraces)))
+	require.NoError(t, exports.Input.ConsumeLogs(ctx, processortest.CreateTestLogs(inputLogs)))
+
+	spanName := func(ch chan ptrace.Traces) string {
+		select {
+		case <-time.After(time.Second):
+			require.FailNow(t, "failed waiting for traces")
+			return ""
+		case td := <-ch:
+			require.Equal(t, 1, td.SpanCount())
+			return td.ResourceSpans().At(0).ScopeSpans().At(0).Spans().At(0).Name()
+		}
+	}
+	require.Equal(t, "AcmeSpan", spanName(acmeTraces))
+	require.Equal(t, "GlobexSpan", spanName(defaultTraces))
+
+	select {
+	case <-time.After(time.Second):
+		require.FailNow(t, "failed waiting for logs")
+	case ld := <-acmeLogs:
+		require.Equal(t, 1, ld.LogRecordCount())
+	}
+}
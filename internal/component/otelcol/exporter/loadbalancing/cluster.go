@@ -0,0 +1,446 @@
+package loadbalancing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"net"
+	"strconv"
+	"sync"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/otelcol/exporter"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/cluster"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	otelexporter "go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+)
+
+// Component is the otelcol.exporter.loadbalancing component. With the cluster
+// resolver, data is routed to the peers of the cluster with the hash ring of
+// the cluster, and the exporters of the peers are kept when the peers change.
+type Component struct {
+	*exporter.Exporter
+
+	opts    component.Options
+	cluster cluster.Cluster
+
+	// otlp creates the exporters sending data to the peers of the cluster.
+	otlp otelexporter.Factory
+
+	mut       sync.Mutex
+	exporters map[*clusterExporter]struct{} // Running exporters of the cluster resolver.
+}
+
+var _ cluster.Component = (*Component)(nil)
+
+func newComponent(opts component.Options, fact otelexporter.Factory, args Arguments) (*Component, error) {
+	c := &Component{
+		opts:      opts,
+		otlp:      otlpexporter.NewFactory(),
+		exporters: make(map[*clusterExporter]struct{}),
+	}
+
+	// The cluster service is only required by the cluster resolver.
+	if data, err := opts.GetServiceData(cluster.ServiceName); err == nil {
+		cl, ok := data.(cluster.Cluster)
+		if !ok {
+			return nil, fmt.Errorf("unexpected data type %T for the cluster service", data)
+		}
+		c.cluster = cl
+	}
+	if err := c.checkCluster(args); err != nil {
+		return nil, err
+	}
+
+	//TODO(ptodev): LB exporter cannot yet work with metrics due to a limitation in the Agent:
+	// https://github.com/grafana/agent/pull/5684
+	// Once the limitation is removed, we may be able to remove the need for exporter.TypeSignal altogether.
+	var err error
+	c.Exporter, err = exporter.New(opts, c.factory(fact), args, exporter.TypeLogs|exporter.TypeTraces)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	if err := c.checkCluster(args.(Arguments)); err != nil {
+		return err
+	}
+	return c.Exporter.Update(args)
+}
+
+func (c *Component) checkCluster(args Arguments) error {
+	if args.Resolver.Cluster != nil && c.cluster == nil {
+		return fmt.Errorf("the cluster resolver is used, but the cluster service isn't available")
+	}
+	return nil
+}
+
+// NotifyClusterChange implements cluster.Component. Data is routed with the
+// current peers of the cluster, so only the exporters of the peers which
+// stopped participating in the cluster need to be shut down.
+func (c *Component) NotifyClusterChange() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	for e := range c.exporters {
+		e.removeStale(c.cluster.Peers())
+	}
+}
+
+// factory returns a factory which creates the exporters of fact, or exporters
+// routing data to the peers of the cluster for the cluster resolver.
+func (c *Component) factory(fact otelexporter.Factory) otelexporter.Factory {
+	return otelexporter.NewFactory(
+		fact.Type(),
+		fact.CreateDefaultConfig,
+		otelexporter.WithTraces(func(ctx context.Context, set otelexporter.CreateSettings, cfg otelcomponent.Config) (otelexporter.Traces, error) {
+			if cfg, ok := cfg.(*clusterConfig); ok {
+				return c.newClusterExporter(set, cfg, otelcomponent.DataTypeTraces), nil
+			}
+			return fact.CreateTracesExporter(ctx, set, cfg)
+		}, fact.TracesExporterStability()),
+		otelexporter.WithLogs(func(ctx context.Context, set otelexporter.CreateSettings, cfg otelcomponent.Config) (otelexporter.Logs, error) {
+			if cfg, ok := cfg.(*clusterConfig); ok {
+				return c.newClusterExporter(set, cfg, otelcomponent.DataTypeLogs), nil
+			}
+			return fact.CreateLogsExporter(ctx, set, cfg)
+		}, fact.LogsExporterStability()),
+	)
+}
+
+// clusterConfig is the configuration of the exporters of the cluster
+// resolver.
+type clusterConfig struct {
+	Protocol   loadbalancingexporter.Protocol
+	RoutingKey string
+	Port       string
+}
+
+// errNoClusterEndpoint is returned when no peer of the cluster can receive
+// data, for example because the local node hasn't joined the cluster yet.
+var errNoClusterEndpoint = errors.New("no participant node of the cluster can receive data")
+
+// clusterExporter routes data to the peers of the cluster which own its
+// routing key, through an OTLP exporter per peer. The exporters of peers are
+// created when they first receive data, and are shut down when the peers stop
+// participating in the cluster.
+type clusterExporter struct {
+	c        *Component
+	set      otelexporter.CreateSettings
+	cfg      *clusterConfig
+	dataType otelcomponent.DataType
+
+	mut       sync.Mutex
+	host      otelcomponent.Host
+	endpoints map[string]otelcomponent.Component // Exporters by endpoint.
+}
+
+var (
+	_ otelexporter.Traces = (*clusterExporter)(nil)
+	_ otelexporter.Logs   = (*clusterExporter)(nil)
+)
+
+func (c *Component) newClusterExporter(set otelexporter.CreateSettings, cfg *clusterConfig, dataType otelcomponent.DataType) *clusterExporter {
+	return &clusterExporter{
+		c:         c,
+		set:       set,
+		cfg:       cfg,
+		dataType:  dataType,
+		endpoints: make(map[string]otelcomponent.Component),
+	}
+}
+
+// Start implements otelcomponent.Component.
+func (e *clusterExporter) Start(_ context.Context, host otelcomponent.Host) error {
+	e.mut.Lock()
+	e.host = host
+	e.mut.Unlock()
+
+	e.c.mut.Lock()
+	defer e.c.mut.Unlock()
+	e.c.exporters[e] = struct{}{}
+	return nil
+}
+
+// Shutdown implements otelcomponent.Component.
+func (e *clusterExporter) Shutdown(ctx context.Context) error {
+	e.c.mut.Lock()
+	delete(e.c.exporters, e)
+	e.c.mut.Unlock()
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	var errs error
+	for endpoint, exp := range e.endpoints {
+		errs = errors.Join(errs, exp.Shutdown(ctx))
+		delete(e.endpoints, endpoint)
+	}
+	return errs
+}
+
+// Capabilities implements otelconsumer.Traces and otelconsumer.Logs.
+func (e *clusterExporter) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{MutatesData: false}
+}
+
+// ConsumeTraces implements otelconsumer.Traces.
+func (e *clusterExporter) ConsumeTraces(ctx context.Context, td ptrace.Traces) error {
+	batches, err := splitTraces(td, e.cfg.RoutingKey, e.endpoint)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for endpoint, batch := range batches {
+		exp, err := e.exporter(endpoint)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		errs = errors.Join(errs, exp.(otelconsumer.Traces).ConsumeTraces(ctx, batch))
+	}
+	return errs
+}
+
+// ConsumeLogs implements otelconsumer.Logs.
+func (e *clusterExporter) ConsumeLogs(ctx context.Context, ld plog.Logs) error {
+	batches, err := splitLogs(ld, e.endpoint)
+	if err != nil {
+		return err
+	}
+
+	var errs error
+	for endpoint, batch := range batches {
+		exp, err := e.exporter(endpoint)
+		if err != nil {
+			errs = errors.Join(errs, err)
+			continue
+		}
+		errs = errors.Join(errs, exp.(otelconsumer.Logs).ConsumeLogs(ctx, batch))
+	}
+	return errs
+}
+
+// endpoint returns the endpoint of the peer which owns key.
+func (e *clusterExporter) endpoint(key string) (string, error) {
+	peers, err := e.c.cluster.Lookup(shard.StringKey(key), 1, shard.OpReadWrite)
+	if err != nil {
+		return "", fmt.Errorf("%w: %w", errNoClusterEndpoint, err)
+	}
+	if len(peers) == 0 {
+		return "", errNoClusterEndpoint
+	}
+	return peerEndpoint(peers[0], e.cfg.Port), nil
+}
+
+// exporter returns the exporter sending data to endpoint, and creates it if
+// needed.
+func (e *clusterExporter) exporter(endpoint string) (otelcomponent.Component, error) {
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	if exp, ok := e.endpoints[endpoint]; ok {
+		return exp, nil
+	}
+
+	cfg := e.cfg.Protocol.OTLP
+	cfg.Endpoint = endpoint
+
+	var (
+		exp otelcomponent.Component
+		err error
+	)
+	switch e.dataType {
+	case otelcomponent.DataTypeTraces:
+		exp, err = e.c.otlp.CreateTracesExporter(context.Background(), e.set, &cfg)
+	case otelcomponent.DataTypeLogs:
+		exp, err = e.c.otlp.CreateLogsExporter(context.Background(), e.set, &cfg)
+	default:
+		err = fmt.Errorf("unsupported data type %s", e.dataType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("creating exporter for %s: %w", endpoint, err)
+	}
+	if err := exp.Start(context.Background(), e.host); err != nil {
+		return nil, fmt.Errorf("starting exporter for %s: %w", endpoint, err)
+	}
+
+	e.endpoints[endpoint] = exp
+	return exp, nil
+}
+
+// removeStale shuts down the exporters of the endpoints which don't belong to
+// a participant peer anymore. Data which is still queued for them is sent
+// before they shut down.
+func (e *clusterExporter) removeStale(peers []peer.Peer) {
+	active := make(map[string]struct{}, len(peers))
+	for _, p := range peers {
+		if p.State == peer.StateParticipant {
+			active[peerEndpoint(p, e.cfg.Port)] = struct{}{}
+		}
+	}
+
+	e.mut.Lock()
+	defer e.mut.Unlock()
+
+	for endpoint, exp := range e.endpoints {
+		if _, ok := active[endpoint]; ok {
+			continue
+		}
+		delete(e.endpoints, endpoint)
+
+		go func(endpoint string, exp otelcomponent.Component) {
+			if err := exp.Shutdown(context.Background()); err != nil {
+				level.Warn(e.c.opts.Logger).Log("msg", "failed to shut down the exporter of a former peer", "endpoint", endpoint, "err", err)
+			}
+		}(endpoint, exp)
+	}
+}
+
+// peerEndpoint returns the endpoint of a peer, which is the host the peer
+// advertises with the given port.
+func peerEndpoint(p peer.Peer, port string) string {
+	host, _, err := net.SplitHostPort(p.Addr)
+	if err != nil {
+		host = p.Addr
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// splitTraces splits td into batches by the endpoint owning the routing key
+// of their spans, which is either their trace ID or the name of their
+// service. It fails if the endpoint of any span can't be found, so that no
+// data is sent twice when the export is retried.
+func splitTraces(td ptrace.Traces, routingKey string, endpoint func(key string) (string, error)) (map[string]ptrace.Traces, error) {
+	var (
+		batches   = make(map[string]ptrace.Traces)
+		endpoints = make(map[string]string) // Endpoints by key.
+	)
+
+	for i := 0; i < td.ResourceSpans().Len(); i++ {
+		rs := td.ResourceSpans().At(i)
+		var service string
+		if v, ok := rs.Resource().Attributes().Get("service.name"); ok {
+			service = v.AsString()
+		}
+
+		resources := make(map[string]ptrace.ResourceSpans) // Resources by endpoint.
+		for j := 0; j < rs.ScopeSpans().Len(); j++ {
+			ss := rs.ScopeSpans().At(j)
+
+			scopes := make(map[string]ptrace.ScopeSpans) // Scopes by endpoint.
+			for k := 0; k < ss.Spans().Len(); k++ {
+				span := ss.Spans().At(k)
+
+				key := service
+				if routingKey == "traceID" {
+					key = span.TraceID().String()
+				}
+				to, ok := endpoints[key]
+				if !ok {
+					var err error
+					if to, err = endpoint(key); err != nil {
+						return nil, err
+					}
+					endpoints[key] = to
+				}
+
+				scope, ok := scopes[to]
+				if !ok {
+					resource, ok := resources[to]
+					if !ok {
+						batch, ok := batches[to]
+						if !ok {
+							batch = ptrace.NewTraces()
+							batches[to] = batch
+						}
+						resource = batch.ResourceSpans().AppendEmpty()
+						rs.Resource().CopyTo(resource.Resource())
+						resource.SetSchemaUrl(rs.SchemaUrl())
+						resources[to] = resource
+					}
+					scope = resource.ScopeSpans().AppendEmpty()
+					ss.Scope().CopyTo(scope.Scope())
+					scope.SetSchemaUrl(ss.SchemaUrl())
+					scopes[to] = scope
+				}
+				span.CopyTo(scope.Spans().AppendEmpty())
+			}
+		}
+	}
+	return batches, nil
+}
+
+// splitLogs splits ld into batches by the endpoint owning the trace ID of
+// their log records. Log records without a trace ID are sent to a random
+// endpoint. Like splitTraces, it fails if the endpoint of any log record
+// can't be found.
+func splitLogs(ld plog.Logs, endpoint func(key string) (string, error)) (map[string]plog.Logs, error) {
+	var (
+		batches   = make(map[string]plog.Logs)
+		endpoints = make(map[string]string) // Endpoints by key.
+		randomKey = strconv.FormatUint(rand.Uint64(), 16)
+	)
+
+	for i := 0; i < ld.ResourceLogs().Len(); i++ {
+		rl := ld.ResourceLogs().At(i)
+
+		resources := make(map[string]plog.ResourceLogs) // Resources by endpoint.
+		for j := 0; j < rl.ScopeLogs().Len(); j++ {
+			sl := rl.ScopeLogs().At(j)
+
+			scopes := make(map[string]plog.ScopeLogs) // Scopes by endpoint.
+			for k := 0; k < sl.LogRecords().Len(); k++ {
+				record := sl.LogRecords().At(k)
+
+				key := randomKey
+				if !record.TraceID().IsEmpty() {
+					key = record.TraceID().String()
+				}
+				to, ok := endpoints[key]
+				if !ok {
+					var err error
+					if to, err = endpoint(key); err != nil {
+						return nil, err
+					}
+					endpoints[key] = to
+				}
+
+				scope, ok := scopes[to]
+				if !ok {
+					resource, ok := resources[to]
+					if !ok {
+						batch, ok := batches[to]
+						if !ok {
+							batch = plog.NewLogs()
+							batches[to] = batch
+						}
+						resource = batch.ResourceLogs().AppendEmpty()
+						rl.Resource().CopyTo(resource.Resource())
+						resource.SetSchemaUrl(rl.SchemaUrl())
+						resources[to] = resource
+					}
+					scope = resource.ScopeLogs().AppendEmpty()
+					sl.Scope().CopyTo(scope.Scope())
+					scope.SetSchemaUrl(sl.SchemaUrl())
+					scopes[to] = scope
+				}
+				record.CopyTo(scope.LogRecords().AppendEmpty())
+			}
+		}
+	}
+	return batches, nil
+}
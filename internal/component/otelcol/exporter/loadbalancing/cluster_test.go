@@ -0,0 +1,327 @@
+package loadbalancing
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/service/cluster"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/grafana/river"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/exporter/loadbalancingexporter"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/component/componenttest"
+	otelconsumer "go.opentelemetry.io/collector/consumer"
+	otelexporter "go.opentelemetry.io/collector/exporter"
+	"go.opentelemetry.io/collector/exporter/otlpexporter"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/plog"
+	"go.opentelemetry.io/collector/pdata/ptrace"
+	"go.opentelemetry.io/otel/trace/noop"
+)
+
+func TestClusterResolver(t *testing.T) {
+	cfg := `
+		resolver {
+			cluster {
+				port = "4318"
+			}
+		}
+		protocol {
+			otlp {
+				client {}
+			}
+		}
+	`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+
+	fc := newFakeCluster(
+		peer.Peer{Name: "b", Addr: "10.0.0.2:12345", State: peer.StateParticipant},
+		peer.Peer{Name: "a", Addr: "10.0.0.1:12345", Self: true, State: peer.StateParticipant},
+		peer.Peer{Name: "c", Addr: "10.0.0.3:12345", State: peer.StateViewer},
+	)
+
+	c, err := newComponent(component.Options{
+		ID:             "otelcol.exporter.loadbalancing.test",
+		Logger:         util.TestFlowLogger(t),
+		Registerer:     prometheus.NewRegistry(),
+		Tracer:         noop.NewTracerProvider(),
+		OnStateChange:  func(component.Exports) {},
+		GetServiceData: func(name string) (interface{}, error) { return fc, nil },
+	}, loadbalancingexporter.NewFactory(), args)
+	require.NoError(t, err)
+
+	endpoints := newFakeEndpoints()
+	c.otlp = endpoints.factory()
+
+	exporterCfg, err := args.Convert()
+	require.NoError(t, err)
+	e := c.newClusterExporter(otelexporter.CreateSettings{}, exporterCfg.(*clusterConfig), otelcomponent.DataTypeTraces)
+	require.NoError(t, e.Start(context.Background(), componenttest.NewNopHost()))
+	defer e.Shutdown(context.Background())
+
+	td := ptrace.NewTraces()
+	spans := td.ResourceSpans().AppendEmpty().ScopeSpans().AppendEmpty().Spans()
+	for i := 0; i < 50; i++ {
+		spans.AppendEmpty().SetTraceID(pcommon.TraceID{byte(i), 1})
+	}
+	require.NoError(t, e.ConsumeTraces(context.Background(), td))
+
+	// Spans are routed to the participant peer owning their trace ID in the
+	// hash ring of the cluster. Viewers don't receive data.
+	var received int
+	for endpoint, traceIDs := range endpoints.traces() {
+		require.Contains(t, []string{"10.0.0.1:4318", "10.0.0.2:4318"}, endpoint)
+		for _, traceID := range traceIDs {
+			owners, err := fc.Lookup(shard.StringKey(traceID), 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.Equal(t, endpoint, peerEndpoint(owners[0], "4318"))
+		}
+		received += len(traceIDs)
+	}
+	require.Equal(t, 50, received)
+	require.Len(t, endpoints.traces(), 2)
+
+	// When a peer leaves, only its exporter is shut down, and the data it owned
+	// is routed to the remaining peers.
+	fc.setPeers(
+		peer.Peer{Name: "a", Addr: "10.0.0.1:12345", Self: true, State: peer.StateParticipant},
+		peer.Peer{Name: "c", Addr: "10.0.0.3:12345", State: peer.StateViewer},
+	)
+	c.NotifyClusterChange()
+	require.Eventually(t, func() bool { return endpoints.isShutdown("10.0.0.2:4318") }, 5*time.Second, 10*time.Millisecond)
+	require.False(t, endpoints.isShutdown("10.0.0.1:4318"))
+
+	endpoints.reset()
+	require.NoError(t, e.ConsumeTraces(context.Background(), td))
+	require.Len(t, endpoints.traces()["10.0.0.1:4318"], 50)
+	require.Equal(t, 1, endpoints.created("10.0.0.1:4318"))
+
+	// Without participants, data isn't sent to any endpoint.
+	fc.setPeers()
+	endpoints.reset()
+	require.ErrorIs(t, e.ConsumeTraces(context.Background(), td), errNoClusterEndpoint)
+	require.Empty(t, endpoints.traces())
+}
+
+func TestClusterResolver_Logs(t *testing.T) {
+	fc := newFakeCluster(
+		peer.Peer{Name: "a", Addr: "10.0.0.1:12345", Self: true, State: peer.StateParticipant},
+		peer.Peer{Name: "b", Addr: "10.0.0.2:12345", State: peer.StateParticipant},
+	)
+	c := &Component{cluster: fc, exporters: make(map[*clusterExporter]struct{})}
+	endpoints := newFakeEndpoints()
+	c.otlp = endpoints.factory()
+
+	e := c.newClusterExporter(otelexporter.CreateSettings{}, &clusterConfig{Port: "4317"}, otelcomponent.DataTypeLogs)
+	require.NoError(t, e.Start(context.Background(), componenttest.NewNopHost()))
+	defer e.Shutdown(context.Background())
+
+	ld := plog.NewLogs()
+	records := ld.ResourceLogs().AppendEmpty().ScopeLogs().AppendEmpty().LogRecords()
+	traceID := pcommon.TraceID{1, 2, 3}
+	records.AppendEmpty().SetTraceID(traceID)
+	records.AppendEmpty().SetTraceID(traceID)
+	records.AppendEmpty() // Without trace ID.
+	require.NoError(t, e.ConsumeLogs(context.Background(), ld))
+
+	owners, err := fc.Lookup(shard.StringKey(traceID.String()), 1, shard.OpReadWrite)
+	require.NoError(t, err)
+	owner := peerEndpoint(owners[0], "4317")
+
+	var received int
+	for endpoint, n := range endpoints.logs() {
+		received += n
+		if endpoint != owner {
+			require.Equal(t, 1, n)
+		}
+	}
+	require.Equal(t, 3, received)
+	require.GreaterOrEqual(t, endpoints.logs()[owner], 2)
+}
+
+func TestClusterResolverValidation(t *testing.T) {
+	cfg := `
+		resolver {
+			cluster {}
+			static {
+				hostnames = ["endpoint-1"]
+			}
+		}
+		protocol {
+			otlp {
+				client {}
+			}
+		}
+	`
+	var args Arguments
+	require.EqualError(t, river.Unmarshal([]byte(cfg), &args), "the cluster resolver can't be used with other resolvers")
+
+	cfg = `
+		resolver {
+			cluster {}
+		}
+		protocol {
+			otlp {
+				client {}
+			}
+		}
+	`
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	require.Equal(t, "4317", args.Resolver.Cluster.Port)
+}
+
+// fakeCluster routes keys with a hash ring of its peers.
+type fakeCluster struct {
+	cluster.Cluster
+
+	mut     sync.Mutex
+	peers   []peer.Peer
+	sharder shard.Sharder
+}
+
+func newFakeCluster(peers ...peer.Peer) *fakeCluster {
+	fc := &fakeCluster{Cluster: cluster.Mock(), sharder: shard.Ring(512)}
+	fc.setPeers(peers...)
+	return fc
+}
+
+func (fc *fakeCluster) setPeers(peers ...peer.Peer) {
+	fc.mut.Lock()
+	defer fc.mut.Unlock()
+	fc.peers = peers
+	// The ring sorts the peers it's given.
+	fc.sharder.SetPeers(slices.Clone(peers))
+}
+
+func (fc *fakeCluster) Peers() []peer.Peer {
+	fc.mut.Lock()
+	defer fc.mut.Unlock()
+	return fc.peers
+}
+
+func (fc *fakeCluster) Lookup(key shard.Key, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
+	return fc.sharder.Lookup(key, replicationFactor, op)
+}
+
+// fakeEndpoints records the data sent to the exporters of endpoints.
+type fakeEndpoints struct {
+	mut       sync.Mutex
+	traceIDs  map[string][]string // Trace IDs of spans by endpoint.
+	logCounts map[string]int      // Number of log records by endpoint.
+	creates   map[string]int      // Number of exporters created by endpoint.
+	shutdown  map[string]bool
+}
+
+func newFakeEndpoints() *fakeEndpoints {
+	fe := &fakeEndpoints{creates: make(map[string]int), shutdown: make(map[string]bool)}
+	fe.reset()
+	return fe
+}
+
+func (fe *fakeEndpoints) reset() {
+	fe.mut.Lock()
+	defer fe.mut.Unlock()
+	fe.traceIDs = make(map[string][]string)
+	fe.logCounts = make(map[string]int)
+}
+
+func (fe *fakeEndpoints) traces() map[string][]string {
+	fe.mut.Lock()
+	defer fe.mut.Unlock()
+	return maps.Clone(fe.traceIDs)
+}
+
+func (fe *fakeEndpoints) logs() map[string]int {
+	fe.mut.Lock()
+	defer fe.mut.Unlock()
+	return maps.Clone(fe.logCounts)
+}
+
+func (fe *fakeEndpoints) created(endpoint string) int {
+	fe.mut.Lock()
+	defer fe.mut.Unlock()
+	return fe.creates[endpoint]
+}
+
+func (fe *fakeEndpoints) isShutdown(endpoint string) bool {
+	fe.mut.Lock()
+	defer fe.mut.Unlock()
+	return fe.shutdown[endpoint]
+}
+
+// factory returns an OTLP exporter factory creating exporters which record
+// the data sent to their endpoint.
+func (fe *fakeEndpoints) factory() otelexporter.Factory {
+	create := func(cfg otelcomponent.Config) *fakeEndpoint {
+		endpoint := cfg.(*otlpexporter.Config).Endpoint
+		fe.mut.Lock()
+		defer fe.mut.Unlock()
+		fe.creates[endpoint]++
+		fe.shutdown[endpoint] = false
+		return &fakeEndpoint{endpoints: fe, endpoint: endpoint}
+	}
+
+	fact := otlpexporter.NewFactory()
+	return otelexporter.NewFactory(
+		fact.Type(),
+		fact.CreateDefaultConfig,
+		otelexporter.WithTraces(func(_ context.Context, _ otelexporter.CreateSettings, cfg otelcomponent.Config) (otelexporter.Traces, error) {
+			return create(cfg), nil
+		}, otelcomponent.StabilityLevelUndefined),
+		otelexporter.WithLogs(func(_ context.Context, _ otelexporter.CreateSettings, cfg otelcomponent.Config) (otelexporter.Logs, error) {
+			return create(cfg), nil
+		}, otelcomponent.StabilityLevelUndefined),
+	)
+}
+
+type fakeEndpoint struct {
+	endpoints *fakeEndpoints
+	endpoint  string
+}
+
+func (fe *fakeEndpoint) Start(context.Context, otelcomponent.Host) error { return nil }
+
+func (fe *fakeEndpoint) Shutdown(context.Context) error {
+	fe.endpoints.mut.Lock()
+	defer fe.endpoints.mut.Unlock()
+	fe.endpoints.shutdown[fe.endpoint] = true
+	return nil
+}
+
+func (fe *fakeEndpoint) Capabilities() otelconsumer.Capabilities {
+	return otelconsumer.Capabilities{}
+}
+
+func (fe *fakeEndpoint) ConsumeTraces(_ context.Context, td ptrace.Traces) error {
+	fe.endpoints.mut.Lock()
+	defer fe.endpoints.mut.Unlock()
+
+	rss := td.ResourceSpans()
+	for i := 0; i < rss.Len(); i++ {
+		sss := rss.At(i).ScopeSpans()
+		for j := 0; j < sss.Len(); j++ {
+			spans := sss.At(j).Spans()
+			for k := 0; k < spans.Len(); k++ {
+				fe.endpoints.traceIDs[fe.endpoint] = append(fe.endpoints.traceIDs[fe.endpoint], spans.At(k).TraceID().String())
+			}
+		}
+	}
+	return nil
+}
+
+func (fe *fakeEndpoint) ConsumeLogs(_ context.Context, ld plog.Logs) error {
+	fe.endpoints.mut.Lock()
+	defer fe.endpoints.mut.Unlock()
+	fe.endpoints.logCounts[fe.endpoint] += ld.LogRecordCount()
+	return nil
+}
@@ -34,7 +34,7 @@ func init() {
 			//TODO(ptodev): LB exporter cannot yet work with metrics due to a limitation in the Agent:
 			// https://github.com/grafana/agent/pull/5684
 			// Once the limitation is removed, we may be able to remove the need for exporter.TypeSignal altogether.
-			return exporter.New(opts, fact, args.(Arguments), exporter.TypeLogs|exporter.TypeTraces)
+			return newComponent(opts, fact, args.(Arguments))
 		},
 	})
 }
@@ -77,11 +77,22 @@ func (args *Arguments) Validate() error {
 	default:
 		return fmt.Errorf("invalid routing key %q", args.RoutingKey)
 	}
+
+	if args.Resolver.Cluster != nil && (args.Resolver.Static != nil || args.Resolver.DNS != nil || args.Resolver.Kubernetes != nil) {
+		return fmt.Errorf("the cluster resolver can't be used with other resolvers")
+	}
 	return nil
 }
 
 // Convert implements exporter.Arguments.
 func (args Arguments) Convert() (otelcomponent.Config, error) {
+	if args.Resolver.Cluster != nil {
+		return &clusterConfig{
+			Protocol:   args.Protocol.Convert(),
+			RoutingKey: args.RoutingKey,
+			Port:       args.Resolver.Cluster.Port,
+		}, nil
+	}
 	return &loadbalancingexporter.Config{
 		Protocol:   args.Protocol.Convert(),
 		Resolver:   args.Resolver.Convert(),
@@ -135,6 +146,7 @@ type ResolverSettings struct {
 	Static     *StaticResolver     `river:"static,block,optional"`
 	DNS        *DNSResolver        `river:"dns,block,optional"`
 	Kubernetes *KubernetesResolver `river:"kubernetes,block,optional"`
+	Cluster    *ClusterResolver    `river:"cluster,block,optional"`
 }
 
 func (resolverSettings ResolverSettings) Convert() loadbalancingexporter.ResolverSettings {
@@ -223,6 +235,24 @@ func (k8sSvcResolver *KubernetesResolver) Convert() loadbalancingexporter.K8sSvc
 	}
 }
 
+// ClusterResolver defines the configuration for the resolver using the peers
+// of the cluster the agent is part of as backends.
+type ClusterResolver struct {
+	Port string `river:"port,attr,optional"`
+}
+
+var _ river.Defaulter = &ClusterResolver{}
+
+// DefaultClusterResolver holds default values for ClusterResolver.
+var DefaultClusterResolver = ClusterResolver{
+	Port: "4317",
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *ClusterResolver) SetToDefault() {
+	*args = DefaultClusterResolver
+}
+
 // Extensions implements exporter.Arguments.
 func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
 	return args.Protocol.OTLP.Client.Extensions()
@@ -0,0 +1,88 @@
+// Package deltatocumulative provides an otelcol.processor.deltatocumulative
+// component.
+package deltatocumulative
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/otelcol"
+	"github.com/grafana/agent/internal/component/otelcol/processor"
+	"github.com/grafana/agent/internal/component/otelcol/processor/deltatocumulative/internal/deltatocumulativeprocessor"
+	"github.com/grafana/agent/internal/featuregate"
+	otelcomponent "go.opentelemetry.io/collector/component"
+	otelextension "go.opentelemetry.io/collector/extension"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "otelcol.processor.deltatocumulative",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   otelcol.ConsumerExports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			fact := deltatocumulativeprocessor.NewFactory()
+			return processor.New(opts, fact, args.(Arguments))
+		},
+	})
+}
+
+// Arguments configures the otelcol.processor.deltatocumulative component.
+type Arguments struct {
+	MaxStale   time.Duration `river:"max_stale,attr,optional"`
+	MaxStreams int           `river:"max_streams,attr,optional"`
+
+	// Output configures where to send processed data. Required.
+	Output *otelcol.ConsumerArguments `river:"output,block"`
+}
+
+var (
+	_ processor.Arguments = Arguments{}
+)
+
+// DefaultArguments holds default settings for Arguments.
+var DefaultArguments = Arguments{
+	MaxStale:   5 * time.Minute,
+	MaxStreams: 100_000,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.MaxStale <= 0 {
+		return fmt.Errorf("max_stale must be greater than 0")
+	}
+	if args.MaxStreams <= 0 {
+		return fmt.Errorf("max_streams must be greater than 0")
+	}
+	return nil
+}
+
+// Convert implements processor.Arguments.
+func (args Arguments) Convert() (otelcomponent.Config, error) {
+	return &deltatocumulativeprocessor.Config{
+		MaxStale:   args.MaxStale,
+		MaxStreams: args.MaxStreams,
+	}, nil
+}
+
+// Extensions implements processor.Arguments.
+func (args Arguments) Extensions() map[otelcomponent.ID]otelextension.Extension {
+	return nil
+}
+
+// Exporters implements processor.Arguments.
+func (args Arguments) Exporters() map[otelcomponent.DataType]map[otelcomponent.ID]otelcomponent.Component {
+	return nil
+}
+
+// NextConsumers implements processor.Arguments.
+func (args Arguments) NextConsumers() *otelcol.ConsumerArguments {
+	return args.Output
+}
@@ -0,0 +1,144 @@
+package deltatocumulative_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component/otelcol/processor/deltatocumulative"
+	"github.com/grafana/agent/internal/component/otelcol/processor/processortest"
+	"github.com/grafana/agent/internal/flow/componenttest"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/stretchr/testify/require"
+)
+
+func TestArguments_UnmarshalRiver(t *testing.T) {
+	tests := []struct {
+		testName      string
+		cfg           string
+		expected      deltatocumulative.Arguments
+		errorExpected string
+	}{
+		{
+			testName: "Defaults",
+			cfg: `
+			output {}
+			`,
+			expected: deltatocumulative.Arguments{
+				MaxStale:   5 * time.Minute,
+				MaxStreams: 100_000,
+			},
+		},
+		{
+			testName: "Custom",
+			cfg: `
+			max_stale   = "1h"
+			max_streams = 10
+			output {}
+			`,
+			expected: deltatocumulative.Arguments{
+				MaxStale:   time.Hour,
+				MaxStreams: 10,
+			},
+		},
+		{
+			testName: "InvalidMaxStreams",
+			cfg: `
+			max_streams = 0
+			output {}
+			`,
+			errorExpected: "max_streams must be greater than 0",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.testName, func(t *testing.T) {
+			var args deltatocumulative.Arguments
+			err := river.Unmarshal([]byte(tc.cfg), &args)
+			if tc.errorExpected != "" {
+				require.EqualError(t, err, tc.errorExpected)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected.MaxStale, args.MaxStale)
+			require.Equal(t, tc.expected.MaxStreams, args.MaxStreams)
+		})
+	}
+}
+
+func TestMetricProcessing(t *testing.T) {
+	inputMetrics := `{
+		"resourceMetrics": [{
+			"scopeMetrics": [{
+				"metrics": [{
+					"name": "requests",
+					"sum": {
+						"aggregationTemporality": 1,
+						"isMonotonic": true,
+						"dataPoints": [{
+							"startTimeUnixNano": "1000000000",
+							"timeUnixNano": "2000000000",
+							"asInt": "5"
+						}]
+					}
+				}, {
+					"name": "temperature",
+					"gauge": {
+						"dataPoints": [{
+							"timeUnixNano": "2000000000",
+							"asDouble": 21.5
+						}]
+					}
+				}]
+			}]
+		}]
+	}`
+
+	expectedOutputMetrics := `{
+		"resourceMetrics": [{
+			"scopeMetrics": [{
+				"metrics": [{
+					"name": "requests",
+					"sum": {
+						"aggregationTemporality": 2,
+						"isMonotonic": true,
+						"dataPoints": [{
+							"startTimeUnixNano": "1000000000",
+							"timeUnixNano": "2000000000",
+							"asInt": "5"
+						}]
+					}
+				}, {
+					"name": "temperature",
+					"gauge": {
+						"dataPoints": [{
+							"timeUnixNano": "2000000000",
+							"asDouble": 21.5
+						}]
+					}
+				}]
+			}]
+		}]
+	}`
+
+	ctx := componenttest.TestContext(t)
+	l := util.TestLogger(t)
+
+	ctrl, err := componenttest.NewControllerFromID(l, "otelcol.processor.deltatocumulative")
+	require.NoError(t, err)
+
+	var args deltatocumulative.Arguments
+	require.NoError(t, river.Unmarshal([]byte(`output {}`), &args))
+
+	testSignal := processortest.NewMetricSignal(inputMetrics, expectedOutputMetrics)
+	args.Output = testSignal.MakeOutput()
+
+	processortest.TestRunProcessor(processortest.ProcessorRunConfig{
+		Ctx:        ctx,
+		T:          t,
+		Args:       args,
+		TestSignal: testSignal,
+		Ctrl:       ctrl,
+		L:          l,
+	})
+}
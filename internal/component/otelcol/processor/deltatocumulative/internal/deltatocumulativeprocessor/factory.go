@@ -0,0 +1,51 @@
+// Package deltatocumulativeprocessor implements an OpenTelemetry Collector
+// processor which converts metrics with delta temporality into metrics with
+// cumulative temporality.
+package deltatocumulativeprocessor
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	otelprocessor "go.opentelemetry.io/collector/processor"
+)
+
+// TypeStr is the unique identifier for the delta to cumulative processor.
+const TypeStr = "deltatocumulative"
+
+// Config holds the configuration for the delta to cumulative processor.
+type Config struct {
+	// MaxStale is how long a stream is kept after its last data point.
+	MaxStale time.Duration `mapstructure:"max_stale"`
+	// MaxStreams is the maximum number of streams tracked at the same time.
+	// Data points of new streams are dropped once the limit is reached.
+	MaxStreams int `mapstructure:"max_streams"`
+}
+
+// NewFactory returns a new factory for the delta to cumulative processor.
+func NewFactory() otelprocessor.Factory {
+	return otelprocessor.NewFactory(
+		TypeStr,
+		createDefaultConfig,
+		otelprocessor.WithMetrics(createMetricsProcessor, component.StabilityLevelAlpha),
+	)
+}
+
+func createDefaultConfig() component.Config {
+	return &Config{
+		MaxStale:   5 * time.Minute,
+		MaxStreams: 100_000,
+	}
+}
+
+func createMetricsProcessor(
+	_ context.Context,
+	set otelprocessor.CreateSettings,
+	cfg component.Config,
+	nextConsumer consumer.Metrics,
+) (otelprocessor.Metrics, error) {
+
+	return newProcessor(cfg.(*Config), set, nextConsumer)
+}
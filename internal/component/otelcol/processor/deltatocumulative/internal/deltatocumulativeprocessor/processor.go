@@ -0,0 +1,276 @@
+package deltatocumulativeprocessor
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"go.opentelemetry.io/collector/component"
+	"go.opentelemetry.io/collector/consumer"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	otelprocessor "go.opentelemetry.io/collector/processor"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.uber.org/zap"
+)
+
+const meterName = "github.com/grafana/agent/internal/component/otelcol/processor/deltatocumulative"
+
+// Reasons for dropping a data point.
+const (
+	reasonLimit      = "stream_limit"
+	reasonOutOfOrder = "out_of_order"
+)
+
+type processor struct {
+	cfg    *Config
+	next   consumer.Metrics
+	logger *zap.Logger
+	now    func() time.Time
+
+	dropped metric.Int64Counter
+
+	mut     sync.Mutex
+	streams map[uint64]*stream
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+var _ otelprocessor.Metrics = (*processor)(nil)
+
+func newProcessor(cfg *Config, set otelprocessor.CreateSettings, next consumer.Metrics) (*processor, error) {
+	p := &processor{
+		cfg:     cfg,
+		next:    next,
+		logger:  set.Logger,
+		now:     time.Now,
+		streams: make(map[uint64]*stream),
+	}
+
+	meter := set.MeterProvider.Meter(meterName)
+	var err error
+	p.dropped, err = meter.Int64Counter(
+		"deltatocumulative_datapoints_dropped",
+		metric.WithDescription("Number of delta data points which were dropped instead of being converted."),
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = meter.Int64ObservableGauge(
+		"deltatocumulative_streams_tracked",
+		metric.WithDescription("Number of streams whose cumulative state is tracked."),
+		metric.WithInt64Callback(func(_ context.Context, o metric.Int64Observer) error {
+			p.mut.Lock()
+			defer p.mut.Unlock()
+			o.Observe(int64(len(p.streams)))
+			return nil
+		}),
+	)
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// Start implements component.Component. It starts removing streams which
+// haven't received data points for longer than MaxStale.
+func (p *processor) Start(_ context.Context, _ component.Host) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	p.cancel = cancel
+	p.done = make(chan struct{})
+
+	go func() {
+		defer close(p.done)
+
+		ticker := time.NewTicker(p.cfg.MaxStale / 2)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				p.expire()
+			}
+		}
+	}()
+	return nil
+}
+
+// Shutdown implements component.Component.
+func (p *processor) Shutdown(context.Context) error {
+	if p.cancel != nil {
+		p.cancel()
+		<-p.done
+	}
+	return nil
+}
+
+// Capabilities implements consumer.Metrics.
+func (p *processor) Capabilities() consumer.Capabilities {
+	return consumer.Capabilities{MutatesData: true}
+}
+
+func (p *processor) expire() {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	deadline := p.now().Add(-p.cfg.MaxStale)
+	for key, s := range p.streams {
+		if s.lastSeen.Before(deadline) {
+			delete(p.streams, key)
+		}
+	}
+}
+
+// ConsumeMetrics implements consumer.Metrics. Delta sums, histograms and
+// exponential histograms are replaced with their cumulative equivalent.
+// Other metrics are passed through unmodified.
+func (p *processor) ConsumeMetrics(ctx context.Context, md pmetric.Metrics) error {
+	p.mut.Lock()
+	now := p.now()
+	md.ResourceMetrics().RemoveIf(func(rm pmetric.ResourceMetrics) bool {
+		rm.ScopeMetrics().RemoveIf(func(sm pmetric.ScopeMetrics) bool {
+			sm.Metrics().RemoveIf(func(m pmetric.Metric) bool {
+				return !p.convertMetric(ctx, now, rm.Resource(), sm.Scope(), m)
+			})
+			return sm.Metrics().Len() == 0
+		})
+		return rm.ScopeMetrics().Len() == 0
+	})
+	p.mut.Unlock()
+
+	if md.ResourceMetrics().Len() == 0 {
+		return nil
+	}
+	return p.next.ConsumeMetrics(ctx, md)
+}
+
+// convertMetric converts m in place if it has delta temporality. It returns
+// false if all data points of the metric were dropped.
+func (p *processor) convertMetric(ctx context.Context, now time.Time, res pcommon.Resource, scope pcommon.InstrumentationScope, m pmetric.Metric) bool {
+	switch m.Type() {
+	case pmetric.MetricTypeSum:
+		sum := m.Sum()
+		if sum.AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+			return true
+		}
+		sum.DataPoints().RemoveIf(func(dp pmetric.NumberDataPoint) bool {
+			s := p.stream(ctx, now, streamID(res, scope, m, dp.Attributes()), dp.StartTimestamp(), dp.Timestamp())
+			if s == nil {
+				return true
+			}
+			s.addNumber(dp)
+			return false
+		})
+		sum.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		return sum.DataPoints().Len() > 0
+
+	case pmetric.MetricTypeHistogram:
+		hist := m.Histogram()
+		if hist.AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+			return true
+		}
+		hist.DataPoints().RemoveIf(func(dp pmetric.HistogramDataPoint) bool {
+			s := p.stream(ctx, now, streamID(res, scope, m, dp.Attributes()), dp.StartTimestamp(), dp.Timestamp())
+			if s == nil {
+				return true
+			}
+			s.addHistogram(dp)
+			return false
+		})
+		hist.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		return hist.DataPoints().Len() > 0
+
+	case pmetric.MetricTypeExponentialHistogram:
+		hist := m.ExponentialHistogram()
+		if hist.AggregationTemporality() != pmetric.AggregationTemporalityDelta {
+			return true
+		}
+		hist.DataPoints().RemoveIf(func(dp pmetric.ExponentialHistogramDataPoint) bool {
+			s := p.stream(ctx, now, streamID(res, scope, m, dp.Attributes()), dp.StartTimestamp(), dp.Timestamp())
+			if s == nil {
+				return true
+			}
+			s.addExponentialHistogram(dp)
+			return false
+		})
+		hist.SetAggregationTemporality(pmetric.AggregationTemporalityCumulative)
+		return hist.DataPoints().Len() > 0
+	}
+	return true
+}
+
+// stream returns the state of the stream with the given ID, creating it if
+// needed. It returns nil if the data point must be dropped, either because
+// it's older than the last data point of the stream or because MaxStreams has
+// been reached.
+//
+// It must be called with p.mut held.
+func (p *processor) stream(ctx context.Context, now time.Time, id uint64, start, ts pcommon.Timestamp) *stream {
+	s, ok := p.streams[id]
+	if !ok {
+		if len(p.streams) >= p.cfg.MaxStreams {
+			p.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reasonLimit)))
+			return nil
+		}
+		if start == 0 {
+			start = ts
+		}
+		s = &stream{start: start}
+		p.streams[id] = s
+	} else if ts <= s.last || (start != 0 && start < s.last) {
+		// The data point overlaps with data points which have already been
+		// added to the cumulative value.
+		p.dropped.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reasonOutOfOrder)))
+		p.logger.Debug("dropping out of order delta data point", zap.Uint64("stream", id))
+		return nil
+	}
+	s.last = ts
+	s.lastSeen = now
+	return s
+}
+
+// streamID identifies the stream a data point belongs to.
+func streamID(res pcommon.Resource, scope pcommon.InstrumentationScope, m pmetric.Metric, attrs pcommon.Map) uint64 {
+	h := xxhash.New()
+	hashMap(h, res.Attributes())
+	writeString(h, scope.Name())
+	writeString(h, scope.Version())
+	hashMap(h, scope.Attributes())
+	writeString(h, m.Name())
+	writeString(h, m.Unit())
+	writeString(h, m.Type().String())
+	if m.Type() == pmetric.MetricTypeSum && m.Sum().IsMonotonic() {
+		writeString(h, "monotonic")
+	}
+	hashMap(h, attrs)
+	return h.Sum64()
+}
+
+func hashMap(h *xxhash.Digest, m pcommon.Map) {
+	keys := make([]string, 0, m.Len())
+	m.Range(func(k string, _ pcommon.Value) bool {
+		keys = append(keys, k)
+		return true
+	})
+	sort.Strings(keys)
+
+	writeString(h, "{")
+	for _, k := range keys {
+		v, _ := m.Get(k)
+		writeString(h, k)
+		writeString(h, v.Type().String())
+		writeString(h, v.AsString())
+	}
+	writeString(h, "}")
+}
+
+func writeString(h *xxhash.Digest, s string) {
+	_, _ = h.WriteString(s)
+	// Separate values so that adjacent strings can't be confused.
+	_, _ = h.Write([]byte{0xff})
+}
@@ -0,0 +1,199 @@
+package deltatocumulativeprocessor
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/collector/component/componenttest"
+	"go.opentelemetry.io/collector/consumer/consumertest"
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+	otelprocessor "go.opentelemetry.io/collector/processor"
+)
+
+func newTestProcessor(t *testing.T, cfg *Config) (*processor, *consumertest.MetricsSink) {
+	sink := &consumertest.MetricsSink{}
+	p, err := newProcessor(cfg, otelprocessor.CreateSettings{TelemetrySettings: componenttest.NewNopTelemetrySettings()}, sink)
+	require.NoError(t, err)
+	return p, sink
+}
+
+func deltaSum(host string, start, ts int64, value int64) pmetric.Metrics {
+	md := pmetric.NewMetrics()
+	rm := md.ResourceMetrics().AppendEmpty()
+	rm.Resource().Attributes().PutStr("host", host)
+	m := rm.ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+	m.SetName("requests")
+	sum := m.SetEmptySum()
+	sum.SetIsMonotonic(true)
+	sum.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+	dp := sum.DataPoints().AppendEmpty()
+	dp.SetStartTimestamp(pcommon.Timestamp(start))
+	dp.SetTimestamp(pcommon.Timestamp(ts))
+	dp.SetIntValue(value)
+	return md
+}
+
+func lastNumberDataPoint(sink *consumertest.MetricsSink) (pmetric.Metric, pmetric.NumberDataPoint) {
+	all := sink.AllMetrics()
+	m := all[len(all)-1].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0)
+	return m, m.Sum().DataPoints().At(0)
+}
+
+func TestSum(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 10})
+	ctx := context.Background()
+
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 10, 20, 5)))
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 20, 30, 3)))
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("b", 20, 30, 7)))
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 30, 40, 2)))
+
+	m, dp := lastNumberDataPoint(sink)
+	require.Equal(t, pmetric.AggregationTemporalityCumulative, m.Sum().AggregationTemporality())
+	require.Equal(t, int64(10), dp.IntValue())
+	require.Equal(t, pcommon.Timestamp(10), dp.StartTimestamp())
+	require.Equal(t, pcommon.Timestamp(40), dp.Timestamp())
+
+	// Streams are tracked separately.
+	all := sink.AllMetrics()
+	require.Equal(t, int64(7), all[2].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Sum().DataPoints().At(0).IntValue())
+}
+
+func TestSum_OutOfOrder(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 10})
+	ctx := context.Background()
+
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 20, 30, 5)))
+	// Overlaps with the previous data point, so it must be dropped.
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 10, 20, 3)))
+	require.Equal(t, 1, sink.DataPointCount())
+
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 30, 40, 1)))
+	_, dp := lastNumberDataPoint(sink)
+	require.Equal(t, int64(6), dp.IntValue())
+}
+
+func TestMaxStreams(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 1})
+	ctx := context.Background()
+
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 10, 20, 5)))
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("b", 10, 20, 5)))
+	require.Equal(t, 1, sink.DataPointCount())
+	require.Len(t, p.streams, 1)
+}
+
+func TestExpiry(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 10})
+	ctx := context.Background()
+
+	now := time.Now()
+	p.now = func() time.Time { return now }
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 10, 20, 5)))
+
+	now = now.Add(2 * time.Minute)
+	p.expire()
+	require.Empty(t, p.streams)
+
+	// An expired stream starts a new cumulative series.
+	require.NoError(t, p.ConsumeMetrics(ctx, deltaSum("a", 20, 30, 3)))
+	_, dp := lastNumberDataPoint(sink)
+	require.Equal(t, int64(3), dp.IntValue())
+	require.Equal(t, pcommon.Timestamp(20), dp.StartTimestamp())
+}
+
+func TestHistogram(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 10})
+	ctx := context.Background()
+
+	histogram := func(start, ts int64, bounds []float64, counts []uint64, sum, min, max float64) pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		h := m.SetEmptyHistogram()
+		h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		dp := h.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(pcommon.Timestamp(start))
+		dp.SetTimestamp(pcommon.Timestamp(ts))
+		dp.ExplicitBounds().FromRaw(bounds)
+		dp.BucketCounts().FromRaw(counts)
+		var count uint64
+		for _, c := range counts {
+			count += c
+		}
+		dp.SetCount(count)
+		dp.SetSum(sum)
+		dp.SetMin(min)
+		dp.SetMax(max)
+		return md
+	}
+	lastDataPoint := func() pmetric.HistogramDataPoint {
+		all := sink.AllMetrics()
+		return all[len(all)-1].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).Histogram().DataPoints().At(0)
+	}
+
+	require.NoError(t, p.ConsumeMetrics(ctx, histogram(10, 20, []float64{1, 5}, []uint64{1, 2, 0}, 6, 0.5, 4)))
+	require.NoError(t, p.ConsumeMetrics(ctx, histogram(20, 30, []float64{1, 5}, []uint64{0, 1, 3}, 30, 2, 10)))
+
+	dp := lastDataPoint()
+	require.Equal(t, []uint64{1, 3, 3}, dp.BucketCounts().AsRaw())
+	require.Equal(t, uint64(7), dp.Count())
+	require.Equal(t, 36.0, dp.Sum())
+	require.Equal(t, 0.5, dp.Min())
+	require.Equal(t, 10.0, dp.Max())
+	require.Equal(t, pcommon.Timestamp(10), dp.StartTimestamp())
+
+	// Changing the bucket layout resets the stream.
+	require.NoError(t, p.ConsumeMetrics(ctx, histogram(30, 40, []float64{2}, []uint64{1, 1}, 3, 1, 2)))
+	dp = lastDataPoint()
+	require.Equal(t, []uint64{1, 1}, dp.BucketCounts().AsRaw())
+	require.Equal(t, uint64(2), dp.Count())
+	require.Equal(t, pcommon.Timestamp(30), dp.StartTimestamp())
+}
+
+func TestExponentialHistogram(t *testing.T) {
+	p, sink := newTestProcessor(t, &Config{MaxStale: time.Minute, MaxStreams: 10})
+	ctx := context.Background()
+
+	histogram := func(start, ts int64, scale int32, offset int32, counts []uint64) pmetric.Metrics {
+		md := pmetric.NewMetrics()
+		m := md.ResourceMetrics().AppendEmpty().ScopeMetrics().AppendEmpty().Metrics().AppendEmpty()
+		m.SetName("latency")
+		h := m.SetEmptyExponentialHistogram()
+		h.SetAggregationTemporality(pmetric.AggregationTemporalityDelta)
+		dp := h.DataPoints().AppendEmpty()
+		dp.SetStartTimestamp(pcommon.Timestamp(start))
+		dp.SetTimestamp(pcommon.Timestamp(ts))
+		dp.SetScale(scale)
+		dp.Positive().SetOffset(offset)
+		dp.Positive().BucketCounts().FromRaw(counts)
+		var count uint64
+		for _, c := range counts {
+			count += c
+		}
+		dp.SetCount(count)
+		return md
+	}
+
+	require.NoError(t, p.ConsumeMetrics(ctx, histogram(10, 20, 1, 2, []uint64{1, 2})))
+	// A lower scale merges every two buckets of scale 1 into one bucket.
+	require.NoError(t, p.ConsumeMetrics(ctx, histogram(20, 30, 0, 0, []uint64{4, 1})))
+
+	all := sink.AllMetrics()
+	dp := all[len(all)-1].ResourceMetrics().At(0).ScopeMetrics().At(0).Metrics().At(0).ExponentialHistogram().DataPoints().At(0)
+	require.Equal(t, int32(0), dp.Scale())
+	require.Equal(t, int32(0), dp.Positive().Offset())
+	require.Equal(t, []uint64{4, 4}, dp.Positive().BucketCounts().AsRaw())
+	require.Equal(t, uint64(8), dp.Count())
+}
+
+func TestExpBucketsDownscale(t *testing.T) {
+	b := expBuckets{offset: -3, counts: []uint64{1, 2, 3, 4, 5}}
+	b.downscale(1)
+	// Indices -3..1 map to -2, -1, -1, 0, 0.
+	require.Equal(t, int32(-2), b.offset)
+	require.Equal(t, []uint64{1, 5, 9}, b.counts)
+}
@@ -0,0 +1,208 @@
+package deltatocumulativeprocessor
+
+import (
+	"math"
+	"slices"
+	"time"
+
+	"go.opentelemetry.io/collector/pdata/pcommon"
+	"go.opentelemetry.io/collector/pdata/pmetric"
+)
+
+// stream is the cumulative state of a single series. Only the fields for the
+// type of the series are used.
+type stream struct {
+	start    pcommon.Timestamp
+	last     pcommon.Timestamp
+	lastSeen time.Time
+
+	// Sums.
+	intValue    int64
+	doubleValue float64
+
+	// Histograms and exponential histograms.
+	count          uint64
+	sum            float64
+	min, max       float64
+	hasMin, hasMax bool
+
+	// Histograms.
+	bounds  []float64
+	buckets []uint64
+
+	// Exponential histograms.
+	initialized   bool
+	scale         int32
+	zeroCount     uint64
+	zeroThreshold float64
+	positive      expBuckets
+	negative      expBuckets
+}
+
+// reset clears the accumulated state of the stream and starts a new
+// cumulative series at start.
+func (s *stream) reset(start pcommon.Timestamp) {
+	*s = stream{start: start, last: s.last, lastSeen: s.lastSeen}
+}
+
+func (s *stream) addNumber(dp pmetric.NumberDataPoint) {
+	switch dp.ValueType() {
+	case pmetric.NumberDataPointValueTypeInt:
+		s.intValue += dp.IntValue()
+		dp.SetIntValue(s.intValue)
+	case pmetric.NumberDataPointValueTypeDouble:
+		s.doubleValue += dp.DoubleValue()
+		dp.SetDoubleValue(s.doubleValue)
+	}
+	dp.SetStartTimestamp(s.start)
+}
+
+func (s *stream) addHistogram(dp pmetric.HistogramDataPoint) {
+	bounds := dp.ExplicitBounds().AsRaw()
+	if s.buckets != nil && !slices.Equal(s.bounds, bounds) {
+		// The buckets changed, so the previous counts can't be carried over.
+		s.reset(startOf(dp.StartTimestamp(), dp.Timestamp()))
+	}
+	if s.buckets == nil {
+		s.bounds = bounds
+		s.buckets = make([]uint64, dp.BucketCounts().Len())
+	}
+
+	s.addAggregates(dp.Count(), dp.Sum(), dp.HasSum(), dp.Min(), dp.HasMin(), dp.Max(), dp.HasMax())
+	for i := 0; i < dp.BucketCounts().Len() && i < len(s.buckets); i++ {
+		s.buckets[i] += dp.BucketCounts().At(i)
+	}
+
+	dp.SetStartTimestamp(s.start)
+	dp.SetCount(s.count)
+	if dp.HasSum() {
+		dp.SetSum(s.sum)
+	}
+	if s.hasMin {
+		dp.SetMin(s.min)
+	}
+	if s.hasMax {
+		dp.SetMax(s.max)
+	}
+	dp.BucketCounts().FromRaw(s.buckets)
+}
+
+func (s *stream) addExponentialHistogram(dp pmetric.ExponentialHistogramDataPoint) {
+	if !s.initialized {
+		s.initialized = true
+		s.scale = dp.Scale()
+	}
+
+	// Buckets of different scales are merged at the lower of both scales,
+	// since every bucket of a higher scale fits into a bucket of a lower one.
+	positive := expBucketsFrom(dp.Positive())
+	negative := expBucketsFrom(dp.Negative())
+	switch {
+	case dp.Scale() < s.scale:
+		s.positive.downscale(s.scale - dp.Scale())
+		s.negative.downscale(s.scale - dp.Scale())
+		s.scale = dp.Scale()
+	case dp.Scale() > s.scale:
+		positive.downscale(dp.Scale() - s.scale)
+		negative.downscale(dp.Scale() - s.scale)
+	}
+	s.positive.merge(positive)
+	s.negative.merge(negative)
+
+	s.addAggregates(dp.Count(), dp.Sum(), dp.HasSum(), dp.Min(), dp.HasMin(), dp.Max(), dp.HasMax())
+	s.zeroCount += dp.ZeroCount()
+	s.zeroThreshold = math.Max(s.zeroThreshold, dp.ZeroThreshold())
+
+	dp.SetStartTimestamp(s.start)
+	dp.SetScale(s.scale)
+	dp.SetCount(s.count)
+	if dp.HasSum() {
+		dp.SetSum(s.sum)
+	}
+	if s.hasMin {
+		dp.SetMin(s.min)
+	}
+	if s.hasMax {
+		dp.SetMax(s.max)
+	}
+	dp.SetZeroCount(s.zeroCount)
+	dp.SetZeroThreshold(s.zeroThreshold)
+	s.positive.copyTo(dp.Positive())
+	s.negative.copyTo(dp.Negative())
+}
+
+func (s *stream) addAggregates(count uint64, sum float64, hasSum bool, min float64, hasMin bool, max float64, hasMax bool) {
+	s.count += count
+	if hasSum {
+		s.sum += sum
+	}
+	if hasMin && (!s.hasMin || min < s.min) {
+		s.min, s.hasMin = min, true
+	}
+	if hasMax && (!s.hasMax || max > s.max) {
+		s.max, s.hasMax = max, true
+	}
+}
+
+func startOf(start, ts pcommon.Timestamp) pcommon.Timestamp {
+	if start == 0 {
+		return ts
+	}
+	return start
+}
+
+// expBuckets are the buckets of one side of an exponential histogram. The
+// bucket at index i of counts has the bucket index offset+i.
+type expBuckets struct {
+	offset int32
+	counts []uint64
+}
+
+func expBucketsFrom(b pmetric.ExponentialHistogramDataPointBuckets) expBuckets {
+	return expBuckets{offset: b.Offset(), counts: b.BucketCounts().AsRaw()}
+}
+
+// downscale lowers the scale of the buckets by the given amount, merging
+// every 2^by adjacent buckets into one.
+func (b *expBuckets) downscale(by int32) {
+	if by <= 0 || len(b.counts) == 0 {
+		return
+	}
+	// Arithmetic shifts round towards negative infinity, which is how bucket
+	// indices of lower scales are computed.
+	newOffset := b.offset >> by
+	last := (b.offset + int32(len(b.counts)) - 1) >> by
+	counts := make([]uint64, last-newOffset+1)
+	for i, c := range b.counts {
+		idx := (b.offset + int32(i)) >> by
+		counts[idx-newOffset] += c
+	}
+	b.offset, b.counts = newOffset, counts
+}
+
+// merge adds the counts of other, which must have the same scale, to b.
+func (b *expBuckets) merge(other expBuckets) {
+	if len(other.counts) == 0 {
+		return
+	}
+	if len(b.counts) == 0 {
+		b.offset, b.counts = other.offset, slices.Clone(other.counts)
+		return
+	}
+
+	start := min(b.offset, other.offset)
+	end := max(b.offset+int32(len(b.counts)), other.offset+int32(len(other.counts)))
+	if start != b.offset || end != b.offset+int32(len(b.counts)) {
+		counts := make([]uint64, end-start)
+		copy(counts[b.offset-start:], b.counts)
+		b.offset, b.counts = start, counts
+	}
+	for i, c := range other.counts {
+		b.counts[other.offset-b.offset+int32(i)] += c
+	}
+}
+
+func (b *expBuckets) copyTo(dst pmetric.ExponentialHistogramDataPointBuckets) {
+	dst.SetOffset(b.offset)
+	dst.BucketCounts().FromRaw(b.counts)
+}
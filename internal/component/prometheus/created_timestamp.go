@@ -0,0 +1,26 @@
+package prometheus
+
+import (
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// CreatedTimestampAppender is implemented by appenders which accept the
+// created timestamp of series, such as the appenders of Fanout and
+// Interceptor. It mirrors the AppendCTZeroSample method of newer versions of
+// storage.Appender.
+type CreatedTimestampAppender interface {
+	// AppendCTZeroSample records ct as the created timestamp of the series,
+	// whose next sample is at t.
+	AppendCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error)
+}
+
+// AppendCTZeroSample passes the created timestamp ct of a series to app if
+// app accepts created timestamps, and otherwise drops it.
+func AppendCTZeroSample(app storage.Appender, ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	ctApp, ok := app.(CreatedTimestampAppender)
+	if !ok {
+		return ref, nil
+	}
+	return ctApp.AppendCTZeroSample(ref, l, t, ct)
+}
@@ -12,6 +12,7 @@ import (
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/component/prometheus/exporter"
 	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/agent/internal/util"
 	"github.com/grafana/agent/static/integrations"
 	"github.com/grafana/agent/static/integrations/blackbox_exporter"
@@ -95,6 +96,7 @@ type Arguments struct {
 	Config             rivertypes.OptionalSecret `river:"config,attr,optional"`
 	Targets            TargetBlock               `river:"target,block"`
 	ProbeTimeoutOffset time.Duration             `river:"probe_timeout_offset,attr,optional"`
+	Clustering         cluster.ComponentBlock    `river:"clustering,block,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -121,6 +123,11 @@ func (a *Arguments) Validate() error {
 	return nil
 }
 
+// ClusteringEnabled implements exporter.ClusteringArguments.
+func (a Arguments) ClusteringEnabled() bool {
+	return a.Clustering.Enabled
+}
+
 // Convert converts the component's Arguments to the integration's Config.
 func (a *Arguments) Convert() *blackbox_exporter.Config {
 	return &blackbox_exporter.Config{
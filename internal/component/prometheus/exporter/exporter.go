@@ -12,9 +12,12 @@ import (
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/cluster"
 	http_service "github.com/grafana/agent/internal/service/http"
 	"github.com/grafana/agent/static/integrations"
+	"github.com/grafana/ckit/shard"
 	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
 )
 
 // Creator is a function provided by an implementation to create a concrete exporter instance.
@@ -25,8 +28,17 @@ type Exports struct {
 	Targets []discovery.Target `river:"targets,attr"`
 }
 
+// ClusteringArguments is implemented by the arguments of exporters which can
+// distribute their targets across the nodes of a cluster.
+type ClusteringArguments interface {
+	// ClusteringEnabled returns whether the targets of the exporter are
+	// distributed across the nodes of the cluster.
+	ClusteringEnabled() bool
+}
+
 type Component struct {
-	opts component.Options
+	opts    component.Options
+	cluster cluster.Cluster
 
 	mut sync.Mutex
 
@@ -36,10 +48,17 @@ type Component struct {
 	targetBuilderFunc func(discovery.Target, component.Arguments) []discovery.Target
 	baseTarget        discovery.Target
 
+	// targets are all the targets of the exporter, of which only the targets
+	// owned by the local node are exported when clustering is enabled.
+	targets    []discovery.Target
+	clustering bool
+
 	exporter       integrations.Integration
 	metricsHandler http.Handler
 }
 
+var _ cluster.Component = (*Component)(nil)
+
 // New creates a new exporter component.
 func New(creator Creator, name string) func(component.Options, component.Arguments) (component.Component, error) {
 	return newExporter(creator, name, nil)
@@ -85,6 +104,14 @@ func (c *Component) Run(ctx context.Context) error {
 
 // Update implements component.Component.
 func (c *Component) Update(args component.Arguments) error {
+	var clustering bool
+	if ca, ok := args.(ClusteringArguments); ok {
+		clustering = ca.ClusteringEnabled()
+	}
+	if clustering && c.cluster == nil {
+		return fmt.Errorf("clustering is enabled, but the cluster service isn't available")
+	}
+
 	exporter, instanceKey, err := c.creator(c.opts, args, defaultInstance())
 	if err != nil {
 		return err
@@ -101,9 +128,11 @@ func (c *Component) Update(args component.Arguments) error {
 	} else {
 		targets = c.targetBuilderFunc(c.baseTarget, args)
 	}
+	c.targets = targets
+	c.clustering = clustering
 
 	c.opts.OnStateChange(Exports{
-		Targets: targets,
+		Targets: c.ownedTargets(),
 	})
 	c.mut.Unlock()
 	select {
@@ -113,6 +142,44 @@ func (c *Component) Update(args component.Arguments) error {
 	return err
 }
 
+// NotifyClusterChange implements cluster.Component.
+func (c *Component) NotifyClusterChange() {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if !c.clustering {
+		return
+	}
+	c.opts.OnStateChange(Exports{
+		Targets: c.ownedTargets(),
+	})
+}
+
+// ownedTargets returns the targets owned by the local node, which are all the
+// targets unless clustering is enabled. c.mut must be held.
+func (c *Component) ownedTargets() []discovery.Target {
+	if !c.clustering || c.cluster == nil {
+		return c.targets
+	}
+
+	owned := make([]discovery.Target, 0, len(c.targets))
+	for _, tgt := range c.targets {
+		peers, err := c.cluster.Lookup(shard.StringKey(targetKey(tgt)), 1, shard.OpReadWrite)
+		if err != nil || len(peers) == 0 || peers[0].Self {
+			// Fall back to owning the target ourselves if no peer owns it, as
+			// discovery.DistributedTargets does.
+			owned = append(owned, tgt)
+		}
+	}
+	return owned
+}
+
+// targetKey identifies a target across the nodes of a cluster. The instance
+// label is ignored, since it defaults to the hostname of each node.
+func targetKey(tgt discovery.Target) string {
+	return labels.NewBuilder(tgt.NonMetaLabels()).Del("instance").Labels().String()
+}
+
 // Handler serves metrics endpoint from the integration implementation.
 func (c *Component) Handler() http.Handler {
 	c.mut.Lock()
@@ -137,6 +204,11 @@ func newExporter(creator Creator, name string, targetBuilderFunc func(discovery.
 		}
 		httpData := data.(http_service.Data)
 
+		// The cluster service is only required when clustering is enabled.
+		if data, err := opts.GetServiceData(cluster.ServiceName); err == nil {
+			c.cluster = data.(cluster.Cluster)
+		}
+
 		componentName := opts.ID[:strings.LastIndex(opts.ID, ".")]
 		if opts.ID == "prometheus.exporter.unix" {
 			componentName = opts.ID
@@ -0,0 +1,178 @@
+package exporter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/discovery"
+	"github.com/grafana/agent/internal/service/cluster"
+	http_service "github.com/grafana/agent/internal/service/http"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/agent/static/integrations"
+	"github.com/grafana/agent/static/integrations/config"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/stretchr/testify/require"
+)
+
+type testArguments struct {
+	Targets    []string
+	Clustering bool
+}
+
+func (a testArguments) ClusteringEnabled() bool { return a.Clustering }
+
+func buildTestTargets(baseTarget discovery.Target, args component.Arguments) []discovery.Target {
+	var targets []discovery.Target
+	for _, name := range args.(testArguments).Targets {
+		target := make(discovery.Target)
+		for k, v := range baseTarget {
+			target[k] = v
+		}
+		target["__param_target"] = name
+		targets = append(targets, target)
+	}
+	return targets
+}
+
+type noopIntegration struct{}
+
+func (noopIntegration) MetricsHandler() (http.Handler, error) { return http.NotFoundHandler(), nil }
+func (noopIntegration) ScrapeConfigs() []config.ScrapeConfig  { return nil }
+func (noopIntegration) Run(ctx context.Context) error         { <-ctx.Done(); return nil }
+func createNoopExporter(component.Options, component.Arguments, string) (integrations.Integration, string, error) {
+	return noopIntegration{}, "", nil
+}
+
+// prefixCluster is a cluster where the local node owns the keys containing
+// its prefix.
+type prefixCluster struct {
+	mut    sync.Mutex
+	prefix string
+}
+
+func (c *prefixCluster) Lookup(key shard.Key, _ int, _ shard.Op) ([]peer.Peer, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	// Keys are hashes; compare them with the hashes of the keys of the targets.
+	for _, name := range []string{"a1", "a2", "b1", "b2"} {
+		if key == shard.StringKey(targetKey(testTarget(name))) {
+			return []peer.Peer{{Name: "node", Self: strings.HasPrefix(name, c.prefix)}}, nil
+		}
+	}
+	return nil, fmt.Errorf("unexpected key")
+}
+
+func (c *prefixCluster) Peers() []peer.Peer { return nil }
+
+func (c *prefixCluster) setPrefix(prefix string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.prefix = prefix
+}
+
+// testTarget returns the target of the exporter built by TestClustering.
+func testTarget(name string) discovery.Target {
+	return discovery.Target{
+		"__address__":           "memory",
+		"__scheme__":            "http",
+		"__metrics_path__":      "/component/prometheus.exporter.test.default/metrics",
+		"__param_target":        name,
+		"instance":              defaultInstance(),
+		"job":                   "integrations/test",
+		"__meta_component_name": "prometheus.exporter.test",
+		"__meta_component_id":   "prometheus.exporter.test.default",
+	}
+}
+
+func TestClustering(t *testing.T) {
+	var (
+		exportsMut sync.Mutex
+		exports    Exports
+
+		c = &prefixCluster{prefix: "a"}
+	)
+	opts := component.Options{
+		ID:     "prometheus.exporter.test.default",
+		Logger: util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {
+			exportsMut.Lock()
+			defer exportsMut.Unlock()
+			exports = e.(Exports)
+		},
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{MemoryListenAddr: "memory", BaseHTTPPath: "/component"}, nil
+			case cluster.ServiceName:
+				return c, nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+	requireTargets := func(names ...string) {
+		t.Helper()
+		exportsMut.Lock()
+		defer exportsMut.Unlock()
+
+		var expected []discovery.Target
+		for _, name := range names {
+			expected = append(expected, testTarget(name))
+		}
+		require.ElementsMatch(t, expected, exports.Targets)
+	}
+
+	build := NewWithTargetBuilder(createNoopExporter, "test", buildTestTargets)
+	comp, err := build(opts, testArguments{Targets: []string{"a1", "a2", "b1", "b2"}})
+	require.NoError(t, err)
+
+	// All targets are exported until clustering is enabled.
+	requireTargets("a1", "a2", "b1", "b2")
+
+	require.NoError(t, comp.Update(testArguments{Targets: []string{"a1", "a2", "b1", "b2"}, Clustering: true}))
+	requireTargets("a1", "a2")
+
+	c.setPrefix("b")
+	comp.(cluster.Component).NotifyClusterChange()
+	requireTargets("b1", "b2")
+}
+
+func TestNoClusterService(t *testing.T) {
+	opts := component.Options{
+		ID:            "prometheus.exporter.test.default",
+		Logger:        util.TestFlowLogger(t),
+		OnStateChange: func(e component.Exports) {},
+		GetServiceData: func(name string) (interface{}, error) {
+			if name == http_service.ServiceName {
+				return http_service.Data{MemoryListenAddr: "memory", BaseHTTPPath: "/component"}, nil
+			}
+			return nil, fmt.Errorf("service %q does not exist", name)
+		},
+	}
+
+	// The cluster service is only required when clustering is enabled.
+	build := NewWithTargetBuilder(createNoopExporter, "test", buildTestTargets)
+	comp, err := build(opts, testArguments{Targets: []string{"a1"}})
+	require.NoError(t, err)
+
+	err = comp.Update(testArguments{Targets: []string{"a1"}, Clustering: true})
+	require.EqualError(t, err, "clustering is enabled, but the cluster service isn't available")
+}
+
+func TestTargetKey(t *testing.T) {
+	// The instance label defaults to the hostname of each node, so it must not
+	// change which node owns a target.
+	a := testTarget("a")
+	b := testTarget("a")
+	b["instance"] = "other-node"
+	require.Equal(t, targetKey(a), targetKey(b))
+
+	require.NotEqual(t, targetKey(testTarget("a")), targetKey(testTarget("b")))
+}
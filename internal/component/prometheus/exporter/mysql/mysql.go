@@ -5,6 +5,7 @@ import (
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/prometheus/exporter"
 	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/agent/static/integrations"
 	"github.com/grafana/agent/static/integrations/mysqld_exporter"
 	"github.com/grafana/river/rivertypes"
@@ -81,6 +82,8 @@ type Arguments struct {
 
 	Heartbeat Heartbeat `river:"heartbeat,block,optional"`
 	MySQLUser MySQLUser `river:"mysql.user,block,optional"`
+
+	Clustering cluster.ComponentBlock `river:"clustering,block,optional"`
 }
 
 // InfoSchemaProcessList configures the info_schema.processlist collector
@@ -130,6 +133,11 @@ func (a *Arguments) SetToDefault() {
 	*a = DefaultArguments
 }
 
+// ClusteringEnabled implements exporter.ClusteringArguments.
+func (a Arguments) ClusteringEnabled() bool {
+	return a.Clustering.Enabled
+}
+
 // Validate implements river.Validator.
 func (a *Arguments) Validate() error {
 	_, err := mysql.ParseDSN(string(a.DataSourceName))
@@ -7,6 +7,7 @@ import (
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/prometheus/exporter"
 	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/agent/static/integrations"
 	"github.com/grafana/agent/static/integrations/postgres_exporter"
 	"github.com/grafana/river/rivertypes"
@@ -86,7 +87,8 @@ type Arguments struct {
 	EnabledCollectors       []string `river:"enabled_collectors,attr,optional"`
 
 	// Blocks
-	AutoDiscovery AutoDiscovery `river:"autodiscovery,block,optional"`
+	AutoDiscovery AutoDiscovery          `river:"autodiscovery,block,optional"`
+	Clustering    cluster.ComponentBlock `river:"clustering,block,optional"`
 }
 
 func (a *Arguments) Validate() error {
@@ -111,6 +113,11 @@ func (a *Arguments) SetToDefault() {
 	*a = DefaultArguments
 }
 
+// ClusteringEnabled implements exporter.ClusteringArguments.
+func (a Arguments) ClusteringEnabled() bool {
+	return a.Clustering.Enabled
+}
+
 func (a *Arguments) convert(instanceName string) *postgres_exporter.Config {
 	return &postgres_exporter.Config{
 		DataSourceNames:        a.convertDataSourceNames(),
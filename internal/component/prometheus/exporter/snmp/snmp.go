@@ -9,6 +9,7 @@ import (
 	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/component/prometheus/exporter"
 	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/service/cluster"
 	"github.com/grafana/agent/static/integrations"
 	"github.com/grafana/agent/static/integrations/snmp_exporter"
 	"github.com/grafana/river/rivertypes"
@@ -121,9 +122,15 @@ type Arguments struct {
 	Config       rivertypes.OptionalSecret `river:"config,attr,optional"`
 	Targets      TargetBlock               `river:"target,block"`
 	WalkParams   WalkParams                `river:"walk_param,block,optional"`
+	Clustering   cluster.ComponentBlock    `river:"clustering,block,optional"`
 	ConfigStruct snmp_config.Config
 }
 
+// ClusteringEnabled implements exporter.ClusteringArguments.
+func (a Arguments) ClusteringEnabled() bool {
+	return a.Clustering.Enabled
+}
+
 // UnmarshalRiver implements River unmarshalling for Arguments.
 func (a *Arguments) UnmarshalRiver(f func(interface{}) error) error {
 	type args Arguments
@@ -101,7 +101,10 @@ type appender struct {
 	stalenessTrackers []labelstore.StalenessTracker
 }
 
-var _ storage.Appender = (*appender)(nil)
+var (
+	_ storage.Appender         = (*appender)(nil)
+	_ CreatedTimestampAppender = (*appender)(nil)
+)
 
 // Append satisfies the Appender interface.
 func (a *appender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
@@ -221,6 +224,24 @@ func (a *appender) AppendHistogram(ref storage.SeriesRef, l labels.Labels, t int
 	return ref, multiErr
 }
 
+// AppendCTZeroSample satisfies the CreatedTimestampAppender interface.
+func (a *appender) AppendCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	if a.start.IsZero() {
+		a.start = time.Now()
+	}
+	if ref == 0 {
+		ref = storage.SeriesRef(a.ls.GetOrAddGlobalRefID(l))
+	}
+	var multiErr error
+	for _, x := range a.children {
+		_, err := AppendCTZeroSample(x, ref, l, t, ct)
+		if err != nil {
+			multiErr = multierror.Append(multiErr, err)
+		}
+	}
+	return ref, multiErr
+}
+
 // NoopMetadataStore implements the MetricMetadataStore interface.
 type NoopMetadataStore map[string]scrape.MetricMetadata
 
@@ -19,6 +19,7 @@ type Interceptor struct {
 	onAppendExemplar  func(ref storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error)
 	onUpdateMetadata  func(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata, next storage.Appender) (storage.SeriesRef, error)
 	onAppendHistogram func(ref storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram, next storage.Appender) (storage.SeriesRef, error)
+	onAppendCTZero    func(ref storage.SeriesRef, l labels.Labels, t, ct int64, next storage.Appender) (storage.SeriesRef, error)
 
 	// next is the next appendable to pass in the chain.
 	next storage.Appendable
@@ -76,6 +77,14 @@ func WithHistogramHook(f func(ref storage.SeriesRef, l labels.Labels, t int64, h
 	}
 }
 
+// WithCreatedTimestampHook returns an InterceptorOption which hooks into calls
+// to AppendCTZeroSample.
+func WithCreatedTimestampHook(f func(ref storage.SeriesRef, l labels.Labels, t, ct int64, next storage.Appender) (storage.SeriesRef, error)) InterceptorOption {
+	return func(i *Interceptor) {
+		i.onAppendCTZero = f
+	}
+}
+
 // Appender satisfies the Appendable interface.
 func (f *Interceptor) Appender(ctx context.Context) storage.Appender {
 	app := &interceptappender{
@@ -96,7 +105,10 @@ type interceptappender struct {
 	stalenessTrackers []labelstore.StalenessTracker
 }
 
-var _ storage.Appender = (*interceptappender)(nil)
+var (
+	_ storage.Appender         = (*interceptappender)(nil)
+	_ CreatedTimestampAppender = (*interceptappender)(nil)
+)
 
 // Append satisfies the Appender interface.
 func (a *interceptappender) Append(ref storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
@@ -196,3 +208,18 @@ func (a *interceptappender) AppendHistogram(
 	}
 	return a.child.AppendHistogram(ref, l, t, h, fh)
 }
+
+// AppendCTZeroSample satisfies the CreatedTimestampAppender interface.
+func (a *interceptappender) AppendCTZeroSample(ref storage.SeriesRef, l labels.Labels, t, ct int64) (storage.SeriesRef, error) {
+	if ref == 0 {
+		ref = storage.SeriesRef(a.ls.GetOrAddGlobalRefID(l))
+	}
+
+	if a.interceptor.onAppendCTZero != nil {
+		return a.interceptor.onAppendCTZero(ref, l, t, ct, a.child)
+	}
+	if a.child == nil {
+		return 0, nil
+	}
+	return AppendCTZeroSample(a.child, ref, l, t, ct)
+}
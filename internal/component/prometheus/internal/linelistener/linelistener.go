@@ -0,0 +1,219 @@
+// Package linelistener implements listeners receiving newline-delimited text
+// protocols, such as Graphite plaintext or InfluxDB line protocol, over TCP
+// and UDP.
+package linelistener
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/flow/logging/level"
+)
+
+// Options configures a Listener.
+type Options struct {
+	// TCPAddress and UDPAddress are the addresses to listen on. Empty
+	// addresses are not listened on.
+	TCPAddress string
+	UDPAddress string
+
+	// ReadTimeout is how long an idle TCP connection is kept open. Zero means
+	// no timeout.
+	ReadTimeout time.Duration
+
+	// MaxLineLength is the maximum length of a line in bytes, without its line
+	// ending.
+	MaxLineLength int
+}
+
+// Listener accepts lines over TCP and UDP.
+type Listener struct {
+	logger  log.Logger
+	handle  func([]string)
+	tooLong func()
+	timeout time.Duration
+	maxLine int
+
+	tcp net.Listener
+	udp net.PacketConn
+	wg  sync.WaitGroup
+
+	connsMut sync.Mutex
+	conns    map[net.Conn]struct{}
+}
+
+// Start starts listening on the addresses of opts. handle is called with
+// batches of received lines, and tooLong with every line longer than
+// opts.MaxLineLength, which is discarded without being buffered.
+func Start(logger log.Logger, opts Options, handle func([]string), tooLong func()) (*Listener, error) {
+	l := &Listener{
+		logger:  logger,
+		handle:  handle,
+		tooLong: tooLong,
+		timeout: opts.ReadTimeout,
+		maxLine: opts.MaxLineLength,
+		conns:   make(map[net.Conn]struct{}),
+	}
+
+	if opts.TCPAddress != "" {
+		tcp, err := net.Listen("tcp", opts.TCPAddress)
+		if err != nil {
+			return nil, fmt.Errorf("failed to listen on tcp address %q: %w", opts.TCPAddress, err)
+		}
+		l.tcp = tcp
+		l.wg.Add(1)
+		go l.acceptTCP()
+	}
+
+	if opts.UDPAddress != "" {
+		udp, err := net.ListenPacket("udp", opts.UDPAddress)
+		if err != nil {
+			l.Stop()
+			return nil, fmt.Errorf("failed to listen on udp address %q: %w", opts.UDPAddress, err)
+		}
+		l.udp = udp
+		l.wg.Add(1)
+		go l.readUDP()
+	}
+
+	return l, nil
+}
+
+// TCPAddr returns the address of the TCP listener, if any.
+func (l *Listener) TCPAddr() net.Addr {
+	if l.tcp == nil {
+		return nil
+	}
+	return l.tcp.Addr()
+}
+
+// UDPAddr returns the address of the UDP listener, if any.
+func (l *Listener) UDPAddr() net.Addr {
+	if l.udp == nil {
+		return nil
+	}
+	return l.udp.LocalAddr()
+}
+
+func (l *Listener) acceptTCP() {
+	defer l.wg.Done()
+	for {
+		conn, err := l.tcp.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				level.Error(l.logger).Log("msg", "failed to accept tcp connection", "err", err)
+			}
+			return
+		}
+
+		l.connsMut.Lock()
+		l.conns[conn] = struct{}{}
+		l.connsMut.Unlock()
+
+		l.wg.Add(1)
+		go l.handleConn(conn)
+	}
+}
+
+func (l *Listener) handleConn(conn net.Conn) {
+	defer l.wg.Done()
+	defer func() {
+		l.connsMut.Lock()
+		delete(l.conns, conn)
+		l.connsMut.Unlock()
+		_ = conn.Close()
+	}()
+
+	// The reader buffers at most one line and its newline. Longer lines are
+	// discarded up to their newline instead of being buffered.
+	r := bufio.NewReaderSize(conn, l.maxLine+1)
+	var (
+		batch      []string
+		discarding bool
+	)
+	for {
+		if l.timeout > 0 {
+			_ = conn.SetReadDeadline(time.Now().Add(l.timeout))
+		}
+		line, err := r.ReadSlice('\n')
+		switch {
+		case errors.Is(err, bufio.ErrBufferFull):
+			if !discarding {
+				l.tooLong()
+				discarding = true
+			}
+			line, err = nil, nil
+		case discarding:
+			// The rest of a discarded line.
+			line, discarding = nil, false
+		}
+		if len(line) > 0 {
+			if len(bytes.TrimRight(line, "\r\n")) > l.maxLine {
+				l.tooLong()
+			} else {
+				batch = append(batch, string(line))
+			}
+		}
+		// Flush whenever there is no more buffered data so that samples from
+		// long-lived connections are forwarded promptly.
+		if len(batch) > 0 && (err != nil || r.Buffered() == 0) {
+			l.handle(batch)
+			batch = nil
+		}
+		if err != nil {
+			if !errors.Is(err, io.EOF) && !errors.Is(err, net.ErrClosed) {
+				level.Debug(l.logger).Log("msg", "closing tcp connection", "remote", conn.RemoteAddr(), "err", err)
+			}
+			return
+		}
+	}
+}
+
+func (l *Listener) readUDP() {
+	defer l.wg.Done()
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := l.udp.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				level.Error(l.logger).Log("msg", "failed to read udp packet", "err", err)
+			}
+			return
+		}
+		lines := strings.Split(string(buf[:n]), "\n")
+		valid := lines[:0]
+		for _, line := range lines {
+			if len(strings.TrimRight(line, "\r")) > l.maxLine {
+				l.tooLong()
+				continue
+			}
+			valid = append(valid, line)
+		}
+		l.handle(valid)
+	}
+}
+
+// Stop closes all listeners and open connections and waits for in-flight
+// lines to be handled.
+func (l *Listener) Stop() {
+	if l.tcp != nil {
+		_ = l.tcp.Close()
+	}
+	if l.udp != nil {
+		_ = l.udp.Close()
+	}
+	l.connsMut.Lock()
+	for conn := range l.conns {
+		_ = conn.Close()
+	}
+	l.connsMut.Unlock()
+	l.wg.Wait()
+}
@@ -0,0 +1,151 @@
+package writev2
+
+import (
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// SymbolTable deduplicates the strings of a request. The empty string is
+// always the first symbol, as required by the protocol.
+type SymbolTable struct {
+	symbols []string
+	refs    map[string]uint32
+}
+
+// NewSymbolTable returns an empty symbol table.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		symbols: []string{""},
+		refs:    map[string]uint32{"": 0},
+	}
+}
+
+// Symbolize returns the reference of str, adding it to the table if needed.
+func (t *SymbolTable) Symbolize(str string) uint32 {
+	if ref, ok := t.refs[str]; ok {
+		return ref
+	}
+	ref := uint32(len(t.symbols))
+	t.symbols = append(t.symbols, str)
+	t.refs[str] = ref
+	return ref
+}
+
+// SymbolizeLabels returns the references of the names and values of lbls.
+func (t *SymbolTable) SymbolizeLabels(lbls labels.Labels) []uint32 {
+	refs := make([]uint32, 0, lbls.Len()*2)
+	lbls.Range(func(l labels.Label) {
+		refs = append(refs, t.Symbolize(l.Name), t.Symbolize(l.Value))
+	})
+	return refs
+}
+
+// Symbols returns the symbols added to the table so far.
+func (t *SymbolTable) Symbols() []string {
+	return t.symbols
+}
+
+// MetadataLookup returns the metadata of the given series.
+type MetadataLookup func(series labels.Labels) (metadata.Metadata, bool)
+
+// CreatedTimestampLookup returns the created timestamp of the given series, or
+// 0 if it is unknown.
+type CreatedTimestampLookup func(series labels.Labels) int64
+
+// FromV1 converts a Remote-Write 1.0 request into a 2.0 request. Since 1.0
+// requests don't carry metadata alongside their series, the metadata of each
+// series is taken from the metadata of the request, and from lookup if the
+// request has none for the series. The created timestamp of each series is
+// taken from ctLookup. lookup and ctLookup may be nil.
+func FromV1(req *prompb.WriteRequest, lookup MetadataLookup, ctLookup CreatedTimestampLookup) *Request {
+	reqMetadata := make(map[string]metadata.Metadata, len(req.Metadata))
+	for _, m := range req.Metadata {
+		reqMetadata[m.MetricFamilyName] = metadata.Metadata{
+			Type: metricTypeToModel(MetricType(m.Type)),
+			Help: m.Help,
+			Unit: m.Unit,
+		}
+	}
+
+	var (
+		symbols = NewSymbolTable()
+		out     = &Request{Timeseries: make([]TimeSeries, 0, len(req.Timeseries))}
+		b       = labels.NewScratchBuilder(0)
+	)
+	for _, ts := range req.Timeseries {
+		lbls := labelProtosToLabels(&b, ts.Labels)
+		v2 := TimeSeries{
+			LabelsRefs: symbols.SymbolizeLabels(lbls),
+			Histograms: ts.Histograms,
+		}
+		if len(ts.Samples) > 0 {
+			v2.Samples = make([]Sample, 0, len(ts.Samples))
+			for _, s := range ts.Samples {
+				v2.Samples = append(v2.Samples, Sample{Value: s.Value, Timestamp: s.Timestamp})
+			}
+		}
+		for _, e := range ts.Exemplars {
+			v2.Exemplars = append(v2.Exemplars, Exemplar{
+				LabelsRefs: symbols.SymbolizeLabels(labelProtosToLabels(&b, e.Labels)),
+				Value:      e.Value,
+				Timestamp:  e.Timestamp,
+			})
+		}
+
+		name := lbls.Get(model.MetricNameLabel)
+		m, ok := reqMetadata[name]
+		if !ok && lookup != nil {
+			m, ok = lookup(lbls)
+		}
+		if ok {
+			v2.Metadata = Metadata{
+				Type:    metricTypeFromModel(m.Type),
+				HelpRef: symbols.Symbolize(m.Help),
+				UnitRef: symbols.Symbolize(m.Unit),
+			}
+		}
+
+		if ctLookup != nil {
+			v2.CreatedTimestamp = ctLookup(lbls)
+		}
+
+		out.Timeseries = append(out.Timeseries, v2)
+	}
+	out.Symbols = symbols.Symbols()
+	return out
+}
+
+func labelProtosToLabels(b *labels.ScratchBuilder, lbls []prompb.Label) labels.Labels {
+	b.Reset()
+	for _, l := range lbls {
+		b.Add(l.Name, l.Value)
+	}
+	b.Sort()
+	return b.Labels()
+}
+
+var modelToMetricType = map[textparse.MetricType]MetricType{
+	textparse.MetricTypeCounter:        MetricTypeCounter,
+	textparse.MetricTypeGauge:          MetricTypeGauge,
+	textparse.MetricTypeHistogram:      MetricTypeHistogram,
+	textparse.MetricTypeGaugeHistogram: MetricTypeGaugeHistogram,
+	textparse.MetricTypeSummary:        MetricTypeSummary,
+	textparse.MetricTypeInfo:           MetricTypeInfo,
+	textparse.MetricTypeStateset:       MetricTypeStateset,
+}
+
+func metricTypeFromModel(t textparse.MetricType) MetricType {
+	return modelToMetricType[t]
+}
+
+func metricTypeToModel(t MetricType) textparse.MetricType {
+	for mt, v2 := range modelToMetricType {
+		if v2 == t {
+			return mt
+		}
+	}
+	return textparse.MetricTypeUnknown
+}
@@ -0,0 +1,429 @@
+// Package writev2 implements the io.prometheus.write.v2.Request message of
+// the Prometheus Remote-Write 2.0 protocol.
+//
+// The messages are encoded and decoded by hand because the vendored
+// Prometheus version only ships the 1.0 protobuf definitions.
+package writev2
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/prompb"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	// ProtobufMessageV1 is the name of the protobuf message of Remote-Write
+	// 1.0 requests.
+	ProtobufMessageV1 = "prometheus.WriteRequest"
+	// ProtobufMessageV2 is the name of the protobuf message of Remote-Write
+	// 2.0 requests.
+	ProtobufMessageV2 = "io.prometheus.write.v2.Request"
+
+	// ContentType is the Content-Type header of Remote-Write 2.0 requests.
+	ContentType = "application/x-protobuf;proto=" + ProtobufMessageV2
+	// VersionHeaderValue is the X-Prometheus-Remote-Write-Version header of
+	// Remote-Write 2.0 requests.
+	VersionHeaderValue = "2.0.0"
+
+	// Response headers reporting how much data of a request was written.
+	SamplesWrittenHeader    = "X-Prometheus-Remote-Write-Samples-Written"
+	HistogramsWrittenHeader = "X-Prometheus-Remote-Write-Histograms-Written"
+	ExemplarsWrittenHeader  = "X-Prometheus-Remote-Write-Exemplars-Written"
+)
+
+// MetricType is the type of a metric. The values are the same as the ones of
+// prompb.MetricMetadata_MetricType.
+type MetricType int32
+
+// Metric types.
+const (
+	MetricTypeUnspecified MetricType = iota
+	MetricTypeCounter
+	MetricTypeGauge
+	MetricTypeHistogram
+	MetricTypeGaugeHistogram
+	MetricTypeSummary
+	MetricTypeInfo
+	MetricTypeStateset
+)
+
+// Request is a Remote-Write 2.0 request. All strings of the request are
+// stored once in Symbols and referenced by their index.
+type Request struct {
+	Symbols    []string
+	Timeseries []TimeSeries
+}
+
+// TimeSeries is a single series with its samples, histograms, exemplars and
+// metadata.
+type TimeSeries struct {
+	// LabelsRefs holds pairs of symbol references for label names and values.
+	LabelsRefs []uint32
+	Samples    []Sample
+	// Histograms use the same wire format as in Remote-Write 1.0.
+	Histograms       []prompb.Histogram
+	Exemplars        []Exemplar
+	Metadata         Metadata
+	CreatedTimestamp int64
+}
+
+// Sample is a float sample.
+type Sample struct {
+	Value     float64
+	Timestamp int64
+}
+
+// Exemplar is an exemplar with its own labels.
+type Exemplar struct {
+	LabelsRefs []uint32
+	Value      float64
+	Timestamp  int64
+}
+
+// Metadata describes the metric a series belongs to.
+type Metadata struct {
+	Type    MetricType
+	HelpRef uint32
+	UnitRef uint32
+}
+
+// Labels resolves refs against the symbols of the request.
+func (r *Request) Labels(refs []uint32) (labels.Labels, error) {
+	if len(refs)%2 != 0 {
+		return labels.EmptyLabels(), fmt.Errorf("odd number of label references: %d", len(refs))
+	}
+	b := labels.NewScratchBuilder(len(refs) / 2)
+	for i := 0; i < len(refs); i += 2 {
+		name, err := r.symbol(refs[i])
+		if err != nil {
+			return labels.EmptyLabels(), err
+		}
+		value, err := r.symbol(refs[i+1])
+		if err != nil {
+			return labels.EmptyLabels(), err
+		}
+		b.Add(name, value)
+	}
+	b.Sort()
+	return b.Labels(), nil
+}
+
+// Metadata resolves the metadata of a series against the symbols of the
+// request.
+func (r *Request) Metadata(m Metadata) (metadata.Metadata, error) {
+	help, err := r.symbol(m.HelpRef)
+	if err != nil {
+		return metadata.Metadata{}, err
+	}
+	unit, err := r.symbol(m.UnitRef)
+	if err != nil {
+		return metadata.Metadata{}, err
+	}
+	return metadata.Metadata{
+		Type: metricTypeToModel(m.Type),
+		Help: help,
+		Unit: unit,
+	}, nil
+}
+
+func (r *Request) symbol(ref uint32) (string, error) {
+	if int(ref) >= len(r.Symbols) {
+		return "", fmt.Errorf("symbol reference %d out of range, request has %d symbols", ref, len(r.Symbols))
+	}
+	return r.Symbols[ref], nil
+}
+
+// Marshal encodes the request into its protobuf wire format.
+func (r *Request) Marshal() []byte {
+	var b []byte
+	for _, s := range r.Symbols {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	for i := range r.Timeseries {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, r.Timeseries[i].marshal())
+	}
+	return b
+}
+
+func (ts *TimeSeries) marshal() []byte {
+	var b []byte
+	if len(ts.LabelsRefs) > 0 {
+		b = appendPackedRefs(b, 1, ts.LabelsRefs)
+	}
+	for _, s := range ts.Samples {
+		b = protowire.AppendTag(b, 2, protowire.BytesType)
+		b = protowire.AppendBytes(b, s.marshal())
+	}
+	for i := range ts.Histograms {
+		// Marshaling a prompb.Histogram only fails if its buffer is too small,
+		// which can't happen when the buffer is sized by Marshal itself.
+		hb, _ := ts.Histograms[i].Marshal()
+		b = protowire.AppendTag(b, 3, protowire.BytesType)
+		b = protowire.AppendBytes(b, hb)
+	}
+	for _, e := range ts.Exemplars {
+		b = protowire.AppendTag(b, 4, protowire.BytesType)
+		b = protowire.AppendBytes(b, e.marshal())
+	}
+	if ts.Metadata != (Metadata{}) {
+		b = protowire.AppendTag(b, 5, protowire.BytesType)
+		b = protowire.AppendBytes(b, ts.Metadata.marshal())
+	}
+	if ts.CreatedTimestamp != 0 {
+		b = protowire.AppendTag(b, 6, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(ts.CreatedTimestamp))
+	}
+	return b
+}
+
+func (s Sample) marshal() []byte {
+	var b []byte
+	if s.Value != 0 || math.Signbit(s.Value) {
+		b = protowire.AppendTag(b, 1, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(s.Value))
+	}
+	if s.Timestamp != 0 {
+		b = protowire.AppendTag(b, 2, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(s.Timestamp))
+	}
+	return b
+}
+
+func (e *Exemplar) marshal() []byte {
+	var b []byte
+	if len(e.LabelsRefs) > 0 {
+		b = appendPackedRefs(b, 1, e.LabelsRefs)
+	}
+	if e.Value != 0 || math.Signbit(e.Value) {
+		b = protowire.AppendTag(b, 2, protowire.Fixed64Type)
+		b = protowire.AppendFixed64(b, math.Float64bits(e.Value))
+	}
+	if e.Timestamp != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(e.Timestamp))
+	}
+	return b
+}
+
+func (m Metadata) marshal() []byte {
+	var b []byte
+	if m.Type != MetricTypeUnspecified {
+		b = protowire.AppendTag(b, 1, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.Type))
+	}
+	if m.HelpRef != 0 {
+		b = protowire.AppendTag(b, 3, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.HelpRef))
+	}
+	if m.UnitRef != 0 {
+		b = protowire.AppendTag(b, 4, protowire.VarintType)
+		b = protowire.AppendVarint(b, uint64(m.UnitRef))
+	}
+	return b
+}
+
+func appendPackedRefs(b []byte, num protowire.Number, refs []uint32) []byte {
+	var packed []byte
+	for _, ref := range refs {
+		packed = protowire.AppendVarint(packed, uint64(ref))
+	}
+	b = protowire.AppendTag(b, num, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+// Unmarshal decodes a request from its protobuf wire format. Unknown fields
+// are skipped.
+func (r *Request) Unmarshal(b []byte) error {
+	*r = Request{}
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 4 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeString(b)
+			if n < 0 {
+				return n, nil
+			}
+			r.Symbols = append(r.Symbols, v)
+			return n, nil
+		case num == 5 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var ts TimeSeries
+			if err := ts.unmarshal(v); err != nil {
+				return 0, fmt.Errorf("invalid timeseries: %w", err)
+			}
+			r.Timeseries = append(r.Timeseries, ts)
+			return n, nil
+		}
+		return skipField(num, typ, b)
+	})
+}
+
+func (ts *TimeSeries) unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1:
+			return consumeRefs(typ, b, &ts.LabelsRefs)
+		case num == 2 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var s Sample
+			if err := s.unmarshal(v); err != nil {
+				return 0, fmt.Errorf("invalid sample: %w", err)
+			}
+			ts.Samples = append(ts.Samples, s)
+			return n, nil
+		case num == 3 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var h prompb.Histogram
+			if err := h.Unmarshal(v); err != nil {
+				return 0, fmt.Errorf("invalid histogram: %w", err)
+			}
+			ts.Histograms = append(ts.Histograms, h)
+			return n, nil
+		case num == 4 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			var e Exemplar
+			if err := e.unmarshal(v); err != nil {
+				return 0, fmt.Errorf("invalid exemplar: %w", err)
+			}
+			ts.Exemplars = append(ts.Exemplars, e)
+			return n, nil
+		case num == 5 && typ == protowire.BytesType:
+			v, n := protowire.ConsumeBytes(b)
+			if n < 0 {
+				return n, nil
+			}
+			if err := ts.Metadata.unmarshal(v); err != nil {
+				return 0, fmt.Errorf("invalid metadata: %w", err)
+			}
+			return n, nil
+		case num == 6 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			ts.CreatedTimestamp = int64(v)
+			return n, nil
+		}
+		return skipField(num, typ, b)
+	})
+}
+
+func (s *Sample) unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			s.Value = math.Float64frombits(v)
+			return n, nil
+		case num == 2 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			s.Timestamp = int64(v)
+			return n, nil
+		}
+		return skipField(num, typ, b)
+	})
+}
+
+func (e *Exemplar) unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		switch {
+		case num == 1:
+			return consumeRefs(typ, b, &e.LabelsRefs)
+		case num == 2 && typ == protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(b)
+			e.Value = math.Float64frombits(v)
+			return n, nil
+		case num == 3 && typ == protowire.VarintType:
+			v, n := protowire.ConsumeVarint(b)
+			e.Timestamp = int64(v)
+			return n, nil
+		}
+		return skipField(num, typ, b)
+	})
+}
+
+func (m *Metadata) unmarshal(b []byte) error {
+	return consumeFields(b, func(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+		if typ != protowire.VarintType {
+			return skipField(num, typ, b)
+		}
+		v, n := protowire.ConsumeVarint(b)
+		switch num {
+		case 1:
+			m.Type = MetricType(v)
+		case 3:
+			m.HelpRef = uint32(v)
+		case 4:
+			m.UnitRef = uint32(v)
+		}
+		return n, nil
+	})
+}
+
+// consumeFields calls fn for every field in b. fn returns the number of bytes
+// of the field value it consumed, or a negative protowire error code.
+func consumeFields(b []byte, fn func(num protowire.Number, typ protowire.Type, b []byte) (int, error)) error {
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+
+		n, err := fn(num, typ, b)
+		if err != nil {
+			return err
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		b = b[n:]
+	}
+	return nil
+}
+
+func skipField(num protowire.Number, typ protowire.Type, b []byte) (int, error) {
+	return protowire.ConsumeFieldValue(num, typ, b), nil
+}
+
+// consumeRefs decodes a repeated uint32 field in either its packed or
+// unpacked encoding.
+func consumeRefs(typ protowire.Type, b []byte, refs *[]uint32) (int, error) {
+	switch typ {
+	case protowire.VarintType:
+		v, n := protowire.ConsumeVarint(b)
+		if n >= 0 {
+			*refs = append(*refs, uint32(v))
+		}
+		return n, nil
+	case protowire.BytesType:
+		packed, n := protowire.ConsumeBytes(b)
+		if n < 0 {
+			return n, nil
+		}
+		for len(packed) > 0 {
+			v, m := protowire.ConsumeVarint(packed)
+			if m < 0 {
+				return m, nil
+			}
+			*refs = append(*refs, uint32(v))
+			packed = packed[m:]
+		}
+		return n, nil
+	}
+	return 0, fmt.Errorf("unexpected wire type %d for label references", typ)
+}
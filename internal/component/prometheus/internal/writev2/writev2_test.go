@@ -0,0 +1,91 @@
+package writev2
+
+import (
+	"testing"
+
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromV1RoundTrip(t *testing.T) {
+	v1 := &prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{{Value: 1, Timestamp: 1000}, {Value: -2.5, Timestamp: 2000}},
+			Exemplars: []prompb.Exemplar{{
+				Labels:    []prompb.Label{{Name: "trace_id", Value: "abc"}},
+				Value:     1,
+				Timestamp: 1000,
+			}},
+		}, {
+			Labels: []prompb.Label{{Name: "__name__", Value: "request_duration_seconds"}, {Name: "job", Value: "api"}},
+			Histograms: []prompb.Histogram{{
+				Count:          &prompb.Histogram_CountInt{CountInt: 5},
+				Sum:            12.5,
+				Schema:         1,
+				ZeroCount:      &prompb.Histogram_ZeroCountInt{ZeroCountInt: 1},
+				PositiveSpans:  []prompb.BucketSpan{{Offset: 0, Length: 2}},
+				PositiveDeltas: []int64{2, 0},
+				Timestamp:      3000,
+			}},
+		}, {
+			Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+			Samples: []prompb.Sample{{Value: 0, Timestamp: 4000}},
+		}},
+		Metadata: []prompb.MetricMetadata{{
+			Type:             prompb.MetricMetadata_COUNTER,
+			MetricFamilyName: "http_requests_total",
+			Help:             "Total requests.",
+		}},
+	}
+	lookup := func(series labels.Labels) (metadata.Metadata, bool) {
+		if series.Get("__name__") == "request_duration_seconds" {
+			return metadata.Metadata{Type: textparse.MetricTypeHistogram, Help: "Request latency.", Unit: "seconds"}, true
+		}
+		return metadata.Metadata{}, false
+	}
+
+	var decoded Request
+	require.NoError(t, decoded.Unmarshal(FromV1(v1, lookup, nil).Marshal()))
+	require.Equal(t, "", decoded.Symbols[0])
+	require.Len(t, decoded.Timeseries, 3)
+
+	ts := decoded.Timeseries[0]
+	lbls, err := decoded.Labels(ts.LabelsRefs)
+	require.NoError(t, err)
+	require.Equal(t, labels.FromStrings("__name__", "http_requests_total", "job", "api"), lbls)
+	require.Equal(t, []Sample{{Value: 1, Timestamp: 1000}, {Value: -2.5, Timestamp: 2000}}, ts.Samples)
+	require.Len(t, ts.Exemplars, 1)
+	exLbls, err := decoded.Labels(ts.Exemplars[0].LabelsRefs)
+	require.NoError(t, err)
+	require.Equal(t, labels.FromStrings("trace_id", "abc"), exLbls)
+	md, err := decoded.Metadata(ts.Metadata)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Total requests."}, md)
+
+	ts = decoded.Timeseries[1]
+	require.Equal(t, v1.Timeseries[1].Histograms, ts.Histograms)
+	md, err = decoded.Metadata(ts.Metadata)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{Type: textparse.MetricTypeHistogram, Help: "Request latency.", Unit: "seconds"}, md)
+
+	ts = decoded.Timeseries[2]
+	require.Equal(t, []Sample{{Value: 0, Timestamp: 4000}}, ts.Samples)
+	md, err = decoded.Metadata(ts.Metadata)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{Type: textparse.MetricTypeUnknown}, md)
+}
+
+func TestUnmarshal_Invalid(t *testing.T) {
+	var req Request
+	require.Error(t, req.Unmarshal([]byte{0x2a, 0x05, 0x01}))
+
+	req = Request{Symbols: []string{""}}
+	_, err := req.Labels([]uint32{0, 1})
+	require.EqualError(t, err, "symbol reference 1 out of range, request has 1 symbols")
+	_, err = req.Labels([]uint32{0})
+	require.EqualError(t, err, "odd number of label references: 1")
+}
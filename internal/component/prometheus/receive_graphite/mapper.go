@@ -0,0 +1,263 @@
+package receive_graphite
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+)
+
+// parseLine parses a single line of the Graphite plaintext protocol:
+//
+//	<metric path>[;<tag>=<value>...] <value> [<timestamp>]
+//
+// If the timestamp is missing or negative, now is used instead.
+func parseLine(line string, now time.Time) (path string, tags map[string]string, v float64, t int64, err error) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 || len(fields) > 3 {
+		return "", nil, 0, 0, fmt.Errorf("expected 2 or 3 fields, got %d", len(fields))
+	}
+
+	path = fields[0]
+	if idx := strings.IndexByte(path, ';'); idx >= 0 {
+		tags = make(map[string]string)
+		for _, tag := range strings.Split(path[idx+1:], ";") {
+			name, value, found := strings.Cut(tag, "=")
+			if !found || name == "" || value == "" {
+				return "", nil, 0, 0, fmt.Errorf("invalid tag %q", tag)
+			}
+			tags[name] = value
+		}
+		path = path[:idx]
+	}
+	if path == "" {
+		return "", nil, 0, 0, fmt.Errorf("empty metric path")
+	}
+
+	v, err = strconv.ParseFloat(fields[1], 64)
+	if err != nil {
+		return "", nil, 0, 0, fmt.Errorf("invalid value %q: %w", fields[1], err)
+	}
+
+	t = now.UnixMilli()
+	if len(fields) == 3 {
+		ts, err := strconv.ParseFloat(fields[2], 64)
+		if err != nil {
+			return "", nil, 0, 0, fmt.Errorf("invalid timestamp %q: %w", fields[2], err)
+		}
+		if ts >= 0 {
+			t = int64(math.Round(ts * 1000))
+		}
+	}
+	return path, tags, v, t, nil
+}
+
+// mapper converts Graphite metric paths into Prometheus metric names and
+// labels using the configured mapping rules and templates.
+type mapper struct {
+	mappings      []compiledMapping
+	templates     []compiledTemplate
+	dropUnmatched bool
+}
+
+type compiledMapping struct {
+	match  []string
+	name   string
+	labels map[string]string
+}
+
+type compiledTemplate struct {
+	filter []string
+	parts  []string
+	labels map[string]string
+}
+
+func newMapper(args Arguments) *mapper {
+	m := &mapper{dropUnmatched: args.DropUnmatched}
+	for _, rule := range args.Mappings {
+		m.mappings = append(m.mappings, compiledMapping{
+			match:  strings.Split(rule.Match, "."),
+			name:   rule.Name,
+			labels: rule.Labels,
+		})
+	}
+	for _, tmpl := range args.Templates {
+		ct := compiledTemplate{
+			parts:  strings.Split(tmpl.Template, "."),
+			labels: tmpl.Labels,
+		}
+		if tmpl.Filter != "" {
+			ct.filter = strings.Split(tmpl.Filter, ".")
+		}
+		m.templates = append(m.templates, ct)
+	}
+	return m
+}
+
+// Map returns the labels, including the metric name, for the Graphite path
+// and tags. It returns false if the path should be dropped.
+func (m *mapper) Map(path string, tags map[string]string) (labels.Labels, bool) {
+	segments := strings.Split(path, ".")
+
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	for name, value := range tags {
+		lb.Set(sanitizeLabelName(name), value)
+	}
+
+	name, matched := m.apply(segments, lb)
+	if !matched {
+		if m.dropUnmatched {
+			return labels.EmptyLabels(), false
+		}
+		name = path
+	}
+
+	name = sanitizeMetricName(name)
+	if name == "" {
+		return labels.EmptyLabels(), false
+	}
+	lb.Set(model.MetricNameLabel, name)
+	return lb.Labels(), true
+}
+
+// apply runs the first matching mapping rule or template against segments,
+// setting any produced labels on lb. It returns the metric name and whether
+// a rule matched.
+func (m *mapper) apply(segments []string, lb *labels.Builder) (string, bool) {
+	for _, rule := range m.mappings {
+		captures, ok := globMatch(rule.match, segments)
+		if !ok {
+			continue
+		}
+		for name, value := range rule.labels {
+			lb.Set(name, expandCaptures(value, captures))
+		}
+		return expandCaptures(rule.name, captures), true
+	}
+
+	for _, tmpl := range m.templates {
+		// Filters only need to match the leading segments of the path.
+		if len(tmpl.filter) > len(segments) {
+			continue
+		}
+		if _, ok := globMatch(tmpl.filter, segments[:len(tmpl.filter)]); !ok {
+			continue
+		}
+		name, ok := tmpl.apply(segments, lb)
+		if !ok {
+			continue
+		}
+		for name, value := range tmpl.labels {
+			lb.Set(name, value)
+		}
+		return name, true
+	}
+	return "", false
+}
+
+// apply uses the template to split segments into a metric name and labels.
+// Template parts named "measurement" are joined to build the metric name, a
+// trailing "measurement*" consumes all remaining segments, empty parts are
+// skipped and any other part becomes a label of that name.
+func (t compiledTemplate) apply(segments []string, lb *labels.Builder) (string, bool) {
+	var (
+		measurement []string
+		tagValues   = make(map[string][]string)
+	)
+	for i, part := range t.parts {
+		if i >= len(segments) {
+			break
+		}
+		switch {
+		case part == "":
+			continue
+		case part == "measurement":
+			measurement = append(measurement, segments[i])
+		case part == "measurement*":
+			measurement = append(measurement, segments[i:]...)
+			return t.finish(measurement, tagValues, lb)
+		default:
+			tagValues[part] = append(tagValues[part], segments[i])
+		}
+	}
+	if len(segments) > len(t.parts) {
+		// The template must account for every segment unless it ends with a
+		// wildcard measurement.
+		return "", false
+	}
+	return t.finish(measurement, tagValues, lb)
+}
+
+func (t compiledTemplate) finish(measurement []string, tagValues map[string][]string, lb *labels.Builder) (string, bool) {
+	if len(measurement) == 0 {
+		return "", false
+	}
+	for name, values := range tagValues {
+		lb.Set(sanitizeLabelName(name), strings.Join(values, "."))
+	}
+	return strings.Join(measurement, "_"), true
+}
+
+// globMatch matches segments against a pattern where "*" matches exactly one
+// segment. The segments matched by each "*" are returned in order.
+func globMatch(pattern, segments []string) ([]string, bool) {
+	if len(pattern) != len(segments) {
+		return nil, false
+	}
+	var captures []string
+	for i, p := range pattern {
+		switch {
+		case p == "*":
+			captures = append(captures, segments[i])
+		case strings.Contains(p, "*"):
+			prefix, suffix, _ := strings.Cut(p, "*")
+			s := segments[i]
+			if len(s) < len(prefix)+len(suffix) || !strings.HasPrefix(s, prefix) || !strings.HasSuffix(s, suffix) {
+				return nil, false
+			}
+			captures = append(captures, s[len(prefix):len(s)-len(suffix)])
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	return captures, true
+}
+
+// expandCaptures replaces $1..$n in s with the corresponding capture.
+func expandCaptures(s string, captures []string) string {
+	for i := len(captures); i >= 1; i-- {
+		s = strings.ReplaceAll(s, "$"+strconv.Itoa(i), captures[i-1])
+	}
+	return s
+}
+
+func sanitizeMetricName(name string) string {
+	return sanitize(name, func(i int, r rune) bool {
+		return r == ':' || isLabelRune(i, r)
+	})
+}
+
+func sanitizeLabelName(name string) string {
+	return sanitize(name, isLabelRune)
+}
+
+func isLabelRune(i int, r rune) bool {
+	return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' || (i > 0 && r >= '0' && r <= '9')
+}
+
+func sanitize(s string, valid func(int, rune) bool) string {
+	var sb strings.Builder
+	sb.Grow(len(s))
+	for i, r := range s {
+		if valid(i, r) {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
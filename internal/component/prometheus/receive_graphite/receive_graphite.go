@@ -0,0 +1,263 @@
+package receive_graphite
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/component/prometheus/internal/linelistener"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/labelstore"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.receive_graphite",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// prometheus.receive_graphite component.
+type Arguments struct {
+	ListenTCP     string               `river:"listen_tcp,attr,optional"`
+	ListenUDP     string               `river:"listen_udp,attr,optional"`
+	ReadTimeout   time.Duration        `river:"read_timeout,attr,optional"`
+	MaxLineLength int                  `river:"max_line_length,attr,optional"`
+	DropUnmatched bool                 `river:"drop_unmatched,attr,optional"`
+	Mappings      []Mapping            `river:"mapping,block,optional"`
+	Templates     []Template           `river:"template,block,optional"`
+	ForwardTo     []storage.Appendable `river:"forward_to,attr"`
+}
+
+// Mapping maps Graphite paths matching a glob into a metric name and labels.
+// Each "*" in Match captures one path segment, which can be referenced as $1,
+// $2, and so on in Name and Labels.
+type Mapping struct {
+	Match  string            `river:"match,attr"`
+	Name   string            `river:"name,attr"`
+	Labels map[string]string `river:"labels,attr,optional"`
+}
+
+// Template splits Graphite paths into a metric name and labels based on the
+// position of each path segment.
+type Template struct {
+	Filter   string            `river:"filter,attr,optional"`
+	Template string            `river:"template,attr"`
+	Labels   map[string]string `river:"labels,attr,optional"`
+}
+
+// DefaultArguments holds the default arguments for the
+// prometheus.receive_graphite component.
+var DefaultArguments = Arguments{
+	ListenTCP:     "0.0.0.0:2003",
+	ReadTimeout:   5 * time.Minute,
+	MaxLineLength: 4096,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	if args.ListenTCP == "" && args.ListenUDP == "" {
+		return fmt.Errorf("at least one of listen_tcp or listen_udp must be set")
+	}
+	if args.MaxLineLength <= 0 {
+		return fmt.Errorf("max_line_length must be greater than 0")
+	}
+	for i, m := range args.Mappings {
+		if m.Match == "" || m.Name == "" {
+			return fmt.Errorf("mapping block %d: match and name must not be empty", i)
+		}
+	}
+	for i, t := range args.Templates {
+		if !strings.Contains(t.Template, "measurement") {
+			return fmt.Errorf("template block %d: template %q must contain at least one measurement part", i, t.Template)
+		}
+	}
+	return nil
+}
+
+// Component implements the prometheus.receive_graphite component.
+type Component struct {
+	opts    component.Options
+	fanout  *agentprom.Fanout
+	metrics *metrics
+
+	mut      sync.Mutex
+	args     Arguments
+	listener *linelistener.Listener
+
+	// mapperMut is separate from mut so that in-flight lines can still be
+	// mapped while the listener is being stopped during an update.
+	mapperMut sync.RWMutex
+	mapper    *mapper
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new prometheus.receive_graphite component.
+func New(opts component.Options, args Arguments) (*Component, error) {
+	service, err := opts.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	c := &Component{
+		opts:    opts,
+		fanout:  agentprom.NewFanout(args.ForwardTo, opts.ID, opts.Registerer, ls),
+		metrics: newMetrics(opts.Registerer),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		if c.listener != nil {
+			c.listener.Stop()
+			c.listener = nil
+		}
+	}()
+
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.mapperMut.Lock()
+	c.mapper = newMapper(newArgs)
+	c.mapperMut.Unlock()
+
+	listenerNeedsUpdate := c.listener == nil ||
+		c.args.ListenTCP != newArgs.ListenTCP ||
+		c.args.ListenUDP != newArgs.ListenUDP ||
+		c.args.ReadTimeout != newArgs.ReadTimeout ||
+		c.args.MaxLineLength != newArgs.MaxLineLength
+	if listenerNeedsUpdate {
+		if c.listener != nil {
+			c.listener.Stop()
+			c.listener = nil
+		}
+		l, err := linelistener.Start(c.opts.Logger, linelistener.Options{
+			TCPAddress:    newArgs.ListenTCP,
+			UDPAddress:    newArgs.ListenUDP,
+			ReadTimeout:   newArgs.ReadTimeout,
+			MaxLineLength: newArgs.MaxLineLength,
+		}, c.handleLines, c.handleTooLong)
+		if err != nil {
+			return err
+		}
+		c.listener = l
+	}
+
+	c.args = newArgs
+	return nil
+}
+
+// handleLines converts a batch of Graphite lines into samples and appends
+// them to the downstream components.
+func (c *Component) handleLines(lines []string) {
+	c.mapperMut.RLock()
+	m := c.mapper
+	c.mapperMut.RUnlock()
+
+	var (
+		now      = time.Now()
+		app      = c.fanout.Appender(context.Background())
+		appended int
+	)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		c.metrics.linesReceived.Inc()
+
+		path, tags, v, t, err := parseLine(line, now)
+		if err != nil {
+			c.metrics.linesInvalid.Inc()
+			level.Debug(c.opts.Logger).Log("msg", "failed to parse graphite line", "line", line, "err", err)
+			continue
+		}
+		lbls, ok := m.Map(path, tags)
+		if !ok {
+			c.metrics.linesDropped.Inc()
+			continue
+		}
+		if _, err := app.Append(0, lbls, t, v); err != nil {
+			level.Warn(c.opts.Logger).Log("msg", "failed to append sample", "err", err)
+			continue
+		}
+		appended++
+	}
+
+	if appended == 0 {
+		_ = app.Rollback()
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to commit samples", "err", err)
+	}
+}
+
+// handleTooLong counts a line longer than max_line_length, which is discarded
+// without being buffered.
+func (c *Component) handleTooLong() {
+	c.metrics.linesReceived.Inc()
+	c.metrics.linesInvalid.Inc()
+	level.Debug(c.opts.Logger).Log("msg", "discarding graphite line longer than max_line_length")
+}
+
+type metrics struct {
+	linesReceived prometheus.Counter
+	linesInvalid  prometheus.Counter
+	linesDropped  prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		linesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_receive_graphite_lines_total",
+			Help: "Total number of Graphite lines received.",
+		}),
+		linesInvalid: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_receive_graphite_lines_invalid_total",
+			Help: "Total number of Graphite lines which could not be parsed or were longer than max_line_length.",
+		}),
+		linesDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_receive_graphite_lines_dropped_total",
+			Help: "Total number of Graphite lines dropped because no mapping matched.",
+		}),
+	}
+	reg.MustRegister(m.linesReceived, m.linesInvalid, m.linesDropped)
+	return m
+}
@@ -0,0 +1,268 @@
+package receive_graphite
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/service/labelstore"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRiverConfig(t *testing.T) {
+	cfg := `
+		listen_tcp = "127.0.0.1:2003"
+		listen_udp = "127.0.0.1:2003"
+		forward_to = []
+
+		mapping {
+			match  = "servers.*.cpu.*"
+			name   = "server_cpu_usage"
+			labels = { host = "$1", cpu = "$2" }
+		}
+
+		template {
+			filter   = "app.*.*.*"
+			template = ".service.measurement*"
+			labels   = { source = "app" }
+		}
+	`
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(cfg), &args))
+	require.Len(t, args.Mappings, 1)
+	require.Len(t, args.Templates, 1)
+	require.Equal(t, 4096, args.MaxLineLength)
+}
+
+func TestRiverConfig_Invalid(t *testing.T) {
+	cfg := `
+		listen_tcp = ""
+		forward_to = []
+	`
+	var args Arguments
+	require.ErrorContains(t, river.Unmarshal([]byte(cfg), &args), "at least one of listen_tcp or listen_udp must be set")
+}
+
+func TestParseLine(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		line     string
+		path     string
+		tags     map[string]string
+		value    float64
+		ts       int64
+		errMatch string
+	}{
+		{line: "foo.bar 12.5 1700000000", path: "foo.bar", value: 12.5, ts: 1700000000000},
+		{line: "foo.bar 1", path: "foo.bar", value: 1, ts: now.UnixMilli()},
+		{line: "foo.bar 1 -1", path: "foo.bar", value: 1, ts: now.UnixMilli()},
+		{line: "foo;env=prod;dc=eu 3 10", path: "foo", tags: map[string]string{"env": "prod", "dc": "eu"}, value: 3, ts: 10000},
+		{line: "foo.bar", errMatch: "expected 2 or 3 fields"},
+		{line: "foo.bar abc", errMatch: "invalid value"},
+		{line: "foo;env 1", errMatch: "invalid tag"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.line, func(t *testing.T) {
+			path, tags, v, ts, err := parseLine(tc.line, now)
+			if tc.errMatch != "" {
+				require.ErrorContains(t, err, tc.errMatch)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.path, path)
+			require.Equal(t, tc.tags, tags)
+			require.Equal(t, tc.value, v)
+			require.Equal(t, tc.ts, ts)
+		})
+	}
+}
+
+func TestMapper(t *testing.T) {
+	m := newMapper(Arguments{
+		Mappings: []Mapping{{
+			Match:  "servers.*.cpu.*",
+			Name:   "server_cpu_$2",
+			Labels: map[string]string{"host": "$1"},
+		}},
+		Templates: []Template{{
+			Filter:   "app",
+			Template: ".service.measurement*",
+			Labels:   map[string]string{"source": "app"},
+		}, {
+			Template: "region.host.measurement",
+		}},
+	})
+
+	tests := []struct {
+		path     string
+		tags     map[string]string
+		expected labels.Labels
+	}{
+		{
+			path:     "servers.web01.cpu.idle",
+			expected: labels.FromStrings("__name__", "server_cpu_idle", "host", "web01"),
+		},
+		{
+			path:     "app.checkout.requests.count",
+			expected: labels.FromStrings("__name__", "requests_count", "service", "checkout", "source", "app"),
+		},
+		{
+			path:     "eu-west.db01.load",
+			tags:     map[string]string{"env": "prod"},
+			expected: labels.FromStrings("__name__", "load", "env", "prod", "host", "db01", "region", "eu-west"),
+		},
+		{
+			path:     "unmatched.metric-name.total.count",
+			expected: labels.FromStrings("__name__", "unmatched_metric_name_total_count"),
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.path, func(t *testing.T) {
+			actual, ok := m.Map(tc.path, tc.tags)
+			require.True(t, ok)
+			require.Equal(t, tc.expected, actual)
+		})
+	}
+
+	t.Run("drop unmatched", func(t *testing.T) {
+		m := newMapper(Arguments{DropUnmatched: true})
+		_, ok := m.Map("foo.bar", nil)
+		require.False(t, ok)
+	})
+}
+
+func TestForwardsMetrics(t *testing.T) {
+	actualSamples := make(chan testSample, 100)
+
+	args := DefaultArguments
+	args.ListenTCP = "127.0.0.1:0"
+	args.ListenUDP = "127.0.0.1:0"
+	args.ForwardTo = testAppendable(actualSamples)
+
+	c, err := New(testOptions(t), args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	tcpConn, err := net.Dial("tcp", c.listener.TCPAddr().String())
+	require.NoError(t, err)
+	defer tcpConn.Close()
+	_, err = fmt.Fprint(tcpConn, "foo.bar 1 100\nfoo.baz 2 200\n")
+	require.NoError(t, err)
+
+	udpConn, err := net.Dial("udp", c.listener.UDPAddr().String())
+	require.NoError(t, err)
+	defer udpConn.Close()
+	_, err = fmt.Fprint(udpConn, "foo.qux 3 300\n")
+	require.NoError(t, err)
+
+	expected := map[string]testSample{
+		"foo_bar": {ts: 100_000, val: 1, l: labels.FromStrings("__name__", "foo_bar")},
+		"foo_baz": {ts: 200_000, val: 2, l: labels.FromStrings("__name__", "foo_baz")},
+		"foo_qux": {ts: 300_000, val: 3, l: labels.FromStrings("__name__", "foo_qux")},
+	}
+	for len(expected) > 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for samples, still waiting for %v", expected)
+		case s := <-actualSamples:
+			name := s.l.Get("__name__")
+			require.Equal(t, expected[name], s)
+			delete(expected, name)
+		}
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	actualSamples := make(chan testSample, 100)
+
+	args := DefaultArguments
+	args.ListenTCP = "127.0.0.1:0"
+	args.MaxLineLength = 20
+	args.ForwardTo = testAppendable(actualSamples)
+
+	c, err := New(testOptions(t), args)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, c.Run(ctx))
+	}()
+
+	tcpConn, err := net.Dial("tcp", c.listener.TCPAddr().String())
+	require.NoError(t, err)
+	defer tcpConn.Close()
+
+	// The long line is written in parts so that the listener reads it across
+	// several reads, and is discarded up to its newline.
+	for i := 0; i < 10; i++ {
+		_, err = fmt.Fprint(tcpConn, strings.Repeat("x", 1000))
+		require.NoError(t, err)
+	}
+	_, err = fmt.Fprint(tcpConn, "\nfoo.bar 1 100\n")
+	require.NoError(t, err)
+
+	select {
+	case <-ctx.Done():
+		t.Fatalf("timed out waiting for samples")
+	case s := <-actualSamples:
+		require.Equal(t, testSample{ts: 100_000, val: 1, l: labels.FromStrings("__name__", "foo_bar")}, s)
+	}
+	require.Equal(t, 1.0, testutil.ToFloat64(c.metrics.linesInvalid))
+	require.Equal(t, 2.0, testutil.ToFloat64(c.metrics.linesReceived))
+}
+
+type testSample struct {
+	ts  int64
+	val float64
+	l   labels.Labels
+}
+
+func testAppendable(actualSamples chan testSample) []storage.Appendable {
+	hookFn := func(
+		ref storage.SeriesRef,
+		l labels.Labels,
+		ts int64,
+		val float64,
+		next storage.Appender,
+	) (storage.SeriesRef, error) {
+
+		actualSamples <- testSample{ts: ts, val: val, l: l}
+		return ref, nil
+	}
+
+	ls := labelstore.New(nil, prometheus.DefaultRegisterer)
+	return []storage.Appendable{agentprom.NewInterceptor(
+		nil,
+		ls,
+		agentprom.WithAppendHook(
+			hookFn))}
+}
+
+func testOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:         "prometheus.receive_graphite.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil, prometheus.DefaultRegisterer), nil
+		},
+	}
+}
@@ -67,8 +67,12 @@ func New(opts component.Options, args Arguments) (*Component, error) {
 	opts.Registerer.MustRegister(uncheckedCollector)
 
 	c := &Component{
-		opts:               opts,
-		handler:            remote.NewWriteHandler(opts.Logger, opts.Registerer, fanout),
+		opts: opts,
+		handler: &writeHandler{
+			logger:     opts.Logger,
+			appendable: fanout,
+			v1:         remote.NewWriteHandler(opts.Logger, opts.Registerer, fanout),
+		},
 		fanout:             fanout,
 		uncheckedCollector: uncheckedCollector,
 	}
@@ -1,10 +1,14 @@
 package receive_http
 
 import (
+	"bytes"
 	"context"
 	"fmt"
+	"io"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
+	"sync"
 	"testing"
 	"time"
 
@@ -12,13 +16,19 @@ import (
 	"github.com/grafana/agent/internal/component"
 	fnet "github.com/grafana/agent/internal/component/common/net"
 	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
+	"github.com/grafana/agent/internal/component/prometheus/remotewrite"
+	"github.com/grafana/agent/internal/flow/componenttest"
 	"github.com/grafana/agent/internal/service/labelstore"
 	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
 	"github.com/phayes/freeport"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/common/config"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/storage/remote"
@@ -78,6 +88,194 @@ func TestForwardsMetrics(t *testing.T) {
 	verifyExpectations(t, input, expected, actualSamples, args, ctx)
 }
 
+func TestForwardsMetrics_RemoteWriteV2(t *testing.T) {
+	timestamp := time.Now().Add(time.Second).UnixMilli()
+
+	var (
+		mut      sync.Mutex
+		samples  []testSample
+		received = map[string]metadata.Metadata{}
+	)
+	ls := labelstore.New(nil, prometheus.DefaultRegisterer)
+	appendable := agentprom.NewInterceptor(
+		nil,
+		ls,
+		agentprom.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, ts int64, val float64, _ storage.Appender) (storage.SeriesRef, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			samples = append(samples, testSample{ts: ts, val: val, l: l})
+			return ref, nil
+		}),
+		agentprom.WithMetadataHook(func(ref storage.SeriesRef, l labels.Labels, m metadata.Metadata, _ storage.Appender) (storage.SeriesRef, error) {
+			mut.Lock()
+			defer mut.Unlock()
+			received[l.Get(model.MetricNameLabel)] = m
+			return ref, nil
+		}),
+	)
+
+	port := getFreePort(t)
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    port,
+			},
+			GRPC: testGRPCConfig(t),
+		},
+		ForwardTo: []storage.Appendable{appendable},
+	}
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+	waitForServerToBeReady(t, args)
+
+	req := writev2.FromV1(&prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "requests_total"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{{Timestamp: timestamp, Value: 12}, {Timestamp: timestamp + 1, Value: 24}},
+		}},
+		Metadata: []prompb.MetricMetadata{{
+			Type:             prompb.MetricMetadata_COUNTER,
+			MetricFamilyName: "requests_total",
+			Help:             "Total requests.",
+		}},
+	}, nil, nil)
+	endpoint := fmt.Sprintf("http://localhost:%d/api/v1/metrics/write", port)
+
+	post := func(contentType string) *http.Response {
+		httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(snappy.Encode(nil, req.Marshal())))
+		require.NoError(t, err)
+		httpReq.Header.Set("Content-Encoding", "snappy")
+		httpReq.Header.Set("Content-Type", contentType)
+		resp, err := http.DefaultClient.Do(httpReq)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := post("application/x-protobuf;proto=io.prometheus.write.v2.Request")
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+	require.Equal(t, "2", resp.Header.Get(writev2.SamplesWrittenHeader))
+	require.Equal(t, "0", resp.Header.Get(writev2.HistogramsWrittenHeader))
+
+	mut.Lock()
+	require.Equal(t, []testSample{
+		{ts: timestamp, val: 12, l: labels.FromStrings("__name__", "requests_total", "job", "api")},
+		{ts: timestamp + 1, val: 24, l: labels.FromStrings("__name__", "requests_total", "job", "api")},
+	}, samples)
+	require.Equal(t, map[string]metadata.Metadata{
+		"requests_total": {Type: textparse.MetricTypeCounter, Help: "Total requests."},
+	}, received)
+	mut.Unlock()
+
+	resp = post("application/x-protobuf;proto=io.prometheus.write.v3.Request")
+	require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+}
+
+// TestRemoteWriteV2Relay relays Remote-Write 2.0 requests through
+// prometheus.receive_http and prometheus.remote_write, and checks that the
+// created timestamps and metadata of series survive.
+func TestRemoteWriteV2Relay(t *testing.T) {
+	timestamp := time.Now().Add(time.Second).UnixMilli()
+	createdTimestamp := timestamp - time.Hour.Milliseconds()
+
+	received := make(chan *writev2.Request, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != writev2.ContentType {
+			http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			return
+		}
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		body, err = snappy.Decode(nil, body)
+		require.NoError(t, err)
+		var req writev2.Request
+		require.NoError(t, req.Unmarshal(body))
+		received <- &req
+	}))
+	defer srv.Close()
+
+	var rwArgs remotewrite.Arguments
+	require.NoError(t, river.Unmarshal([]byte(fmt.Sprintf(`
+		endpoint {
+			url              = "%s/api/v1/write"
+			protobuf_message = "io.prometheus.write.v2.Request"
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+		}
+	`, srv.URL)), &rwArgs))
+	rw, err := componenttest.NewControllerFromID(util.TestLogger(t), "prometheus.remote_write")
+	require.NoError(t, err)
+	go func() {
+		require.NoError(t, rw.Run(componenttest.TestContext(t), rwArgs))
+	}()
+	require.NoError(t, rw.WaitRunning(5*time.Second))
+
+	port := getFreePort(t)
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    port,
+			},
+			GRPC: testGRPCConfig(t),
+		},
+		ForwardTo: []storage.Appendable{rw.Exports().(remotewrite.Exports).Receiver},
+	}
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+	waitForServerToBeReady(t, args)
+
+	req := writev2.FromV1(&prompb.WriteRequest{
+		Timeseries: []prompb.TimeSeries{{
+			Labels:  []prompb.Label{{Name: "__name__", Value: "requests_total"}, {Name: "job", Value: "api"}},
+			Samples: []prompb.Sample{{Timestamp: timestamp, Value: 12}},
+		}},
+		Metadata: []prompb.MetricMetadata{{
+			Type:             prompb.MetricMetadata_COUNTER,
+			MetricFamilyName: "requests_total",
+			Help:             "Total requests.",
+		}},
+	}, nil, func(labels.Labels) int64 { return createdTimestamp })
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("http://localhost:%d/api/v1/metrics/write", port), bytes.NewReader(snappy.Encode(nil, req.Marshal())))
+	require.NoError(t, err)
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", writev2.ContentType)
+	resp, err := http.DefaultClient.Do(httpReq)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	var relayed *writev2.Request
+	select {
+	case relayed = <-received:
+	case <-ctx.Done():
+		require.FailNow(t, "timed out waiting for the relayed request")
+	}
+	require.Len(t, relayed.Timeseries, 1)
+	ts := relayed.Timeseries[0]
+	lbls, err := relayed.Labels(ts.LabelsRefs)
+	require.NoError(t, err)
+	require.Equal(t, labels.FromStrings("__name__", "requests_total", "job", "api"), lbls)
+	require.Equal(t, []writev2.Sample{{Timestamp: timestamp, Value: 12}}, ts.Samples)
+	require.Equal(t, createdTimestamp, ts.CreatedTimestamp)
+	md, err := relayed.Metadata(ts.Metadata)
+	require.NoError(t, err)
+	require.Equal(t, metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Total requests."}, md)
+}
+
 func TestUpdate(t *testing.T) {
 	timestamp := time.Now().Add(time.Second).UnixMilli()
 	input01 := []prompb.TimeSeries{{
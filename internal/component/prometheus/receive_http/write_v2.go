@@ -0,0 +1,189 @@
+package receive_http
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/storage/remote"
+)
+
+// writeHandler accepts both Remote-Write 1.0 and 2.0 requests. Requests are
+// told apart by the proto parameter of their Content-Type header, and
+// requests without one are treated as Remote-Write 1.0 requests.
+type writeHandler struct {
+	logger     log.Logger
+	appendable storage.Appendable
+	v1         http.Handler
+}
+
+func (h *writeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	proto := writev2.ProtobufMessageV1
+	if contentType := r.Header.Get("Content-Type"); contentType != "" {
+		_, params, err := mime.ParseMediaType(contentType)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("invalid Content-Type header: %s", err), http.StatusUnsupportedMediaType)
+			return
+		}
+		if p, ok := params["proto"]; ok {
+			proto = p
+		}
+	}
+
+	switch proto {
+	case writev2.ProtobufMessageV1:
+		h.v1.ServeHTTP(w, r)
+	case writev2.ProtobufMessageV2:
+		h.serveV2(w, r)
+	default:
+		http.Error(w, fmt.Sprintf("unsupported protobuf message %q", proto), http.StatusUnsupportedMediaType)
+	}
+}
+
+func (h *writeHandler) serveV2(w http.ResponseWriter, r *http.Request) {
+	compressed, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	data, err := snappy.Decode(nil, compressed)
+	if err != nil {
+		level.Error(h.logger).Log("msg", "error decoding remote write request", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	var req writev2.Request
+	if err := req.Unmarshal(data); err != nil {
+		level.Error(h.logger).Log("msg", "error decoding remote write request", "err", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	stats, err := h.writeV2(r, &req)
+
+	// The written counts are reported even for failed requests so that
+	// senders know which part of the request has been accepted.
+	w.Header().Set(writev2.SamplesWrittenHeader, strconv.Itoa(stats.samples))
+	w.Header().Set(writev2.HistogramsWrittenHeader, strconv.Itoa(stats.histograms))
+	w.Header().Set(writev2.ExemplarsWrittenHeader, strconv.Itoa(stats.exemplars))
+
+	switch {
+	case err == nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errors.Is(err, errInvalidRequest),
+		errors.Is(err, storage.ErrOutOfOrderSample),
+		errors.Is(err, storage.ErrOutOfBounds),
+		errors.Is(err, storage.ErrDuplicateSampleForTimestamp):
+		// Bad data is reported as a bad request to prevent retries.
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		level.Error(h.logger).Log("msg", "error appending remote write", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+var errInvalidRequest = errors.New("invalid request")
+
+type writeStats struct {
+	samples, histograms, exemplars int
+}
+
+func (h *writeHandler) writeV2(r *http.Request, req *writev2.Request) (stats writeStats, err error) {
+	app := h.appendable.Appender(r.Context())
+	defer func() {
+		if err != nil {
+			_ = app.Rollback()
+			stats = writeStats{}
+			return
+		}
+		err = app.Commit()
+	}()
+
+	for _, ts := range req.Timeseries {
+		lbls, err := req.Labels(ts.LabelsRefs)
+		if err != nil {
+			return stats, fmt.Errorf("%w: %s", errInvalidRequest, err)
+		}
+		if !lbls.IsValid() {
+			return stats, fmt.Errorf("%w: invalid metric name or labels %s", errInvalidRequest, lbls)
+		}
+
+		var ref storage.SeriesRef
+		if ts.CreatedTimestamp != 0 {
+			if t, ok := firstTimestamp(ts); ok {
+				ref, err = agentprom.AppendCTZeroSample(app, ref, lbls, t, ts.CreatedTimestamp)
+				if err != nil {
+					return stats, err
+				}
+			}
+		}
+		for _, s := range ts.Samples {
+			ref, err = app.Append(ref, lbls, s.Timestamp, s.Value)
+			if err != nil {
+				return stats, err
+			}
+			stats.samples++
+		}
+
+		for _, hp := range ts.Histograms {
+			if hp.IsFloatHistogram() {
+				_, err = app.AppendHistogram(0, lbls, hp.Timestamp, nil, remote.FloatHistogramProtoToFloatHistogram(hp))
+			} else {
+				_, err = app.AppendHistogram(0, lbls, hp.Timestamp, remote.HistogramProtoToHistogram(hp), nil)
+			}
+			if err != nil {
+				return stats, err
+			}
+			stats.histograms++
+		}
+
+		for _, e := range ts.Exemplars {
+			exLbls, err := req.Labels(e.LabelsRefs)
+			if err != nil {
+				return stats, fmt.Errorf("%w: %s", errInvalidRequest, err)
+			}
+			ex := exemplar.Exemplar{Labels: exLbls, Value: e.Value, Ts: e.Timestamp, HasTs: true}
+			// Like in Remote-Write 1.0, exemplars which can't be appended don't
+			// fail the request.
+			if _, err := app.AppendExemplar(0, lbls, ex); err != nil {
+				level.Debug(h.logger).Log("msg", "error appending exemplar", "series", lbls, "err", err)
+				continue
+			}
+			stats.exemplars++
+		}
+
+		if ts.Metadata != (writev2.Metadata{}) {
+			m, err := req.Metadata(ts.Metadata)
+			if err != nil {
+				return stats, fmt.Errorf("%w: %s", errInvalidRequest, err)
+			}
+			if _, err := app.UpdateMetadata(0, lbls, m); err != nil {
+				return stats, err
+			}
+		}
+	}
+	return stats, nil
+}
+
+// firstTimestamp returns the timestamp of the first sample or histogram of a
+// series, which its created timestamp precedes.
+func firstTimestamp(ts writev2.TimeSeries) (int64, bool) {
+	switch {
+	case len(ts.Samples) > 0:
+		return ts.Samples[0].Timestamp, true
+	case len(ts.Histograms) > 0:
+		return ts.Histograms[0].Timestamp, true
+	default:
+		return 0, false
+	}
+}
@@ -0,0 +1,151 @@
+package receive_influxdb
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// point is a single parsed line of the InfluxDB line protocol.
+type point struct {
+	measurement string
+	tags        map[string]string
+	fields      map[string]float64
+	ts          time.Time
+}
+
+// precisionMultiplier returns the duration of one timestamp unit for the
+// precision query parameter of the InfluxDB v1 and v2 write APIs.
+func precisionMultiplier(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "n", "ns":
+		return time.Nanosecond, nil
+	case "u", "us":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	case "m":
+		return time.Minute, nil
+	case "h":
+		return time.Hour, nil
+	default:
+		return 0, fmt.Errorf("unsupported precision %q", precision)
+	}
+}
+
+// parseLine parses a single line of the InfluxDB line protocol:
+//
+//	<measurement>[,<tag>=<value>...] <field>=<value>[,<field>=<value>...] [<timestamp>]
+//
+// String fields are ignored since they can't be represented as samples.
+// Boolean fields are converted to 0 or 1.
+func parseLine(line string, precision time.Duration, now time.Time) (point, error) {
+	p := point{
+		tags:   make(map[string]string),
+		fields: make(map[string]float64),
+		ts:     now,
+	}
+
+	seriesKey, rest, _ := cutUnescaped(line, ' ', false)
+	if rest == "" {
+		return p, fmt.Errorf("missing fields")
+	}
+
+	measurement, tagSet, _ := cutUnescaped(seriesKey, ',', false)
+	if measurement == "" {
+		return p, fmt.Errorf("missing measurement")
+	}
+	p.measurement = unescape(measurement)
+
+	for tagSet != "" {
+		var tag string
+		tag, tagSet, _ = cutUnescaped(tagSet, ',', false)
+		name, value, found := cutUnescaped(tag, '=', false)
+		if !found || name == "" || value == "" {
+			return p, fmt.Errorf("invalid tag %q", tag)
+		}
+		p.tags[unescape(name)] = unescape(value)
+	}
+
+	fieldSet, timestamp, _ := cutUnescaped(rest, ' ', true)
+
+	for fieldSet != "" {
+		var field string
+		field, fieldSet, _ = cutUnescaped(fieldSet, ',', true)
+		name, value, found := cutUnescaped(field, '=', false)
+		if !found || name == "" || value == "" {
+			return p, fmt.Errorf("invalid field %q", field)
+		}
+		v, ok, err := parseFieldValue(value)
+		if err != nil {
+			return p, fmt.Errorf("invalid value for field %q: %w", name, err)
+		}
+		if ok {
+			p.fields[unescape(name)] = v
+		}
+	}
+
+	if timestamp = strings.TrimSpace(timestamp); timestamp != "" {
+		ts, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			return p, fmt.Errorf("invalid timestamp %q: %w", timestamp, err)
+		}
+		p.ts = time.Unix(0, ts*int64(precision))
+	}
+	return p, nil
+}
+
+// parseFieldValue parses a field value. The second return value is false for
+// string fields, which are valid but have no numeric representation.
+func parseFieldValue(value string) (float64, bool, error) {
+	switch {
+	case strings.HasPrefix(value, `"`):
+		if len(value) < 2 || !strings.HasSuffix(value, `"`) {
+			return 0, false, fmt.Errorf("unterminated string %s", value)
+		}
+		return 0, false, nil
+	case strings.HasSuffix(value, "i"), strings.HasSuffix(value, "u"):
+		v, err := strconv.ParseInt(value[:len(value)-1], 10, 64)
+		return float64(v), err == nil, err
+	}
+
+	switch value {
+	case "t", "T", "true", "True", "TRUE":
+		return 1, true, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, true, nil
+	}
+
+	v, err := strconv.ParseFloat(value, 64)
+	return v, err == nil, err
+}
+
+// cutUnescaped slices s around the first occurrence of sep which is neither
+// escaped with a backslash nor, if quotes is true, inside a double-quoted
+// string.
+func cutUnescaped(s string, sep byte, quotes bool) (before, after string, found bool) {
+	inQuotes := false
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\':
+			i++
+		case quotes && c == '"':
+			inQuotes = !inQuotes
+		case c == sep && !inQuotes:
+			return s[:i], s[i+1:], true
+		}
+	}
+	return s, "", false
+}
+
+var unescaper = strings.NewReplacer(`\,`, `,`, `\ `, ` `, `\=`, `=`, `\"`, `"`, `\\`, `\`)
+
+func unescape(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	return unescaper.Replace(s)
+}
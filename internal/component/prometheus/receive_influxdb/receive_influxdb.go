@@ -0,0 +1,434 @@
+package receive_influxdb
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/grafana/agent/internal/component"
+	fnet "github.com/grafana/agent/internal/component/common/net"
+	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/component/prometheus/internal/linelistener"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service/labelstore"
+	"github.com/grafana/agent/internal/util"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "prometheus.receive_influxdb",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the
+// prometheus.receive_influxdb component.
+type Arguments struct {
+	Server      *fnet.ServerConfig   `river:",squash"`
+	ListenTCP   string               `river:"listen_tcp,attr,optional"`
+	ListenUDP   string               `river:"listen_udp,attr,optional"`
+	ForwardTo   []storage.Appendable `river:"forward_to,attr"`
+	DropTags    []string             `river:"drop_tags,attr,optional"`
+	TagMappings []TagMapping         `river:"tag_mapping,block,optional"`
+}
+
+// TagMapping renames an InfluxDB tag to a Prometheus label.
+type TagMapping struct {
+	SourceTag   string `river:"source_tag,attr"`
+	TargetLabel string `river:"target_label,attr"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *Arguments) SetToDefault() {
+	*args = Arguments{
+		Server: fnet.DefaultServerConfig(),
+	}
+}
+
+// Validate implements river.Validator.
+func (args *Arguments) Validate() error {
+	for i, m := range args.TagMappings {
+		if !model.LabelName(m.TargetLabel).IsValid() {
+			return fmt.Errorf("tag_mapping block %d: invalid target_label %q", i, m.TargetLabel)
+		}
+	}
+	return nil
+}
+
+const (
+	// maxLineLength is the maximum length of a line in bytes.
+	maxLineLength = 1024 * 1024
+
+	// tcpReadTimeout is how long an idle TCP connection is kept open.
+	tcpReadTimeout = 5 * time.Minute
+)
+
+// Component implements the prometheus.receive_influxdb component.
+type Component struct {
+	opts               component.Options
+	fanout             *agentprom.Fanout
+	uncheckedCollector *util.UncheckedCollector
+	metrics            *metrics
+
+	updateMut sync.RWMutex
+	args      Arguments
+	server    *fnet.TargetServer
+	listener  *linelistener.Listener
+
+	// mappingMut is separate from updateMut so that requests in flight can
+	// still be served while the server is shut down during an update.
+	mappingMut  sync.RWMutex
+	dropTags    map[string]struct{}
+	tagMappings map[string]string
+}
+
+var _ component.Component = (*Component)(nil)
+
+// New creates a new prometheus.receive_influxdb component.
+func New(opts component.Options, args Arguments) (*Component, error) {
+	service, err := opts.GetServiceData(labelstore.ServiceName)
+	if err != nil {
+		return nil, err
+	}
+	ls := service.(labelstore.LabelStore)
+
+	uncheckedCollector := util.NewUncheckedCollector(nil)
+	opts.Registerer.MustRegister(uncheckedCollector)
+
+	c := &Component{
+		opts:               opts,
+		fanout:             agentprom.NewFanout(args.ForwardTo, opts.ID, opts.Registerer, ls),
+		uncheckedCollector: uncheckedCollector,
+		metrics:            newMetrics(opts.Registerer),
+	}
+	if err := c.Update(args); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	defer func() {
+		c.updateMut.Lock()
+		defer c.updateMut.Unlock()
+		c.shutdownServer()
+		c.stopListener()
+	}()
+
+	<-ctx.Done()
+	level.Info(c.opts.Logger).Log("msg", "terminating due to context done")
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	newArgs := args.(Arguments)
+	c.fanout.UpdateChildren(newArgs.ForwardTo)
+
+	dropTags := make(map[string]struct{}, len(newArgs.DropTags))
+	for _, tag := range newArgs.DropTags {
+		dropTags[tag] = struct{}{}
+	}
+	tagMappings := make(map[string]string, len(newArgs.TagMappings))
+	for _, m := range newArgs.TagMappings {
+		tagMappings[m.SourceTag] = m.TargetLabel
+	}
+	c.mappingMut.Lock()
+	c.dropTags, c.tagMappings = dropTags, tagMappings
+	c.mappingMut.Unlock()
+
+	c.updateMut.Lock()
+	defer c.updateMut.Unlock()
+
+	listenerNeedsUpdate := c.listener == nil ||
+		c.args.ListenTCP != newArgs.ListenTCP ||
+		c.args.ListenUDP != newArgs.ListenUDP
+	if listenerNeedsUpdate {
+		c.stopListener()
+		if newArgs.ListenTCP != "" || newArgs.ListenUDP != "" {
+			l, err := linelistener.Start(c.opts.Logger, linelistener.Options{
+				TCPAddress:    newArgs.ListenTCP,
+				UDPAddress:    newArgs.ListenUDP,
+				ReadTimeout:   tcpReadTimeout,
+				MaxLineLength: maxLineLength,
+			}, c.handleLines, c.handleTooLong)
+			if err != nil {
+				return err
+			}
+			c.listener = l
+		}
+	}
+
+	serverNeedsUpdate := c.server == nil || !reflect.DeepEqual(c.args.Server, newArgs.Server)
+	if !serverNeedsUpdate {
+		c.args = newArgs
+		return nil
+	}
+	c.shutdownServer()
+
+	// [server.Server] registers new metrics every time it is created. To
+	// avoid issues with re-registering metrics with the same name, we create a
+	// new registry for the server every time we create one, and pass it to an
+	// unchecked collector to bypass uniqueness checking.
+	serverRegistry := prometheus.NewRegistry()
+	c.uncheckedCollector.SetCollector(serverRegistry)
+
+	s, err := fnet.NewTargetServer(c.opts.Logger, "prometheus_receive_influxdb", serverRegistry, newArgs.Server)
+	if err != nil {
+		return fmt.Errorf("failed to create server: %v", err)
+	}
+	c.server = s
+
+	err = c.server.MountAndRun(func(router *mux.Router) {
+		router.Path("/write").Methods("POST").HandlerFunc(c.handleWrite)
+		router.Path("/api/v2/write").Methods("POST").HandlerFunc(c.handleWrite)
+		router.Path("/ping").Methods("GET", "HEAD").HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			w.WriteHeader(http.StatusNoContent)
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	c.args = newArgs
+	return nil
+}
+
+// shutdownServer will shut down the currently used server.
+// It is not goroutine-safe and an updateMut write lock must be held when it's called.
+func (c *Component) shutdownServer() {
+	if c.server != nil {
+		c.server.StopAndShutdown()
+		c.server = nil
+	}
+}
+
+// stopListener stops the TCP and UDP listeners, if any.
+// It is not goroutine-safe and an updateMut write lock must be held when it's called.
+func (c *Component) stopListener() {
+	if c.listener != nil {
+		c.listener.Stop()
+		c.listener = nil
+	}
+}
+
+// handleWrite implements the InfluxDB v1 /write and v2 /api/v2/write
+// endpoints. Lines which can't be parsed are skipped and reported back to
+// the client after all valid lines have been appended.
+func (c *Component) handleWrite(w http.ResponseWriter, r *http.Request) {
+	precision, err := precisionMultiplier(r.URL.Query().Get("precision"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body := r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		gz, err := gzip.NewReader(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		defer gz.Close()
+		body = gz
+	}
+
+	c.mappingMut.RLock()
+	dropTags, tagMappings := c.dropTags, c.tagMappings
+	c.mappingMut.RUnlock()
+
+	var (
+		now        = time.Now()
+		app        = c.fanout.Appender(r.Context())
+		parseErrs  []error
+		scanner    = bufio.NewScanner(body)
+		lineNumber int
+	)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineLength)
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseLine(line, precision, now)
+		if err != nil {
+			c.metrics.linesInvalid.Inc()
+			parseErrs = append(parseErrs, fmt.Errorf("line %d: %w", lineNumber, err))
+			continue
+		}
+		c.metrics.linesReceived.Inc()
+
+		for _, s := range toSamples(p, dropTags, tagMappings) {
+			if _, err := app.Append(0, s.labels, s.t, s.v); err != nil {
+				_ = app.Rollback()
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil && !errors.Is(err, io.EOF) {
+		_ = app.Rollback()
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to commit samples", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if len(parseErrs) > 0 {
+		http.Error(w, fmt.Sprintf("partial write: %v", errors.Join(parseErrs...)), http.StatusBadRequest)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleLines appends lines received over TCP or UDP. Their timestamps are in
+// nanoseconds, as there is no precision parameter. Lines which can't be
+// parsed are skipped.
+func (c *Component) handleLines(lines []string) {
+	c.mappingMut.RLock()
+	dropTags, tagMappings := c.dropTags, c.tagMappings
+	c.mappingMut.RUnlock()
+
+	var (
+		now      = time.Now()
+		app      = c.fanout.Appender(context.Background())
+		appended int
+	)
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		p, err := parseLine(line, time.Nanosecond, now)
+		if err != nil {
+			c.metrics.linesInvalid.Inc()
+			level.Debug(c.opts.Logger).Log("msg", "failed to parse line protocol line", "line", line, "err", err)
+			continue
+		}
+		c.metrics.linesReceived.Inc()
+
+		for _, s := range toSamples(p, dropTags, tagMappings) {
+			if _, err := app.Append(0, s.labels, s.t, s.v); err != nil {
+				level.Warn(c.opts.Logger).Log("msg", "failed to append sample", "err", err)
+				continue
+			}
+			appended++
+		}
+	}
+
+	if appended == 0 {
+		_ = app.Rollback()
+		return
+	}
+	if err := app.Commit(); err != nil {
+		level.Warn(c.opts.Logger).Log("msg", "failed to commit samples", "err", err)
+	}
+}
+
+// handleTooLong counts a line received over TCP or UDP which is longer than
+// maxLineLength, and is discarded without being buffered.
+func (c *Component) handleTooLong() {
+	c.metrics.linesInvalid.Inc()
+	level.Debug(c.opts.Logger).Log("msg", "discarding line protocol line longer than the maximum line length", "max_line_length", maxLineLength)
+}
+
+type sample struct {
+	labels labels.Labels
+	t      int64
+	v      float64
+}
+
+// toSamples converts a point into one sample per field. The metric name is
+// <measurement>_<field>, or just <measurement> for a field named "value".
+func toSamples(p point, dropTags map[string]struct{}, tagMappings map[string]string) []sample {
+	lb := labels.NewBuilder(labels.EmptyLabels())
+	for tag, value := range p.tags {
+		if _, drop := dropTags[tag]; drop {
+			continue
+		}
+		name, ok := tagMappings[tag]
+		if !ok {
+			name = sanitizeName(tag, false)
+		}
+		lb.Set(name, value)
+	}
+
+	samples := make([]sample, 0, len(p.fields))
+	for field, v := range p.fields {
+		name := p.measurement
+		if field != "value" {
+			name += "_" + field
+		}
+		lb.Set(model.MetricNameLabel, sanitizeName(name, true))
+		samples = append(samples, sample{
+			labels: lb.Labels(),
+			t:      p.ts.UnixMilli(),
+			v:      v,
+		})
+	}
+	return samples
+}
+
+// sanitizeName replaces all characters which are invalid in a Prometheus
+// label name, or metric name if metricName is true, with underscores.
+func sanitizeName(name string, metricName bool) string {
+	var sb strings.Builder
+	sb.Grow(len(name))
+	for i, r := range name {
+		valid := (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' ||
+			(i > 0 && r >= '0' && r <= '9') || (metricName && r == ':')
+		if valid {
+			sb.WriteRune(r)
+		} else {
+			sb.WriteByte('_')
+		}
+	}
+	return sb.String()
+}
+
+type metrics struct {
+	linesReceived prometheus.Counter
+	linesInvalid  prometheus.Counter
+}
+
+func newMetrics(reg prometheus.Registerer) *metrics {
+	m := &metrics{
+		linesReceived: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_receive_influxdb_lines_total",
+			Help: "Total number of InfluxDB line protocol lines received.",
+		}),
+		linesInvalid: prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "prometheus_receive_influxdb_lines_invalid_total",
+			Help: "Total number of InfluxDB line protocol lines which could not be parsed or were too long.",
+		}),
+	}
+	reg.MustRegister(m.linesReceived, m.linesInvalid)
+	return m
+}
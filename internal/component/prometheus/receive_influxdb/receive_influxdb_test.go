@@ -0,0 +1,248 @@
+package receive_influxdb
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	fnet "github.com/grafana/agent/internal/component/common/net"
+	agentprom "github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/service/labelstore"
+	"github.com/grafana/agent/internal/util"
+	"github.com/phayes/freeport"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseLine(t *testing.T) {
+	now := time.Unix(1000, 0)
+
+	tests := []struct {
+		name     string
+		line     string
+		expected point
+		errMatch string
+	}{
+		{
+			name: "full line",
+			line: `cpu,host=server01,region=us-west usage_idle=92.5,usage_user=3i,up=true 1700000000000000000`,
+			expected: point{
+				measurement: "cpu",
+				tags:        map[string]string{"host": "server01", "region": "us-west"},
+				fields:      map[string]float64{"usage_idle": 92.5, "usage_user": 3, "up": 1},
+				ts:          time.Unix(1700000000, 0),
+			},
+		},
+		{
+			name: "escaped characters and string fields",
+			line: `disk\ io,path=/var\,log value=1,note="a, b c" 5`,
+			expected: point{
+				measurement: "disk io",
+				tags:        map[string]string{"path": "/var,log"},
+				fields:      map[string]float64{"value": 1},
+				ts:          time.Unix(0, 5),
+			},
+		},
+		{
+			name: "missing timestamp",
+			line: `mem free=10`,
+			expected: point{
+				measurement: "mem",
+				tags:        map[string]string{},
+				fields:      map[string]float64{"free": 10},
+				ts:          now,
+			},
+		},
+		{name: "missing fields", line: `mem`, errMatch: "missing fields"},
+		{name: "invalid tag", line: `mem,host free=1`, errMatch: "invalid tag"},
+		{name: "invalid field", line: `mem free=abc`, errMatch: `invalid value for field "free"`},
+		{name: "unterminated string", line: `mem note="abc 1`, errMatch: "unterminated string"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := parseLine(tc.line, time.Nanosecond, now)
+			if tc.errMatch != "" {
+				require.ErrorContains(t, err, tc.errMatch)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, p)
+		})
+	}
+}
+
+func TestToSamples(t *testing.T) {
+	p := point{
+		measurement: "cpu",
+		tags:        map[string]string{"host": "server01", "region": "us-west", "cpu-id": "0"},
+		fields:      map[string]float64{"value": 1, "usage_idle": 92.5},
+		ts:          time.Unix(10, 0),
+	}
+	samples := toSamples(p, map[string]struct{}{"region": {}}, map[string]string{"host": "instance"})
+	require.ElementsMatch(t, []sample{
+		{labels: labels.FromStrings("__name__", "cpu", "cpu_id", "0", "instance", "server01"), t: 10_000, v: 1},
+		{labels: labels.FromStrings("__name__", "cpu_usage_idle", "cpu_id", "0", "instance", "server01"), t: 10_000, v: 92.5},
+	}, samples)
+}
+
+func TestForwardsMetrics(t *testing.T) {
+	actualSamples := make(chan testSample, 100)
+
+	port, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{
+				ListenAddress: "localhost",
+				ListenPort:    port,
+			},
+			GRPC: &fnet.GRPCConfig{ListenAddress: "127.0.0.1", ListenPort: getFreePort(t)},
+		},
+		ForwardTo: testAppendable(actualSamples),
+	}
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+
+	baseURL := fmt.Sprintf("http://localhost:%d", port)
+	require.Eventually(t, func() bool {
+		resp, err := http.Get(baseURL + "/ping")
+		if err != nil {
+			return false
+		}
+		resp.Body.Close()
+		return resp.StatusCode == http.StatusNoContent
+	}, 5*time.Second, 20*time.Millisecond, "server failed to start before timeout")
+
+	body := "cpu,host=a value=1 10\ncpu,host=b value=2 20\n"
+	resp, err := http.Post(baseURL+"/write?precision=s", "text/plain", strings.NewReader(body))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusNoContent, resp.StatusCode)
+
+	resp, err = http.Post(baseURL+"/api/v2/write?precision=ms", "text/plain", strings.NewReader("mem free=3 30\nbroken\n"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusBadRequest, resp.StatusCode)
+
+	expected := []testSample{
+		{ts: 10_000, val: 1, l: labels.FromStrings("__name__", "cpu", "host", "a")},
+		{ts: 20_000, val: 2, l: labels.FromStrings("__name__", "cpu", "host", "b")},
+		{ts: 30, val: 3, l: labels.FromStrings("__name__", "mem_free")},
+	}
+	for _, exp := range expected {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for sample %v", exp)
+		case s := <-actualSamples:
+			require.Equal(t, exp, s)
+		}
+	}
+}
+
+func TestForwardsMetrics_Socket(t *testing.T) {
+	actualSamples := make(chan testSample, 100)
+
+	args := Arguments{
+		Server: &fnet.ServerConfig{
+			HTTP: &fnet.HTTPConfig{ListenAddress: "localhost", ListenPort: getFreePort(t)},
+			GRPC: &fnet.GRPCConfig{ListenAddress: "127.0.0.1", ListenPort: getFreePort(t)},
+		},
+		ListenTCP: "127.0.0.1:0",
+		ListenUDP: "127.0.0.1:0",
+		ForwardTo: testAppendable(actualSamples),
+	}
+	comp, err := New(testOptions(t), args)
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go func() {
+		require.NoError(t, comp.Run(ctx))
+	}()
+
+	tcpConn, err := net.Dial("tcp", comp.listener.TCPAddr().String())
+	require.NoError(t, err)
+	defer tcpConn.Close()
+	_, err = fmt.Fprint(tcpConn, "cpu,host=a value=1 10000000000\nbroken\n")
+	require.NoError(t, err)
+
+	udpConn, err := net.Dial("udp", comp.listener.UDPAddr().String())
+	require.NoError(t, err)
+	defer udpConn.Close()
+	_, err = fmt.Fprint(udpConn, "mem free=3 30000000\n")
+	require.NoError(t, err)
+
+	expected := map[string]testSample{
+		"cpu":      {ts: 10_000, val: 1, l: labels.FromStrings("__name__", "cpu", "host", "a")},
+		"mem_free": {ts: 30, val: 3, l: labels.FromStrings("__name__", "mem_free")},
+	}
+	for len(expected) > 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("timed out waiting for samples, still waiting for %v", expected)
+		case s := <-actualSamples:
+			name := s.l.Get("__name__")
+			require.Equal(t, expected[name], s)
+			delete(expected, name)
+		}
+	}
+	require.Eventually(t, func() bool {
+		return testutil.ToFloat64(comp.metrics.linesInvalid) == 1
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+type testSample struct {
+	ts  int64
+	val float64
+	l   labels.Labels
+}
+
+func testAppendable(actualSamples chan testSample) []storage.Appendable {
+	hookFn := func(
+		ref storage.SeriesRef,
+		l labels.Labels,
+		ts int64,
+		val float64,
+		next storage.Appender,
+	) (storage.SeriesRef, error) {
+
+		actualSamples <- testSample{ts: ts, val: val, l: l}
+		return ref, nil
+	}
+
+	ls := labelstore.New(nil, prometheus.DefaultRegisterer)
+	return []storage.Appendable{agentprom.NewInterceptor(
+		nil,
+		ls,
+		agentprom.WithAppendHook(
+			hookFn))}
+}
+
+func testOptions(t *testing.T) component.Options {
+	return component.Options{
+		ID:         "prometheus.receive_influxdb.test",
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			return labelstore.New(nil, prometheus.DefaultRegisterer), nil
+		},
+	}
+}
+
+func getFreePort(t *testing.T) int {
+	p, err := freeport.GetFreePort()
+	require.NoError(t, err)
+	return p
+}
@@ -189,6 +189,17 @@ func New(o component.Options, args Arguments) (*Component, error) {
 			}
 			return next.AppendHistogram(0, newLbl, t, h, fh)
 		}),
+		prometheus.WithCreatedTimestampHook(func(_ storage.SeriesRef, l labels.Labels, t, ct int64, next storage.Appender) (storage.SeriesRef, error) {
+			if c.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			newLbl := c.relabel(0, l)
+			if newLbl.IsEmpty() {
+				return 0, nil
+			}
+			return prometheus.AppendCTZeroSample(next, 0, newLbl, t, ct)
+		}),
 	)
 
 	// Immediately export the receiver which remains the same for the component
@@ -2,12 +2,17 @@ package remotewrite
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sort"
+	"time"
 
 	"github.com/grafana/agent/static/agentctl/waltools"
 	"github.com/olekukonko/tablewriter"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/storage/remote"
 	"github.com/spf13/cobra"
 )
 
@@ -18,6 +23,8 @@ func InstallTools(cmd *cobra.Command) {
 		samplesCmd(),
 		targetStatsCmd(),
 		walStatsCmd(),
+		deadLetterStatsCmd(),
+		deadLetterReplayCmd(),
 	)
 }
 
@@ -203,6 +210,172 @@ deletion but then comes back at some point).`,
 	}
 }
 
+func deadLetterStatsCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "dead-letter-stats [dead letter directory]",
+		Short: "List batches rejected by a remote_write endpoint",
+		Long: `dead-letter-stats reads a dead letter directory written by an endpoint with a
+dead_letter block and lists the batches within it, together with the status
+code and reason the endpoint gave for rejecting them.`,
+		Args: cobra.ExactArgs(1),
+
+		Run: func(_ *cobra.Command, args []string) {
+			files, err := ListDeadLetters(args[0])
+			if err != nil {
+				fmt.Printf("failed to read dead letter directory: %v\n", err)
+				os.Exit(1)
+			}
+
+			table := tablewriter.NewWriter(os.Stdout)
+			defer table.Render()
+
+			table.SetHeader([]string{"File", "Time", "Status", "Series", "Samples", "Reason"})
+			table.SetAutoWrapText(false)
+
+			for _, f := range files {
+				rec, err := ReadDeadLetter(f.Path)
+				if err != nil {
+					fmt.Printf("failed to read batch: %v\n", err)
+					os.Exit(1)
+				}
+				var series, samples int
+				if req, err := rec.Decode(); err == nil {
+					series = len(req.Timeseries)
+					for _, ts := range req.Timeseries {
+						samples += len(ts.Samples) + len(ts.Histograms)
+					}
+				}
+				table.Append([]string{
+					filepath.Base(f.Path),
+					rec.Timestamp.Format(time.RFC3339),
+					fmt.Sprintf("%d", rec.StatusCode),
+					fmt.Sprintf("%d", series),
+					fmt.Sprintf("%d", samples),
+					rec.Reason,
+				})
+			}
+		},
+	}
+}
+
+func deadLetterReplayCmd() *cobra.Command {
+	var (
+		endpointURL     string
+		timeout         time.Duration
+		bearerTokenFile string
+		username        string
+		passwordFile    string
+		headers         map[string]string
+		keep            bool
+	)
+
+	cmd := &cobra.Command{
+		Use:   "dead-letter-replay [dead letter directory]",
+		Short: "Resend batches rejected by a remote_write endpoint",
+		Long: `dead-letter-replay sends the batches in a dead letter directory to a
+remote_write endpoint, oldest first. Use it once the reason for the rejection
+has been fixed, for example after raising a per-tenant limit.
+
+Batches which are accepted are deleted from the directory unless --keep is
+set. Replaying stops at the first batch which is rejected again.
+
+Examples:
+
+Replay all batches to the endpoint they were originally sent to:
+
+dead-letter-replay /var/lib/agent/data/prometheus.remote_write.default/dead_letter/mimir
+
+
+Replay all batches to a different endpoint with basic authentication:
+
+dead-letter-replay --url https://mimir.example.com/api/v1/push \
+  --basic-auth.username tenant --basic-auth.password-file /etc/secret /tmp/dead_letter
+`,
+		Args: cobra.ExactArgs(1),
+
+		Run: func(cmd *cobra.Command, args []string) {
+			files, err := ListDeadLetters(args[0])
+			if err != nil {
+				fmt.Printf("failed to read dead letter directory: %v\n", err)
+				os.Exit(1)
+			}
+
+			httpConfig := common.DefaultHTTPClientConfig
+			if bearerTokenFile != "" {
+				httpConfig.BearerTokenFile = bearerTokenFile
+			}
+			if username != "" {
+				httpConfig.BasicAuth = &common.BasicAuth{Username: username, PasswordFile: passwordFile}
+			}
+
+			clients := make(map[string]remote.WriteClient)
+			getClient := func(rawURL string) (remote.WriteClient, error) {
+				if c, ok := clients[rawURL]; ok {
+					return c, nil
+				}
+				u, err := url.Parse(rawURL)
+				if err != nil {
+					return nil, err
+				}
+				c, err := remote.NewWriteClient("dead-letter-replay", &remote.ClientConfig{
+					URL:              &common.URL{URL: u},
+					Timeout:          model.Duration(timeout),
+					HTTPClientConfig: httpConfig,
+					Headers:          headers,
+				})
+				if err != nil {
+					return nil, err
+				}
+				clients[rawURL] = c
+				return c, nil
+			}
+
+			var replayed int
+			for _, f := range files {
+				rec, err := ReadDeadLetter(f.Path)
+				if err != nil {
+					fmt.Printf("failed to read batch: %v\n", err)
+					os.Exit(1)
+				}
+
+				target := rec.URL
+				if endpointURL != "" {
+					target = endpointURL
+				}
+				client, err := getClient(target)
+				if err != nil {
+					fmt.Printf("failed to create client for %s: %v\n", target, err)
+					os.Exit(1)
+				}
+
+				if err := client.Store(cmd.Context(), rec.Body, 0); err != nil {
+					fmt.Printf("failed to replay %s: %v\n", filepath.Base(f.Path), err)
+					fmt.Printf("replayed %d of %d batches\n", replayed, len(files))
+					os.Exit(1)
+				}
+				replayed++
+
+				if !keep {
+					if err := os.Remove(f.Path); err != nil {
+						fmt.Printf("failed to delete replayed batch: %v\n", err)
+						os.Exit(1)
+					}
+				}
+			}
+			fmt.Printf("replayed %d of %d batches\n", replayed, len(files))
+		},
+	}
+
+	cmd.Flags().StringVar(&endpointURL, "url", "", "URL to send batches to; defaults to the URL each batch was rejected by")
+	cmd.Flags().DurationVar(&timeout, "timeout", 30*time.Second, "timeout for each request")
+	cmd.Flags().StringVar(&bearerTokenFile, "bearer-token-file", "", "file containing a bearer token to authenticate with")
+	cmd.Flags().StringVar(&username, "basic-auth.username", "", "username for basic authentication")
+	cmd.Flags().StringVar(&passwordFile, "basic-auth.password-file", "", "file containing the password for basic authentication")
+	cmd.Flags().StringToStringVarP(&headers, "header", "H", nil, "extra HTTP headers to send, as key=value pairs")
+	cmd.Flags().BoolVar(&keep, "keep", false, "keep batches in the directory after replaying them")
+	return cmd
+}
+
 func must(err error) {
 	if err != nil {
 		panic(err)
@@ -0,0 +1,211 @@
+package remotewrite
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/golang/snappy"
+	"github.com/prometheus/prometheus/prompb"
+)
+
+// deadLetterFileExt is the extension of files holding dead-lettered batches.
+const deadLetterFileExt = ".json"
+
+// maxDeadLetterReasonLen limits how much of the response body of a rejected
+// request is stored as the rejection reason.
+const maxDeadLetterReasonLen = 1024
+
+// DeadLetterOptions configures where batches rejected by an endpoint are
+// stored so that they can be inspected and replayed later.
+type DeadLetterOptions struct {
+	Directory    string `river:"directory,attr,optional"`
+	MaxSizeBytes int64  `river:"max_size_bytes,attr,optional"`
+}
+
+// DefaultDeadLetterOptions holds the default settings for a dead_letter
+// block.
+var DefaultDeadLetterOptions = DeadLetterOptions{
+	MaxSizeBytes: 1 << 30, // 1GiB
+}
+
+// SetToDefault implements river.Defaulter.
+func (o *DeadLetterOptions) SetToDefault() {
+	*o = DefaultDeadLetterOptions
+}
+
+// Validate implements river.Validator.
+func (o *DeadLetterOptions) Validate() error {
+	if o.MaxSizeBytes <= 0 {
+		return fmt.Errorf("max_size_bytes must be greater than 0")
+	}
+	return nil
+}
+
+// deadLetterDirectory returns the directory dead-lettered batches for the
+// endpoint are written to. If no directory is configured, a directory named
+// after the endpoint inside dataPath is used.
+func deadLetterDirectory(dataPath string, ep *EndpointOptions) string {
+	if ep.DeadLetter.Directory != "" {
+		return ep.DeadLetter.Directory
+	}
+	name := ep.Name
+	if name == "" {
+		hash := sha256.Sum256([]byte(ep.URL))
+		name = hex.EncodeToString(hash[:])[:12]
+	}
+	return filepath.Join(dataPath, "dead_letter", name)
+}
+
+// DeadLetterRecord is a single batch rejected by a remote_write endpoint.
+type DeadLetterRecord struct {
+	Timestamp  time.Time         `json:"timestamp"`
+	URL        string            `json:"url"`
+	StatusCode int               `json:"status_code"`
+	Reason     string            `json:"reason"`
+	Headers    map[string]string `json:"headers"`
+
+	// Body is the encoded remote_write request as it was sent to the endpoint.
+	Body []byte `json:"body"`
+}
+
+// Decode decodes the body of the record into a remote_write request.
+func (r *DeadLetterRecord) Decode() (*prompb.WriteRequest, error) {
+	data := r.Body
+	if r.Headers["Content-Encoding"] == "snappy" {
+		var err error
+		data, err = snappy.Decode(nil, r.Body)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress body: %w", err)
+		}
+	}
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal body: %w", err)
+	}
+	return &req, nil
+}
+
+// DeadLetterFile is a dead-lettered batch stored on disk.
+type DeadLetterFile struct {
+	Path string
+	Size int64
+}
+
+// ListDeadLetters returns all dead-lettered batches in dir, oldest first.
+func ListDeadLetters(dir string) ([]DeadLetterFile, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []DeadLetterFile
+	for _, e := range entries {
+		if e.IsDir() || filepath.Ext(e.Name()) != deadLetterFileExt {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, DeadLetterFile{Path: filepath.Join(dir, e.Name()), Size: info.Size()})
+	}
+	// File names start with a zero-padded timestamp, so they sort by age.
+	sort.Slice(files, func(i, j int) bool { return files[i].Path < files[j].Path })
+	return files, nil
+}
+
+// ReadDeadLetter reads the dead-lettered batch at path.
+func ReadDeadLetter(path string) (*DeadLetterRecord, error) {
+	bb, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var rec DeadLetterRecord
+	if err := json.Unmarshal(bb, &rec); err != nil {
+		return nil, fmt.Errorf("failed to decode %s: %w", path, err)
+	}
+	return &rec, nil
+}
+
+// deadLetterStore writes rejected batches to a directory, deleting the oldest
+// batches once the directory grows beyond its maximum size.
+type deadLetterStore struct {
+	dir     string
+	maxSize int64
+
+	mut  sync.Mutex
+	seq  uint64
+	size int64
+}
+
+func newDeadLetterStore(dir string, maxSize int64) (*deadLetterStore, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, fmt.Errorf("failed to create dead letter directory: %w", err)
+	}
+	files, err := ListDeadLetters(dir)
+	if err != nil {
+		return nil, err
+	}
+	s := &deadLetterStore{dir: dir, maxSize: maxSize}
+	for _, f := range files {
+		s.size += f.Size
+	}
+	return s, nil
+}
+
+func (s *deadLetterStore) Write(rec *DeadLetterRecord) error {
+	bb, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.seq++
+	name := fmt.Sprintf("%020d-%06d%s", rec.Timestamp.UnixNano(), s.seq%1_000_000, deadLetterFileExt)
+	path := filepath.Join(s.dir, name)
+
+	// Write to a temporary file first so that readers never see a partially
+	// written batch.
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, bb, 0640); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return err
+	}
+	s.size += int64(len(bb))
+
+	return s.enforceSizeLocked()
+}
+
+// enforceSizeLocked deletes the oldest batches until the store is within its
+// maximum size. The newest batch is always kept.
+func (s *deadLetterStore) enforceSizeLocked() error {
+	if s.size <= s.maxSize {
+		return nil
+	}
+	files, err := ListDeadLetters(s.dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files[:len(files)-1] {
+		if s.size <= s.maxSize {
+			break
+		}
+		if err := os.Remove(f.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		s.size -= f.Size
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package remotewrite
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"google.golang.org/protobuf/proto"
+)
+
+// endpointURLCollector collects the metrics of the remote storage and
+// rewrites the url label of endpoints sent through an endpoint proxy from
+// the address of the proxy to the URL of the endpoint, so that the
+// prometheus_remote_storage_* metrics keep identifying the endpoint.
+type endpointURLCollector struct {
+	reg *prometheus.Registry
+
+	mut  sync.RWMutex
+	urls map[string]string // Proxy URL -> endpoint URL.
+}
+
+var _ prometheus.Collector = (*endpointURLCollector)(nil)
+
+func newEndpointURLCollector() *endpointURLCollector {
+	return &endpointURLCollector{reg: prometheus.NewRegistry()}
+}
+
+// Registerer returns the registerer the remote storage must register its
+// metrics to.
+func (c *endpointURLCollector) Registerer() prometheus.Registerer {
+	return c.reg
+}
+
+// SetURLs sets the endpoint URLs of the proxy URLs.
+func (c *endpointURLCollector) SetURLs(urls map[string]string) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.urls = urls
+}
+
+// Describe implements prometheus.Collector. The metrics of the remote storage
+// change with its configuration, so the collector is unchecked.
+func (c *endpointURLCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *endpointURLCollector) Collect(ch chan<- prometheus.Metric) {
+	families, err := c.reg.Gather()
+	if err != nil {
+		ch <- prometheus.NewInvalidMetric(prometheus.NewDesc("prometheus_remote_storage_error", "Error gathering remote storage metrics.", nil, nil), err)
+		return
+	}
+
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	for _, mf := range families {
+		desc := prometheus.NewDesc(mf.GetName(), mf.GetHelp(), nil, nil)
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if url, ok := c.urls[l.GetValue()]; ok && l.GetName() == "url" {
+					l.Value = proto.String(url)
+				}
+			}
+			ch <- &gatheredMetric{desc: desc, metric: m}
+		}
+	}
+}
+
+// gatheredMetric is a prometheus.Metric holding an already gathered metric.
+type gatheredMetric struct {
+	desc   *prometheus.Desc
+	metric *dto.Metric
+}
+
+func (m *gatheredMetric) Desc() *prometheus.Desc { return m.desc }
+
+func (m *gatheredMetric) Write(out *dto.Metric) error {
+	proto.Reset(out)
+	proto.Merge(out, m.metric)
+	return nil
+}
@@ -0,0 +1,74 @@
+package remotewrite
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/storage/remote"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointURLCollector(t *testing.T) {
+	c := newEndpointURLCollector()
+	for _, u := range []string{"http://127.0.0.1:1234/", "http://example.com/push"} {
+		counter := prometheus.NewCounter(prometheus.CounterOpts{
+			Name:        "prometheus_remote_storage_samples_total",
+			Help:        "Total number of samples sent to remote storage.",
+			ConstLabels: prometheus.Labels{"url": u},
+		})
+		counter.Add(2)
+		c.Registerer().MustRegister(counter)
+	}
+	c.SetURLs(map[string]string{"http://127.0.0.1:1234/": "https://endpoint.example.com/api/v1/push"})
+
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(c)
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP prometheus_remote_storage_samples_total Total number of samples sent to remote storage.
+		# TYPE prometheus_remote_storage_samples_total counter
+		prometheus_remote_storage_samples_total{url="http://example.com/push"} 2
+		prometheus_remote_storage_samples_total{url="https://endpoint.example.com/api/v1/push"} 2
+	`)))
+}
+
+func TestQueueName(t *testing.T) {
+	endpointURL, err := url.Parse("http://example.com/api/v1/push")
+	require.NoError(t, err)
+	rwCfg := config.DefaultRemoteWriteConfig
+	rwCfg.URL = &common.URL{URL: endpointURL}
+
+	name, err := queueName(&rwCfg)
+	require.NoError(t, err)
+
+	// The remote storage must name the queue of the config the same way.
+	reg := prometheus.NewRegistry()
+	s := remote.NewStorage(nil, reg, startTime, t.TempDir(), time.Second, nil)
+	defer s.Close()
+	require.NoError(t, s.ApplyConfig(&config.Config{
+		GlobalConfig:       config.DefaultGlobalConfig,
+		RemoteWriteConfigs: []*config.RemoteWriteConfig{&rwCfg},
+	}))
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var remoteNames []string
+	for _, mf := range families {
+		if mf.GetName() != "prometheus_remote_storage_samples_pending" {
+			continue
+		}
+		for _, m := range mf.GetMetric() {
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "remote_name" {
+					remoteNames = append(remoteNames, l.GetValue())
+				}
+			}
+		}
+	}
+	require.Equal(t, []string{name}, remoteNames)
+}
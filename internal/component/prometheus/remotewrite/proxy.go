@@ -0,0 +1,512 @@
+package remotewrite
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/common/model"
+	promsigv4 "github.com/prometheus/common/sigv4"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/prometheus/prometheus/storage/remote/azuread"
+)
+
+// endpointProxy sits between the remote_write queue manager and an endpoint
+// for features the upstream queue manager doesn't support:
+//
+//   - Batches can be translated into Remote-Write 2.0 requests. If the
+//     endpoint doesn't support Remote-Write 2.0, the proxy falls back to
+//     Remote-Write 1.0 for the endpoint for fallbackDuration.
+//   - Batches the endpoint permanently rejects can be recorded in a dead
+//     letter store before the queue manager drops them.
+//
+// Responses are passed back so that the queue manager retries exactly as if
+// it talked to the endpoint directly.
+//
+// The upstream remote storage builds its HTTP clients itself and offers no
+// way to wrap their transport, so the proxy listens on a loopback address.
+// The proxy holds the credentials of the endpoint, so every request must
+// carry the random token of the proxy in the proxyTokenHeader header; other
+// local processes can't use the proxy to reach the endpoint.
+type endpointProxy struct {
+	log      log.Logger
+	token    string
+	listener net.Listener
+	server   *http.Server
+	rejected *prometheus.CounterVec
+	metadata *metadataCache
+
+	mut             sync.RWMutex
+	url             string
+	client          *http.Client
+	retryOnHTTP429  bool
+	protobufMessage string
+	store           *deadLetterStore // nil if dead-lettering is disabled.
+
+	// fallbackUntil is set when the endpoint rejects a Remote-Write 2.0
+	// request with 415 Unsupported Media Type. Remote-Write 1.0 is used until
+	// then, after which Remote-Write 2.0 is tried again.
+	fallbackUntil time.Time
+}
+
+// proxyTokenHeader is the request header holding the token of the proxy.
+const proxyTokenHeader = "X-Agent-Endpoint-Proxy-Token"
+
+func newEndpointProxy(logger log.Logger, rejected *prometheus.CounterVec, metadata *metadataCache) (*endpointProxy, error) {
+	token := make([]byte, 32)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate endpoint proxy token: %w", err)
+	}
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, fmt.Errorf("failed to start endpoint proxy: %w", err)
+	}
+	p := &endpointProxy{
+		log:      logger,
+		token:    hex.EncodeToString(token),
+		listener: lis,
+		rejected: rejected,
+		metadata: metadata,
+	}
+	p.server = &http.Server{Handler: p}
+	go func() {
+		if err := p.server.Serve(lis); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			level.Error(logger).Log("msg", "endpoint proxy stopped unexpectedly", "err", err)
+		}
+	}()
+	return p, nil
+}
+
+// URL returns the URL the queue manager should send requests to.
+func (p *endpointProxy) URL() string {
+	return "http://" + p.listener.Addr().String() + "/"
+}
+
+// Headers returns the headers the queue manager must send to the proxy.
+func (p *endpointProxy) Headers() map[string]string {
+	return map[string]string{proxyTokenHeader: p.token}
+}
+
+// ApplyConfig updates the endpoint requests are forwarded to. The client is
+// built the same way the upstream remote_write client is so that
+// authentication and TLS settings behave identically. store may be nil.
+func (p *endpointProxy) ApplyConfig(rwCfg *config.RemoteWriteConfig, protobufMessage string, store *deadLetterStore) error {
+	client, err := common.NewClientFromConfig(rwCfg.HTTPClientConfig, "remote_storage_write_client")
+	if err != nil {
+		return err
+	}
+	t := client.Transport
+	if len(rwCfg.Headers) > 0 {
+		t = &injectHeadersRoundTripper{headers: rwCfg.Headers, next: t}
+	}
+	if rwCfg.SigV4Config != nil {
+		t, err = promsigv4.NewSigV4RoundTripper(rwCfg.SigV4Config, t)
+		if err != nil {
+			return err
+		}
+	}
+	if rwCfg.AzureADConfig != nil {
+		t, err = azuread.NewAzureADRoundTripper(rwCfg.AzureADConfig, t)
+		if err != nil {
+			return err
+		}
+	}
+	client.Transport = t
+
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	url := rwCfg.URL.String()
+	if p.url != url || p.protobufMessage != protobufMessage {
+		// Give a changed endpoint the chance to accept Remote-Write 2.0.
+		p.fallbackUntil = time.Time{}
+	}
+	p.url = url
+	p.client = client
+	p.retryOnHTTP429 = rwCfg.QueueConfig.RetryOnRateLimit
+	p.protobufMessage = protobufMessage
+	p.store = store
+	return nil
+}
+
+// forwardedHeaders are the request headers set by the remote_write client
+// which are passed on to the endpoint.
+var forwardedHeaders = []string{
+	"Content-Encoding",
+	"Content-Type",
+	"User-Agent",
+	"X-Prometheus-Remote-Write-Version",
+	"Retry-Attempt",
+}
+
+// proxyResponse is a response received from the endpoint.
+type proxyResponse struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+func (p *endpointProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if subtle.ConstantTimeCompare([]byte(r.Header.Get(proxyTokenHeader)), []byte(p.token)) != 1 {
+		http.Error(w, "invalid endpoint proxy token", http.StatusUnauthorized)
+		return
+	}
+
+	p.mut.RLock()
+	var (
+		url            = p.url
+		client         = p.client
+		retryOnHTTP429 = p.retryOnHTTP429
+		store          = p.store
+		useV2          = p.protobufMessage == writev2.ProtobufMessageV2 && time.Now().After(p.fallbackUntil)
+	)
+	p.mut.RUnlock()
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	headers := make(map[string]string, len(forwardedHeaders))
+	for _, h := range forwardedHeaders {
+		if v := r.Header.Get(h); v != "" {
+			headers[h] = v
+		}
+	}
+
+	var resp *proxyResponse
+	if useV2 {
+		v2Body, err := p.translate(body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if v2Body == nil {
+			// Metadata-only requests have been added to the metadata cache and
+			// are sent along with the next samples of their series.
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+
+		v2Headers := map[string]string{
+			"Content-Encoding":                  "snappy",
+			"Content-Type":                      writev2.ContentType,
+			"X-Prometheus-Remote-Write-Version": writev2.VersionHeaderValue,
+		}
+		for _, h := range []string{"User-Agent", "Retry-Attempt"} {
+			if v, ok := headers[h]; ok {
+				v2Headers[h] = v
+			}
+		}
+		resp, err = do(r.Context(), client, url, v2Body, v2Headers)
+		if err != nil {
+			// Network errors are retried by the queue manager, so report them as
+			// a server-side error.
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		if resp.StatusCode == http.StatusUnsupportedMediaType {
+			level.Warn(p.log).Log("msg", "endpoint does not support Remote-Write 2.0, falling back to Remote-Write 1.0", "retry_in", fallbackDuration)
+			p.setFallback(url)
+			useV2 = false
+		}
+	}
+	if !useV2 {
+		resp, err = do(r.Context(), client, url, body, headers)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+	}
+
+	// Dead-lettered batches are always stored as the Remote-Write 1.0 request
+	// received from the queue manager so that they can be replayed to any
+	// endpoint.
+	if store != nil && isPermanentRejection(resp.StatusCode, retryOnHTTP429) {
+		reason := strings.TrimSpace(string(resp.Body))
+		if len(reason) > maxDeadLetterReasonLen {
+			reason = reason[:maxDeadLetterReasonLen]
+		}
+		rec := &DeadLetterRecord{
+			Timestamp:  time.Now(),
+			URL:        url,
+			StatusCode: resp.StatusCode,
+			Reason:     reason,
+			Headers:    headers,
+			Body:       body,
+		}
+		if err := store.Write(rec); err != nil {
+			level.Error(p.log).Log("msg", "failed to write rejected batch to dead letter directory", "err", err)
+		} else {
+			p.rejected.WithLabelValues(url).Inc()
+		}
+	}
+
+	for _, h := range []string{"Content-Type", "Retry-After"} {
+		if v := resp.Header.Get(h); v != "" {
+			w.Header().Set(h, v)
+		}
+	}
+	w.WriteHeader(resp.StatusCode)
+	_, _ = w.Write(resp.Body)
+}
+
+// translate converts a snappy-compressed Remote-Write 1.0 request into a
+// snappy-compressed Remote-Write 2.0 request. It returns nil if the request
+// only holds metadata.
+func (p *endpointProxy) translate(body []byte) ([]byte, error) {
+	data, err := snappy.Decode(nil, body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress request: %w", err)
+	}
+	var req prompb.WriteRequest
+	if err := req.Unmarshal(data); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal request: %w", err)
+	}
+	if len(req.Timeseries) == 0 {
+		for _, m := range req.Metadata {
+			p.metadata.SetMetric(m.MetricFamilyName, metadata.Metadata{
+				Type: textparse.MetricType(strings.ToLower(m.Type.String())),
+				Help: m.Help,
+				Unit: m.Unit,
+			})
+		}
+		return nil, nil
+	}
+	return snappy.Encode(nil, writev2.FromV1(&req, p.metadata.Get, p.metadata.CreatedTimestamp).Marshal()), nil
+}
+
+// fallbackDuration is how long Remote-Write 1.0 is used after an endpoint
+// rejected a Remote-Write 2.0 request.
+var fallbackDuration = 10 * time.Minute
+
+func (p *endpointProxy) setFallback(url string) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	if p.url == url {
+		p.fallbackUntil = time.Now().Add(fallbackDuration)
+	}
+}
+
+func do(ctx context.Context, client *http.Client, url string, body []byte, headers map[string]string) (*proxyResponse, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return &proxyResponse{StatusCode: resp.StatusCode, Header: resp.Header, Body: respBody}, nil
+}
+
+// isPermanentRejection reports whether the queue manager drops a batch which
+// received the given status code instead of retrying it.
+func isPermanentRejection(statusCode int, retryOnHTTP429 bool) bool {
+	if statusCode == http.StatusTooManyRequests && retryOnHTTP429 {
+		return false
+	}
+	return statusCode/100 == 4
+}
+
+// Close stops the proxy. Requests in flight are given a short time to finish.
+func (p *endpointProxy) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	return p.server.Shutdown(ctx)
+}
+
+type injectHeadersRoundTripper struct {
+	headers map[string]string
+	next    http.RoundTripper
+}
+
+func (t *injectHeadersRoundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+	return t.next.RoundTrip(req)
+}
+
+// metadataCacheSize is the maximum number of entries of a metadataCache.
+const metadataCacheSize = 100_000
+
+// metadataCache holds the most recent metadata and created timestamps of the
+// series appended to the component, which Remote-Write 2.0 sends alongside
+// every series.
+//
+// Metadata is stored per series, as appended, and per metric name, as sent by
+// the queue manager. Lookups prefer the metadata of the series, and fall back
+// to the metadata of its metric name when the series has none, for example
+// because write_relabel_config changed its labels. Created timestamps are
+// only stored per series. The least recently used entries are evicted once
+// the cache holds metadataCacheSize entries.
+type metadataCache struct {
+	mut     sync.Mutex
+	size    int
+	lru     *list.List // Elements are *metadataEntry, most recently used first.
+	series  map[uint64][]*list.Element
+	metrics map[string]*list.Element
+}
+
+type metadataEntry struct {
+	series  labels.Labels // Empty for entries of a metric name.
+	hash    uint64
+	name    string
+	meta    metadata.Metadata
+	hasMeta bool
+	ct      int64 // Created timestamp of the series, 0 if unknown.
+}
+
+func newMetadataCache(size int) *metadataCache {
+	return &metadataCache{
+		size:    size,
+		lru:     list.New(),
+		series:  make(map[uint64][]*list.Element),
+		metrics: make(map[string]*list.Element),
+	}
+}
+
+// SetSeries sets the metadata of a series.
+func (c *metadataCache) SetSeries(series labels.Labels, m metadata.Metadata) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	e := c.seriesEntry(series)
+	e.meta, e.hasMeta = m, true
+}
+
+// SetCreatedTimestamp sets the created timestamp of a series.
+func (c *metadataCache) SetCreatedTimestamp(series labels.Labels, ct int64) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	c.seriesEntry(series).ct = ct
+}
+
+// SetMetric sets the metadata of a metric name.
+func (c *metadataCache) SetMetric(name string, m metadata.Metadata) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if el, ok := c.metrics[name]; ok {
+		el.Value.(*metadataEntry).meta = m
+		c.lru.MoveToFront(el)
+		return
+	}
+	c.metrics[name] = c.lru.PushFront(&metadataEntry{name: name, meta: m, hasMeta: true})
+	c.evict()
+}
+
+// Get returns the metadata of a series. It implements writev2.MetadataLookup.
+func (c *metadataCache) Get(series labels.Labels) (metadata.Metadata, bool) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	el := c.findSeries(series.Hash(), series)
+	if el == nil || !el.Value.(*metadataEntry).hasMeta {
+		el = c.metrics[series.Get(model.MetricNameLabel)]
+	}
+	if el == nil {
+		return metadata.Metadata{}, false
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*metadataEntry).meta, true
+}
+
+// CreatedTimestamp returns the created timestamp of a series, or 0 if it is
+// unknown. It implements writev2.CreatedTimestampLookup.
+func (c *metadataCache) CreatedTimestamp(series labels.Labels) int64 {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	el := c.findSeries(series.Hash(), series)
+	if el == nil {
+		return 0
+	}
+	c.lru.MoveToFront(el)
+	return el.Value.(*metadataEntry).ct
+}
+
+// seriesEntry returns the entry of a series, adding it if needed. It must be
+// called with c.mut held.
+func (c *metadataCache) seriesEntry(series labels.Labels) *metadataEntry {
+	hash := series.Hash()
+	if el := c.findSeries(hash, series); el != nil {
+		c.lru.MoveToFront(el)
+		return el.Value.(*metadataEntry)
+	}
+	e := &metadataEntry{series: series, hash: hash}
+	el := c.lru.PushFront(e)
+	c.series[hash] = append(c.series[hash], el)
+	c.evict()
+	return e
+}
+
+// Len returns the number of entries in the cache.
+func (c *metadataCache) Len() int {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.lru.Len()
+}
+
+func (c *metadataCache) findSeries(hash uint64, series labels.Labels) *list.Element {
+	for _, el := range c.series[hash] {
+		if labels.Equal(el.Value.(*metadataEntry).series, series) {
+			return el
+		}
+	}
+	return nil
+}
+
+// evict removes the least recently used entries until the cache is within
+// its size. It must be called with c.mut held.
+func (c *metadataCache) evict() {
+	for c.lru.Len() > c.size {
+		el := c.lru.Back()
+		c.lru.Remove(el)
+
+		e := el.Value.(*metadataEntry)
+		if e.series.IsEmpty() {
+			delete(c.metrics, e.name)
+			continue
+		}
+		elems := c.series[e.hash]
+		for i, other := range elems {
+			if other == el {
+				elems = append(elems[:i], elems[i+1:]...)
+				break
+			}
+		}
+		if len(elems) == 0 {
+			delete(c.series, e.hash)
+		} else {
+			c.series[e.hash] = elems
+		}
+	}
+}
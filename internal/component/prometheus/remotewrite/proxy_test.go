@@ -0,0 +1,188 @@
+package remotewrite
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/golang/snappy"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
+	"github.com/prometheus/client_golang/prometheus"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/config"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
+	"github.com/prometheus/prometheus/prompb"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestEndpointProxy_Token(t *testing.T) {
+	var (
+		requests atomic.Int32
+		user     atomic.String
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Inc()
+		u, _, _ := r.BasicAuth()
+		user.Store(u)
+		require.Empty(t, r.Header.Get(proxyTokenHeader))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "rejected"}, []string{"url"})
+	p, err := newEndpointProxy(log.NewNopLogger(), rejected, newMetadataCache(metadataCacheSize))
+	require.NoError(t, err)
+	defer p.Close()
+
+	endpointURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	rwCfg := config.DefaultRemoteWriteConfig
+	rwCfg.URL = &common.URL{URL: endpointURL}
+	rwCfg.HTTPClientConfig.BasicAuth = &common.BasicAuth{Username: "user", Password: "secret"}
+	require.NoError(t, p.ApplyConfig(&rwCfg, "", nil))
+
+	send := func(headers map[string]string) int {
+		req, err := http.NewRequest(http.MethodPost, p.URL(), strings.NewReader("body"))
+		require.NoError(t, err)
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(req)
+		require.NoError(t, err)
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	// Requests without the token of the proxy never reach the endpoint.
+	require.Equal(t, http.StatusUnauthorized, send(nil))
+	require.Equal(t, http.StatusUnauthorized, send(map[string]string{proxyTokenHeader: "wrong"}))
+	require.Equal(t, int32(0), requests.Load())
+
+	require.Equal(t, http.StatusNoContent, send(p.Headers()))
+	require.Equal(t, int32(1), requests.Load())
+	require.Equal(t, "user", user.Load())
+}
+
+func TestEndpointProxy_RetryV2(t *testing.T) {
+	defer func(d time.Duration) { fallbackDuration = d }(fallbackDuration)
+	fallbackDuration = 100 * time.Millisecond
+
+	var (
+		acceptV2 atomic.Bool
+		v1, v2   atomic.Int32
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Content-Type") != writev2.ContentType {
+			v1.Inc()
+			return
+		}
+		if !acceptV2.Load() {
+			http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+			return
+		}
+		v2.Inc()
+	}))
+	defer srv.Close()
+
+	rejected := prometheus.NewCounterVec(prometheus.CounterOpts{Name: "rejected"}, []string{"url"})
+	p, err := newEndpointProxy(log.NewNopLogger(), rejected, newMetadataCache(metadataCacheSize))
+	require.NoError(t, err)
+	defer p.Close()
+
+	endpointURL, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	rwCfg := config.DefaultRemoteWriteConfig
+	rwCfg.URL = &common.URL{URL: endpointURL}
+	require.NoError(t, p.ApplyConfig(&rwCfg, writev2.ProtobufMessageV2, nil))
+
+	req := prompb.WriteRequest{Timeseries: []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "__name__", Value: "up"}},
+		Samples: []prompb.Sample{{Timestamp: 1000, Value: 1}},
+	}}}
+	data, err := req.Marshal()
+	require.NoError(t, err)
+	body := snappy.Encode(nil, data)
+
+	send := func() {
+		r, err := http.NewRequest(http.MethodPost, p.URL(), bytes.NewReader(body))
+		require.NoError(t, err)
+		for k, v := range p.Headers() {
+			r.Header.Set(k, v)
+		}
+		resp, err := http.DefaultClient.Do(r)
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	}
+
+	// The endpoint rejects Remote-Write 2.0, so the proxy falls back to 1.0.
+	send()
+	send()
+	require.Equal(t, int32(2), v1.Load())
+
+	// Once the fallback expired, Remote-Write 2.0 is tried again.
+	acceptV2.Store(true)
+	time.Sleep(2 * fallbackDuration)
+	send()
+	require.Equal(t, int32(1), v2.Load())
+	require.Equal(t, int32(2), v1.Load())
+}
+
+func TestMetadataCache(t *testing.T) {
+	var (
+		counter = metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Total requests."}
+		gauge   = metadata.Metadata{Type: textparse.MetricTypeGauge, Help: "In-flight requests."}
+		family  = metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Requests."}
+
+		seriesA = labels.FromStrings("__name__", "requests", "job", "a")
+		seriesB = labels.FromStrings("__name__", "requests", "job", "b")
+		seriesC = labels.FromStrings("__name__", "requests", "job", "c")
+	)
+
+	c := newMetadataCache(3)
+	c.SetSeries(seriesA, counter)
+	c.SetSeries(seriesB, gauge)
+	c.SetMetric("requests", family)
+
+	// Series with the same metric name keep their own metadata, and other
+	// series fall back to the metadata of their metric name.
+	for _, tc := range []struct {
+		series labels.Labels
+		expect metadata.Metadata
+	}{
+		{seriesA, counter},
+		{seriesB, gauge},
+		{seriesC, family},
+	} {
+		m, ok := c.Get(tc.series)
+		require.True(t, ok)
+		require.Equal(t, tc.expect, m)
+	}
+
+	// Adding an entry evicts the least recently used one, seriesA.
+	c.SetSeries(seriesC, gauge)
+	require.Equal(t, 3, c.Len())
+	m, ok := c.Get(seriesA)
+	require.True(t, ok)
+	require.Equal(t, family, m)
+	m, ok = c.Get(seriesC)
+	require.True(t, ok)
+	require.Equal(t, gauge, m)
+
+	// Series with only a created timestamp still use the metadata of their
+	// metric name.
+	c.SetCreatedTimestamp(seriesA, 1000)
+	require.Equal(t, int64(1000), c.CreatedTimestamp(seriesA))
+	require.Equal(t, int64(0), c.CreatedTimestamp(seriesC))
+	m, ok = c.Get(seriesA)
+	require.True(t, ok)
+	require.Equal(t, family, m)
+}
@@ -2,8 +2,11 @@ package remotewrite
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/hex"
 	"fmt"
 	"math"
+	"net/url"
 	"os"
 	"path/filepath"
 	"sync"
@@ -13,11 +16,15 @@ import (
 	"github.com/grafana/agent/internal/agentseed"
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/prometheus"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
 	"github.com/grafana/agent/internal/service/labelstore"
 	"github.com/grafana/agent/internal/useragent"
 	"github.com/grafana/agent/static/metrics/wal"
+	promclient "github.com/prometheus/client_golang/prometheus"
+	common "github.com/prometheus/common/config"
+	"github.com/prometheus/prometheus/config"
 	"github.com/prometheus/prometheus/model/exemplar"
 	"github.com/prometheus/prometheus/model/histogram"
 	"github.com/prometheus/prometheus/model/labels"
@@ -26,6 +33,7 @@ import (
 	"github.com/prometheus/prometheus/storage"
 	"github.com/prometheus/prometheus/storage/remote"
 	"go.uber.org/atomic"
+	"gopkg.in/yaml.v2"
 )
 
 // Options.
@@ -53,14 +61,21 @@ type Component struct {
 	log  log.Logger
 	opts component.Options
 
-	walStore    *wal.Storage
-	remoteStore *remote.Storage
-	storage     storage.Storage
-	exited      atomic.Bool
+	walStore      *wal.Storage
+	remoteStore   *remote.Storage
+	remoteMetrics *endpointURLCollector
+	storage       storage.Storage
+	exited        atomic.Bool
 
 	mut sync.RWMutex
 	cfg Arguments
 
+	// proxies holds the endpoint proxies of endpoints which use Remote-Write
+	// 2.0 or have a dead_letter block, keyed by endpointProxyKey.
+	proxies            map[string]*endpointProxy
+	metadata           *metadataCache
+	deadLetterRejected *promclient.CounterVec
+
 	receiver *prometheus.Interceptor
 }
 
@@ -81,8 +96,16 @@ func New(o component.Options, c Arguments) (*Component, error) {
 		return nil, err
 	}
 
+	// The metrics of the remote storage are registered through a collector
+	// which labels the metrics of endpoints sent through an endpoint proxy
+	// with the URL of the endpoint instead of the address of the proxy.
+	remoteMetrics := newEndpointURLCollector()
+	if err := o.Registerer.Register(remoteMetrics); err != nil {
+		return nil, err
+	}
+
 	remoteLogger := log.With(o.Logger, "subcomponent", "rw")
-	remoteStore := remote.NewStorage(remoteLogger, o.Registerer, startTime, o.DataPath, remoteFlushDeadline, nil)
+	remoteStore := remote.NewStorage(remoteLogger, remoteMetrics.Registerer(), startTime, o.DataPath, remoteFlushDeadline, nil)
 
 	service, err := o.GetServiceData(labelstore.ServiceName)
 	if err != nil {
@@ -90,12 +113,24 @@ func New(o component.Options, c Arguments) (*Component, error) {
 	}
 	ls := service.(labelstore.LabelStore)
 
+	deadLetterRejected := promclient.NewCounterVec(promclient.CounterOpts{
+		Name: "prometheus_remote_write_dead_letter_batches_total",
+		Help: "Total number of batches rejected by an endpoint and written to its dead letter directory.",
+	}, []string{"url"})
+	if err := o.Registerer.Register(deadLetterRejected); err != nil {
+		return nil, err
+	}
+
 	res := &Component{
-		log:         o.Logger,
-		opts:        o,
-		walStore:    walStorage,
-		remoteStore: remoteStore,
-		storage:     storage.NewFanout(o.Logger, walStorage, remoteStore),
+		log:                o.Logger,
+		opts:               o,
+		walStore:           walStorage,
+		remoteStore:        remoteStore,
+		remoteMetrics:      remoteMetrics,
+		storage:            storage.NewFanout(o.Logger, walStorage, remoteStore),
+		proxies:            make(map[string]*endpointProxy),
+		metadata:           newMetadataCache(metadataCacheSize),
+		deadLetterRejected: deadLetterRejected,
 	}
 	res.receiver = prometheus.NewInterceptor(
 		res.storage,
@@ -136,6 +171,8 @@ func New(o component.Options, c Arguments) (*Component, error) {
 				return 0, fmt.Errorf("%s has exited", o.ID)
 			}
 
+			res.metadata.SetSeries(l, m)
+
 			localID := ls.GetLocalRefID(res.opts.ID, uint64(globalRef))
 			newRef, nextErr := next.UpdateMetadata(storage.SeriesRef(localID), l, m)
 			if localID == 0 {
@@ -143,6 +180,18 @@ func New(o component.Options, c Arguments) (*Component, error) {
 			}
 			return globalRef, nextErr
 		}),
+		prometheus.WithCreatedTimestampHook(func(globalRef storage.SeriesRef, l labels.Labels, t, ct int64, next storage.Appender) (storage.SeriesRef, error) {
+			if res.exited.Load() {
+				return 0, fmt.Errorf("%s has exited", o.ID)
+			}
+
+			// Created timestamps are only sent to Remote-Write 2.0 endpoints,
+			// alongside the samples of their series.
+			if ct < t {
+				res.metadata.SetCreatedTimestamp(l, ct)
+			}
+			return globalRef, nil
+		}),
 		prometheus.WithExemplarHook(func(globalRef storage.SeriesRef, l labels.Labels, e exemplar.Exemplar, next storage.Appender) (storage.SeriesRef, error) {
 			if res.exited.Load() {
 				return 0, fmt.Errorf("%s has exited", o.ID)
@@ -182,6 +231,14 @@ func (c *Component) Run(ctx context.Context) error {
 		if err != nil {
 			level.Error(c.log).Log("msg", "error when closing storage", "err", err)
 		}
+
+		// Endpoint proxies are closed after the storage so that the final
+		// flush of the queues can still reach the endpoints.
+		c.mut.Lock()
+		defer c.mut.Unlock()
+		for _, p := range c.proxies {
+			_ = p.Close()
+		}
 	}()
 
 	// Track the last timestamp we truncated for to prevent segments from getting
@@ -264,11 +321,134 @@ func (c *Component) Update(newConfig component.Arguments) error {
 		}
 		cfg.Headers[agentseed.HeaderName] = uid
 	}
+
+	proxies, endpointURLs, err := c.applyProxies(cfg, convertedConfig)
+	if err != nil {
+		return err
+	}
 	err = c.remoteStore.ApplyConfig(convertedConfig)
 	if err != nil {
+		closeUnusedProxies(proxies, c.proxies)
 		return err
 	}
+	closeUnusedProxies(c.proxies, proxies)
+	c.proxies = proxies
+	c.remoteMetrics.SetURLs(endpointURLs)
 
 	c.cfg = cfg
 	return nil
 }
+
+// applyProxies routes every endpoint which uses Remote-Write 2.0 or has a
+// dead_letter block through an endpoint proxy, reusing the proxies from the
+// previous configuration where possible. The converted remote_write configs
+// are modified to send to the proxy, which applies the original
+// authentication, TLS and header settings when forwarding to the endpoint.
+// The queue manager only sends the token of the proxy.
+//
+// Queues of endpoints without a name keep the name they would have without
+// the proxy, so that the remote_name label of their metrics doesn't change.
+// applyProxies also returns the endpoint URLs of the proxy URLs, to label
+// the metrics of the queues with the URL of their endpoint.
+//
+// It must be called with c.mut held.
+func (c *Component) applyProxies(args Arguments, converted *config.Config) (map[string]*endpointProxy, map[string]string, error) {
+	var (
+		proxies      = make(map[string]*endpointProxy)
+		endpointURLs = make(map[string]string)
+	)
+	fail := func(err error) (map[string]*endpointProxy, map[string]string, error) {
+		closeUnusedProxies(proxies, c.proxies)
+		return nil, nil, err
+	}
+
+	for i, ep := range args.Endpoints {
+		if ep.DeadLetter == nil && ep.ProtobufMessage != writev2.ProtobufMessageV2 {
+			continue
+		}
+		rwCfg := converted.RemoteWriteConfigs[i]
+
+		key := endpointProxyKey(c.opts.DataPath, ep)
+		if _, exists := proxies[key]; exists {
+			if ep.DeadLetter != nil {
+				return fail(fmt.Errorf("multiple endpoints use the dead letter directory %q", key))
+			}
+			return fail(fmt.Errorf("multiple endpoints use the same name or URL %q", key))
+		}
+
+		var store *deadLetterStore
+		if ep.DeadLetter != nil {
+			var err error
+			store, err = newDeadLetterStore(key, ep.DeadLetter.MaxSizeBytes)
+			if err != nil {
+				return fail(err)
+			}
+		}
+
+		proxy, ok := c.proxies[key]
+		if !ok {
+			var err error
+			proxy, err = newEndpointProxy(log.With(c.log, "subcomponent", "endpoint_proxy", "url", ep.URL), c.deadLetterRejected, c.metadata)
+			if err != nil {
+				return fail(err)
+			}
+		}
+		proxies[key] = proxy
+		if err := proxy.ApplyConfig(rwCfg, ep.ProtobufMessage, store); err != nil {
+			return fail(err)
+		}
+
+		if rwCfg.Name == "" {
+			name, err := queueName(rwCfg)
+			if err != nil {
+				return fail(err)
+			}
+			rwCfg.Name = name
+		}
+		proxyURL, err := url.Parse(proxy.URL())
+		if err != nil {
+			return fail(err)
+		}
+		endpointURLs[proxyURL.Redacted()] = rwCfg.URL.Redacted()
+
+		rwCfg.URL = &common.URL{URL: proxyURL}
+		rwCfg.HTTPClientConfig = common.DefaultHTTPClientConfig
+		rwCfg.Headers = proxy.Headers()
+		rwCfg.SigV4Config = nil
+		rwCfg.AzureADConfig = nil
+	}
+	return proxies, endpointURLs, nil
+}
+
+// queueName returns the name the remote storage gives to the queue of an
+// unnamed remote_write config: the start of the hash of the config.
+func queueName(rwCfg *config.RemoteWriteConfig) (string, error) {
+	data, err := yaml.Marshal(rwCfg)
+	if err != nil {
+		return "", err
+	}
+	hash := md5.Sum(data)
+	return hex.EncodeToString(hash[:])[:6], nil
+}
+
+// endpointProxyKey identifies the proxy of an endpoint across configuration
+// updates. Endpoints with a dead_letter block are identified by their dead
+// letter directory, and other endpoints by their name or URL.
+func endpointProxyKey(dataPath string, ep *EndpointOptions) string {
+	if ep.DeadLetter != nil {
+		return deadLetterDirectory(dataPath, ep)
+	}
+	if ep.Name != "" {
+		return ep.Name
+	}
+	return ep.URL
+}
+
+// closeUnusedProxies closes the proxies in from which are not in keep.
+func closeUnusedProxies(from, keep map[string]*endpointProxy) {
+	for key, p := range from {
+		if keep[key] != p {
+			_ = p.Close()
+		}
+	}
+}
@@ -3,19 +3,25 @@ package remotewrite_test
 import (
 	"context"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 	"time"
 
+	"github.com/golang/snappy"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
 	"github.com/grafana/agent/internal/component/prometheus/remotewrite"
 	"github.com/grafana/agent/internal/flow/componenttest"
 	"github.com/grafana/agent/internal/util"
 	"github.com/grafana/river"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/model/textparse"
 	"github.com/prometheus/prometheus/prompb"
 	"github.com/prometheus/prometheus/storage/remote"
 	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
 )
 
 // Test is an integration-level test which ensures that metrics can get sent to
@@ -181,6 +187,168 @@ func TestUpdate(t *testing.T) {
 	}})
 }
 
+func TestDeadLetter(t *testing.T) {
+	var tenant atomic.String
+
+	// Create a remote_write server which rejects every request as if all
+	// samples were out of order.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant.Store(r.Header.Get("X-Scope-OrgID"))
+		http.Error(w, "out of order sample", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	args := testArgsForConfig(t, fmt.Sprintf(`
+		endpoint {
+			name           = "test-url"
+			url            = "%s/api/v1/write"
+			remote_timeout = "100ms"
+			headers        = { "X-Scope-OrgID" = "tenant-a" }
+
+			queue_config {
+				batch_send_deadline = "100ms"
+			}
+
+			dead_letter {
+				directory = %q
+			}
+		}
+	`, srv.URL, dir))
+	tc, err := componenttest.NewControllerFromID(util.TestLogger(t), "prometheus.remote_write")
+	require.NoError(t, err)
+	go func() {
+		err = tc.Run(componenttest.TestContext(t), args)
+		require.NoError(t, err)
+	}()
+	require.NoError(t, tc.WaitRunning(5*time.Second))
+
+	sampleTimestamp := time.Now().Add(time.Minute).UnixMilli()
+	sendMetric(t, tc, labels.FromStrings("foo", "bar"), sampleTimestamp, 12)
+
+	var files []remotewrite.DeadLetterFile
+	require.Eventually(t, func() bool {
+		files, err = remotewrite.ListDeadLetters(dir)
+		return err == nil && len(files) > 0
+	}, time.Minute, 50*time.Millisecond, "rejected batch was never written to the dead letter directory")
+
+	// Headers are applied by the proxy in front of the endpoint.
+	require.Equal(t, "tenant-a", tenant.Load())
+
+	rec, err := remotewrite.ReadDeadLetter(files[0].Path)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusBadRequest, rec.StatusCode)
+	require.Equal(t, "out of order sample", rec.Reason)
+	require.Equal(t, srv.URL+"/api/v1/write", rec.URL)
+
+	req, err := rec.Decode()
+	require.NoError(t, err)
+	require.Equal(t, []prompb.TimeSeries{{
+		Labels:  []prompb.Label{{Name: "foo", Value: "bar"}},
+		Samples: []prompb.Sample{{Timestamp: sampleTimestamp, Value: 12}},
+	}}, req.Timeseries)
+}
+
+func TestRemoteWriteV2(t *testing.T) {
+	tt := []struct {
+		name     string
+		acceptV2 bool
+		expectV2 bool
+	}{
+		{name: "endpoint supports 2.0", acceptV2: true, expectV2: true},
+		{name: "endpoint falls back to 1.0", acceptV2: false, expectV2: false},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			type result struct {
+				v2  *writev2.Request
+				v1  *prompb.WriteRequest
+				err error
+			}
+			results := make(chan result, 10)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Header.Get("Content-Type") == writev2.ContentType {
+					if !tc.acceptV2 {
+						http.Error(w, "unsupported", http.StatusUnsupportedMediaType)
+						return
+					}
+					var res result
+					body, err := io.ReadAll(r.Body)
+					if err == nil {
+						body, err = snappy.Decode(nil, body)
+					}
+					if err == nil {
+						res.v2 = &writev2.Request{}
+						err = res.v2.Unmarshal(body)
+					}
+					res.err = err
+					results <- res
+					return
+				}
+				req, err := remote.DecodeWriteRequest(r.Body)
+				results <- result{v1: req, err: err}
+			}))
+			defer srv.Close()
+
+			args := testArgsForConfig(t, fmt.Sprintf(`
+				endpoint {
+					url              = "%s/api/v1/write"
+					protobuf_message = "io.prometheus.write.v2.Request"
+
+					queue_config {
+						batch_send_deadline = "100ms"
+					}
+				}
+			`, srv.URL))
+			ctrl, err := componenttest.NewControllerFromID(util.TestLogger(t), "prometheus.remote_write")
+			require.NoError(t, err)
+			go func() {
+				err = ctrl.Run(componenttest.TestContext(t), args)
+				require.NoError(t, err)
+			}()
+			require.NoError(t, ctrl.WaitRunning(5*time.Second))
+
+			sampleTimestamp := time.Now().Add(time.Minute).UnixMilli()
+			lbls := labels.FromStrings("__name__", "requests_total", "job", "api")
+			app := ctrl.Exports().(remotewrite.Exports).Receiver.Appender(context.Background())
+			_, err = app.UpdateMetadata(0, lbls, metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Total requests."})
+			require.NoError(t, err)
+			_, err = app.Append(0, lbls, sampleTimestamp, 12)
+			require.NoError(t, err)
+			require.NoError(t, app.Commit())
+
+			var res result
+			select {
+			case <-time.After(time.Minute):
+				require.FailNow(t, "timed out waiting for metrics")
+			case res = <-results:
+			}
+			require.NoError(t, res.err)
+
+			if !tc.expectV2 {
+				require.Nil(t, res.v2)
+				require.Equal(t, []prompb.TimeSeries{{
+					Labels:  []prompb.Label{{Name: "__name__", Value: "requests_total"}, {Name: "job", Value: "api"}},
+					Samples: []prompb.Sample{{Timestamp: sampleTimestamp, Value: 12}},
+				}}, res.v1.Timeseries)
+				return
+			}
+
+			require.Len(t, res.v2.Timeseries, 1)
+			ts := res.v2.Timeseries[0]
+			actual, err := res.v2.Labels(ts.LabelsRefs)
+			require.NoError(t, err)
+			require.Equal(t, lbls, actual)
+			require.Equal(t, []writev2.Sample{{Timestamp: sampleTimestamp, Value: 12}}, ts.Samples)
+			md, err := res.v2.Metadata(ts.Metadata)
+			require.NoError(t, err)
+			require.Equal(t, metadata.Metadata{Type: textparse.MetricTypeCounter, Help: "Total requests."}, md)
+		})
+	}
+}
+
 func assertReceived(t *testing.T, writeResult chan *prompb.WriteRequest, expect []prompb.TimeSeries) {
 	select {
 	case <-time.After(time.Minute):
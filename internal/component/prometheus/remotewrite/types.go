@@ -8,6 +8,7 @@ import (
 
 	types "github.com/grafana/agent/internal/component/common/config"
 	flow_relabel "github.com/grafana/agent/internal/component/common/relabel"
+	"github.com/grafana/agent/internal/component/prometheus/internal/writev2"
 	"github.com/grafana/river/rivertypes"
 
 	"github.com/google/uuid"
@@ -20,6 +21,9 @@ import (
 	"github.com/prometheus/prometheus/storage/remote/azuread"
 )
 
+// DefaultProtobufMessage is the protobuf message endpoints send by default.
+const DefaultProtobufMessage = writev2.ProtobufMessageV1
+
 // Defaults for config blocks.
 var (
 	DefaultArguments = Arguments{
@@ -79,6 +83,8 @@ type EndpointOptions struct {
 	WriteRelabelConfigs  []*flow_relabel.Config  `river:"write_relabel_config,block,optional"`
 	SigV4                *SigV4Config            `river:"sigv4,block,optional"`
 	AzureAD              *AzureADConfig          `river:"azuread,block,optional"`
+	DeadLetter           *DeadLetterOptions      `river:"dead_letter,block,optional"`
+	ProtobufMessage      string                  `river:"protobuf_message,attr,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -87,6 +93,7 @@ func (r *EndpointOptions) SetToDefault() {
 		RemoteTimeout:    30 * time.Second,
 		SendExemplars:    true,
 		HTTPClientConfig: types.CloneDefaultHTTPClientConfig(),
+		ProtobufMessage:  DefaultProtobufMessage,
 	}
 }
 
@@ -121,6 +128,12 @@ func (r *EndpointOptions) Validate() error {
 		}
 	}
 
+	switch r.ProtobufMessage {
+	case writev2.ProtobufMessageV1, writev2.ProtobufMessageV2:
+	default:
+		return fmt.Errorf("unsupported protobuf_message %q, must be %q or %q", r.ProtobufMessage, writev2.ProtobufMessageV1, writev2.ProtobufMessageV2)
+	}
+
 	if r.WriteRelabelConfigs != nil {
 		for _, relabelConfig := range r.WriteRelabelConfigs {
 			if err := relabelConfig.Validate(); err != nil {
@@ -0,0 +1,143 @@
+package scrape
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/agent/internal/component/discovery"
+	"github.com/grafana/agent/internal/service/cluster"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/scrape"
+	"github.com/prometheus/prometheus/storage"
+)
+
+// replicaKeyLabel is added to the discovered labels of replicated targets so
+// that the appender of a scrape can tell which target it belongs to. It's a
+// meta label, so it's never added to the scraped series.
+const replicaKeyLabel = model.MetaLabelPrefix + "agent_scrape_replica_key"
+
+// Clustering configures how prometheus.scrape distributes targets when
+// running in a cluster.
+type Clustering struct {
+	Enabled           bool `river:"enabled,attr"`
+	ReplicationFactor int  `river:"replication_factor,attr,optional"`
+
+	// ZoneLabel enables zone-aware sharding, where targets are assigned to the
+	// nodes in the zone read from this label of the target.
+	ZoneLabel    string               `river:"zone_label,attr,optional"`
+	ZoneFallback cluster.ZoneFallback `river:"zone_fallback,attr,optional"`
+}
+
+// SetToDefault implements river.Defaulter.
+func (c *Clustering) SetToDefault() {
+	*c = Clustering{ReplicationFactor: 1, ZoneFallback: cluster.ZoneFallbackAny}
+}
+
+// Validate implements river.Validator.
+func (c *Clustering) Validate() error {
+	if c.ReplicationFactor < 1 {
+		return fmt.Errorf("replication_factor must be at least 1")
+	}
+	return nil
+}
+
+// leaderTracker tracks which of the replicated targets owned by the local
+// node it's the leader of.
+type leaderTracker struct {
+	mut     sync.RWMutex
+	leaders map[string]bool // Keyed by the replica key of the target.
+}
+
+func newLeaderTracker() *leaderTracker {
+	return &leaderTracker{leaders: make(map[string]bool)}
+}
+
+// Set replaces the tracked targets with targets.
+func (lt *leaderTracker) Set(targets []discovery.ReplicatedTarget) {
+	leaders := make(map[string]bool, len(targets))
+	for _, t := range targets {
+		leaders[t.Key] = t.Leader
+	}
+
+	lt.mut.Lock()
+	defer lt.mut.Unlock()
+	lt.leaders = leaders
+}
+
+// IsLeader reports whether samples of the target identified by key should be
+// forwarded. Unknown targets are always forwarded.
+func (lt *leaderTracker) IsLeader(key string) bool {
+	lt.mut.RLock()
+	defer lt.mut.RUnlock()
+	leader, ok := lt.leaders[key]
+	return !ok || leader
+}
+
+// withReplicaKeys returns copies of targets with the replica key added as a
+// label.
+func withReplicaKeys(targets []discovery.ReplicatedTarget) []discovery.Target {
+	res := make([]discovery.Target, 0, len(targets))
+	for _, t := range targets {
+		tgt := make(discovery.Target, len(t.Target)+1)
+		for k, v := range t.Target {
+			tgt[k] = v
+		}
+		tgt[replicaKeyLabel] = t.Key
+		res = append(res, tgt)
+	}
+	return res
+}
+
+// replicaAppendable only forwards the samples of scrapes of targets the local
+// node is the leader of. Replicas keep scraping their targets so that they
+// can take over forwarding as soon as the leader of a target leaves the
+// cluster.
+type replicaAppendable struct {
+	next    storage.Appendable
+	leaders *leaderTracker
+}
+
+var _ storage.Appendable = (*replicaAppendable)(nil)
+
+func (a *replicaAppendable) Appender(ctx context.Context) storage.Appender {
+	if target, ok := scrape.TargetFromContext(ctx); ok {
+		key := target.DiscoveredLabels().Get(replicaKeyLabel)
+		if key != "" && !a.leaders.IsLeader(key) {
+			return discardAppender{}
+		}
+	}
+	return a.next.Appender(ctx)
+}
+
+// discardAppender accepts and drops all data appended to it.
+type discardAppender struct{}
+
+var _ storage.Appender = discardAppender{}
+
+func (discardAppender) Append(ref storage.SeriesRef, _ labels.Labels, _ int64, _ float64) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (discardAppender) AppendExemplar(ref storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (discardAppender) AppendHistogram(ref storage.SeriesRef, _ labels.Labels, _ int64, _ *histogram.Histogram, _ *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (discardAppender) UpdateMetadata(ref storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (discardAppender) AppendCTZeroSample(ref storage.SeriesRef, _ labels.Labels, _, _ int64) (storage.SeriesRef, error) {
+	return ref, nil
+}
+
+func (discardAppender) Commit() error   { return nil }
+func (discardAppender) Rollback() error { return nil }
@@ -92,7 +92,7 @@ type Arguments struct {
 	ExtraMetrics              bool `river:"extra_metrics,attr,optional"`
 	EnableProtobufNegotiation bool `river:"enable_protobuf_negotiation,attr,optional"`
 
-	Clustering cluster.ComponentBlock `river:"clustering,block,optional"`
+	Clustering Clustering `river:"clustering,block,optional"`
 }
 
 // SetToDefault implements river.Defaulter.
@@ -130,11 +130,14 @@ type Component struct {
 	args         Arguments
 	scraper      *scrape.Manager
 	appendable   *prometheus.Fanout
+	leaders      *leaderTracker
 	targetsGauge client_prometheus.Gauge
+	leaderGauge  client_prometheus.Gauge
 }
 
 var (
-	_ component.Component = (*Component)(nil)
+	_ component.Component       = (*Component)(nil)
+	_ cluster.OwnershipReporter = (*Component)(nil)
 )
 
 // New creates a new prometheus.scrape component.
@@ -158,14 +161,18 @@ func New(o component.Options, args Arguments) (*Component, error) {
 	ls := service.(labelstore.LabelStore)
 
 	flowAppendable := prometheus.NewFanout(args.ForwardTo, o.ID, o.Registerer, ls)
+	leaders := newLeaderTracker()
 	scrapeOptions := &scrape.Options{
 		ExtraMetrics: args.ExtraMetrics,
 		HTTPClientOptions: []config_util.HTTPClientOption{
 			config_util.WithDialContextFunc(httpData.DialFunc),
 		},
 		EnableProtobufNegotiation: args.EnableProtobufNegotiation,
+		// The target of a scrape is passed to the appender through the context
+		// so that replicaAppendable can look up its leader.
+		PassMetadataInContext: true,
 	}
-	scraper := scrape.NewManager(scrapeOptions, o.Logger, flowAppendable)
+	scraper := scrape.NewManager(scrapeOptions, o.Logger, &replicaAppendable{next: flowAppendable, leaders: leaders})
 
 	targetsGauge := client_prometheus.NewGauge(client_prometheus.GaugeOpts{
 		Name: "agent_prometheus_scrape_targets_gauge",
@@ -175,13 +182,23 @@ func New(o component.Options, args Arguments) (*Component, error) {
 		return nil, err
 	}
 
+	leaderGauge := client_prometheus.NewGauge(client_prometheus.GaugeOpts{
+		Name: "agent_prometheus_scrape_targets_leader_gauge",
+		Help: "Number of targets this component forwards samples for"})
+	err = o.Registerer.Register(leaderGauge)
+	if err != nil {
+		return nil, err
+	}
+
 	c := &Component{
 		opts:          o,
 		cluster:       clusterData,
 		reloadTargets: make(chan struct{}, 1),
 		scraper:       scraper,
 		appendable:    flowAppendable,
+		leaders:       leaders,
 		targetsGauge:  targetsGauge,
+		leaderGauge:   leaderGauge,
 	}
 
 	// Call to Update() to set the receivers and targets once at the start.
@@ -213,16 +230,16 @@ func (c *Component) Run(ctx context.Context) error {
 		case <-c.reloadTargets:
 			c.mut.RLock()
 			var (
-				targets           = c.args.Targets
-				jobName           = c.opts.ID
-				clusteringEnabled = c.args.Clustering.Enabled
+				targets    = c.args.Targets
+				jobName    = c.opts.ID
+				clustering = c.args.Clustering
 			)
 			if c.args.JobName != "" {
 				jobName = c.args.JobName
 			}
 			c.mut.RUnlock()
 
-			promTargets := c.distTargets(targets, jobName, clusteringEnabled)
+			promTargets := c.distTargets(targets, jobName, clustering)
 
 			select {
 			case targetSetsChan <- promTargets:
@@ -276,6 +293,24 @@ func (c *Component) NotifyClusterChange() {
 	}
 }
 
+// TargetOwners implements cluster.OwnershipReporter.
+func (c *Component) TargetOwners(cl cluster.Cluster) map[string]int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+	dt := newDistributedTargets(cl, c.args.Targets, c.args.Clustering)
+	return dt.Owners()
+}
+
+// newDistributedTargets distributes targets between the nodes of cl
+// according to clustering.
+func newDistributedTargets(cl cluster.Cluster, targets []discovery.Target, clustering Clustering) discovery.DistributedTargets {
+	dt := discovery.NewDistributedTargets(clustering.Enabled, cl, targets)
+	if clustering.ZoneLabel != "" {
+		dt = dt.WithZones(clustering.ZoneLabel, clustering.ZoneFallback)
+	}
+	return dt
+}
+
 // Helper function to bridge the in-house configuration with the Prometheus
 // scrape_config.
 // As explained in the Config struct, the following fields are purposefully
@@ -314,12 +349,30 @@ func getPromScrapeConfigs(jobName string, c Arguments) *config.ScrapeConfig {
 func (c *Component) distTargets(
 	targets []discovery.Target,
 	jobName string,
-	clustering bool,
+	clustering Clustering,
 ) map[string][]*targetgroup.Group {
 	// NOTE(@tpaschalis) First approach, manually building the
 	// 'clustered' targets implementation every time.
-	dt := discovery.NewDistributedTargets(clustering, c.cluster, targets)
-	flowTargets := dt.Get()
+	dt := newDistributedTargets(c.cluster, targets, clustering)
+
+	var flowTargets []discovery.Target
+	if clustering.Enabled && clustering.ReplicationFactor > 1 {
+		replicated := dt.GetReplicated(clustering.ReplicationFactor)
+		c.leaders.Set(replicated)
+		flowTargets = withReplicaKeys(replicated)
+
+		var leading int
+		for _, t := range replicated {
+			if t.Leader {
+				leading++
+			}
+		}
+		c.leaderGauge.Set(float64(leading))
+	} else {
+		c.leaders.Set(nil)
+		flowTargets = dt.Get()
+		c.leaderGauge.Set(float64(len(flowTargets)))
+	}
 	c.targetsGauge.Set(float64(len(flowTargets)))
 	promTargets := c.componentTargetsToProm(jobName, flowTargets)
 	return promTargets
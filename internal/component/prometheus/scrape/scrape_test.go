@@ -9,16 +9,20 @@ import (
 	"time"
 
 	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/discovery"
 	"github.com/grafana/agent/internal/component/prometheus"
 	"github.com/grafana/agent/internal/service/cluster"
 	http_service "github.com/grafana/agent/internal/service/http"
 	"github.com/grafana/agent/internal/service/labelstore"
 	"github.com/grafana/agent/internal/util"
 	"github.com/grafana/ckit/memconn"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
 	"github.com/grafana/river"
 	prometheus_client "github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/scrape"
 	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/require"
 )
@@ -223,3 +227,210 @@ func TestValidateScrapeConfig(t *testing.T) {
 	err := river.Unmarshal([]byte(exampleRiverConfig), &args)
 	require.ErrorContains(t, err, "scrape_timeout (20s) greater than scrape_interval (10s) for scrape config with job name \"local\"")
 }
+
+func TestClusteringRiverConfig(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+	targets    = []
+	forward_to = []
+
+	clustering {
+		enabled = true
+	}
+`), &args)
+	require.NoError(t, err)
+	require.Equal(t, Clustering{Enabled: true, ReplicationFactor: 1, ZoneFallback: cluster.ZoneFallbackAny}, args.Clustering)
+
+	err = river.Unmarshal([]byte(`
+	targets    = []
+	forward_to = []
+
+	clustering {
+		enabled            = true
+		replication_factor = 0
+	}
+`), &args)
+	require.EqualError(t, err, "replication_factor must be at least 1")
+
+	err = river.Unmarshal([]byte(`
+	targets    = []
+	forward_to = []
+
+	clustering {
+		enabled       = true
+		zone_label    = "__meta_kubernetes_node_label_topology_kubernetes_io_zone"
+		zone_fallback = "none"
+	}
+`), &args)
+	require.NoError(t, err)
+	require.Equal(t, "__meta_kubernetes_node_label_topology_kubernetes_io_zone", args.Clustering.ZoneLabel)
+	require.Equal(t, cluster.ZoneFallbackNone, args.Clustering.ZoneFallback)
+}
+
+// replicatedCluster is a cluster of two nodes, where the local node is the
+// leader of targets whose key contains "leader" and a replica of all other
+// targets.
+type replicatedCluster struct{}
+
+func (replicatedCluster) Lookup(key shard.Key, replicationFactor int, _ shard.Op) ([]peer.Peer, error) {
+	var (
+		self  = peer.Peer{Name: "self", Self: true, State: peer.StateParticipant}
+		other = peer.Peer{Name: "other", State: peer.StateParticipant}
+	)
+	peers := []peer.Peer{other, self}
+	if key == shard.StringKey(`{leader="true"}`) {
+		peers = []peer.Peer{self, other}
+	}
+	return peers[:replicationFactor], nil
+}
+
+func (replicatedCluster) Peers() []peer.Peer {
+	return []peer.Peer{{Name: "self", Self: true, State: peer.StateParticipant}, {Name: "other", State: peer.StateParticipant}}
+}
+
+// terminatingCluster is a replicatedCluster where the other node is
+// terminating, so it doesn't own targets anymore.
+type terminatingCluster struct{ replicatedCluster }
+
+func (terminatingCluster) Lookup(_ shard.Key, replicationFactor int, _ shard.Op) ([]peer.Peer, error) {
+	if replicationFactor > 1 {
+		return nil, fmt.Errorf("need %d nodes; only 1 available", replicationFactor)
+	}
+	return []peer.Peer{{Name: "self", Self: true, State: peer.StateParticipant}}, nil
+}
+
+func (terminatingCluster) Peers() []peer.Peer {
+	return []peer.Peer{{Name: "self", Self: true, State: peer.StateParticipant}, {Name: "other", State: peer.StateTerminating}}
+}
+
+func TestReplication_Failover(t *testing.T) {
+	targets := []discovery.Target{{"leader": "true"}, {"leader": "false"}}
+
+	// The local node takes over the targets of the terminating node.
+	dt := discovery.NewDistributedTargets(true, terminatingCluster{}, targets)
+	replicated := dt.GetReplicated(2)
+	require.Len(t, replicated, 2)
+	for _, tgt := range replicated {
+		require.True(t, tgt.Leader, "target %v must be led by the remaining node", tgt.Target)
+	}
+
+	// Both nodes participate, so the other node leads one of the targets.
+	dt = discovery.NewDistributedTargets(true, replicatedCluster{}, targets)
+	replicated = dt.GetReplicated(2)
+	require.Len(t, replicated, 2)
+	require.True(t, replicated[0].Leader)
+	require.False(t, replicated[1].Leader)
+}
+
+func TestReplication(t *testing.T) {
+	opts := component.Options{
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{DialFunc: (&net.Dialer{}).DialContext}, nil
+			case cluster.ServiceName:
+				return replicatedCluster{}, nil
+			case labelstore.ServiceName:
+				return labelstore.New(nil, prometheus_client.DefaultRegisterer), nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+
+	var received []labels.Labels
+	ls := labelstore.New(nil, prometheus_client.DefaultRegisterer)
+	receiver := prometheus.NewInterceptor(nil, ls, prometheus.WithAppendHook(func(ref storage.SeriesRef, l labels.Labels, _ int64, _ float64, _ storage.Appender) (storage.SeriesRef, error) {
+		received = append(received, l)
+		return ref, nil
+	}))
+
+	var args Arguments
+	args.SetToDefault()
+	args.ForwardTo = []storage.Appendable{receiver}
+	args.Clustering = Clustering{Enabled: true, ReplicationFactor: 2}
+
+	s, err := New(opts, args)
+	require.NoError(t, err)
+
+	targets := []discovery.Target{{"leader": "true"}, {"leader": "false"}}
+	groups := s.distTargets(targets, "job", args.Clustering)
+	require.Len(t, groups["job"][0].Targets, 2, "replicas must scrape all targets they own")
+
+	appendFor := func(target discovery.Target) {
+		tgt := groups["job"][0].Targets[0]
+		for _, candidate := range groups["job"][0].Targets {
+			if string(candidate["leader"]) == target["leader"] {
+				tgt = candidate
+			}
+		}
+		discovered := labels.NewBuilder(labels.EmptyLabels())
+		for k, v := range tgt {
+			discovered.Set(string(k), string(v))
+		}
+		ctx := scrape.ContextWithTarget(context.Background(), scrape.NewTarget(labels.EmptyLabels(), discovered.Labels(), nil))
+
+		appender := (&replicaAppendable{next: s.appendable, leaders: s.leaders}).Appender(ctx)
+		_, err := appender.Append(0, labels.FromStrings("leader", target["leader"]), 0, 1)
+		require.NoError(t, err)
+		require.NoError(t, appender.Commit())
+	}
+
+	appendFor(targets[0])
+	appendFor(targets[1])
+	require.Equal(t, []labels.Labels{labels.FromStrings("leader", "true")}, received)
+}
+
+// zonedCluster is a cluster where the local node is in zone "a", and zone "b"
+// has no nodes.
+type zonedCluster struct{ replicatedCluster }
+
+func (zonedCluster) LookupZone(_ shard.Key, zone string, fallback cluster.ZoneFallback, _ int, _ shard.Op) ([]peer.Peer, error) {
+	if zone == "a" || fallback == cluster.ZoneFallbackAny {
+		return []peer.Peer{{Name: "self", Self: true}}, nil
+	}
+	return nil, cluster.ErrNoZoneOwner
+}
+
+func (zonedCluster) Topology(string) cluster.Topology { return cluster.Topology{Zone: "a"} }
+
+func TestZones(t *testing.T) {
+	opts := component.Options{
+		Logger:     util.TestFlowLogger(t),
+		Registerer: prometheus_client.NewRegistry(),
+		GetServiceData: func(name string) (interface{}, error) {
+			switch name {
+			case http_service.ServiceName:
+				return http_service.Data{DialFunc: (&net.Dialer{}).DialContext}, nil
+			case cluster.ServiceName:
+				return zonedCluster{}, nil
+			case labelstore.ServiceName:
+				return labelstore.New(nil, prometheus_client.DefaultRegisterer), nil
+			default:
+				return nil, fmt.Errorf("service %q does not exist", name)
+			}
+		},
+	}
+
+	var args Arguments
+	args.SetToDefault()
+	args.Clustering = Clustering{Enabled: true, ReplicationFactor: 1, ZoneLabel: "__zone", ZoneFallback: cluster.ZoneFallbackNone}
+
+	s, err := New(opts, args)
+	require.NoError(t, err)
+
+	targets := []discovery.Target{
+		{"__address__": "a:80", "__zone": "a"},
+		{"__address__": "b:80", "__zone": "b"},
+		{"__address__": "none:80"},
+	}
+	groups := s.distTargets(targets, "job", args.Clustering)
+	require.Len(t, groups["job"][0].Targets, 1)
+	require.Equal(t, "a:80", string(groups["job"][0].Targets[0]["__address__"]))
+
+	args.Clustering.ZoneFallback = cluster.ZoneFallbackAny
+	groups = s.distTargets(targets, "job", args.Clustering)
+	require.Len(t, groups["job"][0].Targets, 3)
+}
@@ -237,7 +237,10 @@ type Component struct {
 	appendable *pyroscope.Fanout
 }
 
-var _ component.Component = (*Component)(nil)
+var (
+	_ component.Component       = (*Component)(nil)
+	_ cluster.OwnershipReporter = (*Component)(nil)
+)
 
 // New creates a new pprof.scrape component.
 func New(o component.Options, args Arguments) (*Component, error) {
@@ -307,6 +310,15 @@ func (c *Component) Run(ctx context.Context) error {
 	}
 }
 
+// TargetOwners implements cluster.OwnershipReporter.
+func (c *Component) TargetOwners(cl cluster.Cluster) map[string]int {
+	c.mut.RLock()
+	defer c.mut.RUnlock()
+
+	ct := discovery.NewDistributedTargets(c.args.Clustering.Enabled, cl, c.args.Targets)
+	return ct.Owners()
+}
+
 // Update implements component.Component.
 func (c *Component) Update(args component.Arguments) error {
 	newArgs := args.(Arguments)
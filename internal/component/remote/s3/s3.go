@@ -186,6 +186,21 @@ func generateS3Config(args Arguments) (*aws.Config, error) {
 	return &cfg, nil
 }
 
+// Fetch downloads the file at path, using the client configured by options.
+// Fetch can be used to read a file once without running the component.
+func Fetch(ctx context.Context, path string, options Client) ([]byte, error) {
+	s3cfg, err := generateS3Config(Arguments{Options: options})
+	if err != nil {
+		return nil, err
+	}
+	s3Client := s3.NewFromConfig(*s3cfg, func(s3o *s3.Options) {
+		s3o.UsePathStyle = options.UsePathStyle
+	})
+
+	bucket, file := getPathBucketAndFile(path)
+	return getObject(ctx, s3Client, bucket, file)
+}
+
 // handleContentUpdate reads from the update and error channels setting as appropriate
 func (s *Component) handleContentUpdate(ctx context.Context) {
 	for {
@@ -92,9 +92,14 @@ func (w *watcher) downloadSynchronously() (string, error) {
 
 // getObject ensure that the return []byte is never nil
 func (w *watcher) getObject(ctx context.Context) ([]byte, error) {
-	output, err := w.downloader.GetObject(ctx, &s3.GetObjectInput{
-		Bucket: aws.String(w.bucket),
-		Key:    aws.String(w.file),
+	return getObject(ctx, w.downloader, w.bucket, w.file)
+}
+
+// getObject downloads file from bucket. The returned []byte is never nil.
+func getObject(ctx context.Context, downloader *s3.Client, bucket, file string) ([]byte, error) {
+	output, err := downloader.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(bucket),
+		Key:    aws.String(file),
 	})
 	if err != nil {
 		return []byte{}, err
@@ -0,0 +1,86 @@
+package exports
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/module"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/service/remotecfg"
+)
+
+func init() {
+	component.Register(component.Registration{
+		Name:      "remotecfg.exports",
+		Stability: featuregate.StabilityExperimental,
+		Args:      Arguments{},
+		Exports:   module.Exports{},
+
+		Build: func(opts component.Options, args component.Arguments) (component.Component, error) {
+			return New(opts, args.(Arguments))
+		},
+	})
+}
+
+// Arguments holds values which are used to configure the remotecfg.exports
+// component.
+type Arguments struct{}
+
+// Component implements the remotecfg.exports component.
+type Component struct {
+	opts component.Options
+	data remotecfg.Data
+
+	mut     sync.Mutex
+	exports map[string]any
+}
+
+var (
+	_ component.Component = (*Component)(nil)
+	_ remotecfg.Component = (*Component)(nil)
+)
+
+// New creates a new remotecfg.exports component.
+func New(o component.Options, args Arguments) (*Component, error) {
+	data, err := o.GetServiceData(remotecfg.ServiceName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get information about the remotecfg service: %w", err)
+	}
+
+	c := &Component{
+		opts: o,
+		data: data.(remotecfg.Data),
+	}
+	c.NotifyRemoteExportsChange()
+	return c, nil
+}
+
+// Run implements component.Component.
+func (c *Component) Run(ctx context.Context) error {
+	<-ctx.Done()
+	return nil
+}
+
+// Update implements component.Component.
+func (c *Component) Update(args component.Arguments) error {
+	return nil
+}
+
+// NotifyRemoteExportsChange implements remotecfg.Component.
+func (c *Component) NotifyRemoteExportsChange() {
+	exports := c.data.Exports()
+	if exports == nil {
+		exports = map[string]any{}
+	}
+
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.exports != nil && reflect.DeepEqual(c.exports, exports) {
+		return
+	}
+	c.exports = exports
+	c.opts.OnStateChange(module.Exports{Exports: exports})
+}
@@ -0,0 +1,47 @@
+package exports
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/component/module"
+	"github.com/grafana/agent/internal/service/remotecfg"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeData struct {
+	exports map[string]any
+}
+
+func (d *fakeData) Exports() map[string]any { return d.exports }
+
+func TestExports(t *testing.T) {
+	var (
+		data  = &fakeData{}
+		state []component.Exports
+	)
+	opts := component.Options{
+		OnStateChange: func(e component.Exports) { state = append(state, e) },
+		GetServiceData: func(name string) (interface{}, error) {
+			if name != remotecfg.ServiceName {
+				return nil, fmt.Errorf("service %q not found", name)
+			}
+			return data, nil
+		},
+	}
+
+	// The exports are empty until the remote config is loaded.
+	c, err := New(opts, Arguments{})
+	require.NoError(t, err)
+	require.Equal(t, []component.Exports{module.Exports{Exports: map[string]any{}}}, state)
+
+	data.exports = map[string]any{"receiver": "value"}
+	c.NotifyRemoteExportsChange()
+	require.Len(t, state, 2)
+	require.Equal(t, module.Exports{Exports: map[string]any{"receiver": "value"}}, state[1])
+
+	// Notifications without changes don't update the exports.
+	c.NotifyRemoteExportsChange()
+	require.Len(t, state, 2)
+}
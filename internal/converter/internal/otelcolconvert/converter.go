@@ -145,8 +145,29 @@ func (state *State) flowLabelForComponent(c component.InstanceID) string {
 // Next returns the set of Flow component IDs for a given data type that the
 // current component being converted should forward data to.
 func (state *State) Next(c component.InstanceID, dataType component.DataType) []componentID {
-	instances := state.nextInstances(c, dataType)
+	return state.flowComponentIDs(state.nextInstances(c, dataType))
+}
+
+// NextInPipeline returns the set of Flow component IDs that the current
+// component being converted should forward data to when sending data to the
+// pipeline with the given ID. It is used by connectors which route data to
+// specific pipelines, such as the routing connector.
+func (state *State) NextInPipeline(c component.InstanceID, pipelineID component.ID) []componentID {
+	pipeline, ok := state.cfg.Service.Pipelines[pipelineID]
+	if !ok {
+		return nil
+	}
+
+	// The components of the pipeline are labeled after the group of the
+	// pipeline, which may not be the current group.
+	pipelineState := *state
+	pipelineState.group = &pipelineGroup{Name: pipelineID.Name()}
+	return pipelineState.flowComponentIDs(nextInPipeline(pipeline, c))
+}
 
+// flowComponentIDs returns the IDs of the Flow components receiving data for
+// the given OpenTelemetry Collector component instances.
+func (state *State) flowComponentIDs(instances []component.InstanceID) []componentID {
 	var ids []componentID
 
 	for _, instance := range instances {
@@ -0,0 +1,116 @@
+package otelcolconvert
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/internal/component/otelcol"
+	"github.com/grafana/agent/internal/component/otelcol/connector/routing"
+	"github.com/grafana/agent/internal/converter/diag"
+	"github.com/grafana/agent/internal/converter/internal/common"
+	"github.com/open-telemetry/opentelemetry-collector-contrib/connector/routingconnector"
+	"go.opentelemetry.io/collector/component"
+)
+
+func init() {
+	converters = append(converters, routingConnectorConverter{})
+}
+
+type routingConnectorConverter struct{}
+
+func (routingConnectorConverter) Factory() component.Factory {
+	return routingconnector.NewFactory()
+}
+
+func (routingConnectorConverter) InputComponentName() string {
+	return "otelcol.connector.routing"
+}
+
+func (routingConnectorConverter) ConvertAndAppend(state *State, id component.InstanceID, cfg component.Config) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	// The routing connector sends data to the pipelines of its routing table
+	// rather than to the pipelines of the current group, so it only needs to
+	// be converted in the groups sending data to it.
+	if !state.group.exportsTo(id.ID) {
+		return diags
+	}
+
+	label := state.FlowComponentLabel()
+
+	args, convDiags := toRoutingConnector(state, id, cfg.(*routingconnector.Config))
+	diags.AddAll(convDiags)
+	if args == nil {
+		return diags
+	}
+	block := common.NewBlockWithOverride([]string{"otelcol", "connector", "routing"}, label, args)
+
+	diags.Add(
+		diag.SeverityLevelInfo,
+		fmt.Sprintf("Converted %s into %s", StringifyInstanceID(id), StringifyBlock(block)),
+	)
+
+	state.Body().AppendBlock(block)
+	return diags
+}
+
+// routeStatementPrefix is the prefix of the statements of the routing table,
+// followed by the condition of the route.
+const routeStatementPrefix = "route() where "
+
+func toRoutingConnector(state *State, id component.InstanceID, cfg *routingconnector.Config) (*routing.Arguments, diag.Diagnostics) {
+	var diags diag.Diagnostics
+	if cfg == nil {
+		return nil, diags
+	}
+
+	args := &routing.Arguments{
+		ErrorMode: cfg.ErrorMode,
+		MatchOnce: cfg.MatchOnce,
+	}
+	if len(cfg.DefaultPipelines) > 0 {
+		args.DefaultOutput = routingOutput(state, id, cfg.DefaultPipelines)
+	}
+
+	for _, item := range cfg.Table {
+		var condition string
+		switch statement := strings.TrimSpace(item.Statement); {
+		case statement == "route()":
+			condition = "true"
+		case strings.HasPrefix(statement, routeStatementPrefix):
+			condition = strings.TrimSpace(strings.TrimPrefix(statement, routeStatementPrefix))
+		default:
+			diags.Add(
+				diag.SeverityLevelCritical,
+				fmt.Sprintf("Cannot convert the statement %q of %s: only statements of the form \"route() where <condition>\" are supported", item.Statement, StringifyInstanceID(id)),
+			)
+			return nil, diags
+		}
+
+		args.Routes = append(args.Routes, routing.Route{
+			Condition: condition,
+			Output:    routingOutput(state, id, item.Pipelines),
+		})
+	}
+
+	return args, diags
+}
+
+// routingOutput returns the consumers of the pipelines the routing connector
+// with the given ID sends data to.
+func routingOutput(state *State, id component.InstanceID, pipelineIDs []component.ID) *otelcol.ConsumerArguments {
+	var output otelcol.ConsumerArguments
+	for _, pipelineID := range pipelineIDs {
+		next := ToTokenizedConsumers(state.NextInPipeline(id, pipelineID))
+
+		switch pipelineID.Type() {
+		case component.DataTypeMetrics:
+			output.Metrics = append(output.Metrics, next...)
+		case component.DataTypeLogs:
+			output.Logs = append(output.Logs, next...)
+		case component.DataTypeTraces:
+			output.Traces = append(output.Traces, next...)
+		}
+	}
+	return &output
+}
@@ -154,6 +154,14 @@ func mergeIDs(in ...[]component.ID) []component.ID {
 	return res
 }
 
+// exportsTo returns whether any pipeline of the group exports to the
+// component with the given ID.
+func (group pipelineGroup) exportsTo(id component.ID) bool {
+	return slices.Contains(group.Metrics.Exporters, id) ||
+		slices.Contains(group.Logs.Exporters, id) ||
+		slices.Contains(group.Traces.Exporters, id)
+}
+
 // NextMetrics returns the set of components who should be sent metrics from
 // the given component ID.
 func (group pipelineGroup) NextMetrics(fromID component.InstanceID) []component.InstanceID {
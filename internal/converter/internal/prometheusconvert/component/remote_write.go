@@ -78,6 +78,7 @@ func getEndpointOptions(remoteWriteConfigs []*prom_config.RemoteWriteConfig) []*
 			WriteRelabelConfigs:  ToFlowRelabelConfigs(remoteWriteConfig.WriteRelabelConfigs),
 			SigV4:                toSigV4(remoteWriteConfig.SigV4Config),
 			AzureAD:              toAzureAD(remoteWriteConfig.AzureADConfig),
+			ProtobufMessage:      remotewrite.DefaultProtobufMessage,
 		}
 
 		endpoints = append(endpoints, endpoint)
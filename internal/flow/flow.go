@@ -56,6 +56,7 @@ import (
 	"github.com/grafana/agent/internal/flow/internal/worker"
 	"github.com/grafana/agent/internal/flow/logging"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/agent/internal/flow/tracing"
 	"github.com/grafana/agent/internal/service"
 	"github.com/prometheus/client_golang/prometheus"
@@ -95,6 +96,16 @@ type Options struct {
 	// the user, for example, via command-line flags.
 	MinStability featuregate.Stability
 
+	// Lockfile pins the content of modules retrieved by import blocks. Content
+	// which doesn't match its pin is rejected. Nothing is pinned if Lockfile is
+	// nil.
+	Lockfile *moduleverify.Lockfile
+
+	// DryRun evaluates the arguments of components without building or running
+	// them, which is used to validate a config source without side effects.
+	// The exports of components are left to their zero value.
+	DryRun bool
+
 	// OnExportsChange is called when the exports of the controller change.
 	// Exports are controlled by "export" configuration blocks. If
 	// OnExportsChange is nil, export configuration blocks are not allowed in the
@@ -123,6 +134,7 @@ type Flow struct {
 
 	loadMut    sync.RWMutex
 	loadedOnce atomic.Bool
+	source     *Source // Last loaded source, guarded by loadMut.
 }
 
 // New creates a new, unstarted Flow controller. Call Run to run the controller.
@@ -192,6 +204,8 @@ func newController(o controllerOptions) *Flow {
 			TraceProvider: tracer,
 			DataPath:      o.DataPath,
 			MinStability:  o.MinStability,
+			Lockfile:      o.Lockfile,
+			DryRun:        o.DryRun,
 			OnBlockNodeUpdate: func(cn controller.BlockNode) {
 				// Changed node should be queued for reevaluation.
 				f.updateQueue.Enqueue(&controller.QueuedNode{Node: cn, LastUpdatedTime: time.Now()})
@@ -208,6 +222,8 @@ func newController(o controllerOptions) *Flow {
 					Reg:               o.Reg,
 					DataPath:          o.DataPath,
 					MinStability:      o.MinStability,
+					Lockfile:          o.Lockfile,
+					DryRun:            o.DryRun,
 					ID:                id,
 					ServiceMap:        serviceMap,
 					WorkerPool:        workerPool,
@@ -256,8 +272,9 @@ func (f *Flow) Run(ctx context.Context) {
 				components = f.loader.Components()
 				services   = f.loader.Services()
 				imports    = f.loader.Imports()
+				foreachs   = f.loader.Foreachs()
 
-				runnables = make([]controller.RunnableNode, 0, len(components)+len(services)+len(imports))
+				runnables = make([]controller.RunnableNode, 0, len(components)+len(services)+len(imports)+len(foreachs))
 			)
 			for _, c := range components {
 				runnables = append(runnables, c)
@@ -267,6 +284,10 @@ func (f *Flow) Run(ctx context.Context) {
 				runnables = append(runnables, i)
 			}
 
+			for _, fe := range foreachs {
+				runnables = append(runnables, fe)
+			}
+
 			// Only the root controller should run services, since modules share the
 			// same service instance as the root.
 			if !f.opts.IsModule {
@@ -314,6 +335,7 @@ func (f *Flow) loadSource(source *Source, args map[string]any, customComponentRe
 		return diags
 	}
 	f.loadedOnce.Store(true)
+	f.source = source
 
 	select {
 	case f.loadFinished <- struct{}{}:
@@ -323,6 +345,18 @@ func (f *Flow) loadSource(source *Source, args map[string]any, customComponentRe
 	return diags.ErrorOrNil()
 }
 
+// RenderedConfig returns the River text of the config source last loaded by
+// the controller, after merging overlays. The values of attributes of type
+// secret are redacted.
+func (f *Flow) RenderedConfig() ([]byte, error) {
+	f.loadMut.RLock()
+	defer f.loadMut.RUnlock()
+	if f.source == nil {
+		return nil, nil
+	}
+	return renderBody(redactSecrets(f.source.body, f.blockArguments()))
+}
+
 // Ready returns whether the Flow controller has finished its initial load.
 func (f *Flow) Ready() bool {
 	return f.loadedOnce.Load()
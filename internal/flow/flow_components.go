@@ -123,6 +123,7 @@ func (f *Flow) getComponentDetail(cn controller.ComponentNode, graph *dag.Graph,
 
 	if builtinComponent, ok := cn.(*controller.BuiltinComponentNode); ok {
 		componentInfo.Component = builtinComponent.Component()
+		componentInfo.SingletonOwner = builtinComponent.SingletonOwner()
 		if opts.GetDebugInfo {
 			componentInfo.DebugInfo = builtinComponent.DebugInfo()
 		}
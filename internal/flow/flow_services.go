@@ -69,7 +69,7 @@ func serviceConsumersForGraph(graph *dag.Graph, serviceName string, includePeerS
 
 // NewController returns a new, unstarted, isolated Flow controller so that
 // services can instantiate their own components.
-func (f *Flow) NewController(id string) service.Controller {
+func (f *Flow) NewController(id string, onExportsChange func(exports map[string]any)) service.Controller {
 	return serviceController{
 		f: newController(controllerOptions{
 			Options: Options{
@@ -78,9 +78,11 @@ func (f *Flow) NewController(id string) service.Controller {
 				Tracer:          f.opts.Tracer,
 				DataPath:        f.opts.DataPath,
 				MinStability:    f.opts.MinStability,
+				Lockfile:        f.opts.Lockfile,
+				DryRun:          f.opts.DryRun,
 				Reg:             f.opts.Reg,
 				Services:        f.opts.Services,
-				OnExportsChange: nil, // NOTE(@tpaschalis, @wildum) The isolated controller shouldn't be able to export any values.
+				OnExportsChange: onExportsChange,
 			},
 			IsModule:       true,
 			ModuleRegistry: newModuleRegistry(),
@@ -2,6 +2,7 @@ package flow
 
 import (
 	"context"
+	"sync"
 	"testing"
 	"time"
 
@@ -145,6 +146,141 @@ func TestServices_Configurable_Optional(t *testing.T) {
 	require.NoError(t, updateCalled.Wait(5*time.Second), "Service was not configured")
 }
 
+func TestServices_Exporter(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		value       = atomic.NewString("first")
+		notifyMut   sync.Mutex
+		notifyFuncs []func()
+
+		svc = &testservices.FakeExporter{
+			Fake: testservices.Fake{
+				DefinitionFunc: func() service.Definition {
+					return service.Definition{Name: "fake"}
+				},
+			},
+			ExportsFunc: func() any {
+				return map[string]any{"value": value.Load}
+			},
+			SubscribeExportsFunc: func(notify func()) func() {
+				notifyMut.Lock()
+				defer notifyMut.Unlock()
+				notifyFuncs = append(notifyFuncs, notify)
+				return func() {}
+			},
+		}
+	)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		testcomponents.passthrough "uses_service" {
+			input = fake.value()
+		}
+
+		testcomponents.passthrough "static" {
+			input = "static"
+		}
+	`))
+	require.NoError(t, err)
+
+	opts := testOptions(t)
+	opts.Services = append(opts.Services, svc)
+
+	ctrl := New(opts)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	go ctrl.Run(ctx)
+
+	_, out := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.uses_service")
+	require.Equal(t, "first", out.(testcomponents.PassthroughExports).Output)
+
+	// Components referencing the exports of the service depend on it, and are
+	// evaluated again when the service notifies its subscribers.
+	value.Store("second")
+	notifyMut.Lock()
+	require.Len(t, notifyFuncs, 1)
+	notifyFuncs[0]()
+	notifyMut.Unlock()
+
+	require.Eventually(t, func() bool {
+		_, out := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.uses_service")
+		return out.(testcomponents.PassthroughExports).Output == "second"
+	}, 3*time.Second, 10*time.Millisecond)
+}
+
+func TestServices_Exporter_InModules(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var (
+		value       = atomic.NewString("first")
+		notifyMut   sync.Mutex
+		notifyFuncs []func()
+
+		svc = &testservices.FakeExporter{
+			Fake: testservices.Fake{
+				DefinitionFunc: func() service.Definition {
+					return service.Definition{Name: "fake"}
+				},
+			},
+			ExportsFunc: func() any {
+				return map[string]any{"value": value.Load}
+			},
+			SubscribeExportsFunc: func(notify func()) func() {
+				notifyMut.Lock()
+				defer notifyMut.Unlock()
+				notifyFuncs = append(notifyFuncs, notify)
+				return func() {}
+			},
+		}
+	)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		declare "module" {
+			testcomponents.passthrough "uses_service" {
+				input = fake.value()
+			}
+
+			export "output" {
+				value = testcomponents.passthrough.uses_service.output
+			}
+		}
+
+		module "instance" { }
+
+		testcomponents.passthrough "module_output" {
+			input = module.instance.output
+		}
+	`))
+	require.NoError(t, err)
+
+	opts := testOptions(t)
+	opts.Services = append(opts.Services, svc)
+
+	ctrl := New(opts)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	go ctrl.Run(ctx)
+
+	output := func() any {
+		_, out := getFields(t, ctrl.loader.Graph(), "testcomponents.passthrough.module_output")
+		return out.(testcomponents.PassthroughExports).Output
+	}
+	require.Eventually(t, func() bool { return output() == "first" }, 3*time.Second, 10*time.Millisecond)
+
+	// Components of modules referencing the exports of the service are
+	// evaluated again when the service notifies its subscribers.
+	value.Store("second")
+	notifyMut.Lock()
+	for _, notify := range notifyFuncs {
+		notify()
+	}
+	notifyMut.Unlock()
+
+	require.Eventually(t, func() bool { return output() == "second" }, 3*time.Second, 10*time.Millisecond)
+}
+
 func TestFlow_GetServiceConsumers(t *testing.T) {
 	defer verifyNoGoroutineLeaks(t)
 	var (
@@ -0,0 +1,271 @@
+package flow
+
+import (
+	"context"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/internal/testcomponents"
+	"github.com/grafana/agent/internal/flow/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForeach(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	config := func(collection string) string {
+		return `
+			testcomponents.passthrough "suffix" {
+				input = "-suffix"
+			}
+
+			foreach "items" {
+				collection = ` + collection + `
+				var        = "each"
+				id         = "name"
+
+				template {
+					testcomponents.passthrough "pt" {
+						input = each.value + testcomponents.passthrough.suffix.output
+					}
+
+					testcomponents.passthrough "chained" {
+						input = testcomponents.passthrough.pt.output
+					}
+				}
+			}
+		`
+	}
+
+	ctrl := newForeachTestController(t)
+	f, err := ParseSource(t.Name(), []byte(config(`[{name = "a", value = "1"}, {name = "b", value = "2"}]`)))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	requireItems(t, ctrl, map[string]string{
+		"foreach.items/item_a_a98c6e5b": "1-suffix",
+		"foreach.items/item_b_1af39f9b": "2-suffix",
+	})
+	itemA, _ := ctrl.modules.Get("foreach.items/item_a_a98c6e5b")
+
+	// Removing and adding items must only affect their own modules.
+	f, err = ParseSource(t.Name(), []byte(config(`[{name = "a", value = "1"}, {name = "c", value = "3"}]`)))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	requireItems(t, ctrl, map[string]string{
+		"foreach.items/item_a_a98c6e5b": "1-suffix",
+		"foreach.items/item_c_c40657ed": "3-suffix",
+	})
+	newItemA, _ := ctrl.modules.Get("foreach.items/item_a_a98c6e5b")
+	require.Same(t, itemA, newItemA)
+
+	// Items with the same ID are updated in place.
+	f, err = ParseSource(t.Name(), []byte(config(`[{name = "a", value = "4"}, {name = "c", value = "3"}]`)))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	requireItems(t, ctrl, map[string]string{
+		"foreach.items/item_a_a98c6e5b": "4-suffix",
+		"foreach.items/item_c_c40657ed": "3-suffix",
+	})
+	newItemA, _ = ctrl.modules.Get("foreach.items/item_a_a98c6e5b")
+	require.Same(t, itemA, newItemA)
+}
+
+// requireItems waits until exactly the modules in expect are running and the
+// output of their chained passthrough component matches.
+func requireItems(t *testing.T, ctrl *Flow, expect map[string]string) {
+	t.Helper()
+
+	var expectIDs []string
+	for id := range expect {
+		expectIDs = append(expectIDs, id)
+	}
+	sort.Strings(expectIDs)
+
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		var ids []string
+		for _, mod := range ctrl.modules.List() {
+			ids = append(ids, mod.o.ID)
+		}
+		sort.Strings(ids)
+		assert.Equal(c, expectIDs, ids)
+
+		for id, output := range expect {
+			mod, ok := ctrl.modules.Get(id)
+			if !assert.True(c, ok) {
+				return
+			}
+			_, exports := getFields(t, mod.f.loader.Graph(), "testcomponents.passthrough.chained")
+			assert.Equal(c, output, exports.(testcomponents.PassthroughExports).Output)
+		}
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestForeach_IDsWithPunctuation(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	ctrl := newForeachTestController(t)
+	f, err := ParseSource(t.Name(), []byte(`
+		foreach "items" {
+			collection = [{name = "a-b", value = "1"}, {name = "a.b", value = "2"}, {name = "a_b", value = "3"}]
+			var        = "each"
+			id         = "name"
+
+			template {
+				testcomponents.passthrough "chained" {
+					input = each.value
+				}
+			}
+		}
+	`))
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		ctrl.Run(ctx)
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+
+	// IDs which only differ in characters that are replaced in module IDs
+	// still get their own module.
+	requireItems(t, ctrl, map[string]string{
+		"foreach.items/item_a_b_6534d22a": "1",
+		"foreach.items/item_a_b_3ae8ed56": "2",
+		"foreach.items/item_a_b_c147400a": "3",
+	})
+}
+
+func TestForeach_DuplicateItems(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	ctrl := newForeachTestController(t)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		foreach "items" {
+			collection = ["a", "b", "a"]
+			var        = "each"
+
+			template {
+				testcomponents.passthrough "pt" {
+					input = each
+				}
+			}
+		}
+	`))
+	require.NoError(t, err)
+	err = ctrl.LoadSource(f, nil)
+	require.ErrorContains(t, err, "duplicate item ID")
+}
+
+func TestForeach_Errors(t *testing.T) {
+	tt := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{
+			name: "MissingTemplate",
+			config: `
+				foreach "items" {
+					collection = []
+					var        = "each"
+				}
+			`,
+			err: `missing required block "template"`,
+		},
+		{
+			name: "OutOfScopeReference",
+			config: `
+				foreach "items" {
+					collection = ["a"]
+					var        = "each"
+
+					template {
+						testcomponents.passthrough "pt" {
+							input = testcomponents.passthrough.missing.output
+						}
+					}
+				}
+			`,
+			err: `component "testcomponents.passthrough.missing.output" does not exist or is out of scope`,
+		},
+		{
+			name: "MissingIDKey",
+			config: `
+				foreach "items" {
+					collection = [{value = "a"}]
+					var        = "each"
+					id         = "name"
+
+					template {}
+				}
+			`,
+			err: `item is missing the id key "name"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			defer verifyNoGoroutineLeaks(t)
+
+			ctrl := newForeachTestController(t)
+			defer cleanUpController(ctrl)
+
+			f, err := ParseSource(t.Name(), []byte(tc.config))
+			require.NoError(t, err)
+			err = ctrl.LoadSource(f, nil)
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestForeach_Stability(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	ctrl := newTestController(t)
+	defer cleanUpController(ctrl)
+
+	f, err := ParseSource(t.Name(), []byte(`
+		foreach "items" {
+			collection = []
+			var        = "each"
+			template {}
+		}
+	`))
+	require.NoError(t, err)
+	err = ctrl.LoadSource(f, nil)
+	require.ErrorContains(t, err, `foreach block is at stability level "experimental"`)
+}
+
+func newForeachTestController(t *testing.T) *Flow {
+	opts := testOptions(t)
+	opts.MinStability = featuregate.StabilityExperimental
+	return newController(controllerOptions{
+		Options:        opts,
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(4, 100),
+	})
+}
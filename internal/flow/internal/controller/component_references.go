@@ -26,7 +26,11 @@ type Reference struct {
 
 // ComponentReferences returns the list of references a component is making to
 // other components.
-func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnostics) {
+//
+// parentScope holds the variables exposed by a parent foreach block and may be
+// nil. References which can't be found in g but exist in parentScope are
+// ignored.
+func ComponentReferences(cn dag.Node, g *dag.Graph, parentScope *vm.Scope) ([]Reference, diag.Diagnostics) {
 	var (
 		traversals []Traversal
 
@@ -34,6 +38,10 @@ func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnosti
 	)
 
 	switch cn := cn.(type) {
+	case *ForeachConfigNode:
+		// References from the template to its own blocks and to the item are
+		// resolved when the template is instantiated.
+		traversals = cn.referencedTraversals()
 	case BlockNode:
 		if cn.Block() != nil {
 			traversals = expressionsFromBody(cn.Block().Body)
@@ -53,6 +61,9 @@ func ComponentReferences(cn dag.Node, g *dag.Graph) ([]Reference, diag.Diagnosti
 		}
 
 		ref, resolveDiags := resolveTraversal(t, g)
+		if resolveDiags.HasErrors() && inScope(t, parentScope) {
+			continue
+		}
 		diags = append(diags, resolveDiags...)
 		if resolveDiags.HasErrors() {
 			continue
@@ -163,3 +174,27 @@ func resolveTraversal(t Traversal, g *dag.Graph) (Reference, diag.Diagnostics) {
 	})
 	return Reference{}, diags
 }
+
+// inScope returns true if the traversal t refers to a variable of scope. scope
+// may be nil.
+func inScope(t Traversal, scope *vm.Scope) bool {
+	if scope == nil {
+		return false
+	}
+	value, ok := scope.Variables[t[0].Name]
+	if !ok {
+		return false
+	}
+	for _, ident := range t[1:] {
+		obj, ok := value.(map[string]any)
+		if !ok {
+			// The traversal accesses a field of a value, such as the exports of a
+			// component, which is checked during evaluation.
+			return true
+		}
+		if value, ok = obj[ident.Name]; !ok {
+			return false
+		}
+	}
+	return true
+}
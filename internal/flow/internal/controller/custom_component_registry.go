@@ -5,6 +5,7 @@ import (
 	"sync"
 
 	"github.com/grafana/river/ast"
+	"github.com/grafana/river/vm"
 )
 
 // CustomComponentRegistry holds custom component definitions that are available in the context.
@@ -12,6 +13,7 @@ import (
 // Imported definitions are stored inside of the corresponding import registry.
 type CustomComponentRegistry struct {
 	parent *CustomComponentRegistry // nil if root config
+	scope  *vm.Scope                // variables exposed by a parent foreach block, nil otherwise
 
 	mut      sync.RWMutex
 	imports  map[string]*CustomComponentRegistry // importNamespace: importScope
@@ -28,6 +30,15 @@ func NewCustomComponentRegistry(parent *CustomComponentRegistry) *CustomComponen
 	}
 }
 
+// parentScope returns the variables exposed by a parent foreach block. It
+// returns nil if s is nil or wasn't created by a foreach block.
+func (s *CustomComponentRegistry) parentScope() *vm.Scope {
+	if s == nil {
+		return nil
+	}
+	return s.scope
+}
+
 func (s *CustomComponentRegistry) getDeclare(name string) (ast.Body, bool) {
 	s.mut.RLock()
 	defer s.mut.RUnlock()
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"path"
+	"reflect"
 	"strings"
 	"sync"
 	"time"
@@ -45,9 +46,12 @@ type Loader struct {
 	componentNodes       []ComponentNode
 	declareNodes         map[string]*DeclareNode
 	importConfigNodes    map[string]*ImportConfigNode
+	foreachConfigNodes   map[string]*ForeachConfigNode
 	serviceNodes         []*ServiceNode
+	unsubscribes         []func() // Unsubscribe from the exports of services
 	cache                *valueCache
-	blocks               []*ast.BlockStmt // Most recently loaded blocks, used for writing
+	blocks               []*ast.BlockStmt       // Most recently loaded blocks, used for writing
+	appliedNodes         map[string]appliedNode // State of the nodes evaluated by the last Apply
 	cm                   *controllerMetrics
 	cc                   *controllerCollector
 	moduleExportIndex    int
@@ -136,8 +140,10 @@ type ApplyOptions struct {
 // matches the component ID specified by any of the provided River blocks.
 // Reused components will be updated to point at the new River block.
 //
-// Apply will perform an evaluation of all loaded components before returning.
-// The provided parentContext can be used to provide global variables and
+// Apply will evaluate the loaded components before returning. Components
+// whose block and dependencies didn't change since the previous call to Apply
+// aren't evaluated again, unless one of their dependencies changed while being
+// evaluated. The provided parentContext can be used to provide global variables and
 // functions to components. A child context will be constructed from the parent
 // to expose values of other components.
 func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
@@ -147,41 +153,77 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 	l.cm.controllerEvaluation.Set(1)
 	defer l.cm.controllerEvaluation.Set(0)
 
+	// Keep the previous module arguments to detect which ones changed.
+	prevArgs := make(map[string]any, len(l.cache.moduleArguments))
+	for key, value := range l.cache.moduleArguments {
+		prevArgs[key] = value
+	}
 	for key, value := range options.Args {
 		l.cache.CacheModuleArgument(key, value)
 	}
 	l.cache.SyncModuleArgs(options.Args)
 
+	// Every node may reference the variables exposed by a parent foreach block,
+	// so all the nodes are evaluated when they change.
+	scope := options.CustomComponentRegistry.parentScope()
+	fullEvaluation := !reflect.DeepEqual(l.cache.Scope(), scope)
+	l.cache.SetScope(scope)
+
 	// Create a new CustomComponentRegistry based on the provided one.
 	// The provided one should be nil for the root config.
 	l.componentNodeManager.setCustomComponentRegistry(NewCustomComponentRegistry(options.CustomComponentRegistry))
 	newGraph, diags := l.loadNewGraph(options.Args, options.ComponentBlocks, options.ConfigBlocks, options.DeclareBlocks)
+	l.cm.onApplyPhaseDone(applyPhaseLoad, time.Since(start))
 	if diags.HasErrors() {
 		return diags
 	}
 
+	diffStart := time.Now()
+	diff := newGraphDiff(fullEvaluation, l.originalGraph, l.graph, l.appliedNodes, prevArgs)
+	l.cm.onApplyPhaseDone(applyPhaseDiff, time.Since(diffStart))
+
 	var (
 		components   = make([]ComponentNode, 0)
 		componentIDs = make([]ComponentID, 0)
 		services     = make([]*ServiceNode, 0, len(l.services))
+
+		evaluated, skipped int
 	)
 
 	tracer := l.tracer.Tracer("")
 	spanCtx, span := tracer.Start(context.Background(), "GraphEvaluate", trace.WithSpanKind(trace.SpanKindInternal))
 	defer span.End()
 
+	evaluateStart := time.Now()
 	logger := log.With(l.log, "trace_id", span.SpanContext().TraceID())
 	level.Info(logger).Log("msg", "starting complete graph evaluation")
 	defer func() {
 		span.SetStatus(codes.Ok, "")
 
-		level.Info(logger).Log("msg", "finished complete graph evaluation", "duration", time.Since(start))
+		l.cm.onApplyPhaseDone(applyPhaseEvaluate, time.Since(evaluateStart))
+		l.cm.onApplyNodesDone(evaluated, skipped)
+		level.Info(logger).Log("msg", "finished complete graph evaluation", "duration", time.Since(start), "evaluated_nodes", evaluated, "skipped_nodes", skipped)
 	}()
 
 	l.cache.ClearModuleExports()
 
-	// Evaluate all the components.
+	// Evaluate the components which changed and their dependants.
 	_ = dag.WalkTopological(&newGraph, newGraph.Leaves(), func(n dag.Node) error {
+		switch n := n.(type) {
+		case ComponentNode:
+			components = append(components, n)
+			componentIDs = append(componentIDs, n.ID())
+		case *ServiceNode:
+			services = append(services, n)
+		}
+
+		if !diff.NeedsEvaluation(n) {
+			skipped++
+			l.restoreSkipped(n)
+			return nil
+		}
+		evaluated++
+
 		_, span := tracer.Start(spanCtx, "EvaluateNode", trace.WithSpanKind(trace.SpanKindInternal))
 		span.SetAttributes(attribute.String("node_id", n.NodeID()))
 		defer span.End()
@@ -191,13 +233,13 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 			level.Info(logger).Log("msg", "finished node evaluation", "node_id", n.NodeID(), "duration", time.Since(start))
 		}()
 
-		var err error
+		var (
+			err    error
+			before = l.nodeOutputs(n)
+		)
 
 		switch n := n.(type) {
 		case ComponentNode:
-			components = append(components, n)
-			componentIDs = append(componentIDs, n.ID())
-
 			if err = l.evaluate(logger, n); err != nil {
 				var evalDiags diag.Diagnostics
 				if errors.As(err, &evalDiags) {
@@ -213,8 +255,6 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 			}
 
 		case *ServiceNode:
-			services = append(services, n)
-
 			if err = l.evaluate(logger, n); err != nil {
 				var evalDiags diag.Diagnostics
 				if errors.As(err, &evalDiags) {
@@ -242,6 +282,7 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 				l.cache.CacheModuleExportValue(exp.Label(), exp.Value())
 			}
 		}
+		diff.Evaluated(n, before, l.nodeOutputs(n), err)
 
 		// We only use the error for updating the span status; we don't return the
 		// error because we want to evaluate as many nodes as we can.
@@ -256,6 +297,7 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 	l.componentNodes = components
 	l.serviceNodes = services
 	l.graph = &newGraph
+	l.appliedNodes = diff.Nodes()
 	l.cache.SyncIDs(componentIDs)
 	l.blocks = options.ComponentBlocks
 	if l.globals.OnExportsChange != nil && l.cache.ExportChangeIndex() != l.moduleExportIndex {
@@ -265,11 +307,44 @@ func (l *Loader) Apply(options ApplyOptions) diag.Diagnostics {
 	return diags
 }
 
+// nodeOutputs returns the values of n which its dependants depend on, used
+// to detect whether evaluating n changed them. mut must be held when calling
+// nodeOutputs.
+func (l *Loader) nodeOutputs(n dag.Node) any {
+	switch n := n.(type) {
+	case ComponentNode:
+		return n.Exports()
+	case *ArgumentConfigNode:
+		return l.cache.moduleArguments[n.Label()]
+	default:
+		return nil
+	}
+}
+
+// restoreSkipped restores the state which Apply resets for a node which isn't
+// evaluated. mut must be held when calling restoreSkipped.
+func (l *Loader) restoreSkipped(n dag.Node) {
+	switch n := n.(type) {
+	case *ExportConfigNode:
+		l.cache.CacheModuleExportValue(n.Label(), n.Value())
+	case *ImportConfigNode:
+		// The CustomComponentRegistry is recreated by every Apply.
+		l.componentNodeManager.customComponentReg.updateImportContent(n)
+	}
+}
+
 // Cleanup unregisters any existing metrics and optionally stops the worker pool.
 func (l *Loader) Cleanup(stopWorkerPool bool) {
 	if stopWorkerPool {
 		l.workerPool.Stop()
 	}
+	l.mut.Lock()
+	for _, unsubscribe := range l.unsubscribes {
+		unsubscribe()
+	}
+	l.unsubscribes = nil
+	l.mut.Unlock()
+
 	if l.globals.Registerer == nil {
 		return
 	}
@@ -393,10 +468,60 @@ func blockAlreadyDefined(blockMap map[string]*ast.BlockStmt, id string, block *a
 	return diag.Diagnostic{}, false
 }
 
+// subscribeServiceExports exposes the value exported by a service to the
+// config, and queues the node of the service when the expressions referencing
+// the value must be evaluated again. mut must be held when calling
+// subscribeServiceExports.
+func (l *Loader) subscribeServiceExports(node BlockNode, exporter service.Exporter) {
+	l.cache.CacheServiceExports(node.NodeID(), exporter.Exports())
+	unsubscribe := exporter.SubscribeExports(func() {
+		l.globals.OnBlockNodeUpdate(node)
+	})
+	l.unsubscribes = append(l.unsubscribes, unsubscribe)
+}
+
+// populateServiceExportsNodes adds nodes exposing the values exported by
+// services to the graph of a module.
+func (l *Loader) populateServiceExportsNodes(g *dag.Graph) diag.Diagnostics {
+	var diags diag.Diagnostics
+
+	for _, svc := range l.services {
+		exporter, ok := svc.(service.Exporter)
+		if !ok {
+			continue
+		}
+
+		id := svc.Definition().Name
+		if g.GetByID(id) != nil {
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  fmt.Sprintf("cannot add exports of service %q; node with same ID already exists", id),
+			})
+			continue
+		}
+
+		var node *ServiceExportsNode
+		if exist := l.graph.GetByID(id); exist != nil {
+			node = exist.(*ServiceExportsNode)
+		} else {
+			node = NewServiceExportsNode(svc)
+			l.subscribeServiceExports(node, exporter)
+		}
+		g.Add(node)
+	}
+	return diags
+}
+
 // populateServiceNodes adds service nodes to the graph.
 func (l *Loader) populateServiceNodes(g *dag.Graph, serviceBlocks []*ast.BlockStmt) diag.Diagnostics {
 	var diags diag.Diagnostics
 
+	// Modules can't run or configure services, but can reference the values
+	// exported by services.
+	if !l.isRootController() {
+		diags = append(diags, l.populateServiceExportsNodes(g)...)
+	}
+
 	// First, build the services.
 	for _, svc := range l.services {
 		if !l.isRootController() {
@@ -422,6 +547,9 @@ func (l *Loader) populateServiceNodes(g *dag.Graph, serviceBlocks []*ast.BlockSt
 			node = exist.(*ServiceNode)
 		} else {
 			node = NewServiceNode(l.host, svc)
+			if exporter, ok := svc.(service.Exporter); ok {
+				l.subscribeServiceExports(node, exporter)
+			}
 		}
 
 		node.UpdateBlock(nil) // Reset configuration to nil.
@@ -517,6 +645,9 @@ func (l *Loader) populateConfigBlockNodes(args map[string]any, g *dag.Graph, con
 		if importNode, ok := node.(*ImportConfigNode); ok {
 			l.componentNodeManager.customComponentReg.registerImport(importNode.label)
 		}
+		if foreachNode, ok := node.(*ForeachConfigNode); ok {
+			foreachNode.setCustomComponentRegistry(l.componentNodeManager.customComponentReg)
+		}
 
 		g.Add(node)
 	}
@@ -537,6 +668,7 @@ func (l *Loader) populateConfigBlockNodes(args map[string]any, g *dag.Graph, con
 	}
 
 	l.importConfigNodes = nodeMap.importMap
+	l.foreachConfigNodes = nodeMap.foreachMap
 
 	return diags
 }
@@ -613,10 +745,18 @@ func (l *Loader) wireGraphEdges(g *dag.Graph) diag.Diagnostics {
 			continue
 		case *CustomComponentNode:
 			l.wireCustomComponentNode(g, n)
+		case *ForeachConfigNode:
+			// Wire the custom components instantiated in the template, so that
+			// the items are reloaded when their definition changes.
+			refs := make(map[BlockNode]struct{})
+			l.collectCustomComponentReferences(ast.Body{n.Block()}, refs)
+			for ref := range refs {
+				g.AddEdge(dag.Edge{From: n, To: ref})
+			}
 		}
 
 		// Finally, wire component references.
-		refs, nodeDiags := ComponentReferences(n, g)
+		refs, nodeDiags := ComponentReferences(n, g, l.cache.Scope())
 		for _, ref := range refs {
 			g.AddEdge(dag.Edge{From: n, To: ref.Target})
 		}
@@ -669,6 +809,13 @@ func (l *Loader) Imports() map[string]*ImportConfigNode {
 	return l.importConfigNodes
 }
 
+// Foreachs returns the current set of foreach nodes.
+func (l *Loader) Foreachs() map[string]*ForeachConfigNode {
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	return l.foreachConfigNodes
+}
+
 // Graph returns a copy of the DAG managed by the Loader.
 func (l *Loader) Graph() *dag.Graph {
 	l.mut.RLock()
@@ -904,6 +1051,12 @@ func (l *Loader) collectCustomComponentReferences(stmts ast.Body, uniqueReferenc
 		switch {
 		case componentName == declareType:
 			l.collectCustomComponentReferences(blockStmt.Body, uniqueReferences)
+		case componentName == foreachBlockID:
+			for _, foreachStmt := range blockStmt.Body {
+				if template, ok := foreachStmt.(*ast.BlockStmt); ok && template.GetBlockName() == foreachTemplateBlockID {
+					l.collectCustomComponentReferences(template.Body, uniqueReferences)
+				}
+			}
 		case foundDeclare:
 			uniqueReferences[declareNode] = struct{}{}
 		case foundImport:
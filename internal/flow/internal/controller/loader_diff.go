@@ -0,0 +1,206 @@
+package controller
+
+import (
+	"reflect"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/internal/dag"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/printer"
+)
+
+// appliedNode records the state of a node the last time it was evaluated by
+// Apply.
+type appliedNode struct {
+	block  string   // River block of the node, printed to ignore positions.
+	deps   []string // Sorted IDs of the direct dependencies of the node.
+	impure bool     // Whether the block calls functions which read state outside the graph.
+}
+
+// graphDiff computes which nodes of a new graph must be evaluated by Apply.
+//
+// A node is evaluated when it's new, when its block or its set of
+// dependencies changed since the last Apply, when its last evaluation failed,
+// when it calls functions which read state outside the graph, or when a
+// dependency changed during the current Apply. The outputs of
+// evaluated nodes are compared with their previous outputs, so that
+// dependants of a node which was re-evaluated without changes are skipped.
+type graphDiff struct {
+	full      bool       // Evaluate every node.
+	graph     *dag.Graph // New graph, before transitive reduction.
+	prevGraph *dag.Graph // Graph from the last Apply.
+	prevNodes map[string]appliedNode
+	prevArgs  map[string]any // Module arguments from the last Apply.
+
+	nodes   map[string]appliedNode // State of the nodes of the new graph.
+	changed map[dag.Node]struct{}  // Nodes whose outputs changed.
+}
+
+// newGraphDiff computes the state of every node of graph. If full is true,
+// every node is evaluated.
+func newGraphDiff(full bool, graph, prevGraph *dag.Graph, prevNodes map[string]appliedNode, prevArgs map[string]any) *graphDiff {
+	d := &graphDiff{
+		full:      full,
+		graph:     graph,
+		prevGraph: prevGraph,
+		prevNodes: prevNodes,
+		prevArgs:  prevArgs,
+		nodes:     make(map[string]appliedNode, len(graph.Nodes())),
+		changed:   make(map[dag.Node]struct{}),
+	}
+	for _, n := range graph.Nodes() {
+		d.nodes[n.NodeID()] = newAppliedNode(graph, n)
+	}
+	return d
+}
+
+func newAppliedNode(g *dag.Graph, n dag.Node) appliedNode {
+	var state appliedNode
+	if bn, ok := n.(BlockNode); ok && bn.Block() != nil {
+		state.block = printBlock(bn.Block())
+		state.impure = callsImpureFunctions(bn.Block().Body)
+	}
+	for _, dep := range g.Dependencies(n) {
+		state.deps = append(state.deps, dep.NodeID())
+	}
+	sort.Strings(state.deps)
+	return state
+}
+
+// printBlock returns the text of block. Blocks are compared by their text so
+// that moving a block in the file doesn't cause it to be evaluated.
+func printBlock(block *ast.BlockStmt) string {
+	if block == nil {
+		return ""
+	}
+	var sb strings.Builder
+	if err := printer.Fprint(&sb, block); err != nil {
+		return ""
+	}
+	return sb.String()
+}
+
+// pureFunctions are the stdlib functions whose result only depends on their
+// arguments.
+var pureFunctions = map[string]struct{}{
+	"coalesce":     {},
+	"concat":       {},
+	"format":       {},
+	"join":         {},
+	"json_decode":  {},
+	"json_path":    {},
+	"nonsensitive": {},
+	"replace":      {},
+	"split":        {},
+	"to_lower":     {},
+	"to_upper":     {},
+	"trim":         {},
+	"trim_prefix":  {},
+	"trim_suffix":  {},
+	"trim_space":   {},
+}
+
+// callsImpureFunctions returns whether body calls a function which isn't in
+// pureFunctions, such as env or the functions exported by services. The
+// results of such calls can change without the config changing, so the
+// expressions must be evaluated on every Apply.
+func callsImpureFunctions(body ast.Body) bool {
+	var w impureCallWalker
+	ast.Walk(&w, body)
+	return w.impure
+}
+
+type impureCallWalker struct {
+	impure bool
+}
+
+func (w *impureCallWalker) Visit(node ast.Node) ast.Visitor {
+	if w.impure {
+		return nil
+	}
+	if call, ok := node.(*ast.CallExpr); ok {
+		ident, ok := call.Value.(*ast.IdentifierExpr)
+		if !ok {
+			w.impure = true
+			return nil
+		}
+		if _, pure := pureFunctions[ident.Ident.Name]; !pure {
+			w.impure = true
+			return nil
+		}
+	}
+	return w
+}
+
+// NeedsEvaluation returns whether n must be evaluated. Nodes must be passed in
+// topological order, so that the dependencies of n were already handled.
+func (d *graphDiff) NeedsEvaluation(n dag.Node) bool {
+	if d.full {
+		return true
+	}
+
+	switch n.(type) {
+	case *CustomComponentNode, *ForeachConfigNode, *ArgumentConfigNode:
+		// Custom components and foreach blocks load their own graph, which is
+		// itself updated incrementally, and their templates may come from a
+		// parent module. Arguments depend on the values passed to the module.
+		return true
+	}
+
+	id := n.NodeID()
+	prev, ok := d.prevNodes[id]
+	if !ok || d.prevGraph.GetByID(id) != n {
+		return true
+	}
+	// Unhealthy nodes are evaluated to retry, as a full evaluation would.
+	if hc, ok := n.(interface{ CurrentHealth() component.Health }); ok && hc.CurrentHealth().Health == component.HealthTypeUnhealthy {
+		return true
+	}
+	state := d.nodes[id]
+	if state.impure {
+		return true
+	}
+	if prev.block != state.block || !slices.Equal(prev.deps, state.deps) {
+		return true
+	}
+	for _, dep := range d.graph.Dependencies(n) {
+		if _, changed := d.changed[dep]; changed {
+			return true
+		}
+	}
+	return false
+}
+
+// Evaluated records the evaluation of n. before and after hold the outputs of
+// n returned by Loader.nodeOutputs before and after the evaluation. The values
+// of module arguments are compared with the values from the last Apply
+// instead.
+func (d *graphDiff) Evaluated(n dag.Node, before, after any, err error) {
+	if err != nil {
+		// Forget the state of failed nodes so they are evaluated by the next
+		// Apply, even if their block doesn't change.
+		delete(d.nodes, n.NodeID())
+		d.changed[n] = struct{}{}
+		return
+	}
+
+	var unchanged bool
+	switch n := n.(type) {
+	case ComponentNode:
+		unchanged = reflect.DeepEqual(before, after)
+	case *ArgumentConfigNode:
+		prev, ok := d.prevArgs[n.Label()]
+		unchanged = ok && reflect.DeepEqual(prev, after)
+	}
+	if !unchanged {
+		d.changed[n] = struct{}{}
+	}
+}
+
+// Nodes returns the state of the nodes to compare against in the next Apply.
+func (d *graphDiff) Nodes() map[string]appliedNode {
+	return d.nodes
+}
@@ -20,7 +20,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"go.opentelemetry.io/otel/trace/noop"
 
-	_ "github.com/grafana/agent/internal/flow/internal/testcomponents" // Include test components
+	"github.com/grafana/agent/internal/flow/internal/testcomponents"
 )
 
 func TestLoader(t *testing.T) {
@@ -372,6 +372,166 @@ func TestLoader_Services(t *testing.T) {
 	})
 }
 
+func TestLoader_IncrementalApply(t *testing.T) {
+	testFile := `
+		testcomponents.passthrough "a" {
+			input = "a"
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.a.output
+		}
+
+		testcomponents.passthrough "c" {
+			input = "c"
+		}
+	`
+	testConfig := `
+		logging {
+			level = "debug"
+		}
+
+		tracing {
+			sampling_fraction = 1
+		}
+	`
+
+	reg := prometheus.NewRegistry()
+	logger, _ := logging.New(os.Stderr, logging.DefaultOptions)
+	l := controller.NewLoader(controller.LoaderOptions{
+		ComponentGlobals: controller.ComponentGlobals{
+			Logger:            logger,
+			TraceProvider:     noop.NewTracerProvider(),
+			DataPath:          t.TempDir(),
+			MinStability:      featuregate.StabilityBeta,
+			OnBlockNodeUpdate: func(cn controller.BlockNode) { /* no-op */ },
+			Registerer:        reg,
+			NewModuleController: func(id string) controller.ModuleController {
+				return nil
+			},
+		},
+	})
+
+	// applyCounts applies the config and returns the number of evaluated and
+	// skipped nodes.
+	var evaluatedTotal, skippedTotal float64
+	applyCounts := func(file string) (evaluated, skipped float64) {
+		diags := applyFromContent(t, l, []byte(file), []byte(testConfig), nil)
+		require.NoError(t, diags.ErrorOrNil())
+
+		families, err := reg.Gather()
+		require.NoError(t, err)
+		for _, family := range families {
+			if family.GetName() != "agent_component_controller_apply_nodes_total" {
+				continue
+			}
+			for _, m := range family.GetMetric() {
+				for _, label := range m.GetLabel() {
+					if label.GetName() != "result" {
+						continue
+					}
+					switch label.GetValue() {
+					case "evaluated":
+						evaluated = m.GetCounter().GetValue() - evaluatedTotal
+						evaluatedTotal = m.GetCounter().GetValue()
+					case "skipped":
+						skipped = m.GetCounter().GetValue() - skippedTotal
+						skippedTotal = m.GetCounter().GetValue()
+					}
+				}
+			}
+		}
+		return evaluated, skipped
+	}
+
+	// All the nodes are evaluated the first time.
+	evaluated, skipped := applyCounts(testFile)
+	require.Equal(t, 5.0, evaluated)
+	require.Equal(t, 0.0, skipped)
+
+	// Nothing changed.
+	evaluated, skipped = applyCounts(testFile)
+	require.Equal(t, 0.0, evaluated)
+	require.Equal(t, 5.0, skipped)
+
+	// Moving blocks around doesn't cause them to be evaluated.
+	evaluated, _ = applyCounts("\n\n" + testFile)
+	require.Equal(t, 0.0, evaluated)
+
+	// Changing a component evaluates its dependants.
+	evaluated, skipped = applyCounts(strings.Replace(testFile, `input = "a"`, `input = "A"`, 1))
+	require.Equal(t, 2.0, evaluated)
+	require.Equal(t, 3.0, skipped)
+
+	for _, c := range l.Components() {
+		if c.NodeID() == "testcomponents.passthrough.b" {
+			require.Equal(t, "A", c.Exports().(testcomponents.PassthroughExports).Output)
+		}
+	}
+
+	// Dependants are skipped if the exports of the changed component are the
+	// same.
+	evaluated, skipped = applyCounts(strings.Replace(testFile, `input = "a"`, `input = "A"
+			lag   = "1ms"`, 1))
+	require.Equal(t, 1.0, evaluated)
+	require.Equal(t, 4.0, skipped)
+}
+
+func TestLoader_IncrementalApplyEnv(t *testing.T) {
+	testFile := `
+		testcomponents.passthrough "env" {
+			input = env("TEST_LOADER_INCREMENTAL_APPLY")
+		}
+
+		testcomponents.passthrough "b" {
+			input = testcomponents.passthrough.env.output
+		}
+
+		testcomponents.passthrough "c" {
+			input = to_upper("c")
+		}
+	`
+
+	logger, _ := logging.New(os.Stderr, logging.DefaultOptions)
+	l := controller.NewLoader(controller.LoaderOptions{
+		ComponentGlobals: controller.ComponentGlobals{
+			Logger:            logger,
+			TraceProvider:     noop.NewTracerProvider(),
+			DataPath:          t.TempDir(),
+			MinStability:      featuregate.StabilityBeta,
+			OnBlockNodeUpdate: func(cn controller.BlockNode) { /* no-op */ },
+			Registerer:        prometheus.NewRegistry(),
+			NewModuleController: func(id string) controller.ModuleController {
+				return nil
+			},
+		},
+	})
+
+	outputs := func() map[string]string {
+		res := make(map[string]string)
+		for _, c := range l.Components() {
+			res[c.NodeID()] = c.Exports().(testcomponents.PassthroughExports).Output
+		}
+		return res
+	}
+
+	t.Setenv("TEST_LOADER_INCREMENTAL_APPLY", "first")
+	diags := applyFromContent(t, l, []byte(testFile), nil, nil)
+	require.NoError(t, diags.ErrorOrNil())
+	require.Equal(t, "first", outputs()["testcomponents.passthrough.b"])
+
+	// Expressions reading the environment are evaluated on every Apply, even
+	// if the config didn't change.
+	t.Setenv("TEST_LOADER_INCREMENTAL_APPLY", "second")
+	diags = applyFromContent(t, l, []byte(testFile), nil, nil)
+	require.NoError(t, diags.ErrorOrNil())
+	require.Equal(t, map[string]string{
+		"testcomponents.passthrough.env": "second",
+		"testcomponents.passthrough.b":   "second",
+		"testcomponents.passthrough.c":   "C",
+	}, outputs())
+}
+
 // TestScopeWithFailingComponent is used to ensure that the scope is filled out, even if the component
 // fails to properly start.
 func TestScopeWithFailingComponent(t *testing.T) {
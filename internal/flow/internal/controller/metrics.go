@@ -15,8 +15,17 @@ type controllerMetrics struct {
 	evaluationQueueSize         prometheus.Gauge
 	slowComponentThreshold      time.Duration
 	slowComponentEvaluationTime *prometheus.CounterVec
+	applyPhaseTime              *prometheus.HistogramVec
+	applyNodes                  *prometheus.CounterVec
 }
 
+// Phases of Loader.Apply reported by the controller metrics.
+const (
+	applyPhaseLoad     = "load"     // Building the graph from the River blocks.
+	applyPhaseDiff     = "diff"     // Comparing the graph with the previous graph.
+	applyPhaseEvaluate = "evaluate" // Evaluating the nodes which changed.
+)
+
 // newControllerMetrics inits the metrics for the components controller
 func newControllerMetrics(parent, id string) *controllerMetrics {
 	cm := &controllerMetrics{
@@ -69,6 +78,25 @@ func newControllerMetrics(parent, id string) *controllerMetrics {
 		ConstLabels: map[string]string{"controller_path": parent, "controller_id": id},
 	}, []string{"component_id"})
 
+	cm.applyPhaseTime = prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:                            "agent_component_controller_apply_phase_seconds",
+			Help:                            "Time spent in each phase of loading a new config into the controller",
+			ConstLabels:                     map[string]string{"controller_path": parent, "controller_id": id},
+			Buckets:                         evaluationTimesBuckets,
+			NativeHistogramBucketFactor:     1.1,
+			NativeHistogramMaxBucketNumber:  100,
+			NativeHistogramMinResetDuration: 1 * time.Hour,
+		},
+		[]string{"phase"},
+	)
+
+	cm.applyNodes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name:        "agent_component_controller_apply_nodes_total",
+		Help:        "Number of nodes evaluated or skipped because they didn't change when loading a new config into the controller",
+		ConstLabels: map[string]string{"controller_path": parent, "controller_id": id},
+	}, []string{"result"})
+
 	return cm
 }
 
@@ -79,12 +107,23 @@ func (cm *controllerMetrics) onComponentEvaluationDone(name string, duration tim
 	}
 }
 
+func (cm *controllerMetrics) onApplyPhaseDone(phase string, duration time.Duration) {
+	cm.applyPhaseTime.WithLabelValues(phase).Observe(duration.Seconds())
+}
+
+func (cm *controllerMetrics) onApplyNodesDone(evaluated, skipped int) {
+	cm.applyNodes.WithLabelValues("evaluated").Add(float64(evaluated))
+	cm.applyNodes.WithLabelValues("skipped").Add(float64(skipped))
+}
+
 func (cm *controllerMetrics) Collect(ch chan<- prometheus.Metric) {
 	cm.componentEvaluationTime.Collect(ch)
 	cm.controllerEvaluation.Collect(ch)
 	cm.dependenciesWaitTime.Collect(ch)
 	cm.evaluationQueueSize.Collect(ch)
 	cm.slowComponentEvaluationTime.Collect(ch)
+	cm.applyPhaseTime.Collect(ch)
+	cm.applyNodes.Collect(ch)
 }
 
 func (cm *controllerMetrics) Describe(ch chan<- *prometheus.Desc) {
@@ -93,6 +132,8 @@ func (cm *controllerMetrics) Describe(ch chan<- *prometheus.Desc) {
 	cm.dependenciesWaitTime.Describe(ch)
 	cm.evaluationQueueSize.Describe(ch)
 	cm.slowComponentEvaluationTime.Describe(ch)
+	cm.applyPhaseTime.Describe(ch)
+	cm.applyNodes.Describe(ch)
 }
 
 type controllerCollector struct {
@@ -129,6 +170,11 @@ func (cc *controllerCollector) Collect(ch chan<- prometheus.Metric) {
 		im.registry.Collect(ch)
 	}
 
+	for _, fe := range cc.l.Foreachs() {
+		health := fe.CurrentHealth().Health.String()
+		componentsByHealth[health]++
+	}
+
 	for health, count := range componentsByHealth {
 		ch <- prometheus.MustNewConstMetric(cc.runningComponentsTotal, prometheus.GaugeValue, float64(count), health)
 	}
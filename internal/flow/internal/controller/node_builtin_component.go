@@ -17,7 +17,9 @@ import (
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/agent/internal/flow/tracing"
+	"github.com/grafana/agent/internal/util"
 	"github.com/grafana/river/ast"
 	"github.com/grafana/river/vm"
 	"github.com/prometheus/client_golang/prometheus"
@@ -67,6 +69,8 @@ type ComponentGlobals struct {
 	TraceProvider       trace.TracerProvider                   // Tracer shared between all managed components.
 	DataPath            string                                 // Shared directory where component data may be stored
 	MinStability        featuregate.Stability                  // Minimum allowed stability level for features
+	Lockfile            *moduleverify.Lockfile                 // Pins the content of imported modules
+	DryRun              bool                                   // Evaluate arguments without building components
 	OnBlockNodeUpdate   func(cn BlockNode)                     // Informs controller that we need to reevaluate
 	OnExportsChange     func(exports map[string]any)           // Invoked when the managed component updated its exports
 	Registerer          prometheus.Registerer                  // Registerer for serving agent and component metrics
@@ -92,20 +96,30 @@ type BuiltinComponentNode struct {
 	exportsType       reflect.Type
 	moduleController  ModuleController
 	OnBlockNodeUpdate func(cn BlockNode) // Informs controller that we need to reevaluate
-
-	mut     sync.RWMutex
-	block   *ast.BlockStmt // Current River block to derive args from
-	eval    *vm.Evaluator
-	managed component.Component // Inner managed component
-	args    component.Arguments // Evaluated arguments for the managed component
+	dryRun            bool               // Don't build the managed component
+	getServiceData    func(name string) (interface{}, error)
+
+	// ownershipChanged is signaled when the node which must run a singleton
+	// component may have changed.
+	ownershipChanged chan struct{}
+
+	mut           sync.RWMutex
+	block         *ast.BlockStmt // Current River block to derive args from
+	eval          *vm.Evaluator
+	singletonEval *vm.Evaluator       // Evaluates the singleton attribute of the clustering block, if set
+	managed       component.Component // Inner managed component
+	unregisterer  *util.Unregisterer  // Unregisters the metrics of the managed component
+	args          component.Arguments // Evaluated arguments for the managed component
+	singleton     bool                // Whether the component runs on exactly one node of the cluster
 
 	// NOTE(rfratto): health and exports have their own mutex because they may be
 	// set asynchronously while mut is still being held (i.e., when calling Evaluate
 	// and the managed component immediately creates new exports)
 
-	healthMut  sync.RWMutex
-	evalHealth component.Health // Health of the last evaluate
-	runHealth  component.Health // Health of running the component
+	healthMut      sync.RWMutex
+	evalHealth     component.Health // Health of the last evaluate
+	runHealth      component.Health // Health of running the component
+	singletonOwner string           // Name of the node running the singleton component
 
 	exportsMut sync.RWMutex
 	exports    component.Exports // Evaluated exports for the managed component
@@ -147,9 +161,11 @@ func NewBuiltinComponentNode(globals ComponentGlobals, reg component.Registratio
 		exportsType:       getExportsType(reg),
 		moduleController:  globals.NewModuleController(globalID),
 		OnBlockNodeUpdate: globals.OnBlockNodeUpdate,
+		dryRun:            globals.DryRun,
+		getServiceData:    globals.GetServiceData,
+		ownershipChanged:  make(chan struct{}, 1),
 
 		block: b,
-		eval:  vm.New(b.Body),
 
 		// Prepopulate arguments and exports with their zero values.
 		args:    reg.Args,
@@ -159,6 +175,7 @@ func NewBuiltinComponentNode(globals ComponentGlobals, reg component.Registratio
 		runHealth:  initHealth,
 	}
 	cn.managedOpts = getManagedOptions(globals, cn)
+	cn.setBlockEvaluators(b)
 
 	return cn
 }
@@ -232,7 +249,19 @@ func (cn *BuiltinComponentNode) UpdateBlock(b *ast.BlockStmt) {
 	cn.mut.Lock()
 	defer cn.mut.Unlock()
 	cn.block = b
-	cn.eval = vm.New(b.Body)
+	cn.setBlockEvaluators(b)
+}
+
+// setBlockEvaluators sets the evaluators of the arguments of b and of the
+// singleton attribute of its clustering block. cn.mut must be held, except
+// when building cn.
+func (cn *BuiltinComponentNode) setBlockEvaluators(b *ast.BlockStmt) {
+	body, singleton := splitSingleton(b.Body)
+	cn.eval = vm.New(body)
+	cn.singletonEval = nil
+	if singleton != nil {
+		cn.singletonEval = vm.New(ast.Body{singleton})
+	}
 }
 
 // Evaluate implements BlockNode and updates the arguments for the managed component
@@ -263,17 +292,39 @@ func (cn *BuiltinComponentNode) evaluate(scope *vm.Scope) error {
 		return fmt.Errorf("decoding River: %w", err)
 	}
 
+	var singletonArgs singletonArguments
+	if cn.singletonEval != nil {
+		if err := cn.singletonEval.Evaluate(scope, &singletonArgs); err != nil {
+			return fmt.Errorf("decoding River: %w", err)
+		}
+	}
+
 	// args is always a pointer to the args type, so we want to deference it since
 	// components expect a non-pointer.
 	argsCopyValue := reflect.ValueOf(argsPointer).Elem().Interface()
 
+	if cn.dryRun {
+		// Only validate the arguments; the exports keep their zero value.
+		cn.args = argsCopyValue
+		return nil
+	}
+
+	if cn.singleton != singletonArgs.Singleton {
+		cn.singleton = singletonArgs.Singleton
+		cn.notifyOwnershipChange()
+	}
+
 	if cn.managed == nil {
+		if cn.singleton {
+			// Singleton components are built by Run once this node owns them.
+			cn.args = argsCopyValue
+			return nil
+		}
+
 		// We haven't built the managed component successfully yet.
-		managed, err := cn.reg.Build(cn.managedOpts, argsCopyValue)
-		if err != nil {
+		if err := cn.build(argsCopyValue); err != nil {
 			return fmt.Errorf("building component: %w", err)
 		}
-		cn.managed = managed
 		cn.args = argsCopyValue
 
 		return nil
@@ -295,24 +346,127 @@ func (cn *BuiltinComponentNode) evaluate(scope *vm.Scope) error {
 	return nil
 }
 
+// build builds the managed component from args. cn.mut must be held.
+func (cn *BuiltinComponentNode) build(args component.Arguments) error {
+	// The metrics of the managed component are unregistered when it's
+	// discarded, so that it can be built again.
+	opts := cn.managedOpts
+	unregisterer := util.WrapWithUnregisterer(opts.Registerer)
+	opts.Registerer = unregisterer
+
+	managed, err := cn.reg.Build(opts, args)
+	if err != nil {
+		unregisterer.UnregisterAll()
+		return err
+	}
+	cn.managed = managed
+	cn.unregisterer = unregisterer
+	return nil
+}
+
 // Run runs the managed component in the calling goroutine until ctx is
 // canceled. Evaluate must have been called at least once without returning an
 // error before calling Run.
 //
+// Singleton components are only run while the cluster elects this node to
+// run them: the managed component is built when this node becomes the owner
+// of the component, and stopped and discarded when another node becomes the
+// owner.
+//
 // Run will immediately return ErrUnevaluated if Evaluate has never been called
 // successfully. Otherwise, Run will return nil.
 func (cn *BuiltinComponentNode) Run(ctx context.Context) error {
 	cn.mut.RLock()
-	managed := cn.managed
+	managed, singleton := cn.managed, cn.singleton
 	cn.mut.RUnlock()
 
-	if managed == nil {
+	if managed == nil && !singleton {
 		return ErrUnevaluated
 	}
 
-	cn.setRunHealth(component.HealthTypeHealthy, "started component")
-	err := cn.managed.Run(ctx)
+	cluster := cn.getSingletonCluster()
+	if cluster != nil {
+		unsubscribe := cluster.SubscribePeers(cn.notifyOwnershipChange)
+		defer unsubscribe()
+	}
+
+	var (
+		cancel context.CancelFunc
+		exited <-chan error // Receives the result of Run of the managed component while it runs.
+	)
+	for {
+		run, owner, err := cn.owned(cluster)
+		switch {
+		case err != nil:
+			level.Debug(cn.managedOpts.Logger).Log("msg", "failed to find the owner of the singleton component", "err", err)
+			if exited == nil {
+				cn.setRunHealth(component.HealthTypeUnknown, fmt.Sprintf("waiting for the cluster to elect the owner of the singleton component: %s", err))
+			}
+
+		case run && exited == nil:
+			managed, err := cn.buildManaged()
+			if err != nil {
+				cn.setRunHealth(component.HealthTypeUnhealthy, fmt.Sprintf("building singleton component: %s", err))
+				break
+			}
+
+			exited, cancel = runAsync(ctx, managed)
+
+			if owner != "" {
+				level.Info(cn.managedOpts.Logger).Log("msg", "started singleton component", "owner", owner)
+				cn.setRunHealth(component.HealthTypeHealthy, fmt.Sprintf("started singleton component on node %q", owner))
+			} else {
+				cn.setRunHealth(component.HealthTypeHealthy, "started component")
+			}
+
+		case !run && exited != nil:
+			cancel()
+			if err := <-exited; err != nil {
+				level.Warn(cn.managedOpts.Logger).Log("msg", "singleton component exited with error", "err", err)
+			}
+			exited = nil
+			cn.discardManaged()
+
+			level.Info(cn.managedOpts.Logger).Log("msg", "stopped singleton component", "owner", owner)
+			cn.setRunHealth(component.HealthTypeHealthy, fmt.Sprintf("singleton component runs on node %q", owner))
+
+		case !run:
+			cn.setRunHealth(component.HealthTypeHealthy, fmt.Sprintf("singleton component runs on node %q", owner))
+		}
+
+		select {
+		case <-ctx.Done():
+			if exited == nil {
+				cn.setRunHealth(component.HealthTypeExited, "component shut down normally")
+				return nil
+			}
+			err := <-exited
+			cancel()
+			cn.setExited(err)
+			return err
+
+		case err := <-exited:
+			cancel()
+			cn.setExited(err)
+			return err
+
+		case <-cn.ownershipChanged:
+		}
+	}
+}
+
+// runAsync runs c in a new goroutine until the returned cancel function is
+// called. The returned channel receives the result of Run.
+func runAsync(ctx context.Context, c component.Component) (<-chan error, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(ctx)
+	exited := make(chan error, 1)
+	go func() { exited <- c.Run(ctx) }()
+	return exited, cancel
+}
 
+// setExited logs the exit of the managed component and updates the health of
+// running it.
+func (cn *BuiltinComponentNode) setExited(err error) {
 	var exitMsg string
 	logger := cn.managedOpts.Logger
 	if err != nil {
@@ -324,7 +478,83 @@ func (cn *BuiltinComponentNode) Run(ctx context.Context) error {
 	}
 
 	cn.setRunHealth(component.HealthTypeExited, exitMsg)
-	return err
+}
+
+// getSingletonCluster returns the data of the cluster service used to elect
+// the owners of singleton components, or nil if the cluster service isn't
+// available.
+func (cn *BuiltinComponentNode) getSingletonCluster() singletonCluster {
+	if cn.getServiceData == nil {
+		return nil
+	}
+	data, err := cn.getServiceData(clusterServiceName)
+	if err != nil {
+		return nil
+	}
+	cluster, _ := data.(singletonCluster)
+	return cluster
+}
+
+// owned returns whether this node must run the managed component, along with
+// the name of the node owning the component if it's a singleton. Singleton
+// components always run if the cluster service isn't available.
+func (cn *BuiltinComponentNode) owned(cluster singletonCluster) (run bool, owner string, err error) {
+	cn.mut.RLock()
+	singleton := cn.singleton
+	cn.mut.RUnlock()
+
+	if !singleton || cluster == nil {
+		cn.setSingletonOwner("")
+		return true, "", nil
+	}
+
+	p, err := cluster.SingletonOwner(cn.globalID)
+	if err != nil {
+		cn.setSingletonOwner("")
+		return false, "", err
+	}
+	cn.setSingletonOwner(p.Name)
+	return p.Self, p.Name, nil
+}
+
+// buildManaged returns the managed component, building it if it was
+// discarded or never built.
+func (cn *BuiltinComponentNode) buildManaged() (component.Component, error) {
+	cn.mut.Lock()
+	defer cn.mut.Unlock()
+
+	if cn.managed == nil {
+		if err := cn.build(cn.args); err != nil {
+			return nil, err
+		}
+	}
+	return cn.managed, nil
+}
+
+// discardManaged discards the managed component after it stopped running, so
+// that it's built again once this node owns it again. The exports of the
+// component are reset to their zero value.
+func (cn *BuiltinComponentNode) discardManaged() {
+	cn.mut.Lock()
+	cn.managed = nil
+	if cn.unregisterer != nil {
+		cn.unregisterer.UnregisterAll()
+		cn.unregisterer = nil
+	}
+	cn.mut.Unlock()
+
+	if cn.exportsType != nil {
+		cn.setExports(cn.reg.Exports)
+	}
+}
+
+// notifyOwnershipChange wakes up Run to check which node must run the managed
+// component.
+func (cn *BuiltinComponentNode) notifyOwnershipChange() {
+	select {
+	case cn.ownershipChanged <- struct{}{}:
+	default:
+	}
 }
 
 // ErrUnevaluated is returned if BuiltinComponentNode.Run is called before a managed
@@ -393,6 +623,10 @@ func (cn *BuiltinComponentNode) setExports(e component.Exports) {
 //  2. Health from the last call to Evaluate().
 //  3. Health reported from the component.
 func (cn *BuiltinComponentNode) CurrentHealth() component.Health {
+	cn.mut.RLock()
+	managed := cn.managed
+	cn.mut.RUnlock()
+
 	cn.healthMut.RLock()
 	defer cn.healthMut.RUnlock()
 
@@ -401,7 +635,7 @@ func (cn *BuiltinComponentNode) CurrentHealth() component.Health {
 		evalHealth = cn.evalHealth
 	)
 
-	if hc, ok := cn.managed.(component.HealthComponent); ok {
+	if hc, ok := managed.(component.HealthComponent); ok {
 		componentHealth := hc.CurrentHealth()
 		return component.LeastHealthy(runHealth, evalHealth, componentHealth)
 	}
@@ -433,6 +667,20 @@ func (cn *BuiltinComponentNode) setEvalHealth(t component.HealthType, msg string
 	}
 }
 
+// SingletonOwner returns the name of the node of the cluster running the
+// component if it's a singleton, or an empty string otherwise.
+func (cn *BuiltinComponentNode) SingletonOwner() string {
+	cn.healthMut.RLock()
+	defer cn.healthMut.RUnlock()
+	return cn.singletonOwner
+}
+
+func (cn *BuiltinComponentNode) setSingletonOwner(owner string) {
+	cn.healthMut.Lock()
+	defer cn.healthMut.Unlock()
+	cn.singletonOwner = owner
+}
+
 // setRunHealth sets the internal health from a call to Run. See Health for
 // information on how overall health is calculated.
 func (cn *BuiltinComponentNode) setRunHealth(t component.HealthType, msg string) {
@@ -0,0 +1,71 @@
+package controller
+
+import (
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/river/ast"
+)
+
+// clusterServiceName is the name of the cluster service, whose data elects the
+// nodes running singleton components. The controller can't import the cluster
+// service, which depends on the controller through the HTTP service.
+const clusterServiceName = "cluster"
+
+// singletonCluster is implemented by the data of the cluster service.
+type singletonCluster interface {
+	// SingletonOwner returns the peer which runs the singleton component with
+	// the given globally unique ID.
+	SingletonOwner(id string) (peer.Peer, error)
+
+	// SubscribePeers calls notify whenever the peers of the cluster change.
+	SubscribePeers(notify func()) (unsubscribe func())
+}
+
+// singletonArguments holds the singleton attribute of the clustering block of
+// builtin components, which is handled by the controller rather than by the
+// components.
+type singletonArguments struct {
+	Singleton bool `river:"singleton,attr,optional"`
+}
+
+const (
+	clusteringBlockName = "clustering"
+	singletonAttrName   = "singleton"
+)
+
+// splitSingleton returns body without the singleton attribute of its
+// clustering block, along with the attribute, which is nil if body doesn't
+// set it. The clustering block is removed if singleton is its only statement,
+// so that components which don't support clustering accept it. body isn't
+// modified.
+func splitSingleton(body ast.Body) (ast.Body, *ast.AttributeStmt) {
+	for i, stmt := range body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok || block.GetBlockName() != clusteringBlockName || block.Label != "" {
+			continue
+		}
+
+		var (
+			singleton *ast.AttributeStmt
+			rest      ast.Body
+		)
+		for _, stmt := range block.Body {
+			if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == singletonAttrName {
+				singleton = attr
+				continue
+			}
+			rest = append(rest, stmt)
+		}
+		if singleton == nil {
+			return body, nil
+		}
+
+		split := append(ast.Body(nil), body[:i]...)
+		if len(rest) > 0 {
+			clustering := *block
+			clustering.Body = rest
+			split = append(split, &clustering)
+		}
+		return append(split, body[i+1:]...), singleton
+	}
+	return body, nil
+}
@@ -1,11 +1,25 @@
 package controller
 
 import (
+	"context"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/printer"
+	"github.com/grafana/river/vm"
+	"github.com/prometheus/client_golang/prometheus"
 	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel/trace/noop"
 )
 
 func TestGlobalID(t *testing.T) {
@@ -62,3 +76,175 @@ func TestSplitPath(t *testing.T) {
 		require.Equal(t, tt.id, id)
 	}
 }
+
+func TestSplitSingleton(t *testing.T) {
+	tt := []struct {
+		name      string
+		input     string
+		expected  string
+		singleton bool
+	}{
+		{
+			name:     "no clustering block",
+			input:    `value = 1`,
+			expected: `value = 1`,
+		},
+		{
+			name:     "clustering block without singleton",
+			input:    `clustering { enabled = true }`,
+			expected: `clustering { enabled = true }`,
+		},
+		{
+			name:      "only singleton",
+			input:     "value = 1\nclustering { singleton = true }\nother = 2",
+			expected:  "value = 1\nother = 2",
+			singleton: true,
+		},
+		{
+			name:      "singleton with other settings",
+			input:     "clustering {\n\tenabled = true\n\tsingleton = true\n}",
+			expected:  `clustering { enabled = true }`,
+			singleton: true,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			input, err := parser.ParseFile("", []byte(tc.input))
+			require.NoError(t, err)
+			expected, err := parser.ParseFile("", []byte(tc.expected))
+			require.NoError(t, err)
+
+			body, singleton := splitSingleton(input.Body)
+			require.Equal(t, tc.singleton, singleton != nil)
+			require.Equal(t, printBody(t, expected.Body), printBody(t, body))
+		})
+	}
+}
+
+func printBody(t *testing.T, body ast.Body) string {
+	var sb strings.Builder
+	for _, stmt := range body {
+		require.NoError(t, printer.Fprint(&sb, stmt))
+		sb.WriteString("\n")
+	}
+	return sb.String()
+}
+
+func TestSingleton(t *testing.T) {
+	type exports struct {
+		Running bool `river:"running,attr"`
+	}
+
+	var (
+		buildsMut sync.Mutex
+		builds    int
+	)
+	reg := component.Registration{
+		Name:    "testcomponents.singleton",
+		Args:    struct{}{},
+		Exports: exports{},
+		Build: func(opts component.Options, _ component.Arguments) (component.Component, error) {
+			buildsMut.Lock()
+			defer buildsMut.Unlock()
+			builds++
+
+			opts.OnStateChange(exports{Running: true})
+			return runFunc(func(ctx context.Context) error {
+				<-ctx.Done()
+				return nil
+			}), nil
+		},
+	}
+
+	cluster := &fakeSingletonCluster{owner: peer.Peer{Name: "self", Self: true}}
+	l, _ := logging.New(os.Stderr, logging.DefaultOptions)
+	globals := ComponentGlobals{
+		Logger:            l,
+		TraceProvider:     noop.NewTracerProvider(),
+		DataPath:          t.TempDir(),
+		OnBlockNodeUpdate: func(cn BlockNode) { /* no-op */ },
+		Registerer:        prometheus.NewRegistry(),
+		NewModuleController: func(id string) ModuleController {
+			return nil
+		},
+		GetServiceData: func(name string) (interface{}, error) {
+			require.Equal(t, clusterServiceName, name)
+			return cluster, nil
+		},
+	}
+
+	file, err := parser.ParseFile("", []byte(`
+		testcomponents.singleton "default" {
+			clustering {
+				singleton = true
+			}
+		}
+	`))
+	require.NoError(t, err)
+	cn := NewBuiltinComponentNode(globals, reg, file.Body[0].(*ast.BlockStmt))
+
+	// Singleton components aren't built until this node owns them.
+	require.NoError(t, cn.Evaluate(&vm.Scope{}))
+	require.Nil(t, cn.Component())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- cn.Run(ctx) }()
+
+	requireRunning := func(running bool, owner string, expectedBuilds int) {
+		t.Helper()
+		require.Eventually(t, func() bool {
+			buildsMut.Lock()
+			defer buildsMut.Unlock()
+			return (cn.Component() != nil) == running && cn.SingletonOwner() == owner && builds == expectedBuilds
+		}, 5*time.Second, 10*time.Millisecond)
+		require.Equal(t, exports{Running: running}, cn.Exports())
+	}
+
+	requireRunning(true, "self", 1)
+	require.Equal(t, `started singleton component on node "self"`, cn.CurrentHealth().Message)
+
+	cluster.setOwner(peer.Peer{Name: "other"})
+	requireRunning(false, "other", 1)
+	require.Equal(t, `singleton component runs on node "other"`, cn.CurrentHealth().Message)
+
+	cluster.setOwner(peer.Peer{Name: "self", Self: true})
+	requireRunning(true, "self", 2)
+
+	cancel()
+	require.NoError(t, <-done)
+}
+
+type runFunc func(ctx context.Context) error
+
+func (f runFunc) Run(ctx context.Context) error    { return f(ctx) }
+func (f runFunc) Update(component.Arguments) error { return nil }
+
+type fakeSingletonCluster struct {
+	mut    sync.Mutex
+	owner  peer.Peer
+	notify func()
+}
+
+func (c *fakeSingletonCluster) SingletonOwner(string) (peer.Peer, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	return c.owner, nil
+}
+
+func (c *fakeSingletonCluster) SubscribePeers(notify func()) (unsubscribe func()) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	c.notify = notify
+	return func() {}
+}
+
+func (c *fakeSingletonCluster) setOwner(owner peer.Peer) {
+	c.mut.Lock()
+	c.owner = owner
+	notify := c.notify
+	c.mut.Unlock()
+
+	notify()
+}
@@ -3,7 +3,10 @@ package controller
 import (
 	"fmt"
 
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/internal/importsource"
+	"github.com/grafana/agent/internal/flow/logging"
+	"github.com/grafana/agent/internal/flow/tracing"
 	"github.com/grafana/river/ast"
 	"github.com/grafana/river/diag"
 )
@@ -27,8 +30,32 @@ func NewConfigNode(block *ast.BlockStmt, globals ComponentGlobals) (BlockNode, d
 		return NewLoggingConfigNode(block, globals), nil
 	case tracingBlockID:
 		return NewTracingConfigNode(block, globals), nil
-	case importsource.BlockImportFile, importsource.BlockImportString, importsource.BlockImportHTTP, importsource.BlockImportGit:
-		return NewImportConfigNode(block, globals, importsource.GetSourceType(block.GetBlockName())), nil
+	case importsource.BlockImportFile, importsource.BlockImportString, importsource.BlockImportHTTP, importsource.BlockImportGit,
+		importsource.BlockImportS3, importsource.BlockImportOCI:
+		sourceType := importsource.GetSourceType(block.GetBlockName())
+		if err := featuregate.CheckAllowed(sourceType.Stability(), globals.MinStability, fmt.Sprintf("%s block", block.GetBlockName())); err != nil {
+			var diags diag.Diagnostics
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  err.Error(),
+				StartPos: ast.StartPos(block).Position(),
+				EndPos:   ast.EndPos(block).Position(),
+			})
+			return nil, diags
+		}
+		return NewImportConfigNode(block, globals, sourceType), nil
+	case foreachBlockID:
+		if err := featuregate.CheckAllowed(featuregate.StabilityExperimental, globals.MinStability, "foreach block"); err != nil {
+			var diags diag.Diagnostics
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				Message:  err.Error(),
+				StartPos: ast.StartPos(block).Position(),
+				EndPos:   ast.EndPos(block).Position(),
+			})
+			return nil, diags
+		}
+		return NewForeachConfigNode(block, globals), nil
 	default:
 		var diags diag.Diagnostics
 		diags.Add(diag.Diagnostic{
@@ -41,6 +68,26 @@ func NewConfigNode(block *ast.BlockStmt, globals ComponentGlobals) (BlockNode, d
 	}
 }
 
+// ConfigBlockArguments returns the zero value of the arguments of each config
+// block, keyed by block name. The declare block and the template block of
+// foreach hold a module instead of arguments, so they aren't described.
+func ConfigBlockArguments() map[string]any {
+	blocks := map[string]any{
+		argumentBlockID: argumentBlock{},
+		exportBlockID:   exportBlock{},
+		loggingBlockID:  logging.Options{},
+		tracingBlockID:  tracing.Options{},
+		foreachBlockID:  foreachArguments{},
+	}
+	for _, name := range []string{
+		importsource.BlockImportFile, importsource.BlockImportString, importsource.BlockImportHTTP,
+		importsource.BlockImportGit, importsource.BlockImportS3, importsource.BlockImportOCI,
+	} {
+		blocks[name] = importsource.GetSourceType(name).Arguments()
+	}
+	return blocks
+}
+
 // ConfigNodeMap represents the config BlockNodes in their explicit types.
 // This is helpful when validating node conditions specific to config node
 // types.
@@ -50,6 +97,7 @@ type ConfigNodeMap struct {
 	argumentMap map[string]*ArgumentConfigNode
 	exportMap   map[string]*ExportConfigNode
 	importMap   map[string]*ImportConfigNode
+	foreachMap  map[string]*ForeachConfigNode
 }
 
 // NewConfigNodeMap will create an initial ConfigNodeMap. Append must be called
@@ -61,6 +109,7 @@ func NewConfigNodeMap() *ConfigNodeMap {
 		argumentMap: map[string]*ArgumentConfigNode{},
 		exportMap:   map[string]*ExportConfigNode{},
 		importMap:   map[string]*ImportConfigNode{},
+		foreachMap:  map[string]*ForeachConfigNode{},
 	}
 }
 
@@ -80,6 +129,8 @@ func (nodeMap *ConfigNodeMap) Append(configNode BlockNode) diag.Diagnostics {
 		nodeMap.tracing = n
 	case *ImportConfigNode:
 		nodeMap.importMap[n.Label()] = n
+	case *ForeachConfigNode:
+		nodeMap.foreachMap[n.Label()] = n
 	default:
 		diags.Add(diag.Diagnostic{
 			Severity: diag.SeverityLevelError,
@@ -0,0 +1,497 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/runner"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/token"
+	"github.com/grafana/river/vm"
+)
+
+const (
+	foreachBlockID         = "foreach"
+	foreachTemplateBlockID = "template"
+)
+
+// foreachArguments are the arguments of a foreach block, excluding its
+// template block.
+type foreachArguments struct {
+	Collection []any  `river:"collection,attr"`
+	Var        string `river:"var,attr"`
+	ID         string `river:"id,attr,optional"`
+}
+
+// ForeachConfigNode instantiates the body of its template block once for
+// every item of its collection.
+//
+// Every item runs in its own module, identified by a stable item ID. When the
+// collection changes, only the modules of added or removed items are created
+// or destroyed, while the modules of the remaining items are kept running and
+// updated in place.
+type ForeachConfigNode struct {
+	nodeID            string
+	globalID          string
+	label             string
+	moduleController  ModuleController
+	OnBlockNodeUpdate func(cn BlockNode) // Informs controller that we need to reevaluate
+	logger            log.Logger
+
+	itemsUpdateChan chan struct{} // used to trigger an update of the running items
+
+	mut                     sync.RWMutex
+	block                   *ast.BlockStmt // Current River block to derive config from
+	eval                    *vm.Evaluator
+	template                *ast.BlockStmt
+	customComponentRegistry *CustomComponentRegistry
+	evaluated               bool
+	items                   map[string]*foreachItem
+	itemsRunning            bool
+
+	healthMut  sync.RWMutex
+	evalHealth component.Health // Health of the last evaluate
+	runHealth  component.Health // Health of running the items
+}
+
+var _ RunnableNode = (*ForeachConfigNode)(nil)
+
+// foreachItem is the module instantiated for a single item of a collection.
+type foreachItem struct {
+	id      string
+	managed CustomComponent
+}
+
+// NewForeachConfigNode creates a new ForeachConfigNode from an initial
+// ast.BlockStmt. The items aren't instantiated until Evaluate is called.
+func NewForeachConfigNode(block *ast.BlockStmt, globals ComponentGlobals) *ForeachConfigNode {
+	nodeID := BlockComponentID(block).String()
+
+	globalID := nodeID
+	if globals.ControllerID != "" {
+		globalID = path.Join(globals.ControllerID, nodeID)
+	}
+	parent, id := splitPath(globalID)
+
+	initHealth := component.Health{
+		Health:     component.HealthTypeUnknown,
+		Message:    "foreach created",
+		UpdateTime: time.Now(),
+	}
+
+	fn := &ForeachConfigNode{
+		nodeID:            nodeID,
+		globalID:          globalID,
+		label:             block.Label,
+		moduleController:  globals.NewModuleController(globalID),
+		OnBlockNodeUpdate: globals.OnBlockNodeUpdate,
+		logger:            log.With(globals.Logger, "config_path", parent, "config_id", id),
+		itemsUpdateChan:   make(chan struct{}, 1),
+		items:             make(map[string]*foreachItem),
+
+		evalHealth: initHealth,
+		runHealth:  initHealth,
+	}
+	fn.setBlock(block)
+	return fn
+}
+
+// setBlock splits b into the arguments of the foreach block and its template.
+// mut must be held when calling setBlock.
+func (fn *ForeachConfigNode) setBlock(b *ast.BlockStmt) {
+	var (
+		args     ast.Body
+		template *ast.BlockStmt
+	)
+	for _, stmt := range b.Body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && block.GetBlockName() == foreachTemplateBlockID && template == nil {
+			template = block
+			continue
+		}
+		args = append(args, stmt)
+	}
+
+	fn.block = b
+	fn.eval = vm.New(args)
+	fn.template = template
+}
+
+// setCustomComponentRegistry sets the registry which provides the custom
+// component definitions available to the template.
+func (fn *ForeachConfigNode) setCustomComponentRegistry(reg *CustomComponentRegistry) {
+	fn.mut.Lock()
+	defer fn.mut.Unlock()
+	fn.customComponentRegistry = reg
+}
+
+// Evaluate implements BlockNode and updates the items of the foreach block by
+// evaluating its collection with the provided scope.
+func (fn *ForeachConfigNode) Evaluate(scope *vm.Scope) error {
+	err := fn.evaluate(scope)
+
+	switch err {
+	case nil:
+		fn.setEvalHealth(component.HealthTypeHealthy, "foreach evaluated")
+	default:
+		msg := fmt.Sprintf("foreach evaluation failed: %s", err)
+		fn.setEvalHealth(component.HealthTypeUnhealthy, msg)
+	}
+	return err
+}
+
+func (fn *ForeachConfigNode) evaluate(scope *vm.Scope) error {
+	fn.mut.Lock()
+	defer fn.mut.Unlock()
+
+	if fn.template == nil {
+		return fmt.Errorf("missing required block %q", foreachTemplateBlockID)
+	}
+
+	var args foreachArguments
+	if err := fn.eval.Evaluate(scope, &args); err != nil {
+		return fmt.Errorf("decoding River: %w", err)
+	}
+	if args.Var == "" {
+		return fmt.Errorf("var must not be empty")
+	}
+
+	// Only expose the variables of the scope which the template references.
+	vars := make(map[string]any)
+	for _, t := range externalTraversals(fn.block, fn.template.Body) {
+		if v, ok := scope.Lookup(t[0].Name); ok {
+			vars[t[0].Name] = v
+		}
+	}
+
+	var (
+		items   = make(map[string]*foreachItem, len(args.Collection))
+		changed = len(args.Collection) != len(fn.items)
+		loadErr error
+	)
+	for i, value := range args.Collection {
+		id, err := foreachItemID(value, args.ID)
+		if err != nil {
+			return fmt.Errorf("item %d: %w", i, err)
+		}
+		if _, exist := items[id]; exist {
+			return fmt.Errorf("item %d: duplicate item ID %q", i, id)
+		}
+
+		item, exist := fn.items[id]
+		if !exist {
+			managed, err := fn.moduleController.NewCustomComponent(id, nil)
+			if err != nil {
+				return fmt.Errorf("creating module for item %q: %w", id, err)
+			}
+			item = &foreachItem{id: id, managed: managed}
+			changed = true
+		}
+		items[id] = item
+
+		// Reloading an existing item only updates the components whose
+		// arguments changed, so the sub-pipelines of the other items keep
+		// running undisturbed.
+		itemVars := make(map[string]any, len(vars)+1)
+		for k, v := range vars {
+			itemVars[k] = v
+		}
+		itemVars[args.Var] = value
+
+		registry := NewCustomComponentRegistry(fn.customComponentRegistry)
+		registry.scope = &vm.Scope{Variables: itemVars}
+		if err := item.managed.LoadBody(fn.template.Body, nil, registry); err != nil {
+			// Keep loading the other items, so that a single invalid item doesn't
+			// affect the rest of the collection.
+			loadErr = errors.Join(loadErr, fmt.Errorf("loading item %q: %w", id, err))
+		}
+	}
+
+	fn.items = items
+	fn.evaluated = true
+
+	// Trigger to stop the removed items from running and to start running the
+	// new ones.
+	if changed && fn.itemsRunning {
+		select {
+		case fn.itemsUpdateChan <- struct{}{}: // queued trigger
+		default: // trigger already queued; no-op
+		}
+	}
+	return loadErr
+}
+
+var invalidItemIDChars = regexp.MustCompile(`[^a-zA-Z0-9_]`)
+
+// foreachItemID returns the stable ID of an item of a collection. If idKey is
+// set, the ID is derived from the value of that key of the item. Otherwise,
+// the ID is derived from a hash of the whole item.
+//
+// IDs derived from the value of idKey hold the value with invalid characters
+// replaced, followed by a short hash of the raw value, so that values which
+// only differ in replaced characters still get different IDs.
+func foreachItemID(item any, idKey string) (string, error) {
+	if idKey == "" {
+		// fmt prints maps sorted by key, so equal items always have the same
+		// hash.
+		return fmt.Sprintf("item_%016x", xxhash.Sum64String(fmt.Sprint(item))), nil
+	}
+
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return "", fmt.Errorf("item must be an object to use id, got %T", item)
+	}
+	value, ok := obj[idKey]
+	if !ok {
+		return "", fmt.Errorf("item is missing the id key %q", idKey)
+	}
+	raw := fmt.Sprint(value)
+	return fmt.Sprintf("item_%s_%08x", invalidItemIDChars.ReplaceAllString(raw, "_"), uint32(xxhash.Sum64String(raw))), nil
+}
+
+// Run runs the modules of all items until ctx is canceled. Evaluate must have
+// been called at least once without returning an error before calling Run.
+//
+// Run will immediately return ErrUnevaluated if Evaluate has never been called
+// successfully. Otherwise, Run will return nil.
+func (fn *ForeachConfigNode) Run(ctx context.Context) error {
+	fn.mut.RLock()
+	evaluated := fn.evaluated
+	fn.mut.RUnlock()
+	if !evaluated {
+		return ErrUnevaluated
+	}
+
+	newCtx, cancel := context.WithCancel(ctx)
+	defer cancel() // This will stop the items.
+
+	runner := runner.New(func(item *foreachItem) runner.Worker {
+		return &foreachItemRunner{item: item, logger: fn.logger}
+	})
+	defer runner.Stop()
+
+	updateTasks := func() error {
+		fn.mut.Lock()
+		defer fn.mut.Unlock()
+		fn.itemsRunning = true
+		tasks := make([]*foreachItem, 0, len(fn.items))
+		for _, item := range fn.items {
+			tasks = append(tasks, item)
+		}
+		return runner.ApplyTasks(newCtx, tasks)
+	}
+
+	fn.setRunHealth(component.HealthTypeHealthy, "started foreach")
+
+	if err := updateTasks(); err != nil {
+		level.Error(fn.logger).Log("msg", "foreach failed to run items", "err", err)
+		fn.setRunHealth(component.HealthTypeUnhealthy, fmt.Sprintf("error encountered while running items: %s", err))
+		// the error is not fatal, the node can still run in unhealthy mode
+	}
+
+	for {
+		select {
+		case <-fn.itemsUpdateChan:
+			if err := updateTasks(); err != nil {
+				level.Error(fn.logger).Log("msg", "error encountered while updating items", "err", err)
+				fn.setRunHealth(component.HealthTypeUnhealthy, fmt.Sprintf("error encountered while updating items: %s", err))
+			} else {
+				fn.setRunHealth(component.HealthTypeHealthy, "items updated successfully")
+			}
+		case <-ctx.Done():
+			level.Info(fn.logger).Log("msg", "foreach exited")
+			fn.setRunHealth(component.HealthTypeExited, "foreach shut down normally")
+			return nil
+		}
+	}
+}
+
+type foreachItemRunner struct {
+	item   *foreachItem
+	logger log.Logger
+}
+
+func (r *foreachItemRunner) Run(ctx context.Context) {
+	if err := r.item.managed.Run(ctx); err != nil {
+		level.Error(r.logger).Log("msg", "foreach item stopped running", "item", r.item.id, "err", err)
+	}
+}
+
+// Hash implements runner.Task.
+func (item *foreachItem) Hash() uint64 {
+	fnvHash := fnv.New64a()
+	fnvHash.Write([]byte(item.id))
+	return fnvHash.Sum64()
+}
+
+// Equals implements runner.Task. Items are only equal to themselves, so that
+// the module of an item which was removed and added again is restarted.
+func (item *foreachItem) Equals(other runner.Task) bool {
+	return item == other.(*foreachItem)
+}
+
+// CurrentHealth returns the current health of the ForeachConfigNode.
+//
+// The health of a ForeachConfigNode is determined by combining:
+//
+//  1. Health from the call to Run().
+//  2. Health from the last call to Evaluate().
+func (fn *ForeachConfigNode) CurrentHealth() component.Health {
+	fn.healthMut.RLock()
+	defer fn.healthMut.RUnlock()
+	return component.LeastHealthy(fn.runHealth, fn.evalHealth)
+}
+
+func (fn *ForeachConfigNode) setEvalHealth(t component.HealthType, msg string) {
+	fn.healthMut.Lock()
+	defer fn.healthMut.Unlock()
+
+	fn.evalHealth = component.Health{
+		Health:     t,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+func (fn *ForeachConfigNode) setRunHealth(t component.HealthType, msg string) {
+	fn.healthMut.Lock()
+	defer fn.healthMut.Unlock()
+
+	fn.runHealth = component.Health{
+		Health:     t,
+		Message:    msg,
+		UpdateTime: time.Now(),
+	}
+}
+
+// ItemIDs returns the IDs of the current items in unspecified order.
+func (fn *ForeachConfigNode) ItemIDs() []string {
+	fn.mut.RLock()
+	defer fn.mut.RUnlock()
+
+	ids := make([]string, 0, len(fn.items))
+	for id := range fn.items {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// UpdateBlock updates the River block used to construct the items. The new
+// block isn't used until the next time Evaluate is invoked.
+//
+// UpdateBlock will panic if the block does not match the component ID of the
+// ForeachConfigNode.
+func (fn *ForeachConfigNode) UpdateBlock(b *ast.BlockStmt) {
+	if !BlockComponentID(b).Equals(strings.Split(fn.nodeID, ".")) {
+		panic("UpdateBlock called with an River block with a different ID")
+	}
+
+	fn.mut.Lock()
+	defer fn.mut.Unlock()
+	fn.setBlock(b)
+}
+
+// Label returns the label of the block.
+func (fn *ForeachConfigNode) Label() string { return fn.label }
+
+// Block implements BlockNode and returns the current block of the managed config node.
+func (fn *ForeachConfigNode) Block() *ast.BlockStmt {
+	fn.mut.RLock()
+	defer fn.mut.RUnlock()
+	return fn.block
+}
+
+// NodeID implements dag.Node and returns the unique ID for the config node.
+func (fn *ForeachConfigNode) NodeID() string { return fn.nodeID }
+
+// ModuleIDs returns the IDs of the modules of the running items.
+func (fn *ForeachConfigNode) ModuleIDs() []string {
+	return fn.moduleController.ModuleIDs()
+}
+
+// referencedTraversals returns the traversals of the foreach block which
+// reference something outside of the block.
+func (fn *ForeachConfigNode) referencedTraversals() []Traversal {
+	block := fn.Block()
+	return externalTraversals(block, block.Body)
+}
+
+// externalTraversals returns the traversals of body, which is part of the
+// foreach block, which reference something outside of the foreach block.
+// References to item variables and to blocks declared inside the template are
+// excluded.
+func externalTraversals(foreach *ast.BlockStmt, body ast.Body) []Traversal {
+	var (
+		locals = make(map[string]struct{})
+		vars   = make(map[string]struct{})
+	)
+	collectForeachLocals(foreach, locals, vars)
+
+	var res []Traversal
+	for _, t := range expressionsFromBody(body) {
+		if _, ok := vars[t[0].Name]; ok {
+			continue
+		}
+		if isLocalTraversal(t, locals) {
+			continue
+		}
+		res = append(res, t)
+	}
+	return res
+}
+
+// collectForeachLocals collects the item variable names and the IDs of the
+// blocks declared inside the template of foreach, recursing into nested
+// foreach blocks.
+func collectForeachLocals(foreach *ast.BlockStmt, locals, vars map[string]struct{}) {
+	for _, stmt := range foreach.Body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			if stmt.Name.Name != "var" {
+				continue
+			}
+			if lit, ok := stmt.Value.(*ast.LiteralExpr); ok && lit.Kind == token.STRING {
+				if name, err := strconv.Unquote(lit.Value); err == nil {
+					vars[name] = struct{}{}
+				}
+			}
+		case *ast.BlockStmt:
+			if stmt.GetBlockName() != foreachTemplateBlockID {
+				continue
+			}
+			for _, inner := range stmt.Body {
+				block, ok := inner.(*ast.BlockStmt)
+				if !ok {
+					continue
+				}
+				locals[BlockComponentID(block).String()] = struct{}{}
+				if block.GetBlockName() == foreachBlockID {
+					collectForeachLocals(block, locals, vars)
+				}
+			}
+		}
+	}
+}
+
+// isLocalTraversal returns true if any prefix of t is the ID of a local block.
+func isLocalTraversal(t Traversal, locals map[string]struct{}) bool {
+	var id ComponentID
+	for _, ident := range t {
+		id = append(id, ident.Name)
+		if _, ok := locals[id.String()]; ok {
+			return true
+		}
+	}
+	return false
+}
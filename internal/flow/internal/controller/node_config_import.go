@@ -15,6 +15,7 @@ import (
 
 	"github.com/go-kit/log"
 	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/internal/importsource"
 	"github.com/grafana/agent/internal/flow/logging/level"
 	"github.com/grafana/agent/internal/flow/tracing"
@@ -84,7 +85,7 @@ func NewImportConfigNode(block *ast.BlockStmt, globals ComponentGlobals, sourceT
 	}
 	managedOpts := getImportManagedOptions(globals, cn)
 	cn.logger = managedOpts.Logger
-	cn.source = importsource.NewImportSource(sourceType, managedOpts, vm.New(block.Body), cn.onContentUpdate)
+	cn.source = importsource.NewImportSource(sourceType, managedOpts, vm.New(block.Body), globals.Lockfile, cn.onContentUpdate)
 	return cn
 }
 
@@ -256,7 +257,8 @@ func (cn *ImportConfigNode) processImportedContent(content *ast.File) error {
 		switch componentName {
 		case declareType:
 			cn.processDeclareBlock(blockStmt)
-		case importsource.BlockImportFile, importsource.BlockImportString, importsource.BlockImportHTTP, importsource.BlockImportGit:
+		case importsource.BlockImportFile, importsource.BlockImportString, importsource.BlockImportHTTP, importsource.BlockImportGit,
+			importsource.BlockImportS3, importsource.BlockImportOCI:
 			err := cn.processImportBlock(blockStmt, componentName)
 			if err != nil {
 				return err
@@ -280,6 +282,9 @@ func (cn *ImportConfigNode) processDeclareBlock(stmt *ast.BlockStmt) {
 // processDeclareBlock creates an ImportConfigNode child from the provided import block.
 func (cn *ImportConfigNode) processImportBlock(stmt *ast.BlockStmt, fullName string) error {
 	sourceType := importsource.GetSourceType(fullName)
+	if err := featuregate.CheckAllowed(sourceType.Stability(), cn.globals.MinStability, fmt.Sprintf("%s block", fullName)); err != nil {
+		return err
+	}
 	if _, ok := cn.importConfigNodesChildren[stmt.Label]; ok {
 		return fmt.Errorf("import block redefined %s", stmt.Label)
 	}
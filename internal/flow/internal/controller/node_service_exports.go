@@ -0,0 +1,40 @@
+package controller
+
+import (
+	"github.com/grafana/agent/internal/service"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/vm"
+)
+
+// ServiceExportsNode is a Flow DAG node which exposes the values exported by
+// a service to a module. Services only run in the root controller, so the
+// node doesn't run or configure the service; it lets the nodes of the module
+// reference the exports of the service and be evaluated again when they
+// change.
+type ServiceExportsNode struct {
+	def service.Definition
+}
+
+var _ BlockNode = (*ServiceExportsNode)(nil)
+
+// NewServiceExportsNode creates a new ServiceExportsNode for the exports of
+// svc.
+func NewServiceExportsNode(svc service.Service) *ServiceExportsNode {
+	return &ServiceExportsNode{def: svc.Definition()}
+}
+
+// NodeID returns the ID of the ServiceExportsNode, which is equal to the
+// service's name.
+func (sn *ServiceExportsNode) NodeID() string { return sn.def.Name }
+
+// Block implements BlockNode. It returns nil, since services can't be
+// configured in modules.
+func (sn *ServiceExportsNode) Block() *ast.BlockStmt { return nil }
+
+// Evaluate implements BlockNode. It does nothing, since the exports of the
+// service are cached when the node is created.
+func (sn *ServiceExportsNode) Evaluate(scope *vm.Scope) error { return nil }
+
+// UpdateBlock implements BlockNode. It does nothing, since services can't be
+// configured in modules.
+func (sn *ServiceExportsNode) UpdateBlock(b *ast.BlockStmt) {}
@@ -21,6 +21,8 @@ type valueCache struct {
 	moduleArguments    map[string]any         // key -> module arguments value
 	moduleExports      map[string]any         // name -> value for the value of module exports
 	moduleChangedIndex int                    // Everytime a change occurs this is incremented
+	scope              *vm.Scope              // Variables exposed by a parent foreach block
+	serviceExports     map[string]any         // Service name -> value exported by the service
 }
 
 // newValueCache creates a new ValueCache.
@@ -31,6 +33,7 @@ func newValueCache() *valueCache {
 		exports:         make(map[string]interface{}),
 		moduleArguments: make(map[string]any),
 		moduleExports:   make(map[string]any),
+		serviceExports:  make(map[string]any),
 	}
 }
 
@@ -66,6 +69,14 @@ func (vc *valueCache) CacheExports(id ComponentID, exports component.Exports) {
 	vc.exports[nodeID] = exportsVal
 }
 
+// CacheServiceExports caches the value exported by the named service.
+func (vc *valueCache) CacheServiceExports(name string, value any) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+
+	vc.serviceExports[name] = value
+}
+
 // CacheModuleArgument will cache the provided exports using the given id.
 func (vc *valueCache) CacheModuleArgument(key string, value any) {
 	vc.mut.Lock()
@@ -158,6 +169,21 @@ func (vc *valueCache) SyncModuleArgs(args map[string]any) {
 	}
 }
 
+// SetScope sets the variables exposed by a parent foreach block. scope may be
+// nil.
+func (vc *valueCache) SetScope(scope *vm.Scope) {
+	vc.mut.Lock()
+	defer vc.mut.Unlock()
+	vc.scope = scope
+}
+
+// Scope returns the variables exposed by a parent foreach block, or nil.
+func (vc *valueCache) Scope() *vm.Scope {
+	vc.mut.RLock()
+	defer vc.mut.RUnlock()
+	return vc.scope
+}
+
 // BuildContext builds a vm.Scope based on the current set of cached values.
 // The arguments and exports for the same ID are merged into one object.
 func (vc *valueCache) BuildContext() *vm.Scope {
@@ -169,7 +195,13 @@ func (vc *valueCache) BuildContext() *vm.Scope {
 		Variables: make(map[string]interface{}),
 	}
 
-	// First, partition components by River block name.
+	// Add the values exported by services. Components take precedence over
+	// services with the same name.
+	for name, value := range vc.serviceExports {
+		scope.Variables[name] = value
+	}
+
+	// Partition components by River block name.
 	var componentsByBlockName = make(map[string][]ComponentID)
 	for _, id := range vc.components {
 		blockName := id[0]
@@ -181,6 +213,14 @@ func (vc *valueCache) BuildContext() *vm.Scope {
 		scope.Variables[blockName] = vc.buildValue(ids, 1)
 	}
 
+	// Add the variables of a parent foreach block to the scope. Components
+	// take precedence over variables of the parent scope with the same name.
+	if vc.scope != nil {
+		for name, value := range vc.scope.Variables {
+			scope.Variables[name] = mergeScopeValues(value, scope.Variables[name])
+		}
+	}
+
 	// Add module arguments to the scope.
 	if len(vc.moduleArguments) > 0 {
 		scope.Variables["argument"] = make(map[string]any)
@@ -230,3 +270,29 @@ func (vc *valueCache) buildValue(from []ComponentID, offset int) interface{} {
 	}
 	return attrs
 }
+
+// mergeScopeValues merges the value of a variable of a parent scope with the
+// value of a local variable of the same name. Objects are merged recursively,
+// other local values replace the parent value.
+func mergeScopeValues(parent, local any) any {
+	if local == nil {
+		return parent
+	}
+	parentObj, ok := parent.(map[string]any)
+	if !ok {
+		return local
+	}
+	localObj, ok := local.(map[string]any)
+	if !ok {
+		return local
+	}
+
+	merged := make(map[string]any, len(parentObj)+len(localObj))
+	for k, v := range parentObj {
+		merged[k] = v
+	}
+	for k, v := range localObj {
+		merged[k] = mergeScopeValues(parentObj[k], v)
+	}
+	return merged
+}
@@ -0,0 +1,118 @@
+package importsource
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+)
+
+// contentCacheFile is the name of the file storing the cached content in the
+// data path of an import source.
+const contentCacheFile = "module_cache.json"
+
+// contentCache stores the last good content retrieved by a remote import
+// source in its data path, so that the module can be loaded when the remote
+// store is unreachable, for example when the agent starts.
+type contentCache struct {
+	path string
+	log  log.Logger
+}
+
+// cachedContent is the on-disk format of the cache.
+type cachedContent struct {
+	// Source identifies where the content was retrieved from, so that content
+	// from a previous source isn't used after the arguments change.
+	Source  string            `json:"source"`
+	Content map[string]string `json:"content"`
+}
+
+func newContentCache(opts component.Options) contentCache {
+	return contentCache{
+		path: filepath.Join(opts.DataPath, contentCacheFile),
+		log:  opts.Logger,
+	}
+}
+
+// Store caches the content retrieved from source. Failing to cache the
+// content is logged but otherwise ignored, since the content itself is valid.
+func (c contentCache) Store(source string, content map[string]string) {
+	if err := c.store(source, content); err != nil {
+		level.Warn(c.log).Log("msg", "failed to cache module content", "path", c.path, "err", err)
+	}
+}
+
+func (c contentCache) store(source string, content map[string]string) error {
+	bb, err := json.Marshal(cachedContent{Source: source, Content: content})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(c.path), 0o750); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so that the cache is never partially
+	// written.
+	tmp := c.path + ".tmp"
+	if err := os.WriteFile(tmp, bb, 0o640); err != nil {
+		return err
+	}
+	return os.Rename(tmp, c.path)
+}
+
+// Load returns the cached content of source. ok is false if there is no
+// cached content for source.
+func (c contentCache) Load(source string) (content map[string]string, ok bool, err error) {
+	bb, err := os.ReadFile(c.path)
+	if os.IsNotExist(err) {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	var cached cachedContent
+	if err := json.Unmarshal(bb, &cached); err != nil {
+		return nil, false, err
+	}
+	if cached.Source != source {
+		return nil, false, nil
+	}
+	return cached.Content, true, nil
+}
+
+// applyContent passes content retrieved from source to onContentChange and
+// caches it. Content which doesn't match its pin in lockfile is rejected.
+func applyContent(cache contentCache, source string, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string), content map[string]string) error {
+	if err := lockfile.Verify(source, content); err != nil {
+		return err
+	}
+	onContentChange(content)
+	cache.Store(source, content)
+	return nil
+}
+
+// loadCachedContent passes the cached content of source to onContentChange
+// after retrieving the content failed with fetchErr. fetchErr is returned if
+// there is no usable cached content.
+func loadCachedContent(cache contentCache, source string, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string), fetchErr error) error {
+	content, ok, err := cache.Load(source)
+	if err != nil {
+		level.Warn(cache.log).Log("msg", "failed to read cached module content", "path", cache.path, "err", err)
+		return fetchErr
+	}
+	if !ok {
+		return fetchErr
+	}
+	if err := lockfile.Verify(source, content); err != nil {
+		level.Warn(cache.log).Log("msg", "ignoring cached module content", "err", err)
+		return fetchErr
+	}
+
+	level.Warn(cache.log).Log("msg", "failed to retrieve module, using cached content", "err", fetchErr)
+	onContentChange(content)
+	return nil
+}
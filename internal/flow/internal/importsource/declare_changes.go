@@ -0,0 +1,118 @@
+package importsource
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+)
+
+// declareSignature describes the interface of a declare block, which is what
+// configurations using the declared component depend on.
+type declareSignature struct {
+	arguments map[string]bool // Name of the argument -> whether it's optional.
+	exports   map[string]struct{}
+}
+
+// declareSignatures returns the signatures of the declare blocks defined at
+// the top level of the files in content. Files which fail to parse are
+// ignored, since their errors are reported when the module is loaded.
+func declareSignatures(content map[string]string) map[string]declareSignature {
+	signatures := make(map[string]declareSignature)
+	for name, text := range content {
+		file, err := parser.ParseFile(name, []byte(text))
+		if err != nil {
+			continue
+		}
+		for _, stmt := range file.Body {
+			block, ok := stmt.(*ast.BlockStmt)
+			if !ok || block.GetBlockName() != "declare" {
+				continue
+			}
+			signatures[block.Label] = newDeclareSignature(block.Body)
+		}
+	}
+	return signatures
+}
+
+func newDeclareSignature(body ast.Body) declareSignature {
+	sig := declareSignature{
+		arguments: make(map[string]bool),
+		exports:   make(map[string]struct{}),
+	}
+	for _, stmt := range body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+		switch block.GetBlockName() {
+		case "argument":
+			sig.arguments[block.Label] = isOptionalArgument(block.Body)
+		case "export":
+			sig.exports[block.Label] = struct{}{}
+		}
+	}
+	return sig
+}
+
+// isOptionalArgument returns whether the body of an argument block sets
+// optional to true. Values which can't be evaluated without a scope are
+// considered required.
+func isOptionalArgument(body ast.Body) bool {
+	for _, stmt := range body {
+		attr, ok := stmt.(*ast.AttributeStmt)
+		if !ok || attr.Name.Name != "optional" {
+			continue
+		}
+		var optional bool
+		if err := vm.New(attr.Value).Evaluate(nil, &optional); err != nil {
+			return false
+		}
+		return optional
+	}
+	return false
+}
+
+// breakingChanges returns the changes of the declare blocks from oldContent to
+// newContent which can break configurations using the declared components:
+// removed components, arguments and exports, and new required arguments.
+func breakingChanges(oldContent, newContent map[string]string) []string {
+	var (
+		oldSignatures = declareSignatures(oldContent)
+		newSignatures = declareSignatures(newContent)
+		changes       []string
+	)
+
+	for name, oldSig := range oldSignatures {
+		newSig, ok := newSignatures[name]
+		if !ok {
+			changes = append(changes, fmt.Sprintf("declare %q was removed", name))
+			continue
+		}
+
+		for arg, optional := range oldSig.arguments {
+			newOptional, ok := newSig.arguments[arg]
+			switch {
+			case !ok:
+				changes = append(changes, fmt.Sprintf("declare %q: argument %q was removed", name, arg))
+			case optional && !newOptional:
+				changes = append(changes, fmt.Sprintf("declare %q: argument %q is now required", name, arg))
+			}
+		}
+		for arg, optional := range newSig.arguments {
+			if _, ok := oldSig.arguments[arg]; !ok && !optional {
+				changes = append(changes, fmt.Sprintf("declare %q: new argument %q is required", name, arg))
+			}
+		}
+		for export := range oldSig.exports {
+			if _, ok := newSig.exports[export]; !ok {
+				changes = append(changes, fmt.Sprintf("declare %q: export %q was removed", name, export))
+			}
+		}
+	}
+
+	sort.Strings(changes)
+	return changes
+}
@@ -0,0 +1,144 @@
+package importsource
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/config"
+	"github.com/grafana/agent/internal/component"
+	"github.com/stretchr/testify/require"
+)
+
+const libraryV1 = `
+declare "add" {
+	argument "a" {}
+	argument "b" { optional = true }
+	argument "c" {}
+
+	export "sum" { value = 0 }
+	export "carry" { value = 0 }
+}
+
+declare "sub" {}
+`
+
+func TestBreakingChanges(t *testing.T) {
+	tt := []struct {
+		name     string
+		content  string
+		expected []string
+	}{
+		{
+			name:    "compatible",
+			content: libraryV1 + `declare "mul" { argument "a" {} }`,
+		},
+		{
+			name: "new optional argument",
+			content: `
+				declare "add" {
+					argument "a" {}
+					argument "b" { optional = true }
+					argument "c" {}
+					argument "d" { optional = true }
+
+					export "sum" { value = 0 }
+					export "carry" { value = 0 }
+				}
+
+				declare "sub" {}
+			`,
+		},
+		{
+			name: "breaking",
+			content: `
+				declare "add" {
+					argument "a" {}
+					argument "b" {}
+					argument "d" {}
+
+					export "sum" { value = 0 }
+				}
+			`,
+			expected: []string{
+				`declare "add": argument "b" is now required`,
+				`declare "add": argument "c" was removed`,
+				`declare "add": export "carry" was removed`,
+				`declare "add": new argument "d" is required`,
+				`declare "sub" was removed`,
+			},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			changes := breakingChanges(
+				map[string]string{"lib.river": libraryV1},
+				map[string]string{"lib.river": tc.content},
+			)
+			require.Equal(t, tc.expected, changes)
+		})
+	}
+}
+
+func TestImportGit_Version(t *testing.T) {
+	repoDir := t.TempDir()
+	repo, err := git.PlainInit(repoDir, false)
+	require.NoError(t, err)
+	cfg := config.NewConfig()
+	cfg.User.Name = "Go test"
+	cfg.User.Email = "go-test@example.com"
+	require.NoError(t, repo.SetConfig(cfg))
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+
+	release := func(version, content string) {
+		require.NoError(t, os.WriteFile(filepath.Join(repoDir, "lib.river"), []byte(content), 0o644))
+		_, err := wt.Add(".")
+		require.NoError(t, err)
+		hash, err := wt.Commit(version, &git.CommitOptions{})
+		require.NoError(t, err)
+		_, err = repo.CreateTag(version, hash, nil)
+		require.NoError(t, err)
+	}
+	release("v1.4.0", libraryV1)
+	release("v2.0.0", `declare "sub" {}`)
+
+	config := fmt.Sprintf(`
+		repository = %q
+		path       = "lib.river"
+		version    = "~1.4"
+	`, repoDir)
+
+	var content map[string]string
+	im := NewImportGit(testOptions(t.TempDir()), newEvaluator(t, config), nil, func(c map[string]string) { content = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, map[string]string{"lib.river": libraryV1}, content)
+	require.Equal(t, component.HealthTypeHealthy, im.CurrentHealth().Health)
+
+	// Breaking changes are reported when the version is upgraded.
+	release("v1.4.1", `declare "add" {}`)
+	im.tickPollFile(context.Background())
+	require.Equal(t, map[string]string{"lib.river": `declare "add" {}`}, content)
+
+	health := im.CurrentHealth()
+	require.Equal(t, component.HealthTypeHealthy, health.Health)
+	require.Contains(t, health.Message, "version v1.4.1 has breaking changes from v1.4.0")
+	require.Contains(t, health.Message, `declare "sub" was removed`)
+}
+
+func TestGitArguments_Validate(t *testing.T) {
+	args := DefaultGitArguments
+	args.Version = "~1.4"
+	require.NoError(t, args.Validate())
+
+	args.Revision = "main"
+	require.EqualError(t, args.Validate(), "revision and version can't both be set")
+
+	args = DefaultGitArguments
+	args.Version = "not a version"
+	require.ErrorContains(t, args.Validate(), `invalid version "not a version"`)
+}
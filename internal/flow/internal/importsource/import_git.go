@@ -14,6 +14,7 @@ import (
 
 	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/agent/internal/vcs"
 	"github.com/grafana/river/vm"
 )
@@ -28,12 +29,19 @@ type ImportGit struct {
 	repo            *vcs.GitRepo
 	repoOpts        vcs.GitRepoOptions
 	args            GitArguments
+	lockfile        *moduleverify.Lockfile
 	onContentChange func(map[string]string)
 
+	// Last content passed to onContentChange and the version it was retrieved
+	// from, used to detect breaking changes when the version changes.
+	content map[string]string
+	version string
+
 	argsChanged chan struct{}
 
 	healthMut sync.RWMutex
 	health    component.Health
+	upgrade   string // Description of the breaking changes of the last version upgrade.
 }
 
 var (
@@ -45,9 +53,12 @@ var (
 type GitArguments struct {
 	Repository    string            `river:"repository,attr"`
 	Revision      string            `river:"revision,attr,optional"`
+	Version       string            `river:"version,attr,optional"`
 	Path          string            `river:"path,attr"`
 	PullFrequency time.Duration     `river:"pull_frequency,attr,optional"`
 	GitAuthConfig vcs.GitAuthConfig `river:",squash"`
+
+	Signature *SignatureArguments `river:"signature,block,optional"`
 }
 
 var DefaultGitArguments = GitArguments{
@@ -60,11 +71,36 @@ func (args *GitArguments) SetToDefault() {
 	*args = DefaultGitArguments
 }
 
-func NewImportGit(managedOpts component.Options, eval *vm.Evaluator, onContentChange func(map[string]string)) *ImportGit {
+// Validate implements river.Validator.
+func (args *GitArguments) Validate() error {
+	if args.Version == "" {
+		return nil
+	}
+	if args.Revision != DefaultGitArguments.Revision {
+		return fmt.Errorf("revision and version can't both be set")
+	}
+	if err := vcs.ValidateVersionConstraint(args.Version); err != nil {
+		return fmt.Errorf("invalid version %q: %w", args.Version, err)
+	}
+	return nil
+}
+
+// repoOptions returns the options used to manage the repository of args.
+func (args GitArguments) repoOptions() vcs.GitRepoOptions {
+	return vcs.GitRepoOptions{
+		Repository: args.Repository,
+		Revision:   args.Revision,
+		Version:    args.Version,
+		Auth:       args.GitAuthConfig,
+	}
+}
+
+func NewImportGit(managedOpts component.Options, eval *vm.Evaluator, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string)) *ImportGit {
 	return &ImportGit{
 		opts:            managedOpts,
 		log:             managedOpts.Logger,
 		eval:            eval,
+		lockfile:        lockfile,
 		argsChanged:     make(chan struct{}, 1),
 		onContentChange: onContentChange,
 	}
@@ -157,9 +193,13 @@ func (im *ImportGit) updateHealth(err error) {
 			UpdateTime: time.Now(),
 		}
 	} else {
+		message := "module updated"
+		if im.upgrade != "" {
+			message = fmt.Sprintf("module updated; %s", im.upgrade)
+		}
 		im.health = component.Health{
 			Health:     component.HealthTypeHealthy,
-			Message:    "module updated",
+			Message:    message,
 			UpdateTime: time.Now(),
 		}
 	}
@@ -183,11 +223,7 @@ func (im *ImportGit) Update(args component.Arguments) (err error) {
 	// the two different repositories.
 	repoPath := filepath.Join(im.opts.DataPath, "repo")
 
-	repoOpts := vcs.GitRepoOptions{
-		Repository: newArgs.Repository,
-		Revision:   newArgs.Revision,
-		Auth:       newArgs.GitAuthConfig,
-	}
+	repoOpts := newArgs.repoOptions()
 
 	// Create or update the repo field.
 	// Failure to update repository makes the module loader temporarily use cached contents on disk
@@ -234,46 +270,41 @@ func (im *ImportGit) pollFile(ctx context.Context, args GitArguments) error {
 		return err
 	}
 
-	info, err := im.repo.Stat(args.Path)
+	content, err := readGitContent(im.repo, args.Path, args.Signature)
 	if err != nil {
 		return err
 	}
 
-	if info.IsDir() {
-		return im.handleDirectory(args.Path)
-	}
-
-	return im.handleFile(args.Path)
-}
-
-func (im *ImportGit) handleDirectory(path string) error {
-	filesInfo, err := im.repo.ReadDir(path)
-	if err != nil {
+	// Content which doesn't match the lockfile is rejected, so the module keeps
+	// running with the last accepted content.
+	if err := im.lockfile.Verify(args.LockKey(), content); err != nil {
 		return err
 	}
 
-	content := make(map[string]string)
-	for _, fi := range filesInfo {
-		if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".river") {
-			continue
-		}
-		bb, err := im.repo.ReadFile(filepath.Join(path, fi.Name()))
-		if err != nil {
-			return err
-		}
-		content[fi.Name()] = string(bb)
+	version := im.repo.CurrentVersion()
+	if im.version != "" && version != "" && version != im.version {
+		im.checkUpgrade(im.version, version, content)
 	}
+	im.content, im.version = content, version
+
 	im.onContentChange(content)
 	return nil
 }
 
-func (im *ImportGit) handleFile(path string) error {
-	bb, err := im.repo.ReadFile(path)
-	if err != nil {
-		return err
+// checkUpgrade reports the breaking changes in the declare blocks of the
+// module when upgrading from version from to version to.
+func (im *ImportGit) checkUpgrade(from, to string, content map[string]string) {
+	var upgrade string
+	if changes := breakingChanges(im.content, content); len(changes) > 0 {
+		upgrade = fmt.Sprintf("version %s has breaking changes from %s: %s", to, from, strings.Join(changes, "; "))
+		level.Warn(im.log).Log("msg", "module version has breaking changes", "from", from, "to", to, "changes", strings.Join(changes, "; "))
+	} else {
+		level.Info(im.log).Log("msg", "module version changed", "from", from, "to", to)
 	}
-	im.onContentChange(map[string]string{path: string(bb)})
-	return nil
+
+	im.healthMut.Lock()
+	im.upgrade = upgrade
+	im.healthMut.Unlock()
 }
 
 // CurrentHealth implements component.HealthComponent.
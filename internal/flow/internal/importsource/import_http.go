@@ -5,33 +5,52 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
+	"sync"
 	"time"
 
 	"github.com/grafana/agent/internal/component"
 	common_config "github.com/grafana/agent/internal/component/common/config"
 	remote_http "github.com/grafana/agent/internal/component/remote/http"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/river/vm"
 )
 
 // ImportHTTP imports a module from a HTTP server via the remote.http component.
 type ImportHTTP struct {
 	managedRemoteHTTP *remote_http.Component
-	arguments         component.Arguments
 	managedOpts       component.Options
 	eval              *vm.Evaluator
+	lockfile          *moduleverify.Lockfile
+	onContentChange   func(map[string]string)
+
+	argsMut   sync.RWMutex
+	arguments HTTPArguments
+
+	// verifyMut serializes verifying the content received from remote.http.
+	verifyMut   sync.Mutex
+	lastContent string
+
+	// verifyErr has its own mutex because it's read by CurrentHealth while
+	// verifyMut may be held by a content change.
+	healthMut sync.RWMutex
+	verifyErr error
 }
 
 var _ ImportSource = (*ImportHTTP)(nil)
 
-func NewImportHTTP(managedOpts component.Options, eval *vm.Evaluator, onContentChange func(map[string]string)) *ImportHTTP {
+func NewImportHTTP(managedOpts component.Options, eval *vm.Evaluator, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string)) *ImportHTTP {
+	im := &ImportHTTP{
+		eval:            eval,
+		lockfile:        lockfile,
+		onContentChange: onContentChange,
+	}
 	opts := managedOpts
 	opts.OnStateChange = func(e component.Exports) {
-		onContentChange(map[string]string{opts.ID: e.(remote_http.Exports).Content.Value})
-	}
-	return &ImportHTTP{
-		managedOpts: opts,
-		eval:        eval,
+		im.onRemoteContent(e.(remote_http.Exports).Content.Value)
 	}
+	im.managedOpts = opts
+	return im
 }
 
 // HTTPArguments holds values which are used to configure the remote.http component.
@@ -45,6 +64,8 @@ type HTTPArguments struct {
 	Body    string            `river:"body,attr,optional"`
 
 	Client common_config.HTTPClientConfig `river:"client,block,optional"`
+
+	Signature *SignatureArguments `river:"signature,block,optional"`
 }
 
 // DefaultHTTPArguments holds default settings for HTTPArguments.
@@ -65,40 +86,121 @@ func (im *ImportHTTP) Evaluate(scope *vm.Scope) error {
 	if err := im.eval.Evaluate(scope, &arguments); err != nil {
 		return fmt.Errorf("decoding River: %w", err)
 	}
+
+	im.argsMut.Lock()
+	unchanged := im.managedRemoteHTTP != nil && reflect.DeepEqual(im.arguments, arguments)
+	im.arguments = arguments
+	im.argsMut.Unlock()
+
+	if unchanged {
+		// The lockfile may have been updated since the last content was
+		// rejected, so check it again.
+		im.reverify()
+		return nil
+	}
+
 	if im.managedRemoteHTTP == nil {
 		var err error
-		im.managedRemoteHTTP, err = remote_http.New(im.managedOpts, remote_http.Arguments{
-			URL:           arguments.URL,
-			PollFrequency: arguments.PollFrequency,
-			PollTimeout:   arguments.PollTimeout,
-			Method:        arguments.Method,
-			Headers:       arguments.Headers,
-			Body:          arguments.Body,
-			Client:        arguments.Client,
-		})
+		im.managedRemoteHTTP, err = remote_http.New(im.managedOpts, arguments.remoteHTTPArguments())
 		if err != nil {
 			return fmt.Errorf("creating http component: %w", err)
 		}
-		im.arguments = arguments
-	}
-
-	if reflect.DeepEqual(im.arguments, arguments) {
 		return nil
 	}
 
 	// Update the existing managed component
-	if err := im.managedRemoteHTTP.Update(arguments); err != nil {
+	if err := im.managedRemoteHTTP.Update(arguments.remoteHTTPArguments()); err != nil {
 		return fmt.Errorf("updating component: %w", err)
 	}
-	im.arguments = arguments
 	return nil
 }
 
+func (args HTTPArguments) remoteHTTPArguments() remote_http.Arguments {
+	return remote_http.Arguments{
+		URL:           args.URL,
+		PollFrequency: args.PollFrequency,
+		PollTimeout:   args.PollTimeout,
+		Method:        args.Method,
+		Headers:       args.Headers,
+		Body:          args.Body,
+		Client:        args.Client,
+	}
+}
+
+// onRemoteContent is called when remote.http retrieves new content. The
+// content is only passed on if it can be verified, so that the module keeps
+// running with the last accepted content otherwise.
+func (im *ImportHTTP) onRemoteContent(content string) {
+	im.verifyMut.Lock()
+	defer im.verifyMut.Unlock()
+
+	im.lastContent = content
+	im.verifyAndApply()
+}
+
+func (im *ImportHTTP) reverify() {
+	im.verifyMut.Lock()
+	defer im.verifyMut.Unlock()
+
+	im.healthMut.RLock()
+	rejected := im.verifyErr != nil
+	im.healthMut.RUnlock()
+
+	if rejected {
+		im.verifyAndApply()
+	}
+}
+
+// verifyAndApply must only be called with verifyMut held.
+func (im *ImportHTTP) verifyAndApply() {
+	err := im.verify(im.lastContent)
+
+	im.healthMut.Lock()
+	im.verifyErr = err
+	im.healthMut.Unlock()
+
+	if err != nil {
+		level.Error(im.managedOpts.Logger).Log("msg", "rejected module content", "err", err)
+		return
+	}
+	im.onContentChange(map[string]string{im.managedOpts.ID: im.lastContent})
+}
+
+func (im *ImportHTTP) verify(content string) error {
+	im.argsMut.RLock()
+	args := im.arguments
+	im.argsMut.RUnlock()
+
+	if args.Signature != nil {
+		// remote.http trims the content it retrieves, so the raw content which
+		// was signed needs to be retrieved again to verify the signature.
+		signed, err := FetchHTTP(context.Background(), args)
+		if err != nil {
+			return err
+		}
+		if signed[args.URL] != content {
+			return fmt.Errorf("content of %s changed while verifying its signature", args.URL)
+		}
+	}
+	return im.lockfile.Verify(args.LockKey(), map[string]string{args.URL: content})
+}
+
 func (im *ImportHTTP) Run(ctx context.Context) error {
 	return im.managedRemoteHTTP.Run(ctx)
 }
 
 func (im *ImportHTTP) CurrentHealth() component.Health {
+	im.healthMut.RLock()
+	verifyErr := im.verifyErr
+	im.healthMut.RUnlock()
+
+	if verifyErr != nil {
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("verifying module: %s", verifyErr),
+			UpdateTime: time.Now(),
+		}
+	}
 	return im.managedRemoteHTTP.CurrentHealth()
 }
 
@@ -0,0 +1,253 @@
+package importsource
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	common_config "github.com/grafana/agent/internal/component/common/config"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/grafana/river/vm"
+	prom_config "github.com/prometheus/common/config"
+)
+
+// ImportOCI imports a module stored as an artifact in an OCI registry.
+type ImportOCI struct {
+	opts            component.Options
+	log             log.Logger
+	eval            *vm.Evaluator
+	lockfile        *moduleverify.Lockfile
+	cache           contentCache
+	onContentChange func(map[string]string)
+
+	mut        sync.Mutex
+	args       OCIArguments
+	client     *ociClient
+	lastDigest string
+
+	argsChanged chan struct{}
+
+	healthMut sync.RWMutex
+	health    component.Health
+}
+
+var _ ImportSource = (*ImportOCI)(nil)
+
+// OCIArguments holds values which are used to retrieve a module from an OCI
+// registry.
+type OCIArguments struct {
+	Repository    string        `river:"repository,attr"`
+	Reference     string        `river:"reference,attr,optional"`
+	PollFrequency time.Duration `river:"poll_frequency,attr,optional"`
+	PollTimeout   time.Duration `river:"poll_timeout,attr,optional"`
+	Insecure      bool          `river:"insecure,attr,optional"`
+
+	Client    common_config.HTTPClientConfig `river:"client,block,optional"`
+	Signature *SignatureArguments            `river:"signature,block,optional"`
+}
+
+// DefaultOCIArguments holds default settings for OCIArguments.
+var DefaultOCIArguments = OCIArguments{
+	Reference:     "latest",
+	PollFrequency: time.Minute,
+	PollTimeout:   10 * time.Second,
+	Client:        common_config.DefaultHTTPClientConfig,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *OCIArguments) SetToDefault() {
+	*args = DefaultOCIArguments
+}
+
+// Validate implements river.Validator.
+func (args *OCIArguments) Validate() error {
+	if args.PollTimeout <= 0 {
+		return fmt.Errorf("poll_timeout must be greater than 0")
+	}
+	if args.PollFrequency > 0 && args.PollFrequency <= args.PollTimeout {
+		return fmt.Errorf("poll_frequency must be greater than poll_timeout")
+	}
+	return args.Client.Validate()
+}
+
+// LockKey returns the key under which the content retrieved with args is
+// pinned in a lockfile.
+func (args OCIArguments) LockKey() string {
+	return fmt.Sprintf("oci::%s:%s", args.Repository, args.Reference)
+}
+
+func NewImportOCI(managedOpts component.Options, eval *vm.Evaluator, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string)) *ImportOCI {
+	return &ImportOCI{
+		opts:            managedOpts,
+		log:             managedOpts.Logger,
+		eval:            eval,
+		lockfile:        lockfile,
+		cache:           newContentCache(managedOpts),
+		onContentChange: onContentChange,
+		argsChanged:     make(chan struct{}, 1),
+	}
+}
+
+func (im *ImportOCI) Evaluate(scope *vm.Scope) error {
+	var arguments OCIArguments
+	if err := im.eval.Evaluate(scope, &arguments); err != nil {
+		return fmt.Errorf("decoding River: %w", err)
+	}
+
+	im.mut.Lock()
+	defer im.mut.Unlock()
+
+	if im.client != nil && reflect.DeepEqual(im.args, arguments) {
+		return nil
+	}
+
+	cli, err := prom_config.NewClientFromConfig(*arguments.Client.Convert(), im.opts.ID)
+	if err != nil {
+		return fmt.Errorf("creating http client: %w", err)
+	}
+	client, err := newOCIClient(cli, arguments.Repository, arguments.Insecure)
+	if err != nil {
+		return err
+	}
+	im.args = arguments
+	im.client = client
+	im.lastDigest = ""
+
+	select {
+	case im.argsChanged <- struct{}{}:
+	default:
+	}
+
+	// Retrieve the module immediately to report errors early. If the registry
+	// is unreachable, the cached content is used instead.
+	err = im.poll(context.Background())
+	im.updateHealth(err)
+	if err != nil {
+		return loadCachedContent(im.cache, im.args.LockKey(), im.lockfile, im.onContentChange, err)
+	}
+	return nil
+}
+
+func (im *ImportOCI) Run(ctx context.Context) error {
+	var (
+		ticker  *time.Ticker
+		tickerC <-chan time.Time
+	)
+	defer func() {
+		if ticker != nil {
+			ticker.Stop()
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-im.argsChanged:
+			im.mut.Lock()
+			pollFrequency := im.args.PollFrequency
+			im.mut.Unlock()
+
+			if ticker != nil {
+				ticker.Stop()
+				ticker, tickerC = nil, nil
+			}
+			if pollFrequency > 0 {
+				ticker = time.NewTicker(pollFrequency)
+				tickerC = ticker.C
+			}
+
+		case <-tickerC:
+			im.mut.Lock()
+			err := im.poll(ctx)
+			im.mut.Unlock()
+
+			im.updateHealth(err)
+			if err != nil {
+				level.Error(im.log).Log("msg", "failed to retrieve module from OCI registry", "err", err)
+			}
+		}
+	}
+}
+
+// poll retrieves the module if the artifact changed since the last poll.
+// poll must only be called with im.mut held.
+func (im *ImportOCI) poll(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, im.args.PollTimeout)
+	defer cancel()
+
+	if im.lastDigest != "" {
+		digest, err := im.client.ManifestDigest(ctx, im.args.Reference)
+		if err != nil {
+			return err
+		}
+		if digest == im.lastDigest {
+			return nil
+		}
+	}
+
+	content, digest, err := im.client.Fetch(ctx, im.args.Reference, im.args.Signature)
+	if err != nil {
+		return err
+	}
+	if err := applyContent(im.cache, im.args.LockKey(), im.lockfile, im.onContentChange, content); err != nil {
+		return err
+	}
+	im.lastDigest = digest
+	return nil
+}
+
+func (im *ImportOCI) updateHealth(err error) {
+	im.healthMut.Lock()
+	defer im.healthMut.Unlock()
+
+	if err != nil {
+		im.health = component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    err.Error(),
+			UpdateTime: time.Now(),
+		}
+	} else {
+		im.health = component.Health{
+			Health:     component.HealthTypeHealthy,
+			Message:    "module updated",
+			UpdateTime: time.Now(),
+		}
+	}
+}
+
+// CurrentHealth implements component.HealthComponent.
+func (im *ImportOCI) CurrentHealth() component.Health {
+	im.healthMut.RLock()
+	defer im.healthMut.RUnlock()
+	return im.health
+}
+
+// Update the evaluator.
+func (im *ImportOCI) SetEval(eval *vm.Evaluator) {
+	im.eval = eval
+}
+
+// FetchOCI retrieves the module described by args. Signatures are verified
+// if args configures them.
+func FetchOCI(ctx context.Context, args OCIArguments) (map[string]string, error) {
+	cli, err := prom_config.NewClientFromConfig(*args.Client.Convert(), "import.oci")
+	if err != nil {
+		return nil, err
+	}
+	client, err := newOCIClient(cli, args.Repository, args.Insecure)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, args.PollTimeout)
+	defer cancel()
+	content, _, err := client.Fetch(ctx, args.Reference, args.Signature)
+	return content, err
+}
@@ -0,0 +1,226 @@
+package importsource
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRegistry is a minimal OCI registry serving a single artifact, which
+// requires token authentication.
+type fakeRegistry struct {
+	t   *testing.T
+	srv *httptest.Server
+
+	mut      sync.Mutex
+	files    map[string]string
+	requests int
+}
+
+func newFakeRegistry(t *testing.T, files map[string]string) *fakeRegistry {
+	r := &fakeRegistry{t: t, files: files}
+	r.srv = httptest.NewServer(r)
+	return r
+}
+
+func (r *fakeRegistry) setFiles(files map[string]string) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.files = files
+}
+
+func (r *fakeRegistry) requestCount() int {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	return r.requests
+}
+
+func (r *fakeRegistry) repository() string {
+	return strings.TrimPrefix(r.srv.URL, "http://") + "/team/modules"
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	r.requests++
+
+	if req.URL.Path == "/token" {
+		require.Equal(r.t, "repository:team/modules:pull", req.URL.Query().Get("scope"))
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": "secret-token"})
+		return
+	}
+	if req.Header.Get("Authorization") != "Bearer secret-token" {
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry"`, r.srv.URL))
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	blobs := make(map[string]string)
+	manifest := ociManifest{MediaType: ociManifestMediaType}
+	// Layers are sorted so that the digest of the manifest is stable.
+	names := make([]string, 0, len(r.files))
+	for name := range r.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		content := r.files[name]
+		digest := sha256Digest([]byte(content))
+		blobs[digest] = content
+		manifest.Layers = append(manifest.Layers, ociDescriptor{
+			MediaType:   "application/vnd.oci.image.layer.v1.tar",
+			Digest:      digest,
+			Size:        int64(len(content)),
+			Annotations: map[string]string{ociTitleAnnotation: name},
+		})
+	}
+	manifestContent, err := json.Marshal(manifest)
+	require.NoError(r.t, err)
+
+	switch {
+	case req.URL.Path == "/v2/team/modules/manifests/v1":
+		w.Header().Set(ociDigestHeader, sha256Digest(manifestContent))
+		_, _ = w.Write(manifestContent)
+	case strings.HasPrefix(req.URL.Path, "/v2/team/modules/blobs/"):
+		blob, ok := blobs[strings.TrimPrefix(req.URL.Path, "/v2/team/modules/blobs/")]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = w.Write([]byte(blob))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestImportOCI(t *testing.T) {
+	registry := newFakeRegistry(t, map[string]string{
+		"math.river":    `declare "add" {}`,
+		"README.md":     "not a module",
+		"strings.river": `declare "concat" {}`,
+	})
+	defer registry.srv.Close()
+
+	dataPath := t.TempDir()
+	config := fmt.Sprintf(`
+		repository = %q
+		reference  = "v1"
+		insecure   = true
+	`, registry.repository())
+
+	var content map[string]string
+	im := NewImportOCI(testOptions(dataPath), newEvaluator(t, config), nil, func(c map[string]string) { content = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, map[string]string{
+		"math.river":    `declare "add" {}`,
+		"strings.river": `declare "concat" {}`,
+	}, content)
+	require.Equal(t, component.HealthTypeHealthy, im.CurrentHealth().Health)
+
+	// Polling an unchanged artifact only checks the digest of the manifest.
+	requests := registry.requestCount()
+	poll(t, im)
+	require.Equal(t, requests+1, registry.requestCount())
+
+	registry.setFiles(map[string]string{"math.river": `declare "sub" {}`})
+	poll(t, im)
+	require.Equal(t, map[string]string{"math.river": `declare "sub" {}`}, content)
+
+	// The last good content is loaded from the cache when the registry is
+	// unreachable.
+	registry.srv.Close()
+	var cached map[string]string
+	im = NewImportOCI(testOptions(dataPath), newEvaluator(t, config), nil, func(c map[string]string) { cached = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, content, cached)
+	require.Equal(t, component.HealthTypeUnhealthy, im.CurrentHealth().Health)
+
+	// Without cached content, the error is returned.
+	im = NewImportOCI(testOptions(t.TempDir()), newEvaluator(t, config), nil, func(map[string]string) {})
+	require.Error(t, im.Evaluate(nil))
+}
+
+func TestImportOCI_Signature(t *testing.T) {
+	signer := newTestSigner(t)
+	module := `declare "add" {}`
+	registry := newFakeRegistry(t, map[string]string{
+		"math.river":         module,
+		"math.river.minisig": signer.Sign(module),
+	})
+	defer registry.srv.Close()
+
+	config := fmt.Sprintf(`
+		repository = %q
+		reference  = "v1"
+		insecure   = true
+
+		signature {
+			public_keys = [%q]
+		}
+	`, registry.repository(), signer.PublicKey())
+
+	var content map[string]string
+	im := NewImportOCI(testOptions(t.TempDir()), newEvaluator(t, config), nil, func(c map[string]string) { content = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, map[string]string{"math.river": module}, content)
+
+	// Modules whose signature doesn't match are rejected, and the previous
+	// content is kept.
+	registry.setFiles(map[string]string{
+		"math.river":         `declare "sub" {}`,
+		"math.river.minisig": signer.Sign(module),
+	})
+	im.mut.Lock()
+	err := im.poll(context.Background())
+	im.mut.Unlock()
+	require.ErrorContains(t, err, `verifying signature of "math.river"`)
+	require.Equal(t, map[string]string{"math.river": module}, content)
+
+	// Modules without a signature are rejected.
+	registry.setFiles(map[string]string{"math.river": `declare "sub" {}`})
+	im.mut.Lock()
+	err = im.poll(context.Background())
+	im.mut.Unlock()
+	require.ErrorContains(t, err, `doesn't contain the signature "math.river.minisig"`)
+}
+
+func TestParseChallenge(t *testing.T) {
+	params := parseChallenge(`realm="https://auth.example.com/token",service="registry.example.com",scope="repository:a/b:pull,push"`)
+	require.Equal(t, map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:a/b:pull,push",
+	}, params)
+}
+
+func poll(t *testing.T, im *ImportOCI) {
+	im.mut.Lock()
+	defer im.mut.Unlock()
+	require.NoError(t, im.poll(context.Background()))
+}
+
+func testOptions(dataPath string) component.Options {
+	return component.Options{
+		ID:       "import.test",
+		Logger:   log.NewNopLogger(),
+		DataPath: dataPath,
+	}
+}
+
+func newEvaluator(t *testing.T, config string) *vm.Evaluator {
+	file, err := parser.ParseFile(t.Name(), []byte(config))
+	require.NoError(t, err)
+	return vm.New(file.Body)
+}
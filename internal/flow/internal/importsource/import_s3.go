@@ -0,0 +1,210 @@
+package importsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/component"
+	remote_s3 "github.com/grafana/agent/internal/component/remote/s3"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/grafana/river/vm"
+)
+
+// ImportS3 imports a module from an S3-compatible bucket via the remote.s3
+// component.
+type ImportS3 struct {
+	managedRemoteS3 *remote_s3.Component
+	managedOpts     component.Options
+	eval            *vm.Evaluator
+	lockfile        *moduleverify.Lockfile
+	cache           contentCache
+	onContentChange func(map[string]string)
+
+	argsMut   sync.RWMutex
+	arguments S3Arguments
+
+	healthMut sync.RWMutex
+	received  bool  // Whether content was retrieved from the bucket.
+	verifyErr error // Error from verifying the last retrieved content.
+}
+
+var _ ImportSource = (*ImportS3)(nil)
+
+func NewImportS3(managedOpts component.Options, eval *vm.Evaluator, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string)) *ImportS3 {
+	im := &ImportS3{
+		eval:            eval,
+		lockfile:        lockfile,
+		cache:           newContentCache(managedOpts),
+		onContentChange: onContentChange,
+	}
+	opts := managedOpts
+	opts.OnStateChange = func(e component.Exports) {
+		im.onRemoteContent(e.(remote_s3.Exports).Content.Value)
+	}
+	im.managedOpts = opts
+	return im
+}
+
+// S3Arguments holds values which are used to configure the remote.s3
+// component.
+type S3Arguments struct {
+	Path          string           `river:"path,attr"`
+	PollFrequency time.Duration    `river:"poll_frequency,attr,optional"`
+	Client        remote_s3.Client `river:"client,block,optional"`
+
+	Signature *SignatureArguments `river:"signature,block,optional"`
+}
+
+// DefaultS3Arguments holds default settings for S3Arguments.
+var DefaultS3Arguments = S3Arguments{
+	PollFrequency: remote_s3.DefaultArguments.PollFrequency,
+}
+
+// SetToDefault implements river.Defaulter.
+func (args *S3Arguments) SetToDefault() {
+	*args = DefaultS3Arguments
+}
+
+// Validate implements river.Validator.
+func (args *S3Arguments) Validate() error {
+	remoteArgs := args.remoteS3Arguments()
+	return remoteArgs.Validate()
+}
+
+// LockKey returns the key under which the content retrieved with args is
+// pinned in a lockfile.
+func (args S3Arguments) LockKey() string {
+	return "s3::" + args.Path
+}
+
+func (args S3Arguments) remoteS3Arguments() remote_s3.Arguments {
+	return remote_s3.Arguments{
+		Path:          args.Path,
+		PollFrequency: args.PollFrequency,
+		Options:       args.Client,
+	}
+}
+
+func (im *ImportS3) Evaluate(scope *vm.Scope) error {
+	var arguments S3Arguments
+	if err := im.eval.Evaluate(scope, &arguments); err != nil {
+		return fmt.Errorf("decoding River: %w", err)
+	}
+
+	im.argsMut.Lock()
+	unchanged := im.managedRemoteS3 != nil && reflect.DeepEqual(im.arguments, arguments)
+	im.arguments = arguments
+	im.argsMut.Unlock()
+
+	if unchanged {
+		return nil
+	}
+
+	if im.managedRemoteS3 != nil {
+		// Update the existing managed component
+		if err := im.managedRemoteS3.Update(arguments.remoteS3Arguments()); err != nil {
+			return fmt.Errorf("updating component: %w", err)
+		}
+		return nil
+	}
+
+	var err error
+	im.managedRemoteS3, err = remote_s3.New(im.managedOpts, arguments.remoteS3Arguments())
+	if err != nil {
+		return fmt.Errorf("creating s3 component: %w", err)
+	}
+
+	// remote.s3 doesn't fail when the initial download fails, so fall back to
+	// the cached content if nothing was retrieved.
+	im.healthMut.RLock()
+	received, verifyErr := im.received, im.verifyErr
+	im.healthMut.RUnlock()
+	if !received {
+		fetchErr := verifyErr
+		if fetchErr == nil {
+			fetchErr = errors.New(im.managedRemoteS3.CurrentHealth().Message)
+		}
+		return loadCachedContent(im.cache, arguments.LockKey(), im.lockfile, im.onContentChange, fetchErr)
+	}
+	return nil
+}
+
+// onRemoteContent is called when remote.s3 retrieves the content of the
+// module.
+func (im *ImportS3) onRemoteContent(content string) {
+	im.argsMut.RLock()
+	args := im.arguments
+	im.argsMut.RUnlock()
+
+	var err error
+	if args.Signature != nil {
+		err = verifyS3Signature(context.Background(), args, []byte(content))
+	}
+	if err == nil {
+		err = applyContent(im.cache, args.LockKey(), im.lockfile, im.onContentChange, map[string]string{args.Path: content})
+	}
+
+	im.healthMut.Lock()
+	im.verifyErr = err
+	im.received = im.received || err == nil
+	im.healthMut.Unlock()
+
+	if err != nil {
+		level.Error(im.managedOpts.Logger).Log("msg", "rejected module content", "err", err)
+	}
+}
+
+func (im *ImportS3) Run(ctx context.Context) error {
+	return im.managedRemoteS3.Run(ctx)
+}
+
+func (im *ImportS3) CurrentHealth() component.Health {
+	im.healthMut.RLock()
+	verifyErr := im.verifyErr
+	im.healthMut.RUnlock()
+
+	if verifyErr != nil {
+		return component.Health{
+			Health:     component.HealthTypeUnhealthy,
+			Message:    fmt.Sprintf("verifying module: %s", verifyErr),
+			UpdateTime: time.Now(),
+		}
+	}
+	return im.managedRemoteS3.CurrentHealth()
+}
+
+// Update the evaluator.
+func (im *ImportS3) SetEval(eval *vm.Evaluator) {
+	im.eval = eval
+}
+
+// FetchS3 retrieves the module described by args. Signatures are verified
+// if args configures them.
+func FetchS3(ctx context.Context, args S3Arguments) (map[string]string, error) {
+	bb, err := remote_s3.Fetch(ctx, args.Path, args.Client)
+	if err != nil {
+		return nil, err
+	}
+	if args.Signature != nil {
+		if err := verifyS3Signature(ctx, args, bb); err != nil {
+			return nil, err
+		}
+	}
+	return map[string]string{args.Path: string(bb)}, nil
+}
+
+// verifyS3Signature fetches the signature of content from the object of the
+// module with the moduleverify.SignatureSuffix suffix and verifies it.
+func verifyS3Signature(ctx context.Context, args S3Arguments, content []byte) error {
+	sigPath := args.Path + moduleverify.SignatureSuffix
+	sig, err := remote_s3.Fetch(ctx, sigPath, args.Client)
+	if err != nil {
+		return fmt.Errorf("fetching signature from %s: %w", sigPath, err)
+	}
+	return args.Signature.verify(args.Path, content, sig)
+}
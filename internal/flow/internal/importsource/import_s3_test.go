@@ -0,0 +1,120 @@
+package importsource
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeS3 is a minimal S3-compatible server serving objects with path-style
+// requests.
+type fakeS3 struct {
+	mut     sync.Mutex
+	objects map[string]string // Path, including the bucket -> content
+}
+
+func (s *fakeS3) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	content, ok := s.objects[req.URL.Path]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Length", fmt.Sprint(len(content)))
+	_, _ = w.Write([]byte(content))
+}
+
+func TestImportS3(t *testing.T) {
+	bucket := &fakeS3{objects: map[string]string{"/modules/math.river": `declare "add" {}`}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	dataPath := t.TempDir()
+	config := fmt.Sprintf(`
+		path = "s3://modules/math.river"
+
+		client {
+			endpoint       = %q
+			key            = "key"
+			secret         = "secret"
+			region         = "us-east-1"
+			use_path_style = true
+		}
+	`, srv.URL)
+
+	var content map[string]string
+	im := NewImportS3(s3TestOptions(dataPath), newEvaluator(t, config), nil, func(c map[string]string) { content = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, map[string]string{"s3://modules/math.river": `declare "add" {}`}, content)
+	require.Equal(t, component.HealthTypeHealthy, im.CurrentHealth().Health)
+
+	// The last good content is loaded from the cache when the bucket is
+	// unreachable.
+	srv.Close()
+	var cached map[string]string
+	im = NewImportS3(s3TestOptions(dataPath), newEvaluator(t, config), nil, func(c map[string]string) { cached = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, content, cached)
+
+	// Without cached content, the error is returned.
+	im = NewImportS3(s3TestOptions(t.TempDir()), newEvaluator(t, config), nil, func(map[string]string) {})
+	require.Error(t, im.Evaluate(nil))
+}
+
+func TestImportS3_Signature(t *testing.T) {
+	signer := newTestSigner(t)
+	module := `declare "add" {}`
+	bucket := &fakeS3{objects: map[string]string{
+		"/modules/math.river":         module,
+		"/modules/math.river.minisig": signer.Sign(module),
+		"/modules/other.river":        `declare "sub" {}`,
+	}}
+	srv := httptest.NewServer(bucket)
+	defer srv.Close()
+
+	config := func(path string) string {
+		return fmt.Sprintf(`
+			path = %q
+
+			client {
+				endpoint       = %q
+				key            = "key"
+				secret         = "secret"
+				region         = "us-east-1"
+				use_path_style = true
+			}
+
+			signature {
+				public_keys = [%q]
+			}
+		`, path, srv.URL, signer.PublicKey())
+	}
+
+	var content map[string]string
+	im := NewImportS3(s3TestOptions(t.TempDir()), newEvaluator(t, config("s3://modules/math.river")), nil, func(c map[string]string) { content = c })
+	require.NoError(t, im.Evaluate(nil))
+	require.Equal(t, map[string]string{"s3://modules/math.river": module}, content)
+	require.Equal(t, component.HealthTypeHealthy, im.CurrentHealth().Health)
+
+	// Modules without a signature are rejected.
+	content = nil
+	im = NewImportS3(s3TestOptions(t.TempDir()), newEvaluator(t, config("s3://modules/other.river")), nil, func(c map[string]string) { content = c })
+	require.ErrorContains(t, im.Evaluate(nil), "fetching signature from s3://modules/other.river.minisig")
+	require.Nil(t, content)
+	require.Equal(t, component.HealthTypeUnhealthy, im.CurrentHealth().Health)
+	require.Contains(t, im.CurrentHealth().Message, "fetching signature")
+}
+
+func s3TestOptions(dataPath string) component.Options {
+	opts := testOptions(dataPath)
+	opts.Registerer = prometheus.NewRegistry()
+	return opts
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 
 	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/river/vm"
 )
 
@@ -15,6 +17,8 @@ const (
 	String
 	Git
 	HTTP
+	S3
+	OCI
 )
 
 const (
@@ -22,6 +26,8 @@ const (
 	BlockImportString = "import.string"
 	BlockImportHTTP   = "import.http"
 	BlockImportGit    = "import.git"
+	BlockImportS3     = "import.s3"
+	BlockImportOCI    = "import.oci"
 )
 
 // ImportSource retrieves a module from a source.
@@ -38,16 +44,21 @@ type ImportSource interface {
 
 // NewImportSource creates a new ImportSource depending on the type.
 // onContentChange is used by the source when it receives new content.
-func NewImportSource(sourceType SourceType, managedOpts component.Options, eval *vm.Evaluator, onContentChange func(map[string]string)) ImportSource {
+// Remote sources reject content which doesn't match its pin in lockfile.
+func NewImportSource(sourceType SourceType, managedOpts component.Options, eval *vm.Evaluator, lockfile *moduleverify.Lockfile, onContentChange func(map[string]string)) ImportSource {
 	switch sourceType {
 	case File:
 		return NewImportFile(managedOpts, eval, onContentChange)
 	case String:
 		return NewImportString(eval, onContentChange)
 	case HTTP:
-		return NewImportHTTP(managedOpts, eval, onContentChange)
+		return NewImportHTTP(managedOpts, eval, lockfile, onContentChange)
 	case Git:
-		return NewImportGit(managedOpts, eval, onContentChange)
+		return NewImportGit(managedOpts, eval, lockfile, onContentChange)
+	case S3:
+		return NewImportS3(managedOpts, eval, lockfile, onContentChange)
+	case OCI:
+		return NewImportOCI(managedOpts, eval, lockfile, onContentChange)
 	}
 	panic(fmt.Errorf("unsupported source type: %v", sourceType))
 }
@@ -63,6 +74,39 @@ func GetSourceType(fullName string) SourceType {
 		return HTTP
 	case BlockImportGit:
 		return Git
+	case BlockImportS3:
+		return S3
+	case BlockImportOCI:
+		return OCI
 	}
 	panic(fmt.Errorf("name does not map to a known source type: %v", fullName))
 }
+
+// Stability returns the stability level of the source type.
+func (t SourceType) Stability() featuregate.Stability {
+	switch t {
+	case S3, OCI:
+		return featuregate.StabilityExperimental
+	}
+	return featuregate.StabilityStable
+}
+
+// Arguments returns the zero value of the arguments of the source type's
+// block.
+func (t SourceType) Arguments() any {
+	switch t {
+	case File:
+		return FileArguments{}
+	case String:
+		return importStringConfigBlock{}
+	case HTTP:
+		return HTTPArguments{}
+	case Git:
+		return GitArguments{}
+	case S3:
+		return S3Arguments{}
+	case OCI:
+		return OCIArguments{}
+	}
+	panic(fmt.Errorf("unsupported source type: %v", t))
+}
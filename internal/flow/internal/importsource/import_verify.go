@@ -0,0 +1,187 @@
+package importsource
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/grafana/agent/internal/vcs"
+	prom_config "github.com/prometheus/common/config"
+)
+
+// SignatureArguments configures the verification of the signatures of
+// imported modules.
+type SignatureArguments struct {
+	PublicKeys []string `river:"public_keys,attr"`
+}
+
+// Validate implements river.Validator.
+func (args *SignatureArguments) Validate() error {
+	if len(args.PublicKeys) == 0 {
+		return errors.New("at least one public key must be provided")
+	}
+	for i, key := range args.PublicKeys {
+		if _, err := moduleverify.ParsePublicKey(key); err != nil {
+			return fmt.Errorf("public_keys[%d]: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// verify checks the minisign signature of content.
+func (args *SignatureArguments) verify(name string, content, signature []byte) error {
+	keys := make([]moduleverify.PublicKey, 0, len(args.PublicKeys))
+	for _, key := range args.PublicKeys {
+		pk, err := moduleverify.ParsePublicKey(key)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, pk)
+	}
+	if err := moduleverify.VerifySignature(keys, content, signature); err != nil {
+		return fmt.Errorf("verifying signature of %q: %w", name, err)
+	}
+	return nil
+}
+
+// LockKey returns the key under which the content retrieved with args is
+// pinned in a lockfile.
+func (args GitArguments) LockKey() string {
+	if args.Version != "" {
+		return fmt.Sprintf("git::%s//%s?version=%s", args.Repository, args.Path, args.Version)
+	}
+	return fmt.Sprintf("git::%s//%s?ref=%s", args.Repository, args.Path, args.Revision)
+}
+
+// LockKey returns the key under which the content retrieved with args is
+// pinned in a lockfile.
+func (args HTTPArguments) LockKey() string {
+	return "http::" + args.URL
+}
+
+// readGitContent reads the module at path from repo. If path is a directory,
+// all the .river files in the directory are read. If sig is set, the
+// signature of every file is read from a sibling file with the
+// moduleverify.SignatureSuffix suffix and verified.
+func readGitContent(repo *vcs.GitRepo, path string, sig *SignatureArguments) (map[string]string, error) {
+	info, err := repo.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	// Maps the name of the content to the path of the file in the repository.
+	files := make(map[string]string)
+	if info.IsDir() {
+		filesInfo, err := repo.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, fi := range filesInfo {
+			if fi.IsDir() || !strings.HasSuffix(fi.Name(), ".river") {
+				continue
+			}
+			files[fi.Name()] = filepath.Join(path, fi.Name())
+		}
+	} else {
+		files[path] = path
+	}
+
+	content := make(map[string]string, len(files))
+	for name, filePath := range files {
+		bb, err := repo.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if sig != nil {
+			sigContent, err := repo.ReadFile(filePath + moduleverify.SignatureSuffix)
+			if err != nil {
+				return nil, fmt.Errorf("reading signature of %q: %w", filePath, err)
+			}
+			if err := sig.verify(filePath, bb, sigContent); err != nil {
+				return nil, err
+			}
+		}
+		content[name] = string(bb)
+	}
+	return content, nil
+}
+
+// FetchGit retrieves the module described by args, using storagePath to
+// clone the repository. Signatures are verified if args configures them.
+func FetchGit(ctx context.Context, storagePath string, args GitArguments) (map[string]string, error) {
+	repo, err := vcs.NewGitRepo(ctx, storagePath, args.repoOptions())
+	if err != nil {
+		return nil, err
+	}
+	return readGitContent(repo, args.Path, args.Signature)
+}
+
+// FetchHTTP retrieves the module described by args. Signatures are verified
+// if args configures them.
+func FetchHTTP(ctx context.Context, args HTTPArguments) (map[string]string, error) {
+	cli, err := prom_config.NewClientFromConfig(*args.Client.Convert(), "import.http")
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, args.PollTimeout)
+	defer cancel()
+
+	bb, err := httpRequest(ctx, cli, args.Method, args.URL, args.Headers, args.Body)
+	if err != nil {
+		return nil, err
+	}
+	if args.Signature != nil {
+		if err := verifyHTTPSignature(ctx, cli, args, bb); err != nil {
+			return nil, err
+		}
+	}
+	// The content is trimmed the same way as in remote.http, so that the hash
+	// matches the content seen by a running import.http block.
+	return map[string]string{args.URL: strings.TrimSpace(string(bb))}, nil
+}
+
+// verifyHTTPSignature fetches the signature of content from the URL of the
+// module with the moduleverify.SignatureSuffix suffix and verifies it.
+func verifyHTTPSignature(ctx context.Context, cli *http.Client, args HTTPArguments, content []byte) error {
+	sigURL := args.URL + moduleverify.SignatureSuffix
+	sig, err := httpRequest(ctx, cli, http.MethodGet, sigURL, args.Headers, "")
+	if err != nil {
+		return fmt.Errorf("fetching signature from %s: %w", sigURL, err)
+	}
+	return args.Signature.verify(args.URL, content, sig)
+}
+
+func httpRequest(ctx context.Context, cli *http.Client, method, url string, headers map[string]string, body string) ([]byte, error) {
+	var reqBody io.Reader
+	if body != "" {
+		reqBody = strings.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, url, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("building request: %w", err)
+	}
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := cli.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("performing request: %w", err)
+	}
+	defer resp.Body.Close()
+
+	bb, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code %s", resp.Status)
+	}
+	return bb, nil
+}
@@ -0,0 +1,43 @@
+package importsource
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// testSigner signs module files in the format of minisign.
+type testSigner struct {
+	keyID [8]byte
+	pub   ed25519.PublicKey
+	priv  ed25519.PrivateKey
+}
+
+func newTestSigner(t *testing.T) *testSigner {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	return &testSigner{keyID: [8]byte{1, 2, 3, 4, 5, 6, 7, 8}, pub: pub, priv: priv}
+}
+
+// PublicKey returns the public key of the signer, as set in the public_keys
+// argument of signature blocks.
+func (s *testSigner) PublicKey() string {
+	return base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.keyID[:]...), s.pub...))
+}
+
+// Sign returns the content of the signature file of content.
+func (s *testSigner) Sign(content string) string {
+	sig := ed25519.Sign(s.priv, []byte(content))
+	trustedComment := "timestamp:1"
+	globalSig := ed25519.Sign(s.priv, append(sig, trustedComment...))
+
+	return fmt.Sprintf("untrusted comment: signature from minisign secret key\n%s\ntrusted comment: %s\n%s\n",
+		base64.StdEncoding.EncodeToString(append(append([]byte("Ed"), s.keyID[:]...), sig...)),
+		trustedComment,
+		base64.StdEncoding.EncodeToString(globalSig),
+	)
+}
@@ -0,0 +1,316 @@
+package importsource
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/grafana/agent/internal/flow/moduleverify"
+)
+
+// OCI media types and annotations used to retrieve modules from artifacts.
+const (
+	ociManifestMediaType  = "application/vnd.oci.image.manifest.v1+json"
+	ociTitleAnnotation    = "org.opencontainers.image.title"
+	ociDigestHeader       = "Docker-Content-Digest"
+	ociMaxManifestSize    = 4 << 20
+	ociMaxModuleBlobSize  = 16 << 20
+	ociAuthenticateHeader = "WWW-Authenticate"
+)
+
+// ociClient retrieves modules stored as OCI artifacts using the OCI
+// distribution API. Every layer of the artifact whose title annotation is the
+// name of a .river file is a module file, which is the layout used by tools
+// such as oras when pushing files. The signature of a module file is the layer
+// whose title is the name of the file with the moduleverify.SignatureSuffix
+// suffix.
+type ociClient struct {
+	cli    *http.Client
+	scheme string
+	host   string
+	name   string
+
+	token string // Bearer token for registries using token authentication.
+}
+
+type ociManifest struct {
+	MediaType string          `json:"mediaType"`
+	Layers    []ociDescriptor `json:"layers"`
+}
+
+type ociDescriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Annotations map[string]string `json:"annotations"`
+}
+
+// newOCIClient creates a client for repository, which is the registry host
+// followed by the name of the repository, such as
+// "registry.example.com/team/modules".
+func newOCIClient(cli *http.Client, repository string, insecure bool) (*ociClient, error) {
+	host, name, ok := strings.Cut(repository, "/")
+	if !ok || host == "" || name == "" {
+		return nil, fmt.Errorf("invalid repository %q: expected REGISTRY/NAME", repository)
+	}
+
+	scheme := "https"
+	if insecure {
+		scheme = "http"
+	}
+	return &ociClient{cli: cli, scheme: scheme, host: host, name: name}, nil
+}
+
+// ManifestDigest returns the digest of the manifest of reference, which can
+// be used to check whether the artifact changed without retrieving it.
+func (c *ociClient) ManifestDigest(ctx context.Context, reference string) (string, error) {
+	resp, err := c.do(ctx, http.MethodHead, "manifests/"+reference, ociManifestMediaType)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if digest := resp.Header.Get(ociDigestHeader); digest != "" {
+		return digest, nil
+	}
+	// Registries aren't required to return the digest; fall back to
+	// retrieving the manifest.
+	_, digest, err := c.manifest(ctx, reference)
+	return digest, err
+}
+
+// Fetch retrieves the module files of the artifact tagged with reference. If
+// sig is set, the signature of every module file is retrieved and verified.
+func (c *ociClient) Fetch(ctx context.Context, reference string, sig *SignatureArguments) (content map[string]string, digest string, err error) {
+	manifest, digest, err := c.manifest(ctx, reference)
+	if err != nil {
+		return nil, "", err
+	}
+
+	layers := make(map[string]ociDescriptor, len(manifest.Layers))
+	for _, layer := range manifest.Layers {
+		layers[layer.Annotations[ociTitleAnnotation]] = layer
+	}
+
+	content = make(map[string]string)
+	for name, layer := range layers {
+		if !strings.HasSuffix(name, ".river") {
+			continue
+		}
+		bb, err := c.layer(ctx, name, layer)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if sig != nil {
+			sigName := name + moduleverify.SignatureSuffix
+			sigLayer, ok := layers[sigName]
+			if !ok {
+				return nil, "", fmt.Errorf("artifact %s/%s:%s doesn't contain the signature %q", c.host, c.name, reference, sigName)
+			}
+			sigContent, err := c.layer(ctx, sigName, sigLayer)
+			if err != nil {
+				return nil, "", err
+			}
+			if err := sig.verify(name, bb, sigContent); err != nil {
+				return nil, "", err
+			}
+		}
+		content[name] = string(bb)
+	}
+	if len(content) == 0 {
+		return nil, "", fmt.Errorf("artifact %s/%s:%s doesn't contain any .river file", c.host, c.name, reference)
+	}
+	return content, digest, nil
+}
+
+// layer retrieves the content of the layer of the file name.
+func (c *ociClient) layer(ctx context.Context, name string, layer ociDescriptor) ([]byte, error) {
+	if layer.Size > ociMaxModuleBlobSize {
+		return nil, fmt.Errorf("file %q is larger than %d bytes", name, ociMaxModuleBlobSize)
+	}
+	bb, err := c.blob(ctx, layer.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("retrieving file %q: %w", name, err)
+	}
+	return bb, nil
+}
+
+func (c *ociClient) manifest(ctx context.Context, reference string) (ociManifest, string, error) {
+	resp, err := c.do(ctx, http.MethodGet, "manifests/"+reference, ociManifestMediaType)
+	if err != nil {
+		return ociManifest{}, "", err
+	}
+	defer resp.Body.Close()
+
+	bb, err := io.ReadAll(io.LimitReader(resp.Body, ociMaxManifestSize))
+	if err != nil {
+		return ociManifest{}, "", fmt.Errorf("reading manifest: %w", err)
+	}
+
+	var manifest ociManifest
+	if err := json.Unmarshal(bb, &manifest); err != nil {
+		return ociManifest{}, "", fmt.Errorf("decoding manifest: %w", err)
+	}
+
+	digest := resp.Header.Get(ociDigestHeader)
+	if digest == "" {
+		digest = sha256Digest(bb)
+	}
+	return manifest, digest, nil
+}
+
+func (c *ociClient) blob(ctx context.Context, digest string) ([]byte, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		return nil, fmt.Errorf("unsupported digest %q", digest)
+	}
+
+	resp, err := c.do(ctx, http.MethodGet, "blobs/"+digest, "")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	bb, err := io.ReadAll(io.LimitReader(resp.Body, ociMaxModuleBlobSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if actual := sha256Digest(bb); actual != digest {
+		return nil, fmt.Errorf("digest mismatch: expected %s, got %s", digest, actual)
+	}
+	return bb, nil
+}
+
+// do performs a request against the distribution API of the repository. If
+// the registry requires token authentication, a token is requested and the
+// request is retried.
+func (c *ociClient) do(ctx context.Context, method, path, accept string) (*http.Response, error) {
+	u := fmt.Sprintf("%s://%s/v2/%s/%s", c.scheme, c.host, c.name, path)
+
+	send := func() (*http.Response, error) {
+		req, err := http.NewRequestWithContext(ctx, method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if accept != "" {
+			req.Header.Set("Accept", accept)
+		}
+		if c.token != "" {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		}
+		return c.cli.Do(req)
+	}
+
+	resp, err := send()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusUnauthorized {
+		challenge := resp.Header.Get(ociAuthenticateHeader)
+		resp.Body.Close()
+
+		if !strings.HasPrefix(challenge, "Bearer ") {
+			return nil, fmt.Errorf("unauthorized to access %s", u)
+		}
+		if err := c.authenticate(ctx, challenge); err != nil {
+			return nil, fmt.Errorf("authenticating to %s: %w", c.host, err)
+		}
+		if resp, err = send(); err != nil {
+			return nil, err
+		}
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status code %s from %s", resp.Status, u)
+	}
+	return resp, nil
+}
+
+// authenticate requests a token as described by a Bearer challenge.
+// Credentials configured in the HTTP client are sent with the request.
+func (c *ociClient) authenticate(ctx context.Context, challenge string) error {
+	params := parseChallenge(strings.TrimPrefix(challenge, "Bearer "))
+	realm := params["realm"]
+	if realm == "" {
+		return fmt.Errorf("missing realm in challenge %q", challenge)
+	}
+
+	query := url.Values{}
+	if service := params["service"]; service != "" {
+		query.Set("service", service)
+	}
+	if scope := params["scope"]; scope != "" {
+		query.Set("scope", scope)
+	} else {
+		query.Set("scope", fmt.Sprintf("repository:%s:pull", c.name))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, realm+"?"+query.Encode(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.cli.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code %s from %s", resp.Status, realm)
+	}
+
+	var token struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return fmt.Errorf("decoding token: %w", err)
+	}
+	c.token = token.Token
+	if c.token == "" {
+		c.token = token.AccessToken
+	}
+	if c.token == "" {
+		return fmt.Errorf("no token returned by %s", realm)
+	}
+	return nil
+}
+
+// parseChallenge parses the comma-separated key="value" parameters of a
+// WWW-Authenticate challenge.
+func parseChallenge(s string) map[string]string {
+	params := make(map[string]string)
+	for s != "" {
+		key, rest, ok := strings.Cut(s, "=")
+		if !ok {
+			break
+		}
+		key = strings.TrimSpace(key)
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				break
+			}
+			value, rest = rest[1:end+1], rest[end+2:]
+		} else {
+			value, rest, _ = strings.Cut(rest, ",")
+			rest = "," + rest
+		}
+		params[key] = value
+		s = strings.TrimPrefix(strings.TrimSpace(rest), ",")
+	}
+	return params
+}
+
+func sha256Digest(bb []byte) string {
+	sum := sha256.Sum256(bb)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
@@ -56,3 +56,34 @@ func (f *Fake) Data() any {
 
 	return nil
 }
+
+// The FakeExporter service is a Fake service which exposes a value to the
+// config.
+type FakeExporter struct {
+	Fake
+
+	ExportsFunc          func() any
+	SubscribeExportsFunc func(notify func()) (unsubscribe func())
+}
+
+var _ service.Exporter = (*FakeExporter)(nil)
+
+// Exports implements [service.Exporter]. If f.ExportsFunc is non-nil, it will
+// be used. Otherwise, a default implementation is used.
+func (f *FakeExporter) Exports() any {
+	if f.ExportsFunc != nil {
+		return f.ExportsFunc()
+	}
+
+	return map[string]any{}
+}
+
+// SubscribeExports implements [service.Exporter]. If f.SubscribeExportsFunc
+// is non-nil, it will be used. Otherwise, a default implementation is used.
+func (f *FakeExporter) SubscribeExports(notify func()) (unsubscribe func()) {
+	if f.SubscribeExportsFunc != nil {
+		return f.SubscribeExportsFunc(notify)
+	}
+
+	return func() {}
+}
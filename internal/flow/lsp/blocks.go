@@ -0,0 +1,97 @@
+package lsp
+
+import (
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/river/ast"
+)
+
+// blockSchema returns the schema of the body of a top-level block of a module:
+// a component, a config block, or a custom component in scope.
+func (s *Server) blockSchema(d *document, sc moduleScope, name string) *schema {
+	if reg, ok := component.Get(name); ok {
+		return s.cachedSchema(s.argumentSchemas, name, reg.Args)
+	}
+	if cb, ok := s.configBlocks[name]; ok {
+		return cb
+	}
+	if cc, ok := sc.customComponent(d.path, name); ok {
+		return customComponentSchema(cc.block)
+	}
+	return nil
+}
+
+// exportsSchema returns the schema of the exports of a top-level block of a
+// module, which is nil if the block has no exports.
+func (s *Server) exportsSchema(d *document, sc moduleScope, block *ast.BlockStmt) *schema {
+	name := block.GetBlockName()
+	if reg, ok := component.Get(name); ok {
+		if reg.Exports == nil {
+			return nil
+		}
+		return s.cachedSchema(s.exportSchemas, name, reg.Exports)
+	}
+	if name == "argument" {
+		return &schema{attrs: map[string]field{"value": {name: "value"}}}
+	}
+	if cc, ok := sc.customComponent(d.path, name); ok {
+		exports := &schema{attrs: make(map[string]field)}
+		for _, export := range declareExports(cc.block) {
+			exports.attrs[export] = field{name: export}
+		}
+		return exports
+	}
+	return nil
+}
+
+func (s *Server) cachedSchema(cache map[string]*schema, name string, v any) *schema {
+	if sch, ok := cache[name]; ok {
+		return sch
+	}
+	sch := newSchema(v)
+	cache[name] = sch
+	return sch
+}
+
+// customComponentSchema returns the schema of the arguments of the custom
+// component defined by a declare block.
+func customComponentSchema(declare *ast.BlockStmt) *schema {
+	sch := &schema{
+		attrs:  make(map[string]field),
+		blocks: make(map[string]field),
+	}
+	for name, optional := range declareArguments(declare) {
+		sch.attrs[name] = field{name: name, optional: optional}
+	}
+	return sch
+}
+
+// bodySchema returns the schema of the body of the innermost block of path,
+// which lists the names of nested blocks from the top level of the document.
+// module is true if the body holds a module, such as the top level of the
+// document or the body of a declare block. ok is false if a block of path is
+// unknown.
+func (s *Server) bodySchema(d *document, sc moduleScope, path []string) (sch *schema, module bool, ok bool) {
+	module = true
+	for _, name := range path {
+		switch {
+		case module && name == declareBlockName:
+			continue
+		case module:
+			sch = s.blockSchema(d, sc, name)
+			module = false
+		default:
+			f, found := sch.blocks[name]
+			if !found {
+				return nil, false, false
+			}
+			sch = f.block
+			// Blocks without a schema, such as the template block of foreach,
+			// hold a module.
+			module = sch == nil
+		}
+		if !module && sch == nil {
+			return nil, false, false
+		}
+	}
+	return sch, module, true
+}
@@ -0,0 +1,118 @@
+package lsp
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/internal/component"
+)
+
+// completion returns the completion items at the byte offset off of d.
+func (s *Server) completion(d *document, off int) any {
+	prefix, start := d.prefixAt(off)
+	ctx := contextAt(d.text, start)
+	// Offsets before start are the same in the AST used for completion.
+	sc := scopeAt(d.completionFile(start, off, ctx.expression), start)
+	replace := textRange{Start: d.position(start), End: d.position(off)}
+
+	var items []completionItem
+	switch {
+	case ctx.expression:
+		items = s.referenceItems(d, sc)
+	case ctx.statementStart:
+		sch, module, ok := s.bodySchema(d, sc, ctx.blocks)
+		switch {
+		case !ok:
+		case module:
+			items = s.blockNameItems(d, sc)
+		default:
+			items = fieldItems(sch)
+		}
+	}
+
+	res := completionList{Items: []completionItem{}}
+	for _, item := range items {
+		if !strings.HasPrefix(item.Label, prefix) {
+			continue
+		}
+		item.TextEdit = &textEdit{Range: replace, NewText: item.Label}
+		res.Items = append(res.Items, item)
+	}
+	sort.Slice(res.Items, func(i, j int) bool { return res.Items[i].Label < res.Items[j].Label })
+	return res
+}
+
+// blockNameItems returns the names of the blocks which can be defined in a
+// module: components, config blocks, and custom components in scope.
+func (s *Server) blockNameItems(d *document, sc moduleScope) []completionItem {
+	var items []completionItem
+	for _, name := range component.AllNames() {
+		reg, _ := component.Get(name)
+		items = append(items, completionItem{
+			Label:  name,
+			Kind:   completionKindModule,
+			Detail: "component (" + reg.Stability.String() + ")",
+		})
+	}
+	for name := range s.configBlocks {
+		items = append(items, completionItem{Label: name, Kind: completionKindStruct, Detail: "block"})
+	}
+	items = append(items, completionItem{Label: declareBlockName, Kind: completionKindStruct, Detail: "block"})
+
+	for label := range sc.declares {
+		items = append(items, completionItem{Label: label, Kind: completionKindModule, Detail: "custom component"})
+	}
+	for namespace, block := range sc.imports {
+		for _, label := range importedDeclares(d.path, block) {
+			items = append(items, completionItem{
+				Label:  namespace + "." + label,
+				Kind:   completionKindModule,
+				Detail: "custom component",
+			})
+		}
+	}
+	return items
+}
+
+// fieldItems returns the attributes and blocks of sch.
+func fieldItems(sch *schema) []completionItem {
+	var items []completionItem
+	for _, f := range sortedFields(sch.attrs) {
+		items = append(items, completionItem{Label: f.name, Kind: completionKindField, Detail: fieldDetail(f, "attribute")})
+	}
+	for _, f := range sortedFields(sch.blocks) {
+		items = append(items, completionItem{Label: f.name, Kind: completionKindStruct, Detail: fieldDetail(f, "block")})
+	}
+	return items
+}
+
+func fieldDetail(f field, kind string) string {
+	detail := kind
+	if kind == "attribute" {
+		detail = riverType(f.typ)
+	}
+	if f.optional {
+		return detail + ", optional"
+	}
+	return detail + ", required"
+}
+
+// referenceItems returns the references to the exports of the blocks of the
+// module body in scope.
+func (s *Server) referenceItems(d *document, sc moduleScope) []completionItem {
+	var items []completionItem
+	for _, block := range sc.blocks() {
+		exports := s.exportsSchema(d, sc, block)
+		if exports == nil {
+			continue
+		}
+		for _, f := range sortedFields(exports.attrs) {
+			items = append(items, completionItem{
+				Label:  block.GetBlockName() + "." + block.Label + "." + f.name,
+				Kind:   completionKindReference,
+				Detail: riverType(f.typ),
+			})
+		}
+	}
+	return items
+}
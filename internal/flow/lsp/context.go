@@ -0,0 +1,130 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/grafana/river/scanner"
+	"github.com/grafana/river/token"
+)
+
+// cursorContext describes where a cursor is in a document. It's computed by
+// scanning the text before the cursor, so that it works on documents which
+// don't parse while they're being edited.
+type cursorContext struct {
+	// blocks holds the names of the blocks enclosing the cursor, from the
+	// outermost to the innermost.
+	blocks []string
+	// expression is true when the cursor is in the value of an attribute.
+	expression bool
+	// statementStart is true when the cursor is where a new attribute or block
+	// can be written.
+	statementStart bool
+}
+
+type scopeKind int
+
+const (
+	scopeBlock scopeKind = iota
+	scopeExpression
+)
+
+type scope struct {
+	kind scopeKind
+	name string // Name of the block for scopeBlock.
+}
+
+// contextAt returns the context of the cursor at the byte offset off in text.
+// off must be the start of the identifier being typed, if any.
+func contextAt(text string, off int) cursorContext {
+	src := []byte(text[:off])
+	file := token.NewFile("")
+	s := scanner.New(file, src, nil, 0)
+
+	var (
+		scopes    []scope
+		statement []token.Token // Tokens of the current statement in a block.
+		names     []string      // Literals of the identifiers of statement.
+	)
+	inExpression := func() bool {
+		return len(scopes) > 0 && scopes[len(scopes)-1].kind == scopeExpression
+	}
+	pop := func() {
+		if len(scopes) > 0 {
+			scopes = scopes[:len(scopes)-1]
+		}
+	}
+
+	for {
+		pos, tok, lit := s.Scan()
+		if tok == token.EOF || pos.Offset() >= len(src) && tok == token.TERMINATOR {
+			break
+		}
+
+		switch tok {
+		case token.LCURLY:
+			if !inExpression() && isBlockHeader(statement) {
+				scopes = append(scopes, scope{kind: scopeBlock, name: strings.Join(names, ".")})
+			} else {
+				scopes = append(scopes, scope{kind: scopeExpression})
+			}
+			statement, names = nil, nil
+		case token.LBRACK, token.LPAREN:
+			scopes = append(scopes, scope{kind: scopeExpression})
+		case token.RCURLY:
+			pop()
+			if !inExpression() {
+				statement, names = nil, nil
+			}
+		case token.RBRACK, token.RPAREN:
+			pop()
+		case token.TERMINATOR:
+			if !inExpression() {
+				statement, names = nil, nil
+			}
+		default:
+			if !inExpression() {
+				statement = append(statement, tok)
+				if tok == token.IDENT {
+					names = append(names, lit)
+				}
+			}
+		}
+	}
+
+	var ctx cursorContext
+	for _, sc := range scopes {
+		if sc.kind == scopeBlock {
+			ctx.blocks = append(ctx.blocks, sc.name)
+		}
+	}
+	switch {
+	case inExpression():
+		ctx.expression = true
+	case len(statement) == 0:
+		ctx.statementStart = true
+	default:
+		for _, tok := range statement {
+			if tok == token.ASSIGN {
+				ctx.expression = true
+			}
+		}
+	}
+	return ctx
+}
+
+// isBlockHeader returns whether tokens form the header of a block: a name made
+// of identifiers separated by dots, followed by an optional label.
+func isBlockHeader(tokens []token.Token) bool {
+	if len(tokens) > 0 && tokens[len(tokens)-1] == token.STRING {
+		tokens = tokens[:len(tokens)-1]
+	}
+	if len(tokens) == 0 {
+		return false
+	}
+	for i, tok := range tokens {
+		if (i%2 == 0 && tok != token.IDENT) || (i%2 == 1 && tok != token.DOT) {
+			return false
+		}
+	}
+	return len(tokens)%2 == 1
+}
@@ -0,0 +1,56 @@
+package lsp
+
+import (
+	"strings"
+
+	"github.com/grafana/river/ast"
+)
+
+// definition returns the location of the definition of the custom component
+// or the reference at the byte offset off of d: the declare block of a custom
+// component, the import block of an imported custom component, or the block
+// exporting a referenced value.
+func (s *Server) definition(d *document, off int) any {
+	word, start := d.wordAt(off)
+	if word == "" || d.file == nil {
+		return nil
+	}
+	ctx := contextAt(d.text, start)
+	sc := scopeAt(d.file, off)
+
+	switch {
+	case ctx.statementStart:
+		if cc, ok := sc.customComponent(d.path, word); ok {
+			if cc.path == d.path {
+				return s.blockLocation(d, cc.block)
+			}
+			return s.blockLocation(newDocument(pathToURI(cc.path), 0, cc.text), cc.block)
+		}
+		// Modules which can't be inspected lead to their import block.
+		if namespace, _, ok := strings.Cut(word, "."); ok {
+			if block, ok := sc.imports[namespace]; ok {
+				return s.blockLocation(d, block)
+			}
+		}
+	case ctx.expression:
+		if block, _ := sc.findBlock(strings.Split(word, ".")); block != nil {
+			return s.blockLocation(d, block)
+		}
+	}
+	return nil
+}
+
+// blockLocation returns the location of the header of block in d. The
+// location is nil if block doesn't come from the current text of d.
+func (s *Server) blockLocation(d *document, block *ast.BlockStmt) any {
+	if !d.upToDate() {
+		return nil
+	}
+	return location{
+		URI: d.uri,
+		Range: textRange{
+			Start: d.position(block.NamePos.Offset()),
+			End:   d.position(block.LCurlyPos.Offset()),
+		},
+	}
+}
@@ -0,0 +1,199 @@
+package lsp
+
+import (
+	"errors"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/token"
+)
+
+// document is an open text document.
+type document struct {
+	uri     string
+	path    string // Path of the document on the filesystem.
+	version int
+	text    string
+	lines   []int // Byte offsets of the start of each line.
+
+	// file is the last AST of the document which could be parsed, which is
+	// kept while the document has syntax errors so that it can still be
+	// navigated.
+	file *ast.File
+	// fileText is the text file was parsed from.
+	fileText string
+	// syntaxErrors holds the errors of parsing the current text.
+	syntaxErrors diag.Diagnostics
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, path: uriToPath(uri)}
+	d.update(version, text)
+	return d
+}
+
+// update sets the text of the document and parses it.
+func (d *document) update(version int, text string) {
+	d.version = version
+	d.text = text
+	d.lines = lineOffsets(text)
+
+	file, err := parser.ParseFile(d.path, []byte(text))
+	d.syntaxErrors = nil
+	if err != nil {
+		// The parser only returns diagnostics.
+		_ = errors.As(err, &d.syntaxErrors)
+		return
+	}
+	d.file, d.fileText = file, text
+}
+
+func lineOffsets(text string) []int {
+	lines := []int{0}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			lines = append(lines, i+1)
+		}
+	}
+	return lines
+}
+
+// offset returns the byte offset of pos in the document.
+func (d *document) offset(pos position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lines) {
+		return len(d.text)
+	}
+	start := d.lines[pos.Line]
+	end := len(d.text)
+	if pos.Line+1 < len(d.lines) {
+		end = d.lines[pos.Line+1] - 1
+	}
+
+	// Characters are counted in UTF-16 code units.
+	off, units := start, 0
+	for off < end && units < pos.Character {
+		r, size := utf8.DecodeRuneInString(d.text[off:])
+		units += utf16Len(r)
+		off += size
+	}
+	return off
+}
+
+// position returns the position of the byte offset off in the document.
+func (d *document) position(off int) position {
+	off = max(0, min(off, len(d.text)))
+	line := 0
+	for line+1 < len(d.lines) && d.lines[line+1] <= off {
+		line++
+	}
+	var units int
+	for _, r := range d.text[d.lines[line]:off] {
+		units += utf16Len(r)
+	}
+	return position{Line: line, Character: units}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding r.
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// riverPosition returns the position of a River position in the document.
+// River columns are 1-based byte offsets in the line.
+func (d *document) riverPosition(pos token.Position) position {
+	if !pos.Valid() || pos.Line > len(d.lines) {
+		return position{}
+	}
+	return d.position(d.lines[pos.Line-1] + pos.Column - 1)
+}
+
+// nodeRange returns the range of n. n must come from the AST of the current
+// text of the document.
+func (d *document) nodeRange(n ast.Node) textRange {
+	return textRange{
+		Start: d.position(ast.StartPos(n).Offset()),
+		End:   d.position(ast.EndPos(n).Offset() + 1),
+	}
+}
+
+// completionFile returns the AST of the document to complete the identifier
+// between the byte offsets start and end. Documents usually don't parse while
+// an identifier is being typed, so the identifier is removed, or replaced with
+// a placeholder in expressions, before parsing. The last parsed AST is returned
+// if the document still doesn't parse.
+func (d *document) completionFile(start, end int, expression bool) *ast.File {
+	if d.upToDate() {
+		return d.file
+	}
+	var placeholder string
+	if expression {
+		placeholder = "null"
+	}
+	file, err := parser.ParseFile(d.path, []byte(d.text[:start]+placeholder+d.text[end:]))
+	if err != nil {
+		return d.file
+	}
+	return file
+}
+
+// upToDate returns whether the last parsed AST matches the current text.
+func (d *document) upToDate() bool {
+	return d.file != nil && d.fileText == d.text
+}
+
+// wordAt returns the identifier, possibly including dots, around the byte
+// offset off, along with its start offset.
+func (d *document) wordAt(off int) (string, int) {
+	start, end := off, off
+	for start > 0 && isWordChar(d.text[start-1]) {
+		start--
+	}
+	for end < len(d.text) && isWordChar(d.text[end]) {
+		end++
+	}
+	return d.text[start:end], start
+}
+
+// prefixAt returns the part of the identifier before the byte offset off,
+// along with its start offset.
+func (d *document) prefixAt(off int) (string, int) {
+	start := off
+	for start > 0 && isWordChar(d.text[start-1]) {
+		start--
+	}
+	return d.text[start:off], start
+}
+
+func isWordChar(c byte) bool {
+	return c == '_' || c == '.' || ('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z') || ('0' <= c && c <= '9')
+}
+
+// uriToPath returns the filesystem path of a file URI. Other URIs are
+// returned as is.
+func uriToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	return filepath.FromSlash(u.Path)
+}
+
+// pathToURI returns the file URI of a filesystem path.
+func pathToURI(path string) string {
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
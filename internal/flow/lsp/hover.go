@@ -0,0 +1,165 @@
+package lsp
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/tools/docs_generator"
+)
+
+// referenceDocsURL is the URL of the reference documentation.
+const referenceDocsURL = "https://grafana.com/docs/agent/latest/flow/reference/"
+
+// hover returns the documentation of the block, attribute or reference at the
+// byte offset off of d.
+func (s *Server) hover(d *document, off int) any {
+	word, start := d.wordAt(off)
+	if word == "" {
+		return nil
+	}
+	ctx := contextAt(d.text, start)
+	sc := scopeAt(d.file, off)
+
+	var docs string
+	switch {
+	case ctx.statementStart:
+		sch, module, ok := s.bodySchema(d, sc, ctx.blocks)
+		switch {
+		case !ok:
+		case module:
+			docs = s.blockDocs(d, sc, word)
+		default:
+			docs = fieldDocs(sch, word)
+		}
+	case ctx.expression:
+		docs = s.referenceDocs(d, sc, word)
+	}
+	if docs == "" {
+		return nil
+	}
+
+	return hover{
+		Contents: markupContent{Kind: "markdown", Value: docs},
+		Range:    &textRange{Start: d.position(start), End: d.position(start + len(word))},
+	}
+}
+
+// blockDocs returns the documentation of a top-level block of a module.
+func (s *Server) blockDocs(d *document, sc moduleScope, name string) string {
+	var sb strings.Builder
+
+	if reg, ok := component.Get(name); ok {
+		fmt.Fprintf(&sb, "### `%s`\n\nStability: %s\n", name, reg.Stability)
+		writeSchemaDocs(&sb, s.blockSchema(d, sc, name))
+		if reg.Exports != nil {
+			writeExportsDocs(&sb, s.cachedSchema(s.exportSchemas, name, reg.Exports))
+		}
+		// The compatible components are listed as in the reference page of the
+		// component.
+		if compatible, err := docs_generator.CompatibleComponents(name, referenceDocsURL+"compatibility/"); err == nil && compatible != "" {
+			fmt.Fprintf(&sb, "\n#### Compatible components\n\n%s", compatible)
+		}
+		fmt.Fprintf(&sb, "\n[Documentation](%scomponents/%s/)\n", referenceDocsURL, name)
+		return sb.String()
+	}
+
+	if sch, ok := s.configBlocks[name]; ok {
+		fmt.Fprintf(&sb, "### `%s` block\n", name)
+		writeSchemaDocs(&sb, sch)
+		fmt.Fprintf(&sb, "\n[Documentation](%sconfig-blocks/%s/)\n", referenceDocsURL, name)
+		return sb.String()
+	}
+	if name == declareBlockName {
+		fmt.Fprintf(&sb, "### `%s` block\n\nDefines a custom component.\n", name)
+		fmt.Fprintf(&sb, "\n[Documentation](%sconfig-blocks/%s/)\n", referenceDocsURL, name)
+		return sb.String()
+	}
+
+	if cc, ok := sc.customComponent(d.path, name); ok {
+		fmt.Fprintf(&sb, "### `%s`\n\nCustom component declared in `%s`.\n", name, cc.path)
+		writeSchemaDocs(&sb, customComponentSchema(cc.block))
+		exports := &schema{attrs: make(map[string]field)}
+		for _, export := range declareExports(cc.block) {
+			exports.attrs[export] = field{name: export}
+		}
+		writeExportsDocs(&sb, exports)
+		return sb.String()
+	}
+	return ""
+}
+
+// writeSchemaDocs writes the tables of the attributes and blocks of sch.
+func writeSchemaDocs(sb *strings.Builder, sch *schema) {
+	if len(sch.attrs) > 0 {
+		sb.WriteString("\n#### Arguments\n\n| Name | Type | Required |\n| ---- | ---- | -------- |\n")
+		for _, f := range sortedFields(sch.attrs) {
+			fmt.Fprintf(sb, "| `%s` | `%s` | %s |\n", f.name, riverType(f.typ), yesNo(!f.optional))
+		}
+	}
+	if len(sch.blocks) > 0 {
+		sb.WriteString("\n#### Blocks\n\n| Name | Required |\n| ---- | -------- |\n")
+		for _, f := range sortedFields(sch.blocks) {
+			fmt.Fprintf(sb, "| `%s` | %s |\n", f.name, yesNo(!f.optional))
+		}
+	}
+}
+
+// writeExportsDocs writes the table of the exports in sch.
+func writeExportsDocs(sb *strings.Builder, sch *schema) {
+	if len(sch.attrs) == 0 {
+		return
+	}
+	sb.WriteString("\n#### Exports\n\n| Name | Type |\n| ---- | ---- |\n")
+	for _, f := range sortedFields(sch.attrs) {
+		fmt.Fprintf(sb, "| `%s` | `%s` |\n", f.name, riverType(f.typ))
+	}
+}
+
+func yesNo(b bool) string {
+	if b {
+		return "yes"
+	}
+	return "no"
+}
+
+// fieldDocs returns the documentation of an attribute or block of sch.
+func fieldDocs(sch *schema, name string) string {
+	if f, ok := sch.attrs[name]; ok {
+		return fmt.Sprintf("`%s` attribute\n\nType: `%s`, %s", name, riverType(f.typ), requiredText(f))
+	}
+	if f, ok := sch.blocks[name]; ok {
+		var sb strings.Builder
+		fmt.Fprintf(&sb, "`%s` block, %s\n", name, requiredText(f))
+		if f.block != nil {
+			writeSchemaDocs(&sb, f.block)
+		}
+		return sb.String()
+	}
+	return ""
+}
+
+func requiredText(f field) string {
+	if f.optional {
+		return "optional"
+	}
+	return "required"
+}
+
+// referenceDocs returns the documentation of a reference to the export of a
+// block.
+func (s *Server) referenceDocs(d *document, sc moduleScope, ref string) string {
+	block, rest := sc.findBlock(strings.Split(ref, "."))
+	if block == nil || len(rest) == 0 {
+		return ""
+	}
+	exports := s.exportsSchema(d, sc, block)
+	if exports == nil {
+		return ""
+	}
+	f, ok := exports.attrs[rest[0]]
+	if !ok {
+		return ""
+	}
+	return fmt.Sprintf("`%s` export of `%s.%s`\n\nType: `%s`", f.name, block.GetBlockName(), block.Label, riverType(f.typ))
+}
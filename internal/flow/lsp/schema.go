@@ -0,0 +1,160 @@
+package lsp
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// schema describes the attributes and blocks allowed in the body of a block,
+// as defined by the River tags of a Go struct.
+type schema struct {
+	attrs  map[string]field
+	blocks map[string]field
+}
+
+// field is an attribute or block of a schema.
+type field struct {
+	name     string
+	typ      reflect.Type
+	optional bool
+	block    *schema // Schema of the body of a block field.
+}
+
+// newSchema returns the schema of the River-tagged fields of v, which must be
+// a struct or a pointer to a struct.
+func newSchema(v any) *schema {
+	if v == nil {
+		return &schema{}
+	}
+	return schemaForType(reflect.TypeOf(v), map[reflect.Type]*schema{})
+}
+
+// schemaForType returns the schema of ty. Schemas are cached in seen so that
+// recursive types terminate.
+func schemaForType(ty reflect.Type, seen map[reflect.Type]*schema) *schema {
+	ty = indirectType(ty)
+	if s, ok := seen[ty]; ok {
+		return s
+	}
+
+	s := &schema{
+		attrs:  make(map[string]field),
+		blocks: make(map[string]field),
+	}
+	seen[ty] = s
+	if ty.Kind() != reflect.Struct {
+		return s
+	}
+	addFields(s, ty, seen)
+	return s
+}
+
+func addFields(s *schema, ty reflect.Type, seen map[reflect.Type]*schema) {
+	for i := 0; i < ty.NumField(); i++ {
+		sf := ty.Field(i)
+		tag, ok := sf.Tag.Lookup("river")
+		if !ok {
+			if sf.Anonymous && indirectType(sf.Type).Kind() == reflect.Struct {
+				addFields(s, indirectType(sf.Type), seen)
+			}
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		flags := make(map[string]bool)
+		for _, opt := range strings.Split(options, ",") {
+			flags[opt] = true
+		}
+
+		switch {
+		case flags["squash"]:
+			addFields(s, indirectType(sf.Type), seen)
+		case flags["attr"]:
+			s.attrs[name] = field{name: name, typ: sf.Type, optional: flags["optional"]}
+		case flags["block"]:
+			s.blocks[name] = field{
+				name:     name,
+				typ:      sf.Type,
+				optional: flags["optional"],
+				block:    schemaForType(elemType(sf.Type), seen),
+			}
+		case flags["enum"]:
+			// The blocks of an enum are the fields of its element type.
+			enum := schemaForType(elemType(sf.Type), seen)
+			for name, f := range enum.blocks {
+				f.optional = true
+				s.blocks[name] = f
+			}
+		}
+	}
+}
+
+// indirectType returns the type pointed to by ty, if ty is a pointer.
+func indirectType(ty reflect.Type) reflect.Type {
+	for ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	return ty
+}
+
+// elemType returns the type of the blocks of a block field, which may be a
+// slice or array of blocks.
+func elemType(ty reflect.Type) reflect.Type {
+	ty = indirectType(ty)
+	if ty.Kind() == reflect.Slice || ty.Kind() == reflect.Array {
+		ty = indirectType(ty.Elem())
+	}
+	return ty
+}
+
+// sortedFields returns the fields of m sorted by name.
+func sortedFields(m map[string]field) []field {
+	fields := make([]field, 0, len(m))
+	for _, f := range m {
+		fields = append(fields, f)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i].name < fields[j].name })
+	return fields
+}
+
+// riverType returns the name of the River type Go values of ty are encoded
+// to.
+func riverType(ty reflect.Type) string {
+	if ty == nil {
+		return "any"
+	}
+	switch ty.String() {
+	case "time.Duration":
+		return "duration"
+	case "rivertypes.Secret":
+		return "secret"
+	case "rivertypes.OptionalSecret":
+		return "string"
+	}
+
+	switch ty.Kind() {
+	case reflect.Pointer:
+		return riverType(ty.Elem())
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.String:
+		return "string"
+	case reflect.Slice, reflect.Array:
+		return "list(" + riverType(ty.Elem()) + ")"
+	case reflect.Map:
+		return "map(" + riverType(ty.Elem()) + ")"
+	case reflect.Interface:
+		if ty.NumMethod() == 0 {
+			return "any"
+		}
+		return "capsule(" + ty.String() + ")"
+	case reflect.Struct:
+		return "object"
+	}
+	return "capsule(" + ty.String() + ")"
+}
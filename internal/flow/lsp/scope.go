@@ -0,0 +1,240 @@
+package lsp
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+)
+
+const (
+	declareBlockName    = "declare"
+	foreachBlockName    = "foreach"
+	importFileBlockName = "import.file"
+	importBlockPrefix   = "import."
+)
+
+// moduleScope holds the blocks visible from a position in a document: the
+// blocks of the innermost module body enclosing the position, and the declare
+// and import blocks of every enclosing module body.
+type moduleScope struct {
+	body     ast.Body
+	declares map[string]*ast.BlockStmt
+	imports  map[string]*ast.BlockStmt
+}
+
+// scopeAt returns the scope of the byte offset off in file. The scope is empty
+// if file is nil.
+func scopeAt(file *ast.File, off int) moduleScope {
+	sc := moduleScope{
+		declares: make(map[string]*ast.BlockStmt),
+		imports:  make(map[string]*ast.BlockStmt),
+	}
+	if file == nil {
+		return sc
+	}
+
+	body := file.Body
+	for body != nil {
+		sc.body = body
+		var inner ast.Body
+		for _, stmt := range body {
+			block, ok := stmt.(*ast.BlockStmt)
+			if !ok {
+				continue
+			}
+			name := block.GetBlockName()
+			switch {
+			case name == declareBlockName:
+				sc.declares[block.Label] = block
+			case strings.HasPrefix(name, importBlockPrefix):
+				sc.imports[block.Label] = block
+			}
+			if (name == declareBlockName || name == foreachBlockName) && contains(block, off) {
+				inner = moduleBody(block)
+			}
+		}
+		body = inner
+	}
+	return sc
+}
+
+// moduleBody returns the body of block holding a module: the body of a
+// declare block, or of the template block of a foreach block.
+func moduleBody(block *ast.BlockStmt) ast.Body {
+	if block.GetBlockName() == declareBlockName {
+		return block.Body
+	}
+	for _, stmt := range block.Body {
+		if inner, ok := stmt.(*ast.BlockStmt); ok && inner.GetBlockName() == "template" {
+			return inner.Body
+		}
+	}
+	return nil
+}
+
+// contains returns whether the byte offset off is between the braces of
+// block.
+func contains(block *ast.BlockStmt, off int) bool {
+	return block.LCurlyPos.Offset() < off && off <= block.RCurlyPos.Offset()
+}
+
+// blocks returns the blocks of body which have a label.
+func (sc moduleScope) blocks() []*ast.BlockStmt {
+	var blocks []*ast.BlockStmt
+	for _, stmt := range sc.body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && block.Label != "" {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// findBlock returns the block of the scope body referenced by the components
+// of a reference, such as ["prometheus", "remote_write", "default",
+// "receiver"], along with the remaining components of the reference.
+func (sc moduleScope) findBlock(parts []string) (*ast.BlockStmt, []string) {
+	for _, block := range sc.blocks() {
+		n := len(block.Name)
+		if len(parts) > n && slices.Equal(block.Name, parts[:n]) && parts[n] == block.Label {
+			return block, parts[n+1:]
+		}
+	}
+	return nil, nil
+}
+
+// declaredComponent is a custom component defined by a declare block.
+type declaredComponent struct {
+	block *ast.BlockStmt
+	path  string // Path of the file defining the declare block.
+	text  string // Text of the file defining the declare block.
+}
+
+// customComponent returns the declare block defining the custom component
+// name, which is either the label of a declare block in scope or the label of
+// an import block followed by the label of a declare block in the imported
+// module. Only modules imported by import.file can be inspected. docPath is
+// the path of the document the scope comes from.
+func (sc moduleScope) customComponent(docPath, name string) (declaredComponent, bool) {
+	if block, ok := sc.declares[name]; ok {
+		return declaredComponent{block: block, path: docPath}, true
+	}
+
+	namespace, label, ok := strings.Cut(name, ".")
+	if !ok {
+		return declaredComponent{}, false
+	}
+	importBlock, ok := sc.imports[namespace]
+	if !ok {
+		return declaredComponent{}, false
+	}
+	for path, text := range importedFiles(docPath, importBlock) {
+		file, err := parser.ParseFile(path, []byte(text))
+		if err != nil {
+			continue
+		}
+		for _, stmt := range file.Body {
+			if block, ok := stmt.(*ast.BlockStmt); ok && block.GetBlockName() == declareBlockName && block.Label == label {
+				return declaredComponent{block: block, path: path, text: text}, true
+			}
+		}
+	}
+	return declaredComponent{}, false
+}
+
+// importedDeclares returns the labels of the declare blocks of the module
+// imported by an import.file block.
+func importedDeclares(docPath string, importBlock *ast.BlockStmt) []string {
+	var labels []string
+	for path, text := range importedFiles(docPath, importBlock) {
+		file, err := parser.ParseFile(path, []byte(text))
+		if err != nil {
+			continue
+		}
+		for _, stmt := range file.Body {
+			if block, ok := stmt.(*ast.BlockStmt); ok && block.GetBlockName() == declareBlockName {
+				labels = append(labels, block.Label)
+			}
+		}
+	}
+	return labels
+}
+
+// importedFiles returns the content of the files imported by an import.file
+// block whose filename is a string literal, keyed by path. Relative paths are
+// resolved from the directory of docPath.
+func importedFiles(docPath string, importBlock *ast.BlockStmt) map[string]string {
+	if importBlock.GetBlockName() != importFileBlockName {
+		return nil
+	}
+	var filename string
+	for _, stmt := range importBlock.Body {
+		attr, ok := stmt.(*ast.AttributeStmt)
+		if !ok || attr.Name.Name != "filename" {
+			continue
+		}
+		if err := vm.New(attr.Value).Evaluate(nil, &filename); err != nil {
+			return nil
+		}
+	}
+	if filename == "" {
+		return nil
+	}
+	if !filepath.IsAbs(filename) {
+		filename = filepath.Join(filepath.Dir(docPath), filename)
+	}
+
+	fi, err := os.Stat(filename)
+	if err != nil {
+		return nil
+	}
+	paths := []string{filename}
+	if fi.IsDir() {
+		paths, _ = filepath.Glob(filepath.Join(filename, "*.river"))
+	}
+
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		bb, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		files[path] = string(bb)
+	}
+	return files
+}
+
+// declareArguments returns the argument blocks of a declare block, keyed by
+// label, along with whether they're optional.
+func declareArguments(declare *ast.BlockStmt) map[string]bool {
+	args := make(map[string]bool)
+	for _, stmt := range declare.Body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok || block.GetBlockName() != "argument" {
+			continue
+		}
+		var optional bool
+		for _, stmt := range block.Body {
+			if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == "optional" {
+				_ = vm.New(attr.Value).Evaluate(nil, &optional)
+			}
+		}
+		args[block.Label] = optional
+	}
+	return args
+}
+
+// declareExports returns the labels of the export blocks of a declare block.
+func declareExports(declare *ast.BlockStmt) []string {
+	var exports []string
+	for _, stmt := range declare.Body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && block.GetBlockName() == "export" {
+			exports = append(exports, block.Label)
+		}
+	}
+	return exports
+}
@@ -0,0 +1,371 @@
+// Package lsp implements a Language Server Protocol server for River
+// configuration files, backed by the component registry.
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"sync"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/flow/internal/controller"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service"
+	"github.com/grafana/river/diag"
+)
+
+// diagnosticSource is the source of the diagnostics published by the server.
+const diagnosticSource = "grafana-agent-flow"
+
+// Options configures a Server.
+type Options struct {
+	// Logger logs errors of the server. Logs must not be written to the output
+	// of the server.
+	Logger log.Logger
+
+	// Validate loads config files, keyed by path, without running them and
+	// returns the diagnostics of the loader. Documents are validated when they
+	// are opened or saved. If nil, only syntax errors are reported.
+	Validate func(files map[string][]byte) diag.Diagnostics
+
+	// Services holds the definitions of the services which can be configured
+	// with a block.
+	Services []service.Definition
+}
+
+// Server is a Language Server Protocol server for River configuration files.
+// It provides completion of component, argument and block names and of
+// references to exports, hover documentation, go-to-definition for custom
+// components, and diagnostics.
+type Server struct {
+	opts Options
+
+	// Schemas of the blocks which aren't components.
+	configBlocks map[string]*schema
+	// Schemas of the arguments and exports of components, computed on first
+	// use.
+	argumentSchemas map[string]*schema
+	exportSchemas   map[string]*schema
+
+	outMut sync.Mutex
+	out    io.Writer
+
+	docsMut sync.Mutex
+	docs    map[string]*document
+
+	validations sync.WaitGroup
+}
+
+// New creates a new Server.
+func New(opts Options) *Server {
+	if opts.Logger == nil {
+		opts.Logger = log.NewNopLogger()
+	}
+
+	configBlocks := make(map[string]*schema)
+	for name, args := range controller.ConfigBlockArguments() {
+		configBlocks[name] = newSchema(args)
+	}
+	// The template block of foreach holds a module.
+	configBlocks[foreachBlockName].blocks["template"] = field{name: "template"}
+	for _, def := range opts.Services {
+		if def.ConfigType != nil {
+			configBlocks[def.Name] = newSchema(def.ConfigType)
+		}
+	}
+
+	return &Server{
+		opts:            opts,
+		configBlocks:    configBlocks,
+		argumentSchemas: make(map[string]*schema),
+		exportSchemas:   make(map[string]*schema),
+		docs:            make(map[string]*document),
+	}
+}
+
+// Serve reads requests from r and writes responses to w until the client
+// sends the exit notification, r is closed, or ctx is canceled.
+func (s *Server) Serve(ctx context.Context, r io.Reader, w io.Writer) error {
+	s.out = w
+	defer s.validations.Wait()
+
+	msgs := make(chan *message)
+	errc := make(chan error, 1)
+	go func() {
+		br := bufio.NewReader(r)
+		for {
+			msg, err := readMessage(br)
+			var respErr *responseError
+			switch {
+			case errors.As(err, &respErr):
+				s.reply(msg.ID, nil, respErr)
+				continue
+			case err != nil:
+				errc <- err
+				return
+			}
+			select {
+			case msgs <- msg:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errc:
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		case msg := <-msgs:
+			if msg.Method == "exit" {
+				return nil
+			}
+			s.handle(msg)
+		}
+	}
+}
+
+// handle handles a request or a notification.
+func (s *Server) handle(msg *message) {
+	var (
+		result any
+		err    error
+	)
+	switch msg.Method {
+	case "initialize":
+		result = initializeResult{
+			Capabilities: serverCapabilities{
+				TextDocumentSync: textDocumentSyncOptions{
+					OpenClose: true,
+					Change:    textDocumentSyncFull,
+				},
+				CompletionProvider: completionOptions{TriggerCharacters: []string{"."}},
+				HoverProvider:      true,
+				DefinitionProvider: true,
+			},
+			ServerInfo: serverInfo{Name: diagnosticSource},
+		}
+	case "shutdown":
+		s.validations.Wait()
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.didOpen(params)
+		}
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.didChange(params)
+		}
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.didSave(params)
+		}
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			s.didClose(params)
+		}
+	case "textDocument/completion":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.withDocument(params, s.completion)
+		}
+	case "textDocument/hover":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.withDocument(params, s.hover)
+		}
+	case "textDocument/definition":
+		var params textDocumentPositionParams
+		if err = json.Unmarshal(msg.Params, &params); err == nil {
+			result = s.withDocument(params, s.definition)
+		}
+	default:
+		if msg.ID != nil {
+			s.reply(msg.ID, nil, &responseError{Code: codeMethodNotFound, Message: "method not supported: " + msg.Method})
+		}
+		return
+	}
+
+	if msg.ID == nil {
+		if err != nil {
+			level.Warn(s.opts.Logger).Log("msg", "invalid notification", "method", msg.Method, "err", err)
+		}
+		return
+	}
+	if err != nil {
+		s.reply(msg.ID, nil, &responseError{Code: codeInvalidParams, Message: err.Error()})
+		return
+	}
+	s.reply(msg.ID, result, nil)
+}
+
+// withDocument calls f with the document and the byte offset of a position.
+// It returns nil if the document isn't open.
+func (s *Server) withDocument(params textDocumentPositionParams, f func(d *document, off int) any) any {
+	s.docsMut.Lock()
+	defer s.docsMut.Unlock()
+
+	d, ok := s.docs[params.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	return f(d, d.offset(params.Position))
+}
+
+func (s *Server) reply(id *json.RawMessage, result any, respErr *responseError) {
+	// Responses to successful requests must have a result, even if it's null.
+	if result == nil && respErr == nil {
+		result = json.RawMessage("null")
+	}
+	s.send(&message{ID: id, Result: result, Error: respErr})
+}
+
+func (s *Server) notify(method string, params any) {
+	bb, err := json.Marshal(params)
+	if err != nil {
+		level.Error(s.opts.Logger).Log("msg", "failed to encode notification", "method", method, "err", err)
+		return
+	}
+	s.send(&message{Method: method, Params: bb})
+}
+
+func (s *Server) send(msg *message) {
+	s.outMut.Lock()
+	defer s.outMut.Unlock()
+
+	if err := writeMessage(s.out, msg); err != nil {
+		level.Error(s.opts.Logger).Log("msg", "failed to write message", "err", err)
+	}
+}
+
+func (s *Server) didOpen(params didOpenParams) {
+	s.docsMut.Lock()
+	defer s.docsMut.Unlock()
+
+	item := params.TextDocument
+	d := newDocument(item.URI, item.Version, item.Text)
+	s.docs[item.URI] = d
+	s.publishDiagnostics(d, true)
+}
+
+func (s *Server) didChange(params didChangeParams) {
+	s.docsMut.Lock()
+	defer s.docsMut.Unlock()
+
+	d, ok := s.docs[params.TextDocument.URI]
+	if !ok || len(params.ContentChanges) == 0 {
+		return
+	}
+	// Documents are synchronized in full, so the last change holds the whole
+	// text.
+	d.update(params.TextDocument.Version, params.ContentChanges[len(params.ContentChanges)-1].Text)
+	s.publishDiagnostics(d, false)
+}
+
+func (s *Server) didSave(params didSaveParams) {
+	s.docsMut.Lock()
+	defer s.docsMut.Unlock()
+
+	if d, ok := s.docs[params.TextDocument.URI]; ok {
+		s.publishDiagnostics(d, true)
+	}
+}
+
+func (s *Server) didClose(params didCloseParams) {
+	s.docsMut.Lock()
+	defer s.docsMut.Unlock()
+
+	delete(s.docs, params.TextDocument.URI)
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         params.TextDocument.URI,
+		Diagnostics: []diagnostic{},
+	})
+}
+
+// publishDiagnostics publishes the syntax errors of d. If validate is true and
+// d has no syntax error, d is validated in the background and the diagnostics
+// of the loader are published once done. Loading a config may retrieve remote
+// modules, so it isn't done on every change.
+func (s *Server) publishDiagnostics(d *document, validate bool) {
+	diags := d.syntaxErrors
+	s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+		URI:         d.uri,
+		Version:     d.version,
+		Diagnostics: d.diagnostics(diags),
+	})
+
+	if !validate || len(diags) > 0 || s.opts.Validate == nil {
+		return
+	}
+
+	var (
+		uri     = d.uri
+		version = d.version
+		path    = d.path
+		text    = d.text
+	)
+	s.validations.Add(1)
+	go func() {
+		defer s.validations.Done()
+		diags := s.opts.Validate(map[string][]byte{path: []byte(text)})
+
+		s.docsMut.Lock()
+		defer s.docsMut.Unlock()
+		// Drop the results if the document changed in the meantime.
+		if d, ok := s.docs[uri]; !ok || d.version != version || d.text != text {
+			return
+		}
+		s.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{
+			URI:         uri,
+			Version:     version,
+			Diagnostics: d.diagnostics(diags),
+		})
+	}()
+}
+
+// diagnostics converts River diagnostics to LSP diagnostics. Diagnostics of
+// other files, such as imported modules, are reported at the start of the
+// document.
+func (d *document) diagnostics(diags diag.Diagnostics) []diagnostic {
+	res := make([]diagnostic, 0, len(diags))
+	for _, dg := range diags {
+		severity := severityError
+		if dg.Severity == diag.SeverityLevelWarn {
+			severity = severityWarning
+		}
+		message := dg.Message
+
+		var rng textRange
+		if dg.StartPos.Filename == "" || dg.StartPos.Filename == d.path {
+			rng.Start = d.riverPosition(dg.StartPos)
+			rng.End = rng.Start
+			if dg.EndPos.Valid() {
+				// River end positions point to the last character.
+				end := dg.EndPos
+				end.Column++
+				rng.End = d.riverPosition(end)
+			}
+		} else {
+			message = dg.StartPos.String() + ": " + message
+		}
+
+		res = append(res, diagnostic{
+			Range:    rng,
+			Severity: severity,
+			Source:   diagnosticSource,
+			Message:  message,
+		})
+	}
+	return res
+}
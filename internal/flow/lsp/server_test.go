@@ -0,0 +1,408 @@
+package lsp
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/grafana/agent/internal/component/all"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/token"
+	"github.com/stretchr/testify/require"
+)
+
+// testClient is a language client talking to a Server over pipes.
+type testClient struct {
+	t      *testing.T
+	in     io.Writer
+	out    *bufio.Reader
+	nextID int
+
+	notifications chan *message
+	responses     chan *message
+}
+
+func newTestClient(t *testing.T, opts Options) *testClient {
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		require.NoError(t, New(opts).Serve(ctx, inR, outW))
+	}()
+
+	c := &testClient{
+		t:             t,
+		in:            inW,
+		out:           bufio.NewReader(outR),
+		notifications: make(chan *message, 100),
+		responses:     make(chan *message, 100),
+	}
+	go func() {
+		for {
+			msg, err := readMessage(c.out)
+			if err != nil {
+				return
+			}
+			if msg.ID != nil {
+				c.responses <- msg
+			} else {
+				c.notifications <- msg
+			}
+		}
+	}()
+
+	t.Cleanup(func() {
+		inW.Close()
+		<-done
+		cancel()
+		outW.Close()
+	})
+
+	var init initializeResult
+	c.call("initialize", map[string]any{}, &init)
+	require.True(t, init.Capabilities.HoverProvider)
+	return c
+}
+
+// call sends a request and decodes its result into result.
+func (c *testClient) call(method string, params any, result any) {
+	c.nextID++
+	id := json.RawMessage(strings.Repeat("1", c.nextID))
+	c.send(&message{ID: &id, Method: method, Params: c.encode(params)})
+
+	select {
+	case msg := <-c.responses:
+		require.Equal(c.t, string(id), string(*msg.ID))
+		require.Nil(c.t, msg.Error)
+		bb, err := json.Marshal(msg.Result)
+		require.NoError(c.t, err)
+		require.NoError(c.t, json.Unmarshal(bb, result))
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", method)
+	}
+}
+
+func (c *testClient) notify(method string, params any) {
+	c.send(&message{Method: method, Params: c.encode(params)})
+}
+
+func (c *testClient) send(msg *message) {
+	require.NoError(c.t, writeMessage(c.in, msg))
+}
+
+func (c *testClient) encode(params any) json.RawMessage {
+	bb, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	return bb
+}
+
+// diagnostics waits for the next diagnostics published by the server.
+func (c *testClient) diagnostics() publishDiagnosticsParams {
+	for {
+		select {
+		case msg := <-c.notifications:
+			if msg.Method != "textDocument/publishDiagnostics" {
+				continue
+			}
+			var params publishDiagnosticsParams
+			require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+			return params
+		case <-time.After(5 * time.Second):
+			c.t.Fatal("no diagnostics published")
+		}
+	}
+}
+
+// open opens a document whose text holds a cursor marked with "|", and
+// returns the position of the cursor.
+func (c *testClient) open(uri, text string) position {
+	var pos position
+	if idx := strings.Index(text, "|"); idx >= 0 {
+		before := text[:idx]
+		pos.Line = strings.Count(before, "\n")
+		pos.Character = len(before) - strings.LastIndex(before, "\n") - 1
+		text = before + text[idx+1:]
+	}
+	c.notify("textDocument/didOpen", didOpenParams{
+		TextDocument: textDocumentItem{URI: uri, Version: 1, Text: text},
+	})
+	c.diagnostics()
+	return pos
+}
+
+func (c *testClient) positionParams(uri string, pos position) textDocumentPositionParams {
+	return textDocumentPositionParams{
+		TextDocument: textDocumentIdentifier{URI: uri},
+		Position:     pos,
+	}
+}
+
+const testURI = "file:///config/config.river"
+
+func TestCompletion(t *testing.T) {
+	tt := []struct {
+		name     string
+		text     string
+		contains []string
+		excludes []string
+	}{
+		{
+			name:     "component names",
+			text:     `prometheus.scr|`,
+			contains: []string{"prometheus.scrape"},
+			excludes: []string{"prometheus.remote_write"},
+		},
+		{
+			name:     "config blocks",
+			text:     `logg|`,
+			contains: []string{"logging"},
+		},
+		{
+			name: "arguments",
+			text: `
+				prometheus.scrape "default" {
+					|
+				}
+			`,
+			contains: []string{"targets", "forward_to", "scrape_interval", "basic_auth"},
+		},
+		{
+			name: "nested block arguments",
+			text: `
+				prometheus.remote_write "default" {
+					endpoint {
+						ur|
+					}
+				}
+			`,
+			contains: []string{"url"},
+			excludes: []string{"endpoint"},
+		},
+		{
+			name: "references",
+			text: `
+				prometheus.remote_write "default" {}
+
+				prometheus.scrape "default" {
+					targets    = [{"__address__" = "localhost:12345"}]
+					forward_to = [prometheus.|]
+				}
+			`,
+			contains: []string{"prometheus.remote_write.default.receiver"},
+		},
+		{
+			name: "custom components",
+			text: `
+				declare "add" {
+					argument "a" {}
+					argument "b" { optional = true }
+					export "sum" { value = argument.a.value }
+				}
+
+				ad|
+			`,
+			contains: []string{"add"},
+		},
+		{
+			name: "custom component arguments",
+			text: `
+				declare "add" {
+					argument "a" {}
+					argument "b" { optional = true }
+					export "sum" { value = argument.a.value }
+				}
+
+				add "default" {
+					|
+				}
+			`,
+			contains: []string{"a", "b"},
+		},
+		{
+			name: "module arguments",
+			text: `
+				declare "add" {
+					argument "a" {}
+					export "sum" { value = argument.| }
+				}
+			`,
+			contains: []string{"argument.a.value"},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			c := newTestClient(t, Options{})
+			pos := c.open(testURI, tc.text)
+
+			var res completionList
+			c.call("textDocument/completion", c.positionParams(testURI, pos), &res)
+
+			labels := make(map[string]bool)
+			for _, item := range res.Items {
+				labels[item.Label] = true
+			}
+			for _, label := range tc.contains {
+				require.True(t, labels[label], "missing completion %q", label)
+			}
+			for _, label := range tc.excludes {
+				require.False(t, labels[label], "unexpected completion %q", label)
+			}
+		})
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := newTestClient(t, Options{})
+	pos := c.open(testURI, `
+		prometheus.scr|ape "default" {
+			targets    = []
+			forward_to = []
+		}
+	`)
+
+	var res hover
+	c.call("textDocument/hover", c.positionParams(testURI, pos), &res)
+	require.Contains(t, res.Contents.Value, "### `prometheus.scrape`")
+	require.Contains(t, res.Contents.Value, "| `forward_to` | `list(capsule(storage.Appendable))` | yes |")
+	require.Contains(t, res.Contents.Value, "Components that export [Targets]")
+	require.Contains(t, res.Contents.Value, "components/prometheus.scrape/")
+}
+
+func TestDefinition(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.river")
+	require.NoError(t, os.WriteFile(lib, []byte("declare \"sub\" {}\n"), 0o644))
+
+	uri := pathToURI(filepath.Join(dir, "config.river"))
+	text := `
+declare "add" {}
+
+import.file "lib" {
+	filename = "lib.river"
+}
+
+add "default" {}
+
+lib.sub "default" {}
+
+prometheus.remote_write "default" {}
+
+prometheus.scrape "default" {
+	targets    = []
+	forward_to = [prometheus.remote_write.default.receiver]
+}
+`
+	c := newTestClient(t, Options{})
+	c.open(uri, text)
+
+	tt := []struct {
+		name     string
+		at       string // Text at the position to find the definition of.
+		expected location
+	}{
+		{
+			name:     "declare",
+			at:       `add "default"`,
+			expected: location{URI: uri, Range: textRange{Start: position{Line: 1}, End: position{Line: 1, Character: 14}}},
+		},
+		{
+			name:     "import.file",
+			at:       `lib.sub "default"`,
+			expected: location{URI: pathToURI(lib), Range: textRange{End: position{Character: 14}}},
+		},
+		{
+			name:     "reference",
+			at:       `remote_write.default.receiver`,
+			expected: location{URI: uri, Range: textRange{Start: position{Line: 11}, End: position{Line: 11, Character: 34}}},
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			idx := strings.Index(text, tc.at)
+			before := text[:idx]
+			pos := position{
+				Line:      strings.Count(before, "\n"),
+				Character: len(before) - strings.LastIndex(before, "\n") - 1,
+			}
+
+			var res location
+			c.call("textDocument/definition", c.positionParams(uri, pos), &res)
+			require.Equal(t, tc.expected, res)
+		})
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	validated := make(chan map[string][]byte, 1)
+	c := newTestClient(t, Options{
+		Validate: func(files map[string][]byte) diag.Diagnostics {
+			validated <- files
+			return diag.Diagnostics{{
+				Severity: diag.SeverityLevelError,
+				StartPos: token.Position{Filename: "/config/config.river", Line: 2, Column: 1},
+				EndPos:   token.Position{Filename: "/config/config.river", Line: 2, Column: 3},
+				Message:  "unknown component",
+			}}
+		},
+	})
+
+	// Documents are validated when they're opened.
+	c.notify("textDocument/didOpen", didOpenParams{
+		TextDocument: textDocumentItem{URI: testURI, Version: 1, Text: "\nfoo \"bar\" {}\n"},
+	})
+	require.Empty(t, c.diagnostics().Diagnostics)
+	require.Equal(t, map[string][]byte{"/config/config.river": []byte("\nfoo \"bar\" {}\n")}, <-validated)
+
+	res := c.diagnostics()
+	require.Equal(t, []diagnostic{{
+		Range:    textRange{Start: position{Line: 1}, End: position{Line: 1, Character: 3}},
+		Severity: severityError,
+		Source:   diagnosticSource,
+		Message:  "unknown component",
+	}}, res.Diagnostics)
+
+	// Changes only report syntax errors.
+	c.notify("textDocument/didChange", didChangeParams{
+		TextDocument: textDocumentItem{URI: testURI, Version: 2},
+		ContentChanges: []struct {
+			Text string `json:"text"`
+		}{{Text: "foo {"}},
+	})
+	res = c.diagnostics()
+	require.Equal(t, 2, res.Version)
+	require.Len(t, res.Diagnostics, 1)
+	require.Equal(t, severityError, res.Diagnostics[0].Severity)
+	require.Empty(t, validated)
+}
+
+func TestContextAt(t *testing.T) {
+	tt := []struct {
+		text     string
+		expected cursorContext
+	}{
+		{text: ``, expected: cursorContext{statementStart: true}},
+		{text: "a \"b\" {\n", expected: cursorContext{blocks: []string{"a"}, statementStart: true}},
+		{text: "a.b \"c\" {\n d {\n", expected: cursorContext{blocks: []string{"a.b", "d"}, statementStart: true}},
+		{text: "a.b \"c\" {\n d {}\n", expected: cursorContext{blocks: []string{"a.b"}, statementStart: true}},
+		{text: "a \"b\" {\n c = ", expected: cursorContext{blocks: []string{"a"}, expression: true}},
+		{text: "a \"b\" {\n c = [{\"d\" = ", expected: cursorContext{blocks: []string{"a"}, expression: true}},
+		{text: "a \"b\" {\n c = {\n d = 1,\n", expected: cursorContext{blocks: []string{"a"}, expression: true}},
+		{text: "a \"b\" {\n c = {\n d = 1,\n}\n", expected: cursorContext{blocks: []string{"a"}, statementStart: true}},
+		{text: "a ", expected: cursorContext{}},
+	}
+
+	for _, tc := range tt {
+		require.Equal(t, tc.expected, contextAt(tc.text, len(tc.text)), "context of %q", tc.text)
+	}
+}
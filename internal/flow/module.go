@@ -12,6 +12,7 @@ import (
 	"github.com/grafana/agent/internal/flow/internal/worker"
 	"github.com/grafana/agent/internal/flow/logging"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/agent/internal/flow/tracing"
 	"github.com/grafana/river/ast"
 	"github.com/grafana/river/scanner"
@@ -142,6 +143,8 @@ func newModule(o *moduleOptions) *module {
 				Logger:       o.Logger,
 				DataPath:     o.DataPath,
 				MinStability: o.MinStability,
+				Lockfile:     o.Lockfile,
+				DryRun:       o.DryRun,
 				OnExportsChange: func(exports map[string]any) {
 					if o.export != nil {
 						o.export(exports)
@@ -209,6 +212,12 @@ type moduleControllerOptions struct {
 	// the user, for example, via command-line flags.
 	MinStability featuregate.Stability
 
+	// Lockfile pins the content of modules retrieved by import blocks.
+	Lockfile *moduleverify.Lockfile
+
+	// DryRun evaluates the arguments of components without building them.
+	DryRun bool
+
 	// ID is the attached components full ID.
 	ID string
 
@@ -0,0 +1,163 @@
+package flow
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/grafana/agent/internal/flow/internal/importsource"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/parser"
+	"github.com/grafana/river/vm"
+)
+
+// LockModules pins the content of the modules retrieved by the remote import
+// blocks of source in lockfile, including the modules imported by the
+// retrieved modules.
+//
+// If update is false, sources which are already pinned must still match
+// their pin. If update is true, all sources are pinned to their current
+// content. In both cases, the pins of sources which are no longer imported
+// are removed.
+//
+// LockModules returns the sorted list of pinned sources.
+func LockModules(ctx context.Context, source *Source, lockfile *moduleverify.Lockfile, update bool) ([]string, error) {
+	storagePath, err := os.MkdirTemp("", "agent-modules-lock")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(storagePath)
+
+	locker := &moduleLocker{
+		storagePath: storagePath,
+		lockfile:    lockfile,
+		update:      update,
+		seen:        make(map[string]struct{}),
+	}
+
+	var stmts ast.Body
+	for _, block := range source.configBlocks {
+		stmts = append(stmts, block)
+	}
+	for _, block := range source.declareBlocks {
+		stmts = append(stmts, block)
+	}
+	if err := locker.lockBody(ctx, stmts); err != nil {
+		return nil, err
+	}
+
+	lockfile.Retain(locker.seen)
+
+	sources := make([]string, 0, len(locker.seen))
+	for key := range locker.seen {
+		sources = append(sources, key)
+	}
+	sort.Strings(sources)
+	return sources, nil
+}
+
+type moduleLocker struct {
+	storagePath string
+	lockfile    *moduleverify.Lockfile
+	update      bool
+	seen        map[string]struct{} // Keys of the sources found so far.
+}
+
+// lockBody pins the remote import blocks of body, including the ones nested
+// in declare blocks.
+func (l *moduleLocker) lockBody(ctx context.Context, body ast.Body) error {
+	for _, stmt := range body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			continue
+		}
+
+		var (
+			key     string
+			content map[string]string
+			err     error
+		)
+
+		switch block.GetBlockName() {
+		case "declare":
+			if err := l.lockBody(ctx, block.Body); err != nil {
+				return err
+			}
+			continue
+
+		case importsource.BlockImportGit:
+			var args importsource.GitArguments
+			if err = vm.New(block.Body).Evaluate(nil, &args); err == nil {
+				key = args.LockKey()
+				content, err = importsource.FetchGit(ctx, filepath.Join(l.storagePath, fmt.Sprint(len(l.seen))), args)
+			}
+
+		case importsource.BlockImportHTTP:
+			var args importsource.HTTPArguments
+			if err = vm.New(block.Body).Evaluate(nil, &args); err == nil {
+				key = args.LockKey()
+				content, err = importsource.FetchHTTP(ctx, args)
+			}
+
+		case importsource.BlockImportS3:
+			var args importsource.S3Arguments
+			if err = vm.New(block.Body).Evaluate(nil, &args); err == nil {
+				key = args.LockKey()
+				content, err = importsource.FetchS3(ctx, args)
+			}
+
+		case importsource.BlockImportOCI:
+			var args importsource.OCIArguments
+			if err = vm.New(block.Body).Evaluate(nil, &args); err == nil {
+				key = args.LockKey()
+				content, err = importsource.FetchOCI(ctx, args)
+			}
+
+		default:
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", blockDisplayName(block), err)
+		}
+
+		if err := l.lock(key, content); err != nil {
+			return fmt.Errorf("%s: %w", blockDisplayName(block), err)
+		}
+
+		for name, moduleContent := range content {
+			file, err := parser.ParseFile(name, []byte(moduleContent))
+			if err != nil {
+				return fmt.Errorf("%s: parsing %q: %w", blockDisplayName(block), name, err)
+			}
+			if err := l.lockBody(ctx, file.Body); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (l *moduleLocker) lock(key string, content map[string]string) error {
+	l.seen[key] = struct{}{}
+
+	if _, pinned := l.lockfile.Pinned(key); pinned && !l.update {
+		if err := l.lockfile.Verify(key, content); err != nil {
+			return fmt.Errorf("%w; update the lockfile if the change is expected", err)
+		}
+		return nil
+	}
+	l.lockfile.Pin(key, content)
+	return nil
+}
+
+func blockDisplayName(block *ast.BlockStmt) string {
+	name := strings.Join(block.Name, ".")
+	if block.Label != "" {
+		name += fmt.Sprintf(" %q", block.Label)
+	}
+	return name
+}
@@ -0,0 +1,115 @@
+package flow
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/grafana/agent/internal/component"
+	"github.com/grafana/agent/internal/flow/internal/worker"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/stretchr/testify/require"
+)
+
+// moduleServer serves a module over HTTP whose content can be changed.
+type moduleServer struct {
+	mut     sync.Mutex
+	content string
+}
+
+func (s *moduleServer) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	_, _ = w.Write([]byte(s.content))
+}
+
+func (s *moduleServer) set(content string) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+	s.content = content
+}
+
+const lockTestModule = `
+	declare "add" {
+		argument "a" {}
+		export "sum" {
+			value = argument.a.value + 1
+		}
+	}
+`
+
+func TestLockModules(t *testing.T) {
+	module := &moduleServer{content: lockTestModule}
+	moduleSrv := httptest.NewServer(module)
+	defer moduleSrv.Close()
+	nestedSrv := httptest.NewServer(&moduleServer{content: `import.http "nested" { url = "` + moduleSrv.URL + `" }`})
+	defer nestedSrv.Close()
+
+	f, err := ParseSource(t.Name(), []byte(`
+		declare "wrapper" {
+			import.http "mod" {
+				url = "`+nestedSrv.URL+`"
+			}
+		}
+	`))
+	require.NoError(t, err)
+
+	lockfile := moduleverify.NewLockfile(filepath.Join(t.TempDir(), moduleverify.LockfileName))
+	sources, err := LockModules(context.Background(), f, lockfile, false)
+	require.NoError(t, err)
+	require.Len(t, sources, 2, "imports in declare blocks and imported modules must be pinned")
+
+	// Locking again must fail if the content changed, but updating succeeds.
+	module.set(lockTestModule + "\n// changed")
+	_, err = LockModules(context.Background(), f, lockfile, false)
+	require.ErrorContains(t, err, "doesn't match the lockfile")
+
+	_, err = LockModules(context.Background(), f, lockfile, true)
+	require.NoError(t, err)
+}
+
+func TestImportHTTP_Lockfile(t *testing.T) {
+	defer verifyNoGoroutineLeaks(t)
+
+	module := &moduleServer{content: lockTestModule}
+	srv := httptest.NewServer(module)
+	defer srv.Close()
+
+	config := `
+		import.http "mod" {
+			url = "` + srv.URL + `"
+		}
+	`
+	f, err := ParseSource(t.Name(), []byte(config))
+	require.NoError(t, err)
+
+	lockfile := moduleverify.NewLockfile(filepath.Join(t.TempDir(), moduleverify.LockfileName))
+	_, err = LockModules(context.Background(), f, lockfile, false)
+	require.NoError(t, err)
+
+	module.set(lockTestModule + "\n// changed")
+
+	opts := testOptions(t)
+	opts.Lockfile = lockfile
+	ctrl := newController(controllerOptions{
+		Options:        opts,
+		ModuleRegistry: newModuleRegistry(),
+		WorkerPool:     worker.NewFixedWorkerPool(4, 100),
+	})
+	defer cleanUpController(ctrl)
+
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	health := ctrl.loader.Imports()["mod"].CurrentHealth()
+	require.Equal(t, component.HealthTypeUnhealthy, health.Health)
+	require.Contains(t, health.Message, "doesn't match the lockfile")
+
+	// Once the lockfile is updated, reloading accepts the content.
+	_, err = LockModules(context.Background(), f, lockfile, true)
+	require.NoError(t, err)
+	require.NoError(t, ctrl.LoadSource(f, nil))
+	health = ctrl.loader.Imports()["mod"].CurrentHealth()
+	require.NotEqual(t, component.HealthTypeUnhealthy, health.Health)
+}
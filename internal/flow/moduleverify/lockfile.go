@@ -0,0 +1,208 @@
+// Package moduleverify verifies the content of modules retrieved by import
+// blocks, by comparing it against the hashes pinned in a lockfile and by
+// checking minisign signatures.
+package moduleverify
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+)
+
+// LockfileName is the name of the lockfile stored next to the main config.
+const LockfileName = "modules.lock"
+
+// lockfileVersion is the version of the lockfile format.
+const lockfileVersion = 1
+
+// LockfilePath returns the path of the lockfile for the config at
+// configPath. If configPath is a directory, the lockfile is stored inside of
+// it. Otherwise, it's stored in the same directory as the config file.
+func LockfilePath(configPath string) string {
+	if fi, err := os.Stat(configPath); err == nil && fi.IsDir() {
+		return filepath.Join(configPath, LockfileName)
+	}
+	return filepath.Join(filepath.Dir(configPath), LockfileName)
+}
+
+// Pin holds the hashes of the files of a module source.
+type Pin struct {
+	Files map[string]string `json:"files"` // File name -> hash of the content
+}
+
+// lockfileContent is the on-disk format of a lockfile.
+type lockfileContent struct {
+	Version int            `json:"version"`
+	Modules map[string]Pin `json:"modules"` // Source -> pin
+}
+
+// A Lockfile pins the content of module sources to hashes. Sources are
+// identified by a string which describes where the content is retrieved from,
+// such as a repository, revision, and path.
+//
+// A nil *Lockfile is valid and pins nothing.
+type Lockfile struct {
+	path string
+
+	mut  sync.RWMutex
+	pins map[string]Pin
+}
+
+// NewLockfile creates a new, empty Lockfile stored at path. Call Load to read
+// the pins from disk.
+func NewLockfile(path string) *Lockfile {
+	return &Lockfile{
+		path: path,
+		pins: make(map[string]Pin),
+	}
+}
+
+// Path returns the path of the lockfile.
+func (l *Lockfile) Path() string { return l.path }
+
+// Load replaces the pins with the pins stored on disk. A missing file is
+// treated as an empty lockfile.
+func (l *Lockfile) Load() error {
+	bb, err := os.ReadFile(l.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		l.mut.Lock()
+		l.pins = make(map[string]Pin)
+		l.mut.Unlock()
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	var content lockfileContent
+	if err := json.Unmarshal(bb, &content); err != nil {
+		return fmt.Errorf("parsing lockfile %s: %w", l.path, err)
+	}
+	if content.Version != lockfileVersion {
+		return fmt.Errorf("unsupported lockfile version %d in %s", content.Version, l.path)
+	}
+	if content.Modules == nil {
+		content.Modules = make(map[string]Pin)
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.pins = content.Modules
+	return nil
+}
+
+// Save writes the pins to disk.
+func (l *Lockfile) Save() error {
+	l.mut.RLock()
+	content := lockfileContent{Version: lockfileVersion, Modules: l.pins}
+	// Map keys are sorted by encoding/json, so saving the same pins always
+	// results in the same file.
+	bb, err := json.MarshalIndent(content, "", "  ")
+	l.mut.RUnlock()
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(l.path, append(bb, '\n'), 0o644)
+}
+
+// Sources returns the sorted list of pinned sources.
+func (l *Lockfile) Sources() []string {
+	if l == nil {
+		return nil
+	}
+
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+
+	sources := make([]string, 0, len(l.pins))
+	for source := range l.pins {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// Pinned returns the pin of source.
+func (l *Lockfile) Pinned(source string) (Pin, bool) {
+	if l == nil {
+		return Pin{}, false
+	}
+
+	l.mut.RLock()
+	defer l.mut.RUnlock()
+	pin, ok := l.pins[source]
+	return pin, ok
+}
+
+// Pin pins source to the current files.
+func (l *Lockfile) Pin(source string, files map[string]string) {
+	pin := Pin{Files: make(map[string]string, len(files))}
+	for name, content := range files {
+		pin.Files[name] = Hash(content)
+	}
+
+	l.mut.Lock()
+	defer l.mut.Unlock()
+	l.pins[source] = pin
+}
+
+// Retain removes the pins of all sources which aren't in sources.
+func (l *Lockfile) Retain(sources map[string]struct{}) {
+	l.mut.Lock()
+	defer l.mut.Unlock()
+
+	for source := range l.pins {
+		if _, ok := sources[source]; !ok {
+			delete(l.pins, source)
+		}
+	}
+}
+
+// Verify checks that files match the pin of source. Sources which aren't
+// pinned are always valid.
+func (l *Lockfile) Verify(source string, files map[string]string) error {
+	pin, ok := l.Pinned(source)
+	if !ok {
+		return nil
+	}
+
+	for name, content := range files {
+		expect, ok := pin.Files[name]
+		if !ok {
+			return PinMismatchError{Source: source, File: name, Reason: "file is not pinned"}
+		}
+		if actual := Hash(content); actual != expect {
+			return PinMismatchError{Source: source, File: name, Reason: fmt.Sprintf("expected hash %s, got %s", expect, actual)}
+		}
+	}
+	for name := range pin.Files {
+		if _, ok := files[name]; !ok {
+			return PinMismatchError{Source: source, File: name, Reason: "pinned file is missing"}
+		}
+	}
+	return nil
+}
+
+// Hash returns the hash of content as stored in a lockfile.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// PinMismatchError is returned when the content of a source doesn't match its
+// pin.
+type PinMismatchError struct {
+	Source string
+	File   string
+	Reason string
+}
+
+func (err PinMismatchError) Error() string {
+	return fmt.Sprintf("content of %q from %s doesn't match the lockfile: %s", err.File, err.Source, err.Reason)
+}
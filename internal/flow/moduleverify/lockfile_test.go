@@ -0,0 +1,64 @@
+package moduleverify
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockfileName)
+
+	lockfile := NewLockfile(path)
+	require.NoError(t, lockfile.Load(), "a missing lockfile must be treated as empty")
+	require.Empty(t, lockfile.Sources())
+
+	lockfile.Pin("git::repo//module.river?ref=v1", map[string]string{"module.river": "content"})
+	lockfile.Pin("http::http://example.com/module.river", map[string]string{"http://example.com/module.river": "other"})
+	require.NoError(t, lockfile.Save())
+
+	loaded := NewLockfile(path)
+	require.NoError(t, loaded.Load())
+	require.Equal(t, []string{
+		"git::repo//module.river?ref=v1",
+		"http::http://example.com/module.river",
+	}, loaded.Sources())
+
+	require.NoError(t, loaded.Verify("git::repo//module.river?ref=v1", map[string]string{"module.river": "content"}))
+	require.NoError(t, loaded.Verify("git::unpinned//module.river?ref=v1", map[string]string{"module.river": "anything"}))
+
+	err := loaded.Verify("git::repo//module.river?ref=v1", map[string]string{"module.river": "changed"})
+	require.ErrorAs(t, err, &PinMismatchError{})
+	require.ErrorContains(t, err, "expected hash "+Hash("content"))
+
+	err = loaded.Verify("git::repo//module.river?ref=v1", map[string]string{"module.river": "content", "new.river": "content"})
+	require.ErrorContains(t, err, "file is not pinned")
+
+	err = loaded.Verify("git::repo//module.river?ref=v1", map[string]string{})
+	require.ErrorContains(t, err, "pinned file is missing")
+
+	loaded.Retain(map[string]struct{}{"http::http://example.com/module.river": {}})
+	require.Equal(t, []string{"http::http://example.com/module.river"}, loaded.Sources())
+}
+
+func TestLockfile_Nil(t *testing.T) {
+	var lockfile *Lockfile
+	require.NoError(t, lockfile.Verify("git::repo//module.river?ref=v1", map[string]string{"module.river": "content"}))
+	require.Empty(t, lockfile.Sources())
+}
+
+func TestLockfile_InvalidVersion(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockfileName)
+	require.NoError(t, os.WriteFile(path, []byte(`{"version": 2, "modules": {}}`), 0o644))
+
+	err := NewLockfile(path).Load()
+	require.ErrorContains(t, err, "unsupported lockfile version 2")
+}
+
+func TestLockfilePath(t *testing.T) {
+	dir := t.TempDir()
+	require.Equal(t, filepath.Join(dir, LockfileName), LockfilePath(dir))
+	require.Equal(t, filepath.Join(dir, LockfileName), LockfilePath(filepath.Join(dir, "config.river")))
+}
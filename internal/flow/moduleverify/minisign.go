@@ -0,0 +1,105 @@
+package moduleverify
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/blake2b"
+)
+
+// SignatureSuffix is appended to the name of a file to get the name of its
+// signature file.
+const SignatureSuffix = ".minisig"
+
+const (
+	untrustedCommentPrefix = "untrusted comment:"
+	trustedCommentPrefix   = "trusted comment: "
+)
+
+// Signature algorithms supported by minisign.
+var (
+	algLegacy  = [2]byte{'E', 'd'} // Signs the content itself.
+	algHashed  = [2]byte{'E', 'D'} // Signs the BLAKE2b-512 hash of the content.
+	errNoMatch = errors.New("signature wasn't created by any of the trusted public keys")
+)
+
+// PublicKey is a minisign public key.
+type PublicKey struct {
+	keyID [8]byte
+	key   ed25519.PublicKey
+}
+
+// ParsePublicKey parses a minisign public key. s is either the base64-encoded
+// key, or the full content of a minisign public key file.
+func ParsePublicKey(s string) (PublicKey, error) {
+	encoded := strings.TrimSpace(s)
+	if lines := strings.Split(encoded, "\n"); len(lines) == 2 && strings.HasPrefix(lines[0], untrustedCommentPrefix) {
+		encoded = strings.TrimSpace(lines[1])
+	}
+
+	bb, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("decoding public key: %w", err)
+	}
+	if len(bb) != 2+8+ed25519.PublicKeySize || !bytes.Equal(bb[:2], algLegacy[:]) {
+		return PublicKey{}, fmt.Errorf("invalid public key: not a minisign Ed25519 public key")
+	}
+
+	var pk PublicKey
+	copy(pk.keyID[:], bb[2:10])
+	pk.key = ed25519.PublicKey(bb[10:])
+	return pk, nil
+}
+
+// VerifySignature checks that signature, the content of a minisign signature
+// file, is a valid signature of content created by any of keys.
+func VerifySignature(keys []PublicKey, content []byte, signature []byte) error {
+	lines := strings.Split(strings.TrimRight(string(signature), "\n"), "\n")
+	if len(lines) != 4 || !strings.HasPrefix(lines[0], untrustedCommentPrefix) || !strings.HasPrefix(lines[2], trustedCommentPrefix) {
+		return fmt.Errorf("invalid signature: not a minisign signature")
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[1]))
+	if err != nil {
+		return fmt.Errorf("decoding signature: %w", err)
+	}
+	if len(sig) != 2+8+ed25519.SignatureSize {
+		return fmt.Errorf("invalid signature length %d", len(sig))
+	}
+	globalSig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(lines[3]))
+	if err != nil {
+		return fmt.Errorf("decoding global signature: %w", err)
+	}
+	trustedComment := strings.TrimPrefix(strings.TrimRight(lines[2], "\r"), trustedCommentPrefix)
+
+	var message []byte
+	switch {
+	case bytes.Equal(sig[:2], algLegacy[:]):
+		message = content
+	case bytes.Equal(sig[:2], algHashed[:]):
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	default:
+		return fmt.Errorf("unsupported signature algorithm %q", sig[:2])
+	}
+
+	for _, key := range keys {
+		if !bytes.Equal(key.keyID[:], sig[2:10]) {
+			continue
+		}
+		if !ed25519.Verify(key.key, message, sig[10:]) {
+			return fmt.Errorf("invalid signature")
+		}
+		// The global signature covers the trusted comment, so that it can't be
+		// tampered with.
+		if !ed25519.Verify(key.key, append(sig[10:], trustedComment...), globalSig) {
+			return fmt.Errorf("invalid signature of the trusted comment")
+		}
+		return nil
+	}
+	return errNoMatch
+}
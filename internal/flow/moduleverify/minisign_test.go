@@ -0,0 +1,100 @@
+package moduleverify
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/blake2b"
+)
+
+func TestVerifySignature(t *testing.T) {
+	content := []byte("declare \"example\" {}\n")
+
+	keyID := [8]byte{1, 2, 3, 4, 5, 6, 7, 8}
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	pk, err := ParsePublicKey("untrusted comment: minisign public key\n" + encodePublicKey(keyID, pub) + "\n")
+	require.NoError(t, err)
+
+	otherID := [8]byte{8, 7, 6, 5, 4, 3, 2, 1}
+	otherPub, otherPriv, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	otherPK, err := ParsePublicKey(encodePublicKey(otherID, otherPub))
+	require.NoError(t, err)
+
+	t.Run("Hashed", func(t *testing.T) {
+		sig := sign(algHashed, keyID, priv, content, "timestamp:1")
+		require.NoError(t, VerifySignature([]PublicKey{otherPK, pk}, content, sig))
+	})
+
+	t.Run("Legacy", func(t *testing.T) {
+		sig := sign(algLegacy, keyID, priv, content, "timestamp:1")
+		require.NoError(t, VerifySignature([]PublicKey{pk}, content, sig))
+	})
+
+	t.Run("ModifiedContent", func(t *testing.T) {
+		sig := sign(algHashed, keyID, priv, content, "timestamp:1")
+		err := VerifySignature([]PublicKey{pk}, []byte("declare \"other\" {}\n"), sig)
+		require.EqualError(t, err, "invalid signature")
+	})
+
+	t.Run("UntrustedKey", func(t *testing.T) {
+		sig := sign(algHashed, otherID, otherPriv, content, "timestamp:1")
+		err := VerifySignature([]PublicKey{pk}, content, sig)
+		require.ErrorIs(t, err, errNoMatch)
+	})
+
+	t.Run("ModifiedTrustedComment", func(t *testing.T) {
+		sig := sign(algHashed, keyID, priv, content, "timestamp:1")
+		sig = []byte(replaceLine(string(sig), 2, trustedCommentPrefix+"timestamp:2"))
+		err := VerifySignature([]PublicKey{pk}, content, sig)
+		require.EqualError(t, err, "invalid signature of the trusted comment")
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		err := VerifySignature([]PublicKey{pk}, content, []byte("not a signature"))
+		require.ErrorContains(t, err, "not a minisign signature")
+	})
+}
+
+func TestParsePublicKey_Invalid(t *testing.T) {
+	_, err := ParsePublicKey("not base64!")
+	require.ErrorContains(t, err, "decoding public key")
+
+	_, err = ParsePublicKey(base64.StdEncoding.EncodeToString([]byte("Ed too short")))
+	require.ErrorContains(t, err, "not a minisign Ed25519 public key")
+}
+
+func encodePublicKey(keyID [8]byte, pub ed25519.PublicKey) string {
+	bb := append(append(algLegacy[:], keyID[:]...), pub...)
+	return base64.StdEncoding.EncodeToString(bb)
+}
+
+// sign creates a signature file in the same format as minisign.
+func sign(alg [2]byte, keyID [8]byte, priv ed25519.PrivateKey, content []byte, trustedComment string) []byte {
+	message := content
+	if alg == algHashed {
+		sum := blake2b.Sum512(content)
+		message = sum[:]
+	}
+	sig := ed25519.Sign(priv, message)
+	globalSig := ed25519.Sign(priv, append(sig, trustedComment...))
+
+	return []byte(fmt.Sprintf("%s signature from minisign secret key\n%s\n%s%s\n%s\n",
+		untrustedCommentPrefix,
+		base64.StdEncoding.EncodeToString(append(append(alg[:], keyID[:]...), sig...)),
+		trustedCommentPrefix, trustedComment,
+		base64.StdEncoding.EncodeToString(globalSig),
+	))
+}
+
+func replaceLine(s string, n int, line string) string {
+	lines := strings.Split(s, "\n")
+	lines[n] = line
+	return strings.Join(lines, "\n")
+}
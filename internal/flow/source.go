@@ -17,6 +17,9 @@ type Source struct {
 	sourceMap map[string][]byte // Map that links parsed Flow source's name with its content.
 	hash      [sha256.Size]byte // Hash of all files in sourceMap sorted by name.
 
+	// body holds the top-level statements of all files, in order.
+	body ast.Body
+
 	// Components holds the list of raw River AST blocks describing components.
 	// The Flow controller can interpret them.
 	components    []*ast.BlockStmt
@@ -75,7 +78,7 @@ func sourceFromBody(body ast.Body) (*Source, error) {
 			switch fullName {
 			case "declare":
 				declares = append(declares, stmt)
-			case "logging", "tracing", "argument", "export", "import.file", "import.string", "import.http", "import.git":
+			case "logging", "tracing", "argument", "export", "import.file", "import.string", "import.http", "import.git", "import.s3", "import.oci", "foreach":
 				configs = append(configs, stmt)
 			default:
 				components = append(components, stmt)
@@ -92,6 +95,7 @@ func sourceFromBody(body ast.Body) (*Source, error) {
 	}
 
 	return &Source{
+		body:          body,
 		components:    components,
 		configBlocks:  configs,
 		declareBlocks: declares,
@@ -132,6 +136,7 @@ func ParseSources(sources map[string][]byte) (*Source, error) {
 			return nil, err
 		}
 
+		mergedSource.body = append(mergedSource.body, sourceFragment.body...)
 		mergedSource.components = append(mergedSource.components, sourceFragment.components...)
 		mergedSource.configBlocks = append(mergedSource.configBlocks, sourceFragment.configBlocks...)
 		mergedSource.declareBlocks = append(mergedSource.declareBlocks, sourceFragment.declareBlocks...)
@@ -0,0 +1,254 @@
+package flow
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/printer"
+	"github.com/grafana/river/token"
+)
+
+// overlayRemoveBlock is the name of the blocks of overlay files which remove a
+// top-level block from the base config. The block attribute of the block is
+// the ID of the removed block, such as "prometheus.scrape.debug".
+const overlayRemoveBlock = "overlay.remove"
+
+// ParseSourcesWithOverlay parses the map of base sources and the map of
+// overlay sources, and merges the blocks of the overlay into the blocks of
+// the base sources:
+//
+//   - Top-level blocks of the overlay are matched with the blocks of the base
+//     sources by ID, which is the name of a block followed by its label.
+//     Matching blocks are merged, and other blocks are added.
+//   - When merging blocks, attributes of the overlay replace the attributes of
+//     the base block with the same name. Nested blocks with a label are merged
+//     with the nested block of the base block with the same name and label.
+//     Nested blocks without a label replace all the nested blocks of the base
+//     block with the same name.
+//   - An overlay.remove block removes the top-level block whose ID is the
+//     string of its block attribute.
+//
+// Neither sources nor overlay must be modified after calling
+// ParseSourcesWithOverlay.
+func ParseSourcesWithOverlay(sources map[string][]byte, overlay map[string][]byte) (*Source, error) {
+	base, err := ParseSources(sources)
+	if err != nil || len(overlay) == 0 {
+		return base, err
+	}
+	over, err := ParseSources(overlay)
+	if err != nil {
+		return nil, err
+	}
+
+	body, diags := applyOverlay(base.body, over.body)
+	if diags.HasErrors() {
+		return nil, diags
+	}
+	merged, err := sourceFromBody(body)
+	if err != nil {
+		return nil, err
+	}
+
+	merged.sourceMap = make(map[string][]byte, len(sources)+len(overlay))
+	for name, bb := range sources {
+		merged.sourceMap[name] = bb
+	}
+	for name, bb := range overlay {
+		merged.sourceMap[name] = bb
+	}
+	hash := sha256.New()
+	hash.Write(base.hash[:])
+	hash.Write(over.hash[:])
+	merged.hash = [sha256.Size]byte(hash.Sum(nil))
+	return merged, nil
+}
+
+// applyOverlay returns the result of merging the top-level statements of
+// overlay into base.
+func applyOverlay(base, overlay ast.Body) (ast.Body, diag.Diagnostics) {
+	var (
+		diags   diag.Diagnostics
+		merged  = append(ast.Body(nil), base...)
+		removed = make(map[string]bool)
+	)
+
+	for _, stmt := range overlay {
+		// sourceFromBody only accepts blocks at the top level.
+		block := stmt.(*ast.BlockStmt)
+
+		if block.GetBlockName() == overlayRemoveBlock {
+			id, err := removedBlockID(block)
+			if err != nil {
+				diags.Add(*err)
+				continue
+			}
+			if idx := findBlock(merged, id); idx >= 0 && !removed[id] {
+				removed[id] = true
+				continue
+			}
+			diags.Add(diag.Diagnostic{
+				Severity: diag.SeverityLevelError,
+				StartPos: ast.StartPos(block).Position(),
+				EndPos:   ast.EndPos(block).Position(),
+				Message:  fmt.Sprintf("%s block removes %q which isn't defined in the base config", overlayRemoveBlock, id),
+			})
+			continue
+		}
+
+		if idx := findBlock(merged, overlayBlockID(block)); idx >= 0 {
+			merged[idx] = mergeBlocks(merged[idx].(*ast.BlockStmt), block)
+		} else {
+			merged = append(merged, block)
+		}
+	}
+
+	result := merged[:0]
+	for _, stmt := range merged {
+		if block, ok := stmt.(*ast.BlockStmt); ok && removed[overlayBlockID(block)] {
+			continue
+		}
+		result = append(result, stmt)
+	}
+	return result, diags
+}
+
+// removedBlockID returns the ID of the block removed by an overlay.remove
+// block, which must be set as a string literal.
+func removedBlockID(block *ast.BlockStmt) (string, *diag.Diagnostic) {
+	if block.Label == "" && len(block.Body) == 1 {
+		if attr, ok := block.Body[0].(*ast.AttributeStmt); ok && attr.Name.Name == "block" {
+			if lit, ok := attr.Value.(*ast.LiteralExpr); ok && lit.Kind == token.STRING {
+				if id, err := strconv.Unquote(lit.Value); err == nil {
+					return id, nil
+				}
+			}
+		}
+	}
+	return "", &diag.Diagnostic{
+		Severity: diag.SeverityLevelError,
+		StartPos: ast.StartPos(block).Position(),
+		EndPos:   ast.EndPos(block).Position(),
+		Message:  fmt.Sprintf("%s block must only set the block attribute to a string", overlayRemoveBlock),
+	}
+}
+
+// overlayBlockID returns the ID used to match blocks of overlays: the name of
+// the block followed by its label, if any.
+func overlayBlockID(block *ast.BlockStmt) string {
+	if block.Label == "" {
+		return block.GetBlockName()
+	}
+	return block.GetBlockName() + "." + block.Label
+}
+
+// findBlock returns the index of the block of body with the given ID, or -1.
+func findBlock(body ast.Body, id string) int {
+	for i, stmt := range body {
+		if block, ok := stmt.(*ast.BlockStmt); ok && overlayBlockID(block) == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// mergeBlocks returns a copy of base whose body is merged with the body of
+// overlay.
+func mergeBlocks(base, overlay *ast.BlockStmt) *ast.BlockStmt {
+	merged := *base
+	merged.Body = mergeBodies(base.Body, overlay.Body)
+	return &merged
+}
+
+func mergeBodies(base, overlay ast.Body) ast.Body {
+	// Nested blocks without a label are replaced as a group, at the position of
+	// the first block of the group in base.
+	groups := make(map[string]ast.Body)
+	for _, stmt := range overlay {
+		if block, ok := stmt.(*ast.BlockStmt); ok && block.Label == "" {
+			groups[block.GetBlockName()] = append(groups[block.GetBlockName()], block)
+		}
+	}
+
+	var (
+		merged ast.Body
+		placed = make(map[string]bool)
+	)
+	for _, stmt := range base {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok || block.Label != "" || groups[block.GetBlockName()] == nil {
+			merged = append(merged, stmt)
+			continue
+		}
+		if name := block.GetBlockName(); !placed[name] {
+			merged = append(merged, groups[name]...)
+			placed[name] = true
+		}
+	}
+
+	for _, stmt := range overlay {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			if idx := findAttribute(merged, stmt.Name.Name); idx >= 0 {
+				merged[idx] = stmt
+			} else {
+				merged = append(merged, stmt)
+			}
+		case *ast.BlockStmt:
+			switch name := stmt.GetBlockName(); {
+			case stmt.Label == "":
+				if !placed[name] {
+					merged = append(merged, groups[name]...)
+					placed[name] = true
+				}
+			default:
+				if idx := findBlock(merged, overlayBlockID(stmt)); idx >= 0 {
+					merged[idx] = mergeBlocks(merged[idx].(*ast.BlockStmt), stmt)
+				} else {
+					merged = append(merged, stmt)
+				}
+			}
+		}
+	}
+	return merged
+}
+
+// findAttribute returns the index of the attribute of body with the given
+// name, or -1.
+func findAttribute(body ast.Body, name string) int {
+	for i, stmt := range body {
+		if attr, ok := stmt.(*ast.AttributeStmt); ok && attr.Name.Name == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// Render returns the River text of the blocks of the source. For sources
+// parsed with an overlay, the blocks are the result of merging the overlay
+// into the base sources. Comments aren't rendered.
+func (s *Source) Render() ([]byte, error) {
+	if s == nil {
+		return nil, nil
+	}
+	return renderBody(s.body)
+}
+
+// renderBody returns the River text of the statements of body.
+func renderBody(body ast.Body) ([]byte, error) {
+	var buf bytes.Buffer
+	for i, stmt := range body {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		if err := printer.Fprint(&buf, stmt); err != nil {
+			return nil, err
+		}
+		buf.WriteString("\n")
+	}
+	return []byte(strings.TrimSpace(buf.String()) + "\n"), nil
+}
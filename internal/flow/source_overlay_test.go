@@ -0,0 +1,168 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSourcesWithOverlay(t *testing.T) {
+	base := map[string][]byte{
+		"base.river": []byte(`
+			logging {
+				level = "info"
+			}
+
+			testcomponents.tick "ticker" {
+				frequency = "1s"
+			}
+
+			testcomponents.passthrough "static" {
+				input = "hello, world!"
+				lag   = "1s"
+			}
+
+			testcomponents.passthrough "debug" {
+				input = "debug"
+			}
+		`),
+	}
+	overlay := map[string][]byte{
+		"profiles/prod/overlay.river": []byte(`
+			logging {
+				format = "json"
+			}
+
+			testcomponents.passthrough "static" {
+				input = "hello, prod!"
+			}
+
+			testcomponents.tick "extra" {
+				frequency = "5s"
+			}
+
+			overlay.remove {
+				block = "testcomponents.passthrough.debug"
+			}
+		`),
+	}
+
+	s, err := ParseSourcesWithOverlay(base, overlay)
+	require.NoError(t, err)
+
+	require.Len(t, s.components, 3)
+	require.Equal(t, "testcomponents.tick.ticker", getBlockID(s.components[0]))
+	require.Equal(t, "testcomponents.passthrough.static", getBlockID(s.components[1]))
+	require.Equal(t, "testcomponents.tick.extra", getBlockID(s.components[2]))
+	require.Len(t, s.configBlocks, 1)
+	require.Contains(t, s.sourceMap, "base.river")
+	require.Contains(t, s.sourceMap, "profiles/prod/overlay.river")
+
+	rendered, err := s.Render()
+	require.NoError(t, err)
+	expect := `logging {
+	level  = "info"
+	format = "json"
+}
+
+testcomponents.tick "ticker" {
+	frequency = "1s"
+}
+
+testcomponents.passthrough "static" {
+	input = "hello, prod!"
+
+	lag = "1s"
+}
+
+testcomponents.tick "extra" {
+	frequency = "5s"
+}
+`
+	require.Equal(t, expect, string(rendered))
+}
+
+func TestParseSourcesWithOverlay_NestedBlocks(t *testing.T) {
+	base := map[string][]byte{
+		"base.river": []byte(`
+			test.block "a" {
+				inner "x" {
+					value = 1
+					other = 2
+				}
+
+				rule {
+					value = "first"
+				}
+
+				rule {
+					value = "second"
+				}
+			}
+		`),
+	}
+	overlay := map[string][]byte{
+		"overlay.river": []byte(`
+			test.block "a" {
+				inner "x" {
+					value = 10
+				}
+
+				inner "y" {
+					value = 20
+				}
+
+				rule {
+					value = "replaced"
+				}
+			}
+		`),
+	}
+
+	s, err := ParseSourcesWithOverlay(base, overlay)
+	require.NoError(t, err)
+
+	rendered, err := s.Render()
+	require.NoError(t, err)
+	expect := `test.block "a" {
+	inner "x" {
+		value = 10
+		other = 2
+	}
+
+	rule {
+		value = "replaced"
+	}
+
+	inner "y" {
+		value = 20
+	}
+}
+`
+	require.Equal(t, expect, string(rendered))
+}
+
+func TestParseSourcesWithOverlay_RemoveUnknown(t *testing.T) {
+	base := map[string][]byte{
+		"base.river": []byte(`testcomponents.tick "ticker" {}`),
+	}
+	overlay := map[string][]byte{
+		"overlay.river": []byte(`overlay.remove { block = "testcomponents.tick.missing" }`),
+	}
+
+	_, err := ParseSourcesWithOverlay(base, overlay)
+	require.ErrorContains(t, err, `overlay.remove block removes "testcomponents.tick.missing" which isn't defined in the base config`)
+}
+
+func TestParseSourcesWithOverlay_NoOverlay(t *testing.T) {
+	base := map[string][]byte{
+		"base.river": []byte(`testcomponents.tick "ticker" {}`),
+	}
+
+	s, err := ParseSourcesWithOverlay(base, nil)
+	require.NoError(t, err)
+
+	expect, err := ParseSources(base)
+	require.NoError(t, err)
+	require.Equal(t, expect.SHA256(), s.SHA256())
+}
@@ -0,0 +1,189 @@
+package flow
+
+import (
+	"reflect"
+	"strings"
+
+	"github.com/grafana/agent/internal/flow/internal/controller"
+	"github.com/grafana/river/ast"
+	"github.com/grafana/river/rivertypes"
+	"github.com/grafana/river/token"
+)
+
+// redactedSecret replaces the values of secret attributes in rendered
+// configs, matching how the values of secrets are displayed in the UI.
+const redactedSecret = `"(secret)"`
+
+var secretType = reflect.TypeOf(rivertypes.Secret(""))
+
+// redactSecrets returns a copy of body where the values of attributes of
+// type secret are replaced with redactedSecret. argumentsOf returns the
+// arguments of a block by name, or nil if they are unknown, in which case
+// the block is left as is.
+//
+// The declare blocks and the template blocks of foreach hold modules, whose
+// blocks are redacted in the same way.
+func redactSecrets(body ast.Body, argumentsOf func(name string) any) ast.Body {
+	redacted := make(ast.Body, 0, len(body))
+	for _, stmt := range body {
+		block, ok := stmt.(*ast.BlockStmt)
+		if !ok {
+			redacted = append(redacted, stmt)
+			continue
+		}
+
+		copied := *block
+		switch name := block.GetBlockName(); name {
+		case "declare":
+			copied.Body = redactSecrets(block.Body, argumentsOf)
+		case "foreach":
+			copied.Body = redactBody(block.Body, reflect.TypeOf(argumentsOf(name)))
+			for _, stmt := range copied.Body {
+				if template, ok := stmt.(*ast.BlockStmt); ok && template.GetBlockName() == "template" {
+					template.Body = redactSecrets(template.Body, argumentsOf)
+				}
+			}
+		default:
+			copied.Body = redactBody(block.Body, reflect.TypeOf(argumentsOf(name)))
+		}
+		redacted = append(redacted, &copied)
+	}
+	return redacted
+}
+
+// redactBody returns a copy of the body of a block whose arguments are of
+// type ty, where the values of attributes of type secret are replaced with
+// redactedSecret.
+func redactBody(body ast.Body, ty reflect.Type) ast.Body {
+	attrs, blocks := riverFields(ty)
+
+	redacted := make(ast.Body, 0, len(body))
+	for _, stmt := range body {
+		switch stmt := stmt.(type) {
+		case *ast.AttributeStmt:
+			if !containsSecret(attrs[stmt.Name.Name]) {
+				redacted = append(redacted, stmt)
+				continue
+			}
+			copied := *stmt
+			copied.Value = &ast.LiteralExpr{Kind: token.STRING, Value: redactedSecret, ValuePos: ast.StartPos(stmt.Value)}
+			redacted = append(redacted, &copied)
+
+		case *ast.BlockStmt:
+			copied := *stmt
+			copied.Body = redactBody(stmt.Body, blocks[stmt.GetBlockName()])
+			redacted = append(redacted, &copied)
+
+		default:
+			redacted = append(redacted, stmt)
+		}
+	}
+	return redacted
+}
+
+// riverFields returns the types of the attributes and of the blocks of the
+// River-tagged struct ty, by name. Blocks which are slices or arrays have the
+// type of their elements.
+func riverFields(ty reflect.Type) (attrs, blocks map[string]reflect.Type) {
+	attrs = make(map[string]reflect.Type)
+	blocks = make(map[string]reflect.Type)
+	addRiverFields(attrs, blocks, ty)
+	return attrs, blocks
+}
+
+func addRiverFields(attrs, blocks map[string]reflect.Type, ty reflect.Type) {
+	ty = indirectType(ty)
+	if ty == nil || ty.Kind() != reflect.Struct {
+		return
+	}
+
+	for i := 0; i < ty.NumField(); i++ {
+		sf := ty.Field(i)
+		tag, ok := sf.Tag.Lookup("river")
+		if !ok {
+			if sf.Anonymous {
+				addRiverFields(attrs, blocks, sf.Type)
+			}
+			continue
+		}
+
+		name, options, _ := strings.Cut(tag, ",")
+		flags := make(map[string]bool)
+		for _, opt := range strings.Split(options, ",") {
+			flags[opt] = true
+		}
+
+		switch {
+		case flags["squash"]:
+			addRiverFields(attrs, blocks, sf.Type)
+		case flags["attr"]:
+			attrs[name] = sf.Type
+		case flags["block"]:
+			blocks[name] = elemType(sf.Type)
+		case flags["enum"]:
+			// The blocks of an enum are the fields of its element type.
+			addRiverFields(map[string]reflect.Type{}, blocks, elemType(sf.Type))
+		}
+	}
+}
+
+// containsSecret returns whether values of ty hold secrets, such as a secret
+// or a map of secrets.
+func containsSecret(ty reflect.Type) bool {
+	for ty != nil {
+		if ty == secretType {
+			return true
+		}
+		switch ty.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Array, reflect.Map:
+			ty = ty.Elem()
+		default:
+			return false
+		}
+	}
+	return false
+}
+
+// indirectType returns the type pointed to by ty, if ty is a pointer.
+func indirectType(ty reflect.Type) reflect.Type {
+	for ty != nil && ty.Kind() == reflect.Pointer {
+		ty = ty.Elem()
+	}
+	return ty
+}
+
+// elemType returns the type of the blocks of a block field, which may be a
+// slice or array of blocks.
+func elemType(ty reflect.Type) reflect.Type {
+	ty = indirectType(ty)
+	if ty.Kind() == reflect.Slice || ty.Kind() == reflect.Array {
+		ty = indirectType(ty.Elem())
+	}
+	return ty
+}
+
+// blockArguments returns a function returning the arguments of the blocks
+// of the config by name: the arguments of components, config blocks and
+// services.
+func (f *Flow) blockArguments() func(name string) any {
+	configBlocks := controller.ConfigBlockArguments()
+	reg := f.opts.ComponentRegistry
+	if reg == nil {
+		reg = controller.NewDefaultComponentRegistry(f.opts.MinStability)
+	}
+
+	return func(name string) any {
+		if args, ok := configBlocks[name]; ok {
+			return args
+		}
+		for _, svc := range f.opts.Services {
+			if def := svc.Definition(); def.Name == name {
+				return def.ConfigType
+			}
+		}
+		if registration, err := reg.Get(name); err == nil {
+			return registration.Args
+		}
+		return nil
+	}
+}
@@ -0,0 +1,88 @@
+package flow
+
+import (
+	"testing"
+
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+)
+
+type redactTestArguments struct {
+	URL      string                       `river:"url,attr"`
+	Password rivertypes.Secret            `river:"password,attr,optional"`
+	Headers  map[string]rivertypes.Secret `river:"headers,attr,optional"`
+	Username rivertypes.OptionalSecret    `river:"username,attr,optional"`
+	Auth     []redactTestAuth             `river:"auth,block,optional"`
+}
+
+type redactTestAuth struct {
+	Token rivertypes.Secret `river:"token,attr"`
+	Scope string            `river:"scope,attr,optional"`
+}
+
+func TestRedactSecrets(t *testing.T) {
+	s, err := ParseSource(t.Name(), []byte(`
+		fake.component "a" {
+			url      = "http://localhost"
+			password = "hunter2"
+			headers  = {"Authorization" = "Bearer token"}
+			username = "admin"
+
+			auth {
+				token = "token"
+				scope = "read"
+			}
+		}
+
+		declare "module" {
+			fake.component "b" {
+				url      = "http://localhost"
+				password = env("PASSWORD")
+			}
+		}
+
+		unknown.component "c" {
+			password = "unknown"
+		}
+	`))
+	require.NoError(t, err)
+
+	argumentsOf := func(name string) any {
+		if name == "fake.component" {
+			return redactTestArguments{}
+		}
+		return nil
+	}
+	rendered, err := renderBody(redactSecrets(s.body, argumentsOf))
+	require.NoError(t, err)
+
+	expect := `fake.component "a" {
+	url      = "http://localhost"
+	password = "(secret)"
+	headers  = "(secret)"
+	username = "admin"
+
+	auth {
+		token = "(secret)"
+		scope = "read"
+	}
+}
+
+declare "module" {
+	fake.component "b" {
+		url      = "http://localhost"
+		password = "(secret)"
+	}
+}
+
+unknown.component "c" {
+	password = "unknown"
+}
+`
+	require.Equal(t, expect, string(rendered))
+
+	// The source itself isn't modified.
+	rendered, err = s.Render()
+	require.NoError(t, err)
+	require.Contains(t, string(rendered), `"hunter2"`)
+}
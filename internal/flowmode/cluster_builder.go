@@ -1,6 +1,7 @@
 package flowmode
 
 import (
+	"bytes"
 	"fmt"
 	stdlog "log"
 	"net"
@@ -33,6 +34,19 @@ type clusterOptions struct {
 	AdvertiseInterfaces []string
 	ClusterMaxJoinPeers int
 	ClusterName         string
+	WaitForSize         int
+	WaitTimeout         time.Duration
+	WarmupPeriod        time.Duration
+	NodeZone            string
+	NodeRack            string
+	EnableTLS           bool
+	TLSCAPath           string
+	TLSCertPath         string
+	TLSKeyPath          string
+	TLSServerName       string
+	TLSAllowedSANs      []string
+	TLSAllowedCNs       []string
+	SecretPath          string
 }
 
 func buildClusterService(opts clusterOptions) (*cluster.Service, error) {
@@ -49,6 +63,41 @@ func buildClusterService(opts clusterOptions) (*cluster.Service, error) {
 		RejoinInterval:      opts.RejoinInterval,
 		ClusterMaxJoinPeers: opts.ClusterMaxJoinPeers,
 		ClusterName:         opts.ClusterName,
+		WaitForSize:         opts.WaitForSize,
+		WaitTimeout:         opts.WaitTimeout,
+		WarmupPeriod:        opts.WarmupPeriod,
+		Topology: cluster.Topology{
+			Zone: opts.NodeZone,
+			Rack: opts.NodeRack,
+		},
+	}
+
+	switch {
+	case opts.EnableTLS:
+		if opts.TLSCAPath == "" || opts.TLSCertPath == "" || opts.TLSKeyPath == "" {
+			return nil, fmt.Errorf("the CA, certificate, and key paths must be set when TLS is enabled for clustering")
+		}
+		config.TLS = &cluster.TLSOptions{
+			CAFile:             opts.TLSCAPath,
+			CertFile:           opts.TLSCertPath,
+			KeyFile:            opts.TLSKeyPath,
+			ServerName:         opts.TLSServerName,
+			AllowedSANs:        opts.TLSAllowedSANs,
+			AllowedCommonNames: opts.TLSAllowedCNs,
+		}
+	case len(opts.TLSAllowedSANs) > 0 || len(opts.TLSAllowedCNs) > 0:
+		return nil, fmt.Errorf("allowed SANs and common names require TLS to be enabled for clustering")
+	}
+
+	if opts.SecretPath != "" {
+		secret, err := os.ReadFile(opts.SecretPath)
+		if err != nil {
+			return nil, fmt.Errorf("reading cluster secret: %w", err)
+		}
+		config.SharedSecret = bytes.TrimSpace(secret)
+		if len(config.SharedSecret) == 0 {
+			return nil, fmt.Errorf("cluster secret file %q is empty", opts.SecretPath)
+		}
 	}
 
 	if config.NodeName == "" {
@@ -16,3 +16,16 @@ func TestBuildClusterService(t *testing.T) {
 	require.Nil(t, cs)
 	require.EqualError(t, err, "at most one of join peers and discover peers may be set")
 }
+
+func TestBuildClusterService_Auth(t *testing.T) {
+	_, err := buildClusterService(clusterOptions{
+		EnableTLS:   true,
+		TLSCertPath: "node.crt",
+	})
+	require.EqualError(t, err, "the CA, certificate, and key paths must be set when TLS is enabled for clustering")
+
+	_, err = buildClusterService(clusterOptions{
+		TLSAllowedCNs: []string{"node-a"},
+	})
+	require.EqualError(t, err, "allowed SANs and common names require TLS to be enabled for clustering")
+}
@@ -0,0 +1,106 @@
+package flowmode
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow"
+	"github.com/grafana/agent/internal/flow/logging"
+	"github.com/grafana/agent/internal/flow/lsp"
+	"github.com/grafana/agent/internal/service"
+	"github.com/grafana/river/diag"
+	"github.com/spf13/cobra"
+)
+
+func lspCommand() *cobra.Command {
+	l := &flowLSP{
+		minStability: featuregate.StabilityExperimental,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "lsp [flags]",
+		Short: "Run a language server for configuration files",
+		Long: `The lsp subcommand runs a Language Server Protocol server over stdio
+for River configuration files.
+
+The language server completes the names of components, their arguments and
+blocks, and references to exports. It shows the documentation of components on
+hover, finds the definition of custom components, and reports the diagnostics of
+the validate subcommand when a file is opened or saved.
+
+Logs are written to stderr.`,
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, _ []string) error {
+			return l.Run()
+		},
+	}
+
+	cmd.Flags().Var(&l.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to allow. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	return cmd
+}
+
+type flowLSP struct {
+	minStability featuregate.Stability
+}
+
+// Run serves the language server over stdio until the client exits.
+func (fl *flowLSP) Run() error {
+	logger, err := logging.New(os.Stderr, logging.DefaultOptions)
+	if err != nil {
+		return fmt.Errorf("building logger: %w", err)
+	}
+
+	dataPath, err := os.MkdirTemp("", "agent-lsp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dataPath)
+
+	services, err := validationServices(logger, dataPath)
+	if err != nil {
+		return err
+	}
+	definitions := make([]service.Definition, 0, len(services))
+	for _, svc := range services {
+		definitions = append(definitions, svc.Definition())
+	}
+
+	server := lsp.New(lsp.Options{
+		Logger:   logger,
+		Validate: fl.validate,
+		Services: definitions,
+	})
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer cancel()
+	return server.Serve(ctx, os.Stdin, os.Stdout)
+}
+
+// validate returns the diagnostics of loading files without running them.
+func (fl *flowLSP) validate(files map[string][]byte) diag.Diagnostics {
+	source, err := flow.ParseSources(files)
+	if err == nil {
+		var diags diag.Diagnostics
+		diags, err = validateSource(source, fl.minStability)
+		if err == nil {
+			return diags
+		}
+	}
+
+	var diags diag.Diagnostics
+	if errors.As(err, &diags) {
+		return diags
+	}
+	return diag.Diagnostics{{
+		Severity: diag.SeverityLevelError,
+		Message:  err.Error(),
+	}}
+}
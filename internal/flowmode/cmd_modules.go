@@ -0,0 +1,89 @@
+package flowmode
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/grafana/agent/internal/flow"
+	"github.com/grafana/agent/internal/flow/moduleverify"
+	"github.com/spf13/cobra"
+)
+
+func modulesCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "modules",
+		Short: "Manage the lockfile of imported modules",
+		Long: `The modules command manages the lockfile which pins the content of modules
+retrieved by import.git, import.http, import.oci, and import.s3 blocks.
+
+The lockfile is named modules.lock and is stored next to the River file-path, or
+inside of the River dir if path is a directory. When a lockfile exists, the run
+command rejects imported modules whose content doesn't match the lockfile.`,
+	}
+
+	cmd.AddCommand(
+		modulesLockCommand(),
+		modulesUpdateCommand(),
+	)
+	return cmd
+}
+
+func modulesLockCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "lock [flags] path",
+		Short: "Pin the content of imported modules",
+		Long: `The lock subcommand retrieves all modules imported by the River dir/file-path
+and pins the content of modules which aren't in the lockfile yet.
+
+lock fails if the content of a module which is already in the lockfile has
+changed. Use the update subcommand to pin the new content.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockModules(cmd.Context(), args[0], false)
+		},
+	}
+}
+
+func modulesUpdateCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "update [flags] path",
+		Short: "Update the pinned content of imported modules",
+		Long: `The update subcommand retrieves all modules imported by the River
+dir/file-path and pins their current content, replacing existing pins.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return lockModules(cmd.Context(), args[0], true)
+		},
+	}
+}
+
+func lockModules(ctx context.Context, configPath string, update bool) error {
+	source, err := loadFlowSource(configPath, "flow", false, "", "")
+	if err != nil {
+		return fmt.Errorf("reading config path %q: %w", configPath, err)
+	}
+
+	lockfile := moduleverify.NewLockfile(moduleverify.LockfilePath(configPath))
+	if err := lockfile.Load(); err != nil {
+		return err
+	}
+
+	sources, err := flow.LockModules(ctx, source, lockfile, update)
+	if err != nil {
+		return err
+	}
+	if err := lockfile.Save(); err != nil {
+		return fmt.Errorf("writing lockfile: %w", err)
+	}
+
+	for _, source := range sources {
+		fmt.Fprintln(os.Stdout, source)
+	}
+	fmt.Fprintf(os.Stderr, "pinned %d module source(s) in %s\n", len(sources), lockfile.Path())
+	return nil
+}
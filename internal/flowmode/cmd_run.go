@@ -26,17 +26,18 @@ import (
 	"github.com/grafana/agent/internal/flow"
 	"github.com/grafana/agent/internal/flow/logging"
 	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/flow/moduleverify"
 	"github.com/grafana/agent/internal/flow/tracing"
 	"github.com/grafana/agent/internal/service"
 	httpservice "github.com/grafana/agent/internal/service/http"
 	"github.com/grafana/agent/internal/service/labelstore"
 	otel_service "github.com/grafana/agent/internal/service/otel"
 	remotecfgservice "github.com/grafana/agent/internal/service/remotecfg"
+	secretsservice "github.com/grafana/agent/internal/service/secrets"
 	uiservice "github.com/grafana/agent/internal/service/ui"
 	"github.com/grafana/agent/internal/usagestats"
 	"github.com/grafana/agent/static/config/instrumentation"
 	"github.com/grafana/ckit/advertise"
-	"github.com/grafana/ckit/peer"
 	"github.com/grafana/river/diag"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/spf13/cobra"
@@ -75,6 +76,10 @@ immediately.
 If path is a directory, all *.river files in that directory will be combined
 into a single unit. Subdirectories are not recursively searched for further merging.
 
+If a modules.lock file created by the modules lock subcommand exists next to the
+River file-path or inside of the River dir, modules retrieved by remote import
+blocks are rejected when their content doesn't match the lockfile.
+
 run starts an HTTP server which can be used to debug Grafana Agent Flow or
 force it to reload (by sending a GET or POST request to /-/reload). The listen
 address can be changed through the --server.http.listen-addr flag.
@@ -126,11 +131,38 @@ depending on the nature of the reload error.
 		IntVar(&r.ClusterMaxJoinPeers, "cluster.max-join-peers", r.ClusterMaxJoinPeers, "Number of peers to join from the discovered set")
 	cmd.Flags().
 		StringVar(&r.clusterName, "cluster.name", r.clusterName, "The name of the cluster to join")
+	cmd.Flags().
+		IntVar(&r.clusterWaitForSize, "cluster.wait-for-size", r.clusterWaitForSize, "Number of nodes the cluster must have before this node participates in it")
+	cmd.Flags().
+		DurationVar(&r.clusterWaitTimeout, "cluster.wait-timeout", r.clusterWaitTimeout, "How long to wait for the cluster to have --cluster.wait-for-size nodes; 0 waits forever")
+	cmd.Flags().
+		DurationVar(&r.clusterWarmupPeriod, "cluster.warmup-period", r.clusterWarmupPeriod, "How long to wait after joining the cluster before participating in it")
+	cmd.Flags().
+		StringVar(&r.clusterNodeZone, "cluster.node-zone", r.clusterNodeZone, "The availability zone of this node, used for zone-aware sharding")
+	cmd.Flags().
+		StringVar(&r.clusterNodeRack, "cluster.node-rack", r.clusterNodeRack, "The rack of this node")
+	cmd.Flags().
+		BoolVar(&r.clusterEnableTLS, "cluster.enable-tls", r.clusterEnableTLS, "Use mTLS between the nodes of the cluster")
+	cmd.Flags().
+		StringVar(&r.clusterTLSCAPath, "cluster.tls-ca-path", r.clusterTLSCAPath, "Path to the CA that must sign the certificates of peers")
+	cmd.Flags().
+		StringVar(&r.clusterTLSCertPath, "cluster.tls-cert-path", r.clusterTLSCertPath, "Path to the certificate presented to peers")
+	cmd.Flags().
+		StringVar(&r.clusterTLSKeyPath, "cluster.tls-key-path", r.clusterTLSKeyPath, "Path to the key of the certificate presented to peers")
+	cmd.Flags().
+		StringVar(&r.clusterTLSServerName, "cluster.tls-server-name", r.clusterTLSServerName, "Name used to verify the certificates of peers")
+	cmd.Flags().
+		StringSliceVar(&r.clusterTLSAllowedSANs, "cluster.tls-allowed-sans", r.clusterTLSAllowedSANs, "List of subject alternative names of the certificates of peers allowed in the cluster")
+	cmd.Flags().
+		StringSliceVar(&r.clusterTLSAllowedCNs, "cluster.tls-allowed-common-names", r.clusterTLSAllowedCNs, "List of common names of the certificates of peers allowed in the cluster")
+	cmd.Flags().
+		StringVar(&r.clusterSecretPath, "cluster.secret-path", r.clusterSecretPath, "Path to a secret shared by the nodes of the cluster to sign their requests")
 
 	// Config flags
 	cmd.Flags().StringVar(&r.configFormat, "config.format", r.configFormat, fmt.Sprintf("The format of the source file. Supported formats: %s.", supportedFormatsList()))
 	cmd.Flags().BoolVar(&r.configBypassConversionErrors, "config.bypass-conversion-errors", r.configBypassConversionErrors, "Enable bypassing errors when converting")
 	cmd.Flags().StringVar(&r.configExtraArgs, "config.extra-args", r.configExtraArgs, "Extra arguments from the original format used by the converter. Multiple arguments can be passed by separating them with a space.")
+	cmd.Flags().StringVar(&r.configProfile, "config.profile", r.configProfile, "Name of the profile whose files under the profiles directory overlay the config.")
 
 	// Misc flags
 	cmd.Flags().
@@ -156,9 +188,23 @@ type flowRun struct {
 	clusterRejoinInterval        time.Duration
 	ClusterMaxJoinPeers          int
 	clusterName                  string
+	clusterWaitForSize           int
+	clusterWaitTimeout           time.Duration
+	clusterWarmupPeriod          time.Duration
+	clusterNodeZone              string
+	clusterNodeRack              string
+	clusterEnableTLS             bool
+	clusterTLSCAPath             string
+	clusterTLSCertPath           string
+	clusterTLSKeyPath            string
+	clusterTLSServerName         string
+	clusterTLSAllowedSANs        []string
+	clusterTLSAllowedCNs         []string
+	clusterSecretPath            string
 	configFormat                 string
 	configBypassConversionErrors bool
 	configExtraArgs              string
+	configProfile                string
 }
 
 func (fr *flowRun) Run(configPath string) error {
@@ -236,6 +282,19 @@ func (fr *flowRun) Run(configPath string) error {
 		AdvertiseInterfaces: fr.clusterAdvInterfaces,
 		ClusterMaxJoinPeers: fr.ClusterMaxJoinPeers,
 		ClusterName:         fr.clusterName,
+		WaitForSize:         fr.clusterWaitForSize,
+		WaitTimeout:         fr.clusterWaitTimeout,
+		WarmupPeriod:        fr.clusterWarmupPeriod,
+		NodeZone:            fr.clusterNodeZone,
+		NodeRack:            fr.clusterNodeRack,
+		EnableTLS:           fr.clusterEnableTLS,
+		TLSCAPath:           fr.clusterTLSCAPath,
+		TLSCertPath:         fr.clusterTLSCertPath,
+		TLSKeyPath:          fr.clusterTLSKeyPath,
+		TLSServerName:       fr.clusterTLSServerName,
+		TLSAllowedSANs:      fr.clusterTLSAllowedSANs,
+		TLSAllowedCNs:       fr.clusterTLSAllowedCNs,
+		SecretPath:          fr.clusterSecretPath,
 	})
 	if err != nil {
 		return err
@@ -272,14 +331,18 @@ func (fr *flowRun) Run(configPath string) error {
 	}
 
 	labelService := labelstore.New(l, reg)
+	secretsService := secretsservice.New(secretsservice.Options{Logger: l})
 	agentseed.Init(fr.storagePath, l)
 
+	lockfile := moduleverify.NewLockfile(moduleverify.LockfilePath(configPath))
+
 	f := flow.New(flow.Options{
 		Logger:       l,
 		Tracer:       t,
 		DataPath:     fr.storagePath,
 		Reg:          reg,
 		MinStability: fr.minStability,
+		Lockfile:     lockfile,
 		Services: []service.Service{
 			httpService,
 			uiService,
@@ -287,18 +350,22 @@ func (fr *flowRun) Run(configPath string) error {
 			otelService,
 			labelService,
 			remoteCfgService,
+			secretsService,
 		},
 	})
 
 	ready = f.Ready
 	reload = func() (*flow.Source, error) {
-		flowSource, err := loadFlowSource(configPath, fr.configFormat, fr.configBypassConversionErrors, fr.configExtraArgs)
+		flowSource, err := loadFlowSource(configPath, fr.configFormat, fr.configBypassConversionErrors, fr.configExtraArgs, fr.configProfile)
 		defer instrumentation.InstrumentSHA256(flowSource.SHA256())
 		defer instrumentation.InstrumentLoad(err == nil)
 
 		if err != nil {
 			return nil, fmt.Errorf("reading config path %q: %w", configPath, err)
 		}
+		if err := lockfile.Load(); err != nil {
+			return nil, fmt.Errorf("reading lockfile: %w", err)
+		}
 		if err := f.LoadSource(flowSource, nil); err != nil {
 			return flowSource, fmt.Errorf("error during the initial grafana/agent load: %w", err)
 		}
@@ -354,12 +421,20 @@ func (fr *flowRun) Run(configPath string) error {
 
 	// By now, have either joined or started a new cluster.
 	// Nodes initially join in the Viewer state. After the graph has been
-	// loaded successfully, we can move to the Participant state to signal that
-	// we wish to participate in reading or writing data.
-	err = clusterService.ChangeState(ctx, peer.StateParticipant)
-	if err != nil {
-		return fmt.Errorf("failed to set clusterer state to Participant after initial load")
-	}
+	// loaded successfully and the cluster is ready, we can move to the
+	// Participant state to signal that we wish to participate in reading or
+	// writing data. Waiting for the cluster to be ready may take a while, so
+	// it's done in the background, and a failure stops the process like a
+	// failed initial load does.
+	participateErr := make(chan error, 1)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		if err := clusterService.Participate(ctx); err != nil && ctx.Err() == nil {
+			participateErr <- err
+		}
+	}()
 
 	reloadSignal := make(chan os.Signal, 1)
 	signal.Notify(reloadSignal, syscall.SIGHUP)
@@ -369,6 +444,8 @@ func (fr *flowRun) Run(configPath string) error {
 		select {
 		case <-ctx.Done():
 			return nil
+		case err := <-participateErr:
+			return fmt.Errorf("failed to set clusterer state to Participant after initial load: %w", err)
 		case <-reloadSignal:
 			if _, err := reload(); err != nil {
 				level.Error(l).Log("msg", "failed to reload config", "err", err)
@@ -391,39 +468,33 @@ func getEnabledComponentsFunc(f *flow.Flow) func() map[string]interface{} {
 	}
 }
 
-func loadFlowSource(path string, converterSourceFormat string, converterBypassErrors bool, configExtraArgs string) (*flow.Source, error) {
+func loadFlowSource(path string, converterSourceFormat string, converterBypassErrors bool, configExtraArgs string, profile string) (*flow.Source, error) {
 	fi, err := os.Stat(path)
 	if err != nil {
 		return nil, err
 	}
 
-	if fi.IsDir() {
-		sources := map[string][]byte{}
-		err := filepath.WalkDir(path, func(curPath string, d fs.DirEntry, err error) error {
-			if err != nil {
-				return err
-			}
-			// Skip all directories and don't recurse into child dirs that aren't at top-level
-			if d.IsDir() {
-				if curPath != path {
-					return filepath.SkipDir
-				}
-				return nil
-			}
-			// Ignore files not ending in .river extension
-			if !strings.HasSuffix(curPath, ".river") {
-				return nil
-			}
+	var overlay map[string][]byte
+	if profile != "" {
+		if converterSourceFormat != "flow" {
+			return nil, fmt.Errorf("profiles can't be used with the %s config format", converterSourceFormat)
+		}
+		profileDir := filepath.Join(path, "profiles", profile)
+		if !fi.IsDir() {
+			profileDir = filepath.Join(filepath.Dir(path), "profiles", profile)
+		}
+		overlay, err = readRiverDir(profileDir)
+		if err != nil {
+			return nil, fmt.Errorf("reading profile %q: %w", profile, err)
+		}
+	}
 
-			bb, err := os.ReadFile(curPath)
-			sources[curPath] = bb
-			return err
-		})
+	if fi.IsDir() {
+		sources, err := readRiverDir(path)
 		if err != nil {
 			return nil, err
 		}
-
-		return flow.ParseSources(sources)
+		return flow.ParseSourcesWithOverlay(sources, overlay)
 	}
 
 	bb, err := os.ReadFile(path)
@@ -447,9 +518,42 @@ func loadFlowSource(path string, converterSourceFormat string, converterBypassEr
 
 	instrumentation.InstrumentConfig(bb)
 
+	if overlay != nil {
+		return flow.ParseSourcesWithOverlay(map[string][]byte{path: bb}, overlay)
+	}
 	return flow.ParseSource(path, bb)
 }
 
+// readRiverDir reads the .river files at the top level of the directory at
+// path.
+func readRiverDir(path string) (map[string][]byte, error) {
+	sources := map[string][]byte{}
+	err := filepath.WalkDir(path, func(curPath string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// Skip all directories and don't recurse into child dirs that aren't at top-level
+		if d.IsDir() {
+			if curPath != path {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		// Ignore files not ending in .river extension
+		if !strings.HasSuffix(curPath, ".river") {
+			return nil
+		}
+
+		bb, err := os.ReadFile(curPath)
+		sources[curPath] = bb
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sources, nil
+}
+
 func interruptContext() (context.Context, context.CancelFunc) {
 	ctx, cancel := context.WithCancel(context.Background())
 
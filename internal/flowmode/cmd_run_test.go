@@ -0,0 +1,48 @@
+package flowmode
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadFlowSource_Profile(t *testing.T) {
+	dir := t.TempDir()
+	writeFile := func(name, content string) {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+	}
+	writeFile("config.river", `
+logging {
+	level = "info"
+}
+
+prometheus.exporter.self "default" { }
+`)
+	writeFile("profiles/prod/logging.river", `
+logging {
+	level = "warn"
+}
+
+overlay.remove {
+	block = "prometheus.exporter.self.default"
+}
+`)
+
+	for _, path := range []string{dir, filepath.Join(dir, "config.river")} {
+		source, err := loadFlowSource(path, "flow", false, "", "prod")
+		require.NoError(t, err)
+		rendered, err := source.Render()
+		require.NoError(t, err)
+		require.Equal(t, "logging {\n\tlevel = \"warn\"\n}\n", string(rendered))
+	}
+
+	_, err := loadFlowSource(dir, "flow", false, "", "missing")
+	require.ErrorContains(t, err, `reading profile "missing"`)
+
+	_, err = loadFlowSource(filepath.Join(dir, "config.river"), "prometheus", false, "", "prod")
+	require.ErrorContains(t, err, "profiles can't be used with the prometheus config format")
+}
@@ -0,0 +1,394 @@
+package flowmode
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/fatih/color"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow"
+	"github.com/grafana/agent/internal/flow/logging"
+	"github.com/grafana/agent/internal/service"
+	httpservice "github.com/grafana/agent/internal/service/http"
+	"github.com/grafana/agent/internal/service/labelstore"
+	otel_service "github.com/grafana/agent/internal/service/otel"
+	remotecfgservice "github.com/grafana/agent/internal/service/remotecfg"
+	secretsservice "github.com/grafana/agent/internal/service/secrets"
+	uiservice "github.com/grafana/agent/internal/service/ui"
+	"github.com/grafana/river/diag"
+	"github.com/grafana/river/token"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/spf13/cobra"
+)
+
+// Output formats supported by the validate command.
+const (
+	validateOutputText  = "text"
+	validateOutputJSON  = "json"
+	validateOutputSARIF = "sarif"
+)
+
+func validateCommand() *cobra.Command {
+	v := &flowValidate{
+		minStability: featuregate.StabilityExperimental,
+		configFormat: "flow",
+		output:       validateOutputText,
+	}
+
+	cmd := &cobra.Command{
+		Use:   "validate [flags] path",
+		Short: "Validate a configuration file",
+		Long: `The validate subcommand checks the configuration at the specified
+River dir/file-path without running any component.
+
+The configuration is loaded by the component controller in the same way as the
+run subcommand does, so validate reports unknown components, invalid references,
+type mismatches, cycles and invalid arguments. Modules imported by import
+blocks are retrieved and validated as well. Components are never built nor run.
+
+The --stability.level flag reports the components and blocks which are below
+the given minimum stability level.
+
+The --output flag sets the format of the diagnostics: text, json, or sarif. The
+json and sarif formats can be used to annotate files in CI pipelines.
+
+validate exits with a non-zero status if the configuration contains errors.`,
+		Args:         cobra.ExactArgs(1),
+		SilenceUsage: true,
+
+		RunE: func(_ *cobra.Command, args []string) error {
+			return v.Run(os.Stdout, args[0])
+		},
+	}
+
+	cmd.Flags().Var(&v.minStability, "stability.level", fmt.Sprintf("Minimum stability level of features to allow. Supported values: %s", strings.Join(featuregate.AllowedValues(), ", ")))
+	cmd.Flags().StringVarP(&v.output, "output", "o", v.output, "Format of the diagnostics. Supported formats: text, json, sarif.")
+	cmd.Flags().StringVar(&v.configFormat, "config.format", v.configFormat, fmt.Sprintf("The format of the source file. Supported formats: %s.", supportedFormatsList()))
+	cmd.Flags().BoolVar(&v.configBypassConversionErrors, "config.bypass-conversion-errors", v.configBypassConversionErrors, "Enable bypassing errors when converting")
+	cmd.Flags().StringVar(&v.configExtraArgs, "config.extra-args", v.configExtraArgs, "Extra arguments from the original format used by the converter. Multiple arguments can be passed by separating them with a space.")
+	cmd.Flags().StringVar(&v.configProfile, "config.profile", v.configProfile, "Name of the profile whose files under the profiles directory overlay the config.")
+	return cmd
+}
+
+type flowValidate struct {
+	minStability                 featuregate.Stability
+	output                       string
+	configFormat                 string
+	configBypassConversionErrors bool
+	configExtraArgs              string
+	configProfile                string
+}
+
+// Run validates the configuration at configPath and writes the diagnostics
+// to w.
+func (fv *flowValidate) Run(w io.Writer, configPath string) error {
+	switch fv.output {
+	case validateOutputText, validateOutputJSON, validateOutputSARIF:
+	default:
+		return fmt.Errorf("unsupported output format %q", fv.output)
+	}
+
+	files, diags, err := fv.validate(configPath)
+	if err != nil {
+		return err
+	}
+
+	switch fv.output {
+	case validateOutputText:
+		p := diag.NewPrinter(diag.PrinterConfig{
+			Color:              !color.NoColor,
+			ContextLinesBefore: 1,
+			ContextLinesAfter:  1,
+		})
+		if err := p.Fprint(w, files, diags); err != nil {
+			return err
+		}
+	case validateOutputJSON:
+		if err := writeDiagnosticsJSON(w, diags); err != nil {
+			return err
+		}
+	case validateOutputSARIF:
+		if err := writeDiagnosticsSARIF(w, diags); err != nil {
+			return err
+		}
+	}
+
+	if diags.HasErrors() {
+		return fmt.Errorf("configuration %q is invalid", configPath)
+	}
+	return nil
+}
+
+// validate loads the configuration at configPath in a controller which
+// doesn't build components, and returns the content of the configuration
+// files and the diagnostics.
+func (fv *flowValidate) validate(configPath string) (map[string][]byte, diag.Diagnostics, error) {
+	source, err := loadFlowSource(configPath, fv.configFormat, fv.configBypassConversionErrors, fv.configExtraArgs, fv.configProfile)
+	if err != nil {
+		var diags diag.Diagnostics
+		if errors.As(err, &diags) {
+			return nil, diags, nil
+		}
+		return nil, nil, err
+	}
+
+	diags, err := validateSource(source, fv.minStability)
+	if err != nil {
+		return nil, nil, err
+	}
+	return source.RawConfigs(), diags, nil
+}
+
+// validateSource loads source in a controller which doesn't build
+// components, and returns the diagnostics of the loader.
+func validateSource(source *flow.Source, minStability featuregate.Stability) (diag.Diagnostics, error) {
+	dataPath, err := os.MkdirTemp("", "agent-validate-*")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dataPath)
+
+	logger, err := logging.New(io.Discard, logging.DefaultOptions)
+	if err != nil {
+		return nil, err
+	}
+	services, err := validationServices(logger, dataPath)
+	if err != nil {
+		return nil, err
+	}
+
+	f := flow.New(flow.Options{
+		Logger:       logger,
+		DataPath:     dataPath,
+		Reg:          prometheus.NewRegistry(),
+		MinStability: minStability,
+		DryRun:       true,
+		Services:     services,
+	})
+	if err := f.LoadSource(source, nil); err != nil {
+		var diags diag.Diagnostics
+		if errors.As(err, &diags) {
+			return diags, nil
+		}
+		return nil, err
+	}
+	return nil, nil
+}
+
+// validationServices returns the services of the run command. Only the
+// definitions of the services are used, so that the blocks configuring them
+// are validated without running them.
+func validationServices(l *logging.Logger, dataPath string) ([]service.Service, error) {
+	reg := prometheus.NewRegistry()
+
+	clusterService, err := buildClusterService(clusterOptions{
+		Log:           l,
+		Metrics:       reg,
+		NodeName:      "validate",
+		ListenAddress: "127.0.0.1:12345",
+	})
+	if err != nil {
+		return nil, err
+	}
+	remoteCfgService, err := remotecfgservice.New(remotecfgservice.Options{
+		Logger:      l,
+		StoragePath: dataPath,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	services := []service.Service{
+		httpservice.New(httpservice.Options{Logger: l, Gatherer: reg}),
+		uiservice.New(uiservice.Options{}),
+		clusterService,
+		otel_service.New(l),
+		labelstore.New(l, reg),
+		remoteCfgService,
+	}
+	for i, svc := range services {
+		services[i] = definitionService{def: svc.Definition()}
+	}
+	// The secrets service exposes functions to the config, so it's used in dry
+	// run mode instead of its definition.
+	services = append(services, secretsservice.New(secretsservice.Options{Logger: l, DryRun: true}))
+	return services, nil
+}
+
+// definitionService is a service which only exposes the definition of another
+// service.
+type definitionService struct {
+	def service.Definition
+}
+
+var _ service.Service = definitionService{}
+
+func (s definitionService) Definition() service.Definition { return s.def }
+
+func (s definitionService) Run(ctx context.Context, _ service.Host) error {
+	<-ctx.Done()
+	return nil
+}
+
+func (s definitionService) Update(any) error { return nil }
+
+func (s definitionService) Data() any { return nil }
+
+// jsonDiagnostic is the JSON representation of a diagnostic.
+type jsonDiagnostic struct {
+	Severity string        `json:"severity"`
+	Message  string        `json:"message"`
+	Value    string        `json:"value,omitempty"`
+	File     string        `json:"file,omitempty"`
+	Start    *jsonPosition `json:"start,omitempty"`
+	End      *jsonPosition `json:"end,omitempty"`
+}
+
+type jsonPosition struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func writeDiagnosticsJSON(w io.Writer, diags diag.Diagnostics) error {
+	out := make([]jsonDiagnostic, 0, len(diags))
+	for _, d := range diags {
+		jd := jsonDiagnostic{
+			Severity: severityName(d.Severity),
+			Message:  d.Message,
+			Value:    d.Value,
+			File:     d.StartPos.Filename,
+		}
+		if d.StartPos.Valid() {
+			jd.Start = &jsonPosition{Line: d.StartPos.Line, Column: d.StartPos.Column}
+			end := diagEndPos(d)
+			jd.End = &jsonPosition{Line: end.Line, Column: end.Column}
+		}
+		out = append(out, jd)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+// sarifRuleID is the ID of the single rule reported in SARIF logs.
+const sarifRuleID = "invalid-config"
+
+func writeDiagnosticsSARIF(w io.Writer, diags diag.Diagnostics) error {
+	type (
+		sarifMessage struct {
+			Text string `json:"text"`
+		}
+		sarifRegion struct {
+			StartLine   int `json:"startLine"`
+			StartColumn int `json:"startColumn"`
+			EndLine     int `json:"endLine"`
+			EndColumn   int `json:"endColumn"`
+		}
+		sarifArtifactLocation struct {
+			URI string `json:"uri"`
+		}
+		sarifPhysicalLocation struct {
+			ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+			Region           *sarifRegion          `json:"region,omitempty"`
+		}
+		sarifLocation struct {
+			PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+		}
+		sarifResult struct {
+			RuleID    string          `json:"ruleId"`
+			Level     string          `json:"level"`
+			Message   sarifMessage    `json:"message"`
+			Locations []sarifLocation `json:"locations,omitempty"`
+		}
+		sarifRule struct {
+			ID               string       `json:"id"`
+			ShortDescription sarifMessage `json:"shortDescription"`
+		}
+		sarifDriver struct {
+			Name           string      `json:"name"`
+			InformationURI string      `json:"informationUri"`
+			Rules          []sarifRule `json:"rules"`
+		}
+		sarifTool struct {
+			Driver sarifDriver `json:"driver"`
+		}
+		sarifRun struct {
+			Tool    sarifTool     `json:"tool"`
+			Results []sarifResult `json:"results"`
+		}
+		sarifLog struct {
+			Schema  string     `json:"$schema"`
+			Version string     `json:"version"`
+			Runs    []sarifRun `json:"runs"`
+		}
+	)
+
+	results := make([]sarifResult, 0, len(diags))
+	for _, d := range diags {
+		result := sarifResult{
+			RuleID:  sarifRuleID,
+			Level:   severityName(d.Severity),
+			Message: sarifMessage{Text: d.Message},
+		}
+		if d.StartPos.Filename != "" {
+			loc := sarifLocation{PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: d.StartPos.Filename},
+			}}
+			if d.StartPos.Valid() {
+				end := diagEndPos(d)
+				loc.PhysicalLocation.Region = &sarifRegion{
+					StartLine:   d.StartPos.Line,
+					StartColumn: d.StartPos.Column,
+					EndLine:     end.Line,
+					EndColumn:   end.Column,
+				}
+			}
+			result.Locations = []sarifLocation{loc}
+		}
+		results = append(results, result)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(sarifLog{
+		Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+		Version: "2.1.0",
+		Runs: []sarifRun{{
+			Tool: sarifTool{Driver: sarifDriver{
+				Name:           "grafana-agent-flow",
+				InformationURI: "https://grafana.com/docs/agent/latest/flow/reference/cli/validate/",
+				Rules: []sarifRule{{
+					ID:               sarifRuleID,
+					ShortDescription: sarifMessage{Text: "The configuration is invalid"},
+				}},
+			}},
+			Results: results,
+		}},
+	})
+}
+
+// severityName returns the name of a severity level, which matches the SARIF
+// result levels.
+func severityName(s diag.Severity) string {
+	switch s {
+	case diag.SeverityLevelWarn:
+		return "warning"
+	default:
+		return "error"
+	}
+}
+
+// diagEndPos returns the end position of d, which is its start position if
+// it has no end position.
+func diagEndPos(d diag.Diagnostic) token.Position {
+	if d.EndPos.Valid() {
+		return d.EndPos
+	}
+	return d.StartPos
+}
@@ -0,0 +1,144 @@
+package flowmode
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	tt := []struct {
+		name         string
+		config       string
+		minStability featuregate.Stability
+		expectError  string // Expected message of the diagnostic, if any.
+	}{
+		{
+			name: "valid",
+			config: `
+				prometheus.remote_write "default" {
+					endpoint {
+						url = "http://localhost:9009/api/prom/push"
+					}
+				}
+
+				prometheus.scrape "default" {
+					targets    = [{"__address__" = "localhost:12345"}]
+					forward_to = [prometheus.remote_write.default.receiver]
+				}
+			`,
+		},
+		{
+			name:        "unknown component",
+			config:      `prometheus.does_not_exist "default" {}`,
+			expectError: `cannot find the definition of component name "prometheus.does_not_exist"`,
+		},
+		{
+			name: "bad reference",
+			config: `
+				prometheus.scrape "default" {
+					targets    = []
+					forward_to = [prometheus.remote_write.missing.receiver]
+				}
+			`,
+			expectError: `component "prometheus.remote_write.missing.receiver" does not exist or is out of scope`,
+		},
+		{
+			name: "type mismatch",
+			config: `
+				prometheus.scrape "default" {
+					targets    = "localhost:12345"
+					forward_to = []
+				}
+			`,
+			expectError: `"localhost:12345" should be array, got string`,
+		},
+		{
+			name:         "below minimum stability",
+			config:       `prometheus.receive_graphite "default" { forward_to = [] }`,
+			minStability: featuregate.StabilityStable,
+			expectError:  `component "prometheus.receive_graphite" is at stability level "experimental", which is below the minimum allowed stability level "stable"`,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.river")
+			require.NoError(t, os.WriteFile(path, []byte(tc.config), 0o644))
+
+			v := &flowValidate{
+				minStability: tc.minStability,
+				configFormat: "flow",
+				output:       validateOutputJSON,
+			}
+			if v.minStability == featuregate.StabilityUndefined {
+				v.minStability = featuregate.StabilityExperimental
+			}
+
+			var buf bytes.Buffer
+			err := v.Run(&buf, path)
+
+			var diags []jsonDiagnostic
+			require.NoError(t, json.Unmarshal(buf.Bytes(), &diags))
+			if tc.expectError == "" {
+				require.NoError(t, err)
+				require.Empty(t, diags)
+				return
+			}
+
+			require.Error(t, err)
+			require.NotEmpty(t, diags)
+			require.Equal(t, "error", diags[0].Severity)
+			require.Contains(t, diags[0].Message, tc.expectError)
+			require.Equal(t, path, diags[0].File)
+			require.NotNil(t, diags[0].Start)
+		})
+	}
+}
+
+func TestValidate_SARIF(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.river")
+	require.NoError(t, os.WriteFile(path, []byte("\nprometheus.does_not_exist \"default\" {}\n"), 0o644))
+
+	v := &flowValidate{
+		minStability: featuregate.StabilityExperimental,
+		configFormat: "flow",
+		output:       validateOutputSARIF,
+	}
+	var buf bytes.Buffer
+	require.Error(t, v.Run(&buf, path))
+
+	var log struct {
+		Version string `json:"version"`
+		Runs    []struct {
+			Results []struct {
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+						Region struct {
+							StartLine int `json:"startLine"`
+						} `json:"region"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &log))
+	require.Equal(t, "2.1.0", log.Version)
+	require.Len(t, log.Runs, 1)
+	require.Len(t, log.Runs[0].Results, 1)
+
+	result := log.Runs[0].Results[0]
+	require.Equal(t, "error", result.Level)
+	require.Len(t, result.Locations, 1)
+	require.Equal(t, path, result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+	require.Equal(t, 2, result.Locations[0].PhysicalLocation.Region.StartLine)
+}
@@ -26,8 +26,11 @@ func Run() {
 	cmd.AddCommand(
 		convertCommand(),
 		fmtCommand(),
+		lspCommand(),
+		modulesCommand(),
 		runCommand(),
 		toolsCommand(),
+		validateCommand(),
 	)
 
 	if err := cmd.Execute(); err != nil {
@@ -0,0 +1,440 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// TLSOptions configures mTLS between the nodes of a cluster. Nodes connect to
+// each other over TLS, and present the certificate of CertFile and KeyFile to
+// their peers. The certificates of peers must be signed by the CA of CAFile.
+//
+// The TLS settings of the HTTP server, which serves the requests of peers,
+// are set by the tls block of the http service. The server must request
+// client certificates for the cluster service to verify them.
+type TLSOptions struct {
+	CAFile   string // CA that must sign the certificates of peers.
+	CertFile string // Certificate presented to peers.
+	KeyFile  string // Key of the certificate presented to peers.
+
+	// ServerName is used to verify the certificates of the peers this node
+	// connects to. When empty, the host of the address of the peer is used.
+	ServerName string
+
+	// AllowedSANs and AllowedCommonNames restrict the peers allowed in the
+	// cluster. A peer is allowed if one of the subject alternative names of its
+	// certificate is in AllowedSANs, or if the common name of its certificate
+	// is in AllowedCommonNames. When both are empty, all peers with a
+	// certificate signed by the CA are allowed.
+	AllowedSANs        []string
+	AllowedCommonNames []string
+}
+
+const (
+	// signatureHeader, timestampHeader and nonceHeader hold the HMAC of
+	// requests between nodes when a shared secret is used.
+	signatureHeader = "X-Cluster-Signature"
+	timestampHeader = "X-Cluster-Timestamp"
+	nonceHeader     = "X-Cluster-Nonce"
+
+	// maxSignatureAge is the maximum difference between the timestamp of a
+	// signed request and the clock of the node receiving it.
+	maxSignatureAge = 5 * time.Minute
+
+	// maxSignedBodySize is the maximum size of the body of a signed request.
+	maxSignedBodySize = 64 << 20
+
+	// streamEndpoint is the endpoint of ckit for streams between peers, whose
+	// bodies are written while the request is sent and can't be hashed before
+	// signing it. Streams are signed with unsignedBody instead of the hash of
+	// their body; their nonce still prevents replaying them.
+	streamEndpoint = "/api/v1/ckit/transport/stream"
+	unsignedBody   = "UNSIGNED-STREAM"
+
+	// rejectLogInterval is the minimum interval between two logs of rejected
+	// requests.
+	rejectLogInterval = 10 * time.Second
+)
+
+// Reasons for rejecting the requests of peers, used as the value of the
+// reason label of the rejected requests metric.
+const (
+	reasonTLSRequired          = "tls_required"
+	reasonMissingCertificate   = "missing_certificate"
+	reasonUntrustedCertificate = "untrusted_certificate"
+	reasonNameNotAllowed       = "name_not_allowed"
+	reasonMissingSignature     = "missing_signature"
+	reasonExpiredSignature     = "expired_signature"
+	reasonInvalidSignature     = "invalid_signature"
+	reasonReplayedSignature    = "replayed_signature"
+)
+
+// peerAuth authenticates the requests between the nodes of a cluster, with
+// mTLS, a shared secret, or both.
+type peerAuth struct {
+	log    log.Logger
+	tls    *TLSOptions
+	caPool *x509.CertPool
+	secret []byte
+	now    func() time.Time
+	nonces *nonceCache
+
+	rejectedRequests *prometheus.CounterVec
+
+	logMut     sync.Mutex
+	lastLog    time.Time
+	suppressed int // Rejected requests not logged since lastLog.
+}
+
+func newPeerAuth(l log.Logger, tlsOpts *TLSOptions, secret []byte) (*peerAuth, error) {
+	a := &peerAuth{
+		log:    l,
+		tls:    tlsOpts,
+		secret: secret,
+		now:    time.Now,
+		nonces: newNonceCache(),
+
+		rejectedRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "cluster_node_rejected_requests_total",
+			Help: "Total number of requests of peers rejected because they failed authentication.",
+		}, []string{"reason"}),
+	}
+
+	if tlsOpts != nil {
+		// The CA is read once so that it's pinned for the lifetime of the node,
+		// while certificates are read again on every handshake so that they can
+		// be rotated.
+		caPEM, err := os.ReadFile(tlsOpts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		a.caPool = x509.NewCertPool()
+		if !a.caPool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in CA file %q", tlsOpts.CAFile)
+		}
+	}
+
+	return a, nil
+}
+
+// dial connects to the peer at addr, over TLS when mTLS is enabled.
+func (a *peerAuth) dial(ctx context.Context, network, addr string, timeout time.Duration) (net.Conn, error) {
+	conn, err := net.DialTimeout(network, addr, timeout)
+	if err != nil || a.tls == nil {
+		return conn, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	tlsConn := tls.Client(conn, a.clientTLSConfig(addr))
+	if err := tlsConn.HandshakeContext(ctx); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if proto := tlsConn.ConnectionState().NegotiatedProtocol; proto != http2Proto {
+		conn.Close()
+		return nil, fmt.Errorf("peer %s doesn't support HTTP/2 over TLS", addr)
+	}
+	return tlsConn, nil
+}
+
+const http2Proto = "h2"
+
+// clientTLSConfig returns the TLS config used to connect to the peer at addr.
+func (a *peerAuth) clientTLSConfig(addr string) *tls.Config {
+	serverName := a.tls.ServerName
+	if serverName == "" {
+		serverName, _, _ = net.SplitHostPort(addr)
+	}
+
+	return &tls.Config{
+		ServerName: serverName,
+		RootCAs:    a.caPool,
+		NextProtos: []string{http2Proto},
+
+		GetClientCertificate: func(_ *tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, err := tls.LoadX509KeyPair(a.tls.CertFile, a.tls.KeyFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to load cluster certificate: %w", err)
+			}
+			return &cert, nil
+		},
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			if !a.allowedName(cs.PeerCertificates[0]) {
+				return fmt.Errorf("certificate of peer %s isn't allowed in the cluster", addr)
+			}
+			return nil
+		},
+	}
+}
+
+// transport wraps next to send requests to peers over HTTPS when mTLS is
+// enabled, and to sign them when a shared secret is used.
+func (a *peerAuth) transport(next http.RoundTripper) http.RoundTripper {
+	if a.tls == nil && len(a.secret) == 0 {
+		return next
+	}
+	return &authTransport{next: next, auth: a}
+}
+
+type authTransport struct {
+	next http.RoundTripper
+	auth *peerAuth
+}
+
+// RoundTrip implements [http.RoundTripper].
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+
+	if t.auth.tls != nil {
+		// ckit always uses the http scheme, but peers only expose the TLS state
+		// of the connection to handlers for https requests.
+		req.URL.Scheme = "https"
+	}
+	if len(t.auth.secret) > 0 {
+		bodyHash := unsignedBody
+		if req.URL.Path != streamEndpoint {
+			var body []byte
+			if req.Body != nil {
+				var err error
+				body, err = io.ReadAll(req.Body)
+				req.Body.Close()
+				if err != nil {
+					return nil, fmt.Errorf("failed to read request body: %w", err)
+				}
+				req.Body = io.NopCloser(bytes.NewReader(body))
+				req.ContentLength = int64(len(body))
+			}
+			bodyHash = hashBody(body)
+		}
+
+		nonce := make([]byte, 16)
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, fmt.Errorf("failed to generate nonce: %w", err)
+		}
+		ts := strconv.FormatInt(t.auth.now().Unix(), 10)
+		req.Header.Set(timestampHeader, ts)
+		req.Header.Set(nonceHeader, hex.EncodeToString(nonce))
+		req.Header.Set(signatureHeader, t.auth.sign(req.Method, req.URL.Path, ts, hex.EncodeToString(nonce), bodyHash))
+	}
+	return t.next.RoundTrip(req)
+}
+
+// hashBody returns the hex-encoded SHA-256 of body.
+func hashBody(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign returns the HMAC of a request with the shared secret. bodyHash is the
+// value returned by hashBody for the body of the request, or unsignedBody for
+// streams.
+func (a *peerAuth) sign(method, path, ts, nonce, bodyHash string) string {
+	mac := hmac.New(sha256.New, a.secret)
+	fmt.Fprintf(mac, "%s\n%s\n%s\n%s\n%s", method, path, ts, nonce, bodyHash)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// handler wraps next to reject the requests of peers which fail
+// authentication.
+func (a *peerAuth) handler(next http.Handler) http.Handler {
+	if a.tls == nil && len(a.secret) == 0 {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if reason, err := a.authenticate(r); err != nil {
+			a.rejectedRequests.WithLabelValues(reason).Inc()
+			a.logRejected(r, reason, err)
+			http.Error(w, "peer authentication failed", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// logRejected logs a rejected request. At most one request is logged every
+// rejectLogInterval, so that a misconfigured or malicious peer can't flood
+// the logs; the number of requests rejected in between is logged with the
+// next one.
+func (a *peerAuth) logRejected(r *http.Request, reason string, err error) {
+	a.logMut.Lock()
+	defer a.logMut.Unlock()
+
+	now := a.now()
+	if !a.lastLog.IsZero() && now.Sub(a.lastLog) < rejectLogInterval {
+		a.suppressed++
+		return
+	}
+	level.Warn(a.log).Log("msg", "rejected request of cluster peer", "remote_addr", r.RemoteAddr, "reason", reason, "err", err, "suppressed", a.suppressed)
+	a.lastLog = now
+	a.suppressed = 0
+}
+
+// authenticate checks the certificate and the signature of the request of a
+// peer. It returns the reason for rejecting the request on failure.
+func (a *peerAuth) authenticate(r *http.Request) (reason string, err error) {
+	if a.tls != nil {
+		if reason, err := a.verifyCertificate(r.TLS); err != nil {
+			return reason, err
+		}
+	}
+	if len(a.secret) > 0 {
+		if reason, err := a.verifySignature(r); err != nil {
+			return reason, err
+		}
+	}
+	return "", nil
+}
+
+func (a *peerAuth) verifyCertificate(state *tls.ConnectionState) (reason string, err error) {
+	if state == nil {
+		return reasonTLSRequired, fmt.Errorf("request wasn't sent over TLS")
+	}
+	if len(state.PeerCertificates) == 0 {
+		return reasonMissingCertificate, fmt.Errorf("no client certificate presented; the tls block of the http service must request client certificates")
+	}
+
+	leaf := state.PeerCertificates[0]
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	_, err = leaf.Verify(x509.VerifyOptions{
+		Roots:         a.caPool,
+		Intermediates: intermediates,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	})
+	if err != nil {
+		return reasonUntrustedCertificate, err
+	}
+
+	if !a.allowedName(leaf) {
+		return reasonNameNotAllowed, fmt.Errorf("certificate with common name %q isn't allowed in the cluster", leaf.Subject.CommonName)
+	}
+	return "", nil
+}
+
+// allowedName returns whether the names of cert are in the allowed lists.
+func (a *peerAuth) allowedName(cert *x509.Certificate) bool {
+	if len(a.tls.AllowedSANs) == 0 && len(a.tls.AllowedCommonNames) == 0 {
+		return true
+	}
+
+	for _, name := range a.tls.AllowedCommonNames {
+		if cert.Subject.CommonName == name {
+			return true
+		}
+	}
+
+	sans := append([]string(nil), cert.DNSNames...)
+	sans = append(sans, cert.EmailAddresses...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	for _, uri := range cert.URIs {
+		sans = append(sans, uri.String())
+	}
+	for _, name := range a.tls.AllowedSANs {
+		for _, san := range sans {
+			if san == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (a *peerAuth) verifySignature(r *http.Request) (reason string, err error) {
+	var (
+		signature = r.Header.Get(signatureHeader)
+		ts        = r.Header.Get(timestampHeader)
+		nonce     = r.Header.Get(nonceHeader)
+	)
+	if signature == "" || ts == "" || nonce == "" {
+		return reasonMissingSignature, fmt.Errorf("request isn't signed")
+	}
+
+	sec, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return reasonInvalidSignature, fmt.Errorf("invalid timestamp %q", ts)
+	}
+	sentAt := time.Unix(sec, 0)
+	if age := a.now().Sub(sentAt); age > maxSignatureAge || age < -maxSignatureAge {
+		return reasonExpiredSignature, fmt.Errorf("timestamp of signature is %s away from the clock of the node", age)
+	}
+
+	bodyHash := unsignedBody
+	if r.URL.Path != streamEndpoint {
+		body, err := io.ReadAll(http.MaxBytesReader(nil, r.Body, maxSignedBodySize))
+		if err != nil {
+			return reasonInvalidSignature, fmt.Errorf("failed to read request body: %w", err)
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		bodyHash = hashBody(body)
+	}
+
+	expected := a.sign(r.Method, r.URL.Path, ts, nonce, bodyHash)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return reasonInvalidSignature, fmt.Errorf("signature doesn't match the shared secret")
+	}
+
+	// Nonces are remembered until the timestamp of their request expires, after
+	// which the request is rejected anyway.
+	if !a.nonces.add(nonce, a.now(), sentAt.Add(maxSignatureAge)) {
+		return reasonReplayedSignature, fmt.Errorf("nonce of signature was already used")
+	}
+	return "", nil
+}
+
+// nonceCache holds the nonces of the signed requests received by a node.
+type nonceCache struct {
+	mut       sync.Mutex
+	expires   map[string]time.Time
+	lastPrune time.Time
+}
+
+func newNonceCache() *nonceCache {
+	return &nonceCache{expires: make(map[string]time.Time)}
+}
+
+// add records nonce until expires, and returns false if nonce was already
+// recorded.
+func (c *nonceCache) add(nonce string, now, expires time.Time) bool {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	if now.Sub(c.lastPrune) >= time.Minute {
+		for n, exp := range c.expires {
+			if now.After(exp) {
+				delete(c.expires, n)
+			}
+		}
+		c.lastPrune = now
+	}
+
+	if exp, seen := c.expires[nonce]; seen && !now.After(exp) {
+		return false
+	}
+	c.expires[nonce] = expires
+	return true
+}
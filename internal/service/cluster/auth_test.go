@@ -0,0 +1,367 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/hex"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+func TestPeerAuth_TLS(t *testing.T) {
+	dir := t.TempDir()
+	ca, caKey := writeTestCA(t, dir)
+	writeTestCert(t, dir, "node-a", ca, caKey)
+	writeTestCert(t, dir, "node-b", ca, caKey)
+
+	otherCA, otherKey := writeTestCA(t, t.TempDir())
+	writeTestCert(t, dir, "untrusted", otherCA, otherKey)
+
+	tt := []struct {
+		name          string
+		clientCert    string
+		allowedCNs    []string
+		allowedSANs   []string
+		expectAllowed bool
+		expectReason  string
+	}{
+		{
+			name:          "signed by CA",
+			clientCert:    "node-b",
+			expectAllowed: true,
+		},
+		{
+			name:          "allowed common name",
+			clientCert:    "node-b",
+			allowedCNs:    []string{"node-a", "node-b"},
+			expectAllowed: true,
+		},
+		{
+			name:          "allowed SAN",
+			clientCert:    "node-b",
+			allowedSANs:   []string{"node-b.cluster.local"},
+			expectAllowed: true,
+		},
+		{
+			name:         "name not allowed",
+			clientCert:   "node-b",
+			allowedCNs:   []string{"node-a"},
+			expectReason: reasonNameNotAllowed,
+		},
+		{
+			name:         "not signed by CA",
+			clientCert:   "untrusted",
+			expectReason: reasonUntrustedCertificate,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			serverAuth, err := newPeerAuth(log.NewNopLogger(), &TLSOptions{
+				CAFile:             filepath.Join(dir, "ca.crt"),
+				CertFile:           filepath.Join(dir, "node-a.crt"),
+				KeyFile:            filepath.Join(dir, "node-a.key"),
+				AllowedCommonNames: tc.allowedCNs,
+				AllowedSANs:        tc.allowedSANs,
+			}, nil)
+			require.NoError(t, err)
+
+			// The server requests client certificates without verifying them,
+			// like the http service with the RequireAnyClientCert client auth type.
+			srv := httptest.NewUnstartedServer(serverAuth.handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})))
+			serverCert, err := tls.LoadX509KeyPair(filepath.Join(dir, "node-a.crt"), filepath.Join(dir, "node-a.key"))
+			require.NoError(t, err)
+			srv.EnableHTTP2 = true
+			srv.TLS = &tls.Config{
+				Certificates: []tls.Certificate{serverCert},
+				ClientAuth:   tls.RequireAnyClientCert,
+			}
+			srv.StartTLS()
+			defer srv.Close()
+
+			clientAuth, err := newPeerAuth(log.NewNopLogger(), &TLSOptions{
+				CAFile:   filepath.Join(dir, "ca.crt"),
+				CertFile: filepath.Join(dir, tc.clientCert+".crt"),
+				KeyFile:  filepath.Join(dir, tc.clientCert+".key"),
+			}, nil)
+			require.NoError(t, err)
+
+			resp, err := testClient(clientAuth).Get("http://" + srv.Listener.Addr().String())
+			require.NoError(t, err)
+			resp.Body.Close()
+
+			if tc.expectAllowed {
+				require.Equal(t, http.StatusOK, resp.StatusCode)
+				return
+			}
+			require.Equal(t, http.StatusUnauthorized, resp.StatusCode)
+			require.Equal(t, 1.0, testutil.ToFloat64(serverAuth.rejectedRequests.WithLabelValues(tc.expectReason)))
+		})
+	}
+}
+
+func TestPeerAuth_TLSRequired(t *testing.T) {
+	dir := t.TempDir()
+	writeTestCA(t, dir)
+
+	auth, err := newPeerAuth(log.NewNopLogger(), &TLSOptions{CAFile: filepath.Join(dir, "ca.crt")}, nil)
+	require.NoError(t, err)
+
+	rec := httptest.NewRecorder()
+	auth.handler(http.NotFoundHandler()).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/api/v1/ckit/transport/message", nil))
+	require.Equal(t, http.StatusUnauthorized, rec.Code)
+	require.Equal(t, 1.0, testutil.ToFloat64(auth.rejectedRequests.WithLabelValues(reasonTLSRequired)))
+}
+
+func TestPeerAuth_SharedSecret(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+
+	serverAuth, err := newPeerAuth(log.NewNopLogger(), nil, []byte("secret"))
+	require.NoError(t, err)
+	serverAuth.now = func() time.Time { return now }
+
+	handler := serverAuth.handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Handlers can still read the body after it was verified.
+		body, _ := io.ReadAll(r.Body)
+		require.Equal(t, "message", string(body))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	replayed := signedTestRequest(t, "secret", now, "/api/v1/ckit/transport/message", "message")
+	handler.ServeHTTP(httptest.NewRecorder(), replayed.Clone(context.Background()))
+
+	tt := []struct {
+		name         string
+		req          func() *http.Request
+		expectReason string
+	}{
+		{
+			name: "valid signature",
+			req: func() *http.Request {
+				return signedTestRequest(t, "secret", now, "/api/v1/ckit/transport/message", "message")
+			},
+		},
+		{
+			name: "unsigned",
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/api/v1/ckit/transport/message", strings.NewReader("message"))
+			},
+			expectReason: reasonMissingSignature,
+		},
+		{
+			name: "different secret",
+			req: func() *http.Request {
+				return signedTestRequest(t, "other", now, "/api/v1/ckit/transport/message", "message")
+			},
+			expectReason: reasonInvalidSignature,
+		},
+		{
+			name: "expired",
+			req: func() *http.Request {
+				return signedTestRequest(t, "secret", now.Add(-time.Hour), "/api/v1/ckit/transport/message", "message")
+			},
+			expectReason: reasonExpiredSignature,
+		},
+		{
+			name: "changed body",
+			req: func() *http.Request {
+				req := signedTestRequest(t, "secret", now, "/api/v1/ckit/transport/message", "message")
+				req.Body = io.NopCloser(strings.NewReader("tampered"))
+				return req
+			},
+			expectReason: reasonInvalidSignature,
+		},
+		{
+			name: "replayed nonce",
+			req: func() *http.Request {
+				req := replayed.Clone(context.Background())
+				req.Body = io.NopCloser(strings.NewReader("message"))
+				return req
+			},
+			expectReason: reasonReplayedSignature,
+		},
+	}
+
+	for _, tc := range tt {
+		t.Run(tc.name, func(t *testing.T) {
+			rejected := testutil.ToFloat64(serverAuth.rejectedRequests.WithLabelValues(tc.expectReason))
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, tc.req())
+
+			if tc.expectReason == "" {
+				require.Equal(t, http.StatusOK, rec.Code)
+				return
+			}
+			require.Equal(t, http.StatusUnauthorized, rec.Code)
+			require.Equal(t, rejected+1, testutil.ToFloat64(serverAuth.rejectedRequests.WithLabelValues(tc.expectReason)))
+		})
+	}
+}
+
+// signedTestRequest returns a request signed with secret at sentAt.
+func signedTestRequest(t *testing.T, secret string, sentAt time.Time, path, body string) *http.Request {
+	t.Helper()
+
+	var (
+		clientAuth = &peerAuth{secret: []byte(secret)}
+		ts         = strconv.FormatInt(sentAt.Unix(), 10)
+		nonce      = make([]byte, 16)
+	)
+	_, err := rand.Read(nonce)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+	req.Header.Set(timestampHeader, ts)
+	req.Header.Set(nonceHeader, hex.EncodeToString(nonce))
+	req.Header.Set(signatureHeader, clientAuth.sign(req.Method, path, ts, hex.EncodeToString(nonce), hashBody([]byte(body))))
+	return req
+}
+
+func TestPeerAuth_LogRejected(t *testing.T) {
+	var (
+		buf = &bytes.Buffer{}
+		now = time.Unix(1700000000, 0)
+	)
+	auth, err := newPeerAuth(log.NewLogfmtLogger(buf), nil, []byte("secret"))
+	require.NoError(t, err)
+	auth.now = func() time.Time { return now }
+
+	handler := auth.handler(http.NotFoundHandler())
+	send := func() {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/ckit/transport/message", nil)
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	// Only the first rejected request of an interval is logged.
+	for i := 0; i < 5; i++ {
+		send()
+	}
+	require.Equal(t, 1, strings.Count(buf.String(), "rejected request of cluster peer"))
+
+	now = now.Add(rejectLogInterval)
+	send()
+	require.Equal(t, 2, strings.Count(buf.String(), "rejected request of cluster peer"))
+	require.Contains(t, buf.String(), "suppressed=4")
+	require.Equal(t, 6.0, testutil.ToFloat64(auth.rejectedRequests.WithLabelValues(reasonMissingSignature)))
+}
+
+func TestPeerAuth_SigningTransport(t *testing.T) {
+	auth, err := newPeerAuth(log.NewNopLogger(), nil, []byte("secret"))
+	require.NoError(t, err)
+
+	srv := httptest.NewServer(auth.handler(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})))
+	defer srv.Close()
+
+	client := &http.Client{Transport: auth.transport(http.DefaultTransport)}
+	resp, err := client.Post(srv.URL+"/api/v1/ckit/transport/message", "application/octet-stream", strings.NewReader("message"))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// The bodies of streams aren't hashed, since they're written while the
+	// request is sent.
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("stream"))
+		pw.Close()
+	}()
+	resp, err = client.Post(srv.URL+streamEndpoint, "application/octet-stream", pr)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+}
+
+// testClient returns a client which connects to peers like the client of the
+// cluster service.
+func testClient(auth *peerAuth) *http.Client {
+	return &http.Client{
+		Transport: auth.transport(&http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return auth.dial(ctx, network, addr, 5*time.Second)
+			},
+		}),
+	}
+}
+
+func writeTestCA(t *testing.T, dir string) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "cluster-ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	writePEM(t, filepath.Join(dir, "ca.crt"), "CERTIFICATE", der)
+	return cert, key
+}
+
+// writeTestCert writes a certificate for name, valid for both client and
+// server authentication on 127.0.0.1.
+func writeTestCert(t *testing.T, dir, name string, ca *x509.Certificate, caKey *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		DNSNames:     []string{name + ".cluster.local"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, &key.PublicKey, caKey)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	writePEM(t, filepath.Join(dir, name+".crt"), "CERTIFICATE", der)
+	writePEM(t, filepath.Join(dir, name+".key"), "EC PRIVATE KEY", keyDER)
+}
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	bb := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	require.NoError(t, os.WriteFile(path, bb, 0600))
+}
@@ -65,6 +65,25 @@ type Options struct {
 	ClusterMaxJoinPeers int           // Number of initial peers to join from the discovered set.
 	ClusterName         string        // Name to prevent nodes without this identifier from joining the cluster.
 
+	// TLS enables mTLS between the nodes of the cluster. When TLS is nil, nodes
+	// connect to each other over HTTP/2 without TLS.
+	TLS *TLSOptions
+
+	// WaitForSize, WaitTimeout, and WarmupPeriod delay the move of the node to
+	// the participant state in [Service.Participate], so that the node doesn't
+	// own keys until the cluster is stable.
+	WaitForSize  int           // Number of nodes the cluster must have. Disabled when 0.
+	WaitTimeout  time.Duration // How long to wait for WaitForSize nodes. Waits forever when 0.
+	WarmupPeriod time.Duration // How long to wait after joining the cluster.
+
+	// Topology describes where the node runs. Peers in the same zone are
+	// preferred to own the keys of the zone with zone-aware sharding.
+	Topology Topology
+
+	// SharedSecret, when set, authenticates the requests between nodes with an
+	// HMAC signed with the secret. All nodes must use the same secret.
+	SharedSecret []byte
+
 	// Function to discover peers to join. If this function is nil or returns an
 	// empty slice, no peers will be joined.
 	DiscoverPeers func() ([]string, error)
@@ -76,9 +95,16 @@ type Service struct {
 	tracer trace.TracerProvider
 	opts   Options
 
-	sharder shard.Sharder
-	node    *ckit.Node
-	randGen *rand.Rand
+	sharder     shard.Sharder
+	node        *ckit.Node
+	auth        *peerAuth
+	httpClient  *http.Client
+	zones       *zoneSharders
+	fetches     *topologyFetches
+	kv          *kvStore
+	randGen     *rand.Rand
+	subscribers *peerSubscribers
+	readiness   readiness
 }
 
 var (
@@ -107,8 +133,14 @@ func New(opts Options) (*Service, error) {
 		Label:         opts.ClusterName,
 	}
 
+	auth, err := newPeerAuth(l, opts.TLS, opts.SharedSecret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure peer authentication: %w", err)
+	}
+
 	httpClient := &http.Client{
-		Transport: &http2.Transport{
+		Transport: auth.transport(&http2.Transport{
+			// When mTLS is enabled, TLS is negotiated by auth.dial.
 			AllowHTTP: true,
 			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
 				// Set a maximum timeout for establishing the connection. If our
@@ -121,9 +153,9 @@ func New(opts Options) (*Service, error) {
 					timeout = dur
 				}
 
-				return net.DialTimeout(network, addr, timeout)
+				return auth.dial(ctx, network, addr, timeout)
 			},
-		},
+		}),
 	}
 
 	node, err := ckit.NewNode(httpClient, ckitConfig)
@@ -134,6 +166,9 @@ func New(opts Options) (*Service, error) {
 		if err := opts.Metrics.Register(node.Metrics()); err != nil {
 			return nil, fmt.Errorf("failed to register metrics: %w", err)
 		}
+		if err := opts.Metrics.Register(auth.rejectedRequests); err != nil {
+			return nil, fmt.Errorf("failed to register metrics: %w", err)
+		}
 	}
 
 	return &Service{
@@ -141,9 +176,15 @@ func New(opts Options) (*Service, error) {
 		tracer: t,
 		opts:   opts,
 
-		sharder: ckitConfig.Sharder,
-		node:    node,
-		randGen: rand.New(rand.NewSource(time.Now().UnixNano())),
+		sharder:     ckitConfig.Sharder,
+		node:        node,
+		auth:        auth,
+		httpClient:  httpClient,
+		zones:       newZoneSharders(),
+		fetches:     newTopologyFetches(),
+		kv:          newKVStore(opts.NodeName),
+		randGen:     rand.New(rand.NewSource(time.Now().UnixNano())),
+		subscribers: newPeerSubscribers(),
 	}, nil
 }
 
@@ -168,9 +209,17 @@ func (s *Service) Definition() service.Definition {
 }
 
 // ServiceHandler returns the service handler for the clustering service. The
-// resulting handler always returns 404 when clustering is disabled.
+// resulting handler always returns 404 when clustering is disabled, and
+// rejects the requests of peers which fail authentication.
 func (s *Service) ServiceHandler(host service.Host) (base string, handler http.Handler) {
-	base, handler = s.node.Handler()
+	ckitBase, ckitHandler := s.node.Handler()
+
+	mux := http.NewServeMux()
+	mux.Handle(ckitBase, ckitHandler)
+	mux.HandleFunc(topologyEndpoint, s.topologyHandler)
+	mux.HandleFunc(kvEndpoint, s.kvHandler)
+
+	base, handler = "/api/v1/ckit/", s.auth.handler(mux)
 
 	if !s.opts.EnableClustering {
 		handler = http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
@@ -200,6 +249,10 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 	var wg sync.WaitGroup
 	defer wg.Wait()
 
+	// Pending fetches of the topology of peers notify components, so they
+	// must exit before Run returns.
+	defer s.stopTopologyFetches()
+
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
 
@@ -209,38 +262,21 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 			return false
 		}
 
-		tracer := s.tracer.Tracer("")
-		spanCtx, span := tracer.Start(ctx, "NotifyClusterChange", trace.WithSpanKind(trace.SpanKindInternal))
-		defer span.End()
-
 		names := make([]string, len(peers))
 		for i, p := range peers {
 			names[i] = p.Name
 		}
 		level.Info(s.log).Log("msg", "peers changed", "new_peers", strings.Join(names, ","))
 
-		// Notify all components about the clustering change.
-		components := component.GetAllComponents(host, component.InfoOptions{})
-		for _, component := range components {
-			if ctx.Err() != nil {
-				// Stop early if we exited so we don't do unnecessary work notifying
-				// consumers that do not need to be notified.
-				break
-			}
-
-			clusterComponent, ok := component.Component.(Component)
-			if !ok {
-				continue
-			}
-
-			_, span := tracer.Start(spanCtx, "NotifyClusterChange", trace.WithSpanKind(trace.SpanKindInternal))
-			span.SetAttributes(attribute.String("component_id", component.ID.String()))
-
-			clusterComponent.NotifyClusterChange()
-
-			span.End()
-		}
+		// Components sharding keys by zone need the topology of new peers,
+		// which is fetched in the background. Components are notified again
+		// once it's known.
+		s.updateTopologies(ctx, peers, func() {
+			level.Debug(s.log).Log("msg", "fetched topology of peer, notifying components")
+			s.notifyClusterChange(ctx, host)
+		})
 
+		s.notifyClusterChange(ctx, host)
 		return true
 	}))
 
@@ -260,6 +296,15 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 			return fmt.Errorf("failed to bootstrap a fresh cluster with no peers: %w", err)
 		}
 	}
+	s.readiness.setJoined(time.Now())
+
+	if s.opts.EnableClustering {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runKVGossip(ctx)
+		}()
+	}
 
 	if s.opts.EnableClustering && s.opts.RejoinInterval > 0 {
 		wg.Add(1)
@@ -296,6 +341,38 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 	return nil
 }
 
+// notifyClusterChange notifies all components and the owners of singleton
+// components about a change of the cluster.
+func (s *Service) notifyClusterChange(ctx context.Context, host service.Host) {
+	tracer := s.tracer.Tracer("")
+	spanCtx, span := tracer.Start(ctx, "NotifyClusterChange", trace.WithSpanKind(trace.SpanKindInternal))
+	defer span.End()
+
+	components := component.GetAllComponents(host, component.InfoOptions{})
+	for _, component := range components {
+		if ctx.Err() != nil {
+			// Stop early if we exited so we don't do unnecessary work notifying
+			// consumers that do not need to be notified.
+			break
+		}
+
+		clusterComponent, ok := component.Component.(Component)
+		if !ok {
+			continue
+		}
+
+		_, span := tracer.Start(spanCtx, "NotifyClusterChange", trace.WithSpanKind(trace.SpanKindInternal))
+		span.SetAttributes(attribute.String("component_id", component.ID.String()))
+
+		clusterComponent.NotifyClusterChange()
+
+		span.End()
+	}
+
+	// Notify the owners of singleton components, which may have changed.
+	s.subscribers.notify()
+}
+
 func (s *Service) getPeers() ([]string, error) {
 	if !s.opts.EnableClustering || s.opts.DiscoverPeers == nil {
 		return nil, nil
@@ -342,9 +419,16 @@ func (s *Service) Update(newConfig any) error {
 	return fmt.Errorf("cluster service does not support configuration")
 }
 
-// Data returns an instance of [Cluster].
+// Data returns an instance of [Cluster], which also implements [Singletons],
+// [Zones], [ReadinessReporter], [KV], and [RingInspector].
 func (s *Service) Data() any {
-	return &sharderCluster{sharder: s.sharder}
+	return &sharderCluster{
+		KV:          s.kv,
+		sharder:     s.sharder,
+		subscribers: s.subscribers,
+		zones:       s.zones,
+		readiness:   s.Readiness,
+	}
 }
 
 // Component is a Flow component which subscribes to clustering updates.
@@ -383,9 +467,23 @@ type Cluster interface {
 
 // sharderCluster shims an implementation of [shard.Sharder] to [Cluster] which
 // removes the ability to change peers.
-type sharderCluster struct{ sharder shard.Sharder }
+type sharderCluster struct {
+	KV
+
+	sharder     shard.Sharder
+	subscribers *peerSubscribers
+	zones       *zoneSharders
+	readiness   func() Readiness
+}
 
-var _ Cluster = (*sharderCluster)(nil)
+var (
+	_ Cluster           = (*sharderCluster)(nil)
+	_ Singletons        = (*sharderCluster)(nil)
+	_ Zones             = (*sharderCluster)(nil)
+	_ ReadinessReporter = (*sharderCluster)(nil)
+	_ KV                = (*sharderCluster)(nil)
+	_ RingInspector     = (*sharderCluster)(nil)
+)
 
 func (sc *sharderCluster) Lookup(key shard.Key, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
 	return sc.sharder.Lookup(key, replicationFactor, op)
@@ -0,0 +1,594 @@
+package cluster
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/ckit/peer"
+)
+
+// KV is an eventually consistent key/value store shared by the nodes of the
+// cluster, meant for small amounts of state such as checkpoints or counters.
+// The data of the cluster service implements KV.
+//
+// Writes are applied locally and gossiped to peers in the background, so
+// reads on other nodes may return stale values for a few seconds. Concurrent
+// writes are merged without coordination:
+//
+//   - Registers hold a string. The last write wins, ordered by the wall clock
+//     time of the writes, and then by the name of the node which wrote them.
+//   - Counters hold an integer. The increments of all the nodes are summed.
+//
+// Registers and counters have separate keys. Keys are shared by all the
+// components of the cluster, so components should prefix their keys with
+// their ID. Registers and counters which aren't written for kvEntryTTL
+// expire.
+type KV interface {
+	// Get returns the value of a register.
+	Get(key string) (value string, ok bool)
+	// Set sets the value of a register.
+	Set(key, value string)
+	// Delete deletes a register.
+	Delete(key string)
+
+	// Add adds delta to a counter, and returns its new value.
+	Add(key string, delta int64) int64
+	// Counter returns the value of a counter, which is 0 for counters which
+	// were never incremented.
+	Counter(key string) int64
+
+	// Entries returns the registers and counters of the store, sorted by key.
+	Entries() []KVEntry
+}
+
+// KVEntry describes a key of a [KV] store.
+type KVEntry struct {
+	Key       string    `json:"key"`
+	Type      string    `json:"type"` // "register" or "counter".
+	Value     string    `json:"value"`
+	UpdatedBy string    `json:"updatedBy,omitempty"` // Node which last set a register.
+	UpdatedAt time.Time `json:"updatedAt,omitempty"` // Time a register was last set.
+}
+
+const (
+	// kvEndpoint exchanges the state of the KV store with peers.
+	kvEndpoint = "/api/v1/ckit/kv"
+
+	// kvGossipFanout is the number of peers the state of the KV store is
+	// exchanged with on every gossip round.
+	kvGossipFanout = 3
+
+	// kvTombstoneTTL is how long deleted registers are remembered, so that the
+	// deletion wins over stale values of peers.
+	kvTombstoneTTL = time.Hour
+
+	// kvEntryTTL is how long registers and counters are kept after their last
+	// write.
+	kvEntryTTL = 24 * time.Hour
+)
+
+// kvGossipInterval is how often the state of the KV store is exchanged with
+// peers.
+var kvGossipInterval = 2 * time.Second
+
+// kvRegister is a last-writer-wins register.
+type kvRegister struct {
+	Value     string `json:"value,omitempty"`
+	Deleted   bool   `json:"deleted,omitempty"`
+	Timestamp int64  `json:"timestamp"` // Unix nanoseconds of the write.
+	Node      string `json:"node"`      // Node which wrote the value.
+}
+
+// newerThan returns whether r wins over other.
+func (r kvRegister) newerThan(other kvRegister) bool {
+	if r.Timestamp != other.Timestamp {
+		return r.Timestamp > other.Timestamp
+	}
+	return r.Node > other.Node
+}
+
+// kvCounter is a PN-counter: the increments and decrements of every node
+// are tracked separately, and only grow.
+type kvCounter map[string]kvCounterNode
+
+type kvCounterNode struct {
+	Inc     int64 `json:"inc,omitempty"`
+	Dec     int64 `json:"dec,omitempty"`
+	Updated int64 `json:"updated,omitempty"` // Unix nanoseconds of the last write.
+}
+
+func (c kvCounter) value() int64 {
+	var sum int64
+	for _, n := range c {
+		sum += n.Inc - n.Dec
+	}
+	return sum
+}
+
+// updated returns the time of the last write to the counter by any node.
+func (c kvCounter) updated() int64 {
+	var latest int64
+	for _, n := range c {
+		latest = max(latest, n.Updated)
+	}
+	return latest
+}
+
+// hash returns a hash of the state of the counter, which is the same on all
+// the nodes with the same state.
+func (c kvCounter) hash() uint64 {
+	nodes := make([]string, 0, len(c))
+	for node := range c {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	h := xxhash.New()
+	for _, node := range nodes {
+		n := c[node]
+		fmt.Fprintf(h, "%s\x00%d\x00%d\x00%d\x00", node, n.Inc, n.Dec, n.Updated)
+	}
+	return h.Sum64()
+}
+
+// kvState is the state of a KV store exchanged between peers.
+type kvState struct {
+	Registers map[string]kvRegister `json:"registers,omitempty"`
+	Counters  map[string]kvCounter  `json:"counters,omitempty"`
+}
+
+// kvDigest summarizes a kvState, so that peers only exchange the entries
+// which differ. Registers are summarized by the version of their last write,
+// and counters by a hash of their state.
+type kvDigest struct {
+	Registers map[string]kvVersion `json:"registers,omitempty"`
+	Counters  map[string]uint64    `json:"counters,omitempty"`
+}
+
+type kvVersion struct {
+	Timestamp int64  `json:"timestamp"`
+	Node      string `json:"node"`
+}
+
+// kvKeys lists keys of a KV store.
+type kvKeys struct {
+	Registers []string `json:"registers,omitempty"`
+	Counters  []string `json:"counters,omitempty"`
+}
+
+func (k kvKeys) empty() bool { return len(k.Registers) == 0 && len(k.Counters) == 0 }
+
+// kvExchangeRequest is sent to a peer to gossip the state of the KV store.
+// A gossip round first sends the digest of the local store. The peer responds
+// with the entries which are newer on its side, and the keys it needs, which
+// are then sent in a second request.
+type kvExchangeRequest struct {
+	Digest *kvDigest `json:"digest,omitempty"`
+	State  *kvState  `json:"state,omitempty"`
+}
+
+// kvExchangeResponse answers a kvExchangeRequest with a digest.
+type kvExchangeResponse struct {
+	State kvState `json:"state"` // Entries the sender of the digest is missing.
+	Want  kvKeys  `json:"want"`  // Keys to send back.
+}
+
+// kvStore implements [KV].
+type kvStore struct {
+	node string // Name of the local node.
+	now  func() time.Time
+
+	mut   sync.RWMutex
+	state kvState
+}
+
+var _ KV = (*kvStore)(nil)
+
+func newKVStore(node string) *kvStore {
+	return &kvStore{
+		node: node,
+		now:  time.Now,
+		state: kvState{
+			Registers: make(map[string]kvRegister),
+			Counters:  make(map[string]kvCounter),
+		},
+	}
+}
+
+func (kv *kvStore) Get(key string) (string, bool) {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	r, ok := kv.state.Registers[key]
+	if !ok || r.Deleted {
+		return "", false
+	}
+	return r.Value, true
+}
+
+func (kv *kvStore) Set(key, value string) {
+	kv.write(key, kvRegister{Value: value})
+}
+
+func (kv *kvStore) Delete(key string) {
+	kv.write(key, kvRegister{Deleted: true})
+}
+
+func (kv *kvStore) write(key string, r kvRegister) {
+	kv.mut.Lock()
+	defer kv.mut.Unlock()
+
+	// Local writes must win over the current value, even if the clock of the
+	// node which wrote it is ahead.
+	r.Timestamp = kv.now().UnixNano()
+	if prev, ok := kv.state.Registers[key]; ok && prev.Timestamp >= r.Timestamp {
+		r.Timestamp = prev.Timestamp + 1
+	}
+	r.Node = kv.node
+	kv.state.Registers[key] = r
+}
+
+func (kv *kvStore) Add(key string, delta int64) int64 {
+	kv.mut.Lock()
+	defer kv.mut.Unlock()
+
+	c, ok := kv.state.Counters[key]
+	if !ok {
+		c = make(kvCounter)
+		kv.state.Counters[key] = c
+	}
+	n := c[kv.node]
+	n.Updated = kv.now().UnixNano()
+	if delta >= 0 {
+		n.Inc += delta
+	} else {
+		n.Dec -= delta
+	}
+	c[kv.node] = n
+	return c.value()
+}
+
+func (kv *kvStore) Counter(key string) int64 {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+	return kv.state.Counters[key].value()
+}
+
+func (kv *kvStore) Entries() []KVEntry {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	entries := make([]KVEntry, 0, len(kv.state.Registers)+len(kv.state.Counters))
+	for key, r := range kv.state.Registers {
+		if r.Deleted {
+			continue
+		}
+		entries = append(entries, KVEntry{
+			Key:       key,
+			Type:      "register",
+			Value:     r.Value,
+			UpdatedBy: r.Node,
+			UpdatedAt: time.Unix(0, r.Timestamp),
+		})
+	}
+	for key, c := range kv.state.Counters {
+		entries = append(entries, KVEntry{
+			Key:   key,
+			Type:  "counter",
+			Value: strconv.FormatInt(c.value(), 10),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Key != entries[j].Key {
+			return entries[i].Key < entries[j].Key
+		}
+		return entries[i].Type < entries[j].Type
+	})
+	return entries
+}
+
+// snapshot returns a copy of the state of the store.
+func (kv *kvStore) snapshot() kvState {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	state := kvState{
+		Registers: make(map[string]kvRegister, len(kv.state.Registers)),
+		Counters:  make(map[string]kvCounter, len(kv.state.Counters)),
+	}
+	for key, r := range kv.state.Registers {
+		state.Registers[key] = r
+	}
+	for key, c := range kv.state.Counters {
+		state.Counters[key] = c.clone()
+	}
+	return state
+}
+
+// digest returns the digest of the state of the store.
+func (kv *kvStore) digest() kvDigest {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	d := kvDigest{
+		Registers: make(map[string]kvVersion, len(kv.state.Registers)),
+		Counters:  make(map[string]uint64, len(kv.state.Counters)),
+	}
+	for key, r := range kv.state.Registers {
+		d.Registers[key] = kvVersion{Timestamp: r.Timestamp, Node: r.Node}
+	}
+	for key, c := range kv.state.Counters {
+		d.Counters[key] = c.hash()
+	}
+	return d
+}
+
+// diff compares the store with the digest of a peer. It returns the entries
+// which are missing or older on the peer, and the keys which are missing or
+// older locally. Counters which differ are both sent and requested, since
+// their states are merged.
+func (kv *kvStore) diff(d kvDigest) (delta kvState, want kvKeys) {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	delta = kvState{
+		Registers: make(map[string]kvRegister),
+		Counters:  make(map[string]kvCounter),
+	}
+
+	for key, r := range kv.state.Registers {
+		v, ok := d.Registers[key]
+		remote := kvRegister{Timestamp: v.Timestamp, Node: v.Node}
+		switch {
+		case !ok || r.newerThan(remote):
+			delta.Registers[key] = r
+		case remote.newerThan(r):
+			want.Registers = append(want.Registers, key)
+		}
+	}
+	for key := range d.Registers {
+		if _, ok := kv.state.Registers[key]; !ok {
+			want.Registers = append(want.Registers, key)
+		}
+	}
+
+	for key, c := range kv.state.Counters {
+		h, ok := d.Counters[key]
+		if ok && h == c.hash() {
+			continue
+		}
+		delta.Counters[key] = c.clone()
+		if ok {
+			want.Counters = append(want.Counters, key)
+		}
+	}
+	for key := range d.Counters {
+		if _, ok := kv.state.Counters[key]; !ok {
+			want.Counters = append(want.Counters, key)
+		}
+	}
+	return delta, want
+}
+
+// subset returns a copy of the entries of the store with the given keys.
+func (kv *kvStore) subset(keys kvKeys) kvState {
+	kv.mut.RLock()
+	defer kv.mut.RUnlock()
+
+	state := kvState{
+		Registers: make(map[string]kvRegister, len(keys.Registers)),
+		Counters:  make(map[string]kvCounter, len(keys.Counters)),
+	}
+	for _, key := range keys.Registers {
+		if r, ok := kv.state.Registers[key]; ok {
+			state.Registers[key] = r
+		}
+	}
+	for _, key := range keys.Counters {
+		if c, ok := kv.state.Counters[key]; ok {
+			state.Counters[key] = c.clone()
+		}
+	}
+	return state
+}
+
+// merge merges the state of a peer into the store. Expired entries are
+// ignored, so that peers which haven't expired them yet don't bring them
+// back.
+func (kv *kvStore) merge(state kvState) {
+	kv.mut.Lock()
+	defer kv.mut.Unlock()
+
+	now := kv.now()
+	for key, r := range state.Registers {
+		if r.expired(now) {
+			continue
+		}
+		if prev, ok := kv.state.Registers[key]; !ok || r.newerThan(prev) {
+			kv.state.Registers[key] = r
+		}
+	}
+	for key, c := range state.Counters {
+		if c.expired(now) {
+			continue
+		}
+		local, ok := kv.state.Counters[key]
+		if !ok {
+			local = make(kvCounter, len(c))
+			kv.state.Counters[key] = local
+		}
+		for node, n := range c {
+			prev := local[node]
+			local[node] = kvCounterNode{
+				Inc:     max(prev.Inc, n.Inc),
+				Dec:     max(prev.Dec, n.Dec),
+				Updated: max(prev.Updated, n.Updated),
+			}
+		}
+	}
+}
+
+// removeExpired forgets the registers deleted more than kvTombstoneTTL ago,
+// and the registers and counters written more than kvEntryTTL ago.
+func (kv *kvStore) removeExpired() {
+	kv.mut.Lock()
+	defer kv.mut.Unlock()
+
+	now := kv.now()
+	for key, r := range kv.state.Registers {
+		if r.expired(now) {
+			delete(kv.state.Registers, key)
+		}
+	}
+	for key, c := range kv.state.Counters {
+		if c.expired(now) {
+			delete(kv.state.Counters, key)
+		}
+	}
+}
+
+func (r kvRegister) expired(now time.Time) bool {
+	ttl := kvEntryTTL
+	if r.Deleted {
+		ttl = kvTombstoneTTL
+	}
+	return r.Timestamp < now.Add(-ttl).UnixNano()
+}
+
+func (c kvCounter) expired(now time.Time) bool {
+	return c.updated() < now.Add(-kvEntryTTL).UnixNano()
+}
+
+func (c kvCounter) clone() kvCounter {
+	copied := make(kvCounter, len(c))
+	for node, n := range c {
+		copied[node] = n
+	}
+	return copied
+}
+
+// kvHandler merges the entries of the KV store sent by a peer, and responds
+// to a digest with the entries the peer is missing and the keys it must send
+// back.
+func (s *Service) kvHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	var req kvExchangeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, fmt.Sprintf("decoding request: %s", err), http.StatusBadRequest)
+		return
+	}
+	if req.State != nil {
+		s.kv.merge(*req.State)
+	}
+
+	var resp kvExchangeResponse
+	if req.Digest != nil {
+		resp.State, resp.Want = s.kv.diff(*req.Digest)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(resp)
+}
+
+// runKVGossip exchanges the state of the KV store with random peers until
+// ctx is canceled.
+func (s *Service) runKVGossip(ctx context.Context) {
+	t := time.NewTicker(kvGossipInterval)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			s.kv.removeExpired()
+			s.gossipKV(ctx)
+		}
+	}
+}
+
+// gossipKV exchanges the state of the KV store with up to kvGossipFanout
+// random peers.
+func (s *Service) gossipKV(ctx context.Context) {
+	var peers []peer.Peer
+	for _, p := range s.node.Peers() {
+		if !p.Self {
+			peers = append(peers, p)
+		}
+	}
+	rand.Shuffle(len(peers), func(i, j int) { peers[i], peers[j] = peers[j], peers[i] })
+	if len(peers) > kvGossipFanout {
+		peers = peers[:kvGossipFanout]
+	}
+
+	for _, p := range peers {
+		if err := s.exchangeKV(ctx, p.Addr); err != nil {
+			level.Debug(s.log).Log("msg", "failed to exchange key/value state with peer", "peer", p.Name, "err", err)
+		}
+	}
+}
+
+// exchangeKV runs a gossip round with the peer at addr: the peer receives
+// the digest of the local store, and only the entries which differ are sent
+// in either direction.
+func (s *Service) exchangeKV(ctx context.Context, addr string) error {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	digest := s.kv.digest()
+	resp, err := s.postKV(ctx, addr, kvExchangeRequest{Digest: &digest})
+	if err != nil {
+		return err
+	}
+	s.kv.merge(resp.State)
+
+	if resp.Want.empty() {
+		return nil
+	}
+	state := s.kv.subset(resp.Want)
+	_, err = s.postKV(ctx, addr, kvExchangeRequest{State: &state})
+	return err
+}
+
+func (s *Service) postKV(ctx context.Context, addr string, exchange kvExchangeRequest) (kvExchangeResponse, error) {
+	bb, err := json.Marshal(exchange)
+	if err != nil {
+		return kvExchangeResponse{}, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, "http://"+addr+kvEndpoint, bytes.NewReader(bb))
+	if err != nil {
+		return kvExchangeResponse{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return kvExchangeResponse{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return kvExchangeResponse{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var res kvExchangeResponse
+	if err := json.NewDecoder(resp.Body).Decode(&res); err != nil {
+		return kvExchangeResponse{}, fmt.Errorf("decoding response: %w", err)
+	}
+	return res, nil
+}
@@ -0,0 +1,246 @@
+package cluster
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestKVStore(t *testing.T) {
+	t.Run("last writer wins", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		now := time.Now()
+		a.now = func() time.Time { return now }
+		b.now = func() time.Time { return now.Add(time.Second) }
+
+		a.Set("key", "from a")
+		b.Set("key", "from b")
+
+		a.merge(b.snapshot())
+		b.merge(a.snapshot())
+
+		for _, kv := range []*kvStore{a, b} {
+			v, ok := kv.Get("key")
+			require.True(t, ok)
+			require.Equal(t, "from b", v)
+		}
+	})
+
+	t.Run("ties broken by node name", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		now := time.Now()
+		a.now = func() time.Time { return now }
+		b.now = a.now
+
+		a.Set("key", "from a")
+		b.Set("key", "from b")
+
+		a.merge(b.snapshot())
+		b.merge(a.snapshot())
+
+		for _, kv := range []*kvStore{a, b} {
+			v, _ := kv.Get("key")
+			require.Equal(t, "from b", v)
+		}
+	})
+
+	t.Run("local writes win over clock skew", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		b.now = func() time.Time { return time.Now().Add(time.Hour) }
+
+		b.Set("key", "from b")
+		a.merge(b.snapshot())
+		a.Set("key", "from a")
+
+		b.merge(a.snapshot())
+		v, _ := b.Get("key")
+		require.Equal(t, "from a", v)
+	})
+
+	t.Run("deletes", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		a.Set("key", "value")
+		b.merge(a.snapshot())
+		b.Delete("key")
+
+		// The deletion wins over the stale value of a.
+		b.merge(a.snapshot())
+		a.merge(b.snapshot())
+		for _, kv := range []*kvStore{a, b} {
+			_, ok := kv.Get("key")
+			require.False(t, ok)
+			require.Empty(t, kv.Entries())
+		}
+
+		// Tombstones are forgotten after kvTombstoneTTL.
+		a.removeExpired()
+		require.Len(t, a.snapshot().Registers, 1)
+		a.now = func() time.Time { return time.Now().Add(2 * kvTombstoneTTL) }
+		a.removeExpired()
+		require.Empty(t, a.snapshot().Registers)
+	})
+
+	t.Run("expiry", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		a.Set("key", "value")
+		a.Add("count", 1)
+		b.merge(a.snapshot())
+
+		a.removeExpired()
+		require.Len(t, a.Entries(), 2)
+
+		// Registers and counters which aren't written expire.
+		later := time.Now().Add(kvEntryTTL + time.Minute)
+		a.now = func() time.Time { return later }
+		a.removeExpired()
+		require.Empty(t, a.Entries())
+
+		// Peers which haven't expired them yet don't bring them back.
+		a.merge(b.snapshot())
+		require.Empty(t, a.Entries())
+
+		// Writes keep entries alive.
+		a.Set("key", "value")
+		a.Add("count", 1)
+		a.removeExpired()
+		require.Len(t, a.Entries(), 2)
+	})
+
+	t.Run("digests", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		a.Set("both", "from a")
+		a.Set("only-a", "value")
+		a.Add("count", 1)
+		b.merge(a.snapshot())
+		b.Set("both", "from b")
+		b.Set("only-b", "value")
+
+		// a only needs the registers b wrote, and b needs nothing from a.
+		delta, want := b.diff(a.digest())
+		require.Equal(t, []string{"both", "only-b"}, sortedKeys(delta.Registers))
+		require.Empty(t, delta.Counters)
+		require.True(t, want.empty())
+
+		a.merge(delta)
+		delta, want = b.diff(a.digest())
+		require.Empty(t, delta.Registers)
+		require.True(t, want.empty())
+
+		// Counters which differ are exchanged in both directions.
+		a.Add("count", 1)
+		b.Add("count", 2)
+		delta, want = b.diff(a.digest())
+		require.Equal(t, []string{"count"}, sortedKeys(delta.Counters))
+		require.Equal(t, []string{"count"}, want.Counters)
+
+		a.merge(delta)
+		b.merge(a.subset(want))
+		require.Equal(t, int64(4), a.Counter("count"))
+		require.Equal(t, int64(4), b.Counter("count"))
+	})
+
+	t.Run("counters", func(t *testing.T) {
+		var (
+			a = newKVStore("a")
+			b = newKVStore("b")
+		)
+		require.Equal(t, int64(3), a.Add("count", 3))
+		require.Equal(t, int64(5), b.Add("count", 5))
+		require.Equal(t, int64(4), b.Add("count", -1))
+
+		// Merging is idempotent.
+		for i := 0; i < 2; i++ {
+			a.merge(b.snapshot())
+			b.merge(a.snapshot())
+		}
+		require.Equal(t, int64(7), a.Counter("count"))
+		require.Equal(t, int64(7), b.Counter("count"))
+		require.Equal(t, int64(0), a.Counter("unknown"))
+	})
+
+	t.Run("entries", func(t *testing.T) {
+		kv := newKVStore("a")
+		kv.Set("b", "value")
+		kv.Add("a", 2)
+
+		entries := kv.Entries()
+		require.Len(t, entries, 2)
+		require.Equal(t, KVEntry{Key: "a", Type: "counter", Value: "2"}, entries[0])
+		require.Equal(t, "b", entries[1].Key)
+		require.Equal(t, "register", entries[1].Type)
+		require.Equal(t, "value", entries[1].Value)
+		require.Equal(t, "a", entries[1].UpdatedBy)
+	})
+}
+
+func TestExchangeKV(t *testing.T) {
+	remote := &Service{kv: newKVStore("remote")}
+	srv := httptest.NewServer(http.HandlerFunc(remote.kvHandler))
+	defer srv.Close()
+
+	s := &Service{
+		log:        log.NewNopLogger(),
+		httpClient: srv.Client(),
+		kv:         newKVStore("local"),
+	}
+
+	remote.kv.Set("remote-key", "remote value")
+	s.kv.Set("local-key", "local value")
+	s.kv.Add("count", 1)
+	remote.kv.Add("count", 2)
+
+	require.NoError(t, s.exchangeKV(context.Background(), strings.TrimPrefix(srv.URL, "http://")))
+
+	for _, kv := range []KV{remote.Data().(KV), s.Data().(KV)} {
+		v, _ := kv.Get("remote-key")
+		require.Equal(t, "remote value", v)
+		v, _ = kv.Get("local-key")
+		require.Equal(t, "local value", v)
+		require.Equal(t, int64(3), kv.Counter("count"))
+	}
+
+	// Stores which are in sync only exchange their digests.
+	delta, want := remote.kv.diff(s.kv.digest())
+	require.Empty(t, delta.Registers)
+	require.Empty(t, delta.Counters)
+	require.True(t, want.empty())
+
+	resp, err := srv.Client().Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -8,10 +8,13 @@ import (
 	"github.com/grafana/ckit/shard"
 )
 
-// Mock returns a mock implementation of the Cluster interface.
-func Mock() Cluster { return mockCluster{} }
+// Mock returns a mock implementation of the Cluster interface. Its KV store
+// is local to the mock.
+func Mock() Cluster { return mockCluster{KV: newKVStore("self")} }
 
-type mockCluster struct{}
+type mockCluster struct {
+	KV
+}
 
 func (mockCluster) Lookup(key shard.Key, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
 	return []peer.Peer{{
@@ -31,6 +34,43 @@ func (mockCluster) Peers() []peer.Peer {
 	}}
 }
 
+func (mockCluster) SingletonOwner(id string) (peer.Peer, error) {
+	return peer.Peer{
+		Name:  "self",
+		Addr:  "127.0.0.1",
+		Self:  true,
+		State: peer.StateParticipant,
+	}, nil
+}
+
+func (mockCluster) SubscribePeers(notify func()) (unsubscribe func()) {
+	return func() {}
+}
+
+func (mockCluster) LookupZone(key shard.Key, zone string, fallback ZoneFallback, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
+	return mockCluster{}.Lookup(key, replicationFactor, op)
+}
+
+func (mockCluster) Topology(peerName string) Topology {
+	return Topology{}
+}
+
+func (mockCluster) Readiness() Readiness {
+	return Readiness{Ready: true, ClusterSize: 1}
+}
+
+func (mockCluster) Ring(leaving ...string) Ring {
+	return Ring{
+		TokensPerNode: tokensPerNode,
+		Peers:         []RingPeer{{Name: "self", Self: true, State: peer.StateParticipant.String(), Share: 1}},
+		Ranges:        []RingRange{{Start: 0, End: 1, Peer: "self"}},
+	}
+}
+
+func (m mockCluster) Simulate(leaving ...string) Cluster {
+	return m
+}
+
 func (mockCluster) Observe(ckit.Observer) {
 	// no-op
 }
@@ -0,0 +1,135 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/ckit/peer"
+)
+
+// readinessCheckInterval is how often Participate checks whether the node is
+// ready to own keys.
+var readinessCheckInterval = time.Second
+
+// Readiness describes whether the local node is ready to own keys. Nodes
+// which aren't ready stay in the viewer state, so that no key is assigned to
+// them.
+type Readiness struct {
+	Ready  bool   `json:"ready"`
+	Reason string `json:"reason,omitempty"` // Why the node isn't ready.
+
+	ClusterSize        int           `json:"clusterSize"`                  // Number of nodes in the cluster, including the local node.
+	MinimumClusterSize int           `json:"minimumClusterSize,omitempty"` // Number of nodes to wait for.
+	WarmupPeriod       time.Duration `json:"warmupPeriod,omitempty"`       // How long the node waits after joining.
+}
+
+// ReadinessReporter reports the readiness of the local node. The data of the
+// cluster service implements ReadinessReporter.
+type ReadinessReporter interface {
+	Readiness() Readiness
+}
+
+// readiness tracks whether the local node is ready to own keys. Once ready,
+// a node stays ready until it shuts down.
+type readiness struct {
+	mut      sync.RWMutex
+	joinedAt time.Time
+	ready    bool
+}
+
+func (r *readiness) setJoined(t time.Time) {
+	r.mut.Lock()
+	defer r.mut.Unlock()
+	if r.joinedAt.IsZero() {
+		r.joinedAt = t
+	}
+}
+
+// Readiness returns the readiness of the local node.
+func (s *Service) Readiness() Readiness {
+	s.readiness.mut.RLock()
+	var (
+		joinedAt = s.readiness.joinedAt
+		ready    = s.readiness.ready
+	)
+	s.readiness.mut.RUnlock()
+
+	res := Readiness{
+		Ready:              ready,
+		ClusterSize:        len(s.node.Peers()),
+		MinimumClusterSize: s.opts.WaitForSize,
+		WarmupPeriod:       s.opts.WarmupPeriod,
+	}
+	if !ready {
+		res.Reason = s.waitReason(joinedAt, res.ClusterSize)
+		if res.Reason == "" {
+			res.Reason = "node hasn't started participating in the cluster yet"
+		}
+	}
+	return res
+}
+
+// waitReason returns why the node must wait before participating in the
+// cluster, or an empty string if it can participate.
+func (s *Service) waitReason(joinedAt time.Time, clusterSize int) string {
+	if !s.opts.EnableClustering || (s.opts.WaitForSize == 0 && s.opts.WarmupPeriod == 0) {
+		return ""
+	}
+	if joinedAt.IsZero() {
+		return "node hasn't joined the cluster yet"
+	}
+
+	sinceJoined := time.Since(joinedAt)
+	switch {
+	case clusterSize < s.opts.WaitForSize && (s.opts.WaitTimeout == 0 || sinceJoined < s.opts.WaitTimeout):
+		return fmt.Sprintf("waiting for the cluster to have %d nodes, has %d", s.opts.WaitForSize, clusterSize)
+	case sinceJoined < s.opts.WarmupPeriod:
+		return fmt.Sprintf("warming up for %s after joining the cluster", (s.opts.WarmupPeriod - sinceJoined).Round(time.Second))
+	default:
+		return ""
+	}
+}
+
+// Participate waits until the local node is ready to own keys, and then moves
+// it to the participant state. The node is ready once the cluster has at
+// least WaitForSize nodes, or WaitTimeout elapsed, and WarmupPeriod elapsed
+// since the node joined the cluster.
+func (s *Service) Participate(ctx context.Context) error {
+	t := time.NewTicker(readinessCheckInterval)
+	defer t.Stop()
+
+	var lastReason string
+	for {
+		s.readiness.mut.RLock()
+		joinedAt := s.readiness.joinedAt
+		s.readiness.mut.RUnlock()
+
+		reason := s.waitReason(joinedAt, len(s.node.Peers()))
+		if reason == "" {
+			break
+		}
+		if reason != lastReason {
+			level.Info(s.log).Log("msg", "waiting before participating in the cluster", "reason", reason)
+			lastReason = reason
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+
+	s.readiness.mut.Lock()
+	s.readiness.ready = true
+	s.readiness.mut.Unlock()
+
+	return s.ChangeState(ctx, peer.StateParticipant)
+}
+
+func (sc *sharderCluster) Readiness() Readiness {
+	return sc.readiness()
+}
@@ -0,0 +1,72 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadiness(t *testing.T) {
+	newService := func(t *testing.T, opts Options) *Service {
+		opts.NodeName = "node"
+		opts.AdvertiseAddress = "127.0.0.1:12345"
+		s, err := New(opts)
+		require.NoError(t, err)
+		return s
+	}
+
+	t.Run("not gated", func(t *testing.T) {
+		s := newService(t, Options{EnableClustering: true})
+		require.Empty(t, s.waitReason(time.Time{}, 0))
+
+		r := s.Readiness()
+		require.False(t, r.Ready)
+		require.Equal(t, "node hasn't started participating in the cluster yet", r.Reason)
+	})
+
+	t.Run("clustering disabled", func(t *testing.T) {
+		s := newService(t, Options{WaitForSize: 3})
+		require.Empty(t, s.waitReason(time.Time{}, 1))
+	})
+
+	t.Run("wait for size", func(t *testing.T) {
+		s := newService(t, Options{EnableClustering: true, WaitForSize: 3})
+		require.Equal(t, "node hasn't joined the cluster yet", s.waitReason(time.Time{}, 1))
+
+		joinedAt := time.Now()
+		require.Equal(t, "waiting for the cluster to have 3 nodes, has 2", s.waitReason(joinedAt, 2))
+		require.Empty(t, s.waitReason(joinedAt, 3))
+	})
+
+	t.Run("wait timeout", func(t *testing.T) {
+		s := newService(t, Options{EnableClustering: true, WaitForSize: 3, WaitTimeout: time.Minute})
+		require.NotEmpty(t, s.waitReason(time.Now(), 1))
+		require.Empty(t, s.waitReason(time.Now().Add(-2*time.Minute), 1))
+	})
+
+	t.Run("warmup period", func(t *testing.T) {
+		s := newService(t, Options{EnableClustering: true, WaitForSize: 2, WarmupPeriod: time.Minute})
+		require.Equal(t, "warming up for 1m0s after joining the cluster", s.waitReason(time.Now(), 2))
+		require.Empty(t, s.waitReason(time.Now().Add(-2*time.Minute), 2))
+	})
+
+	t.Run("participate waits until ready", func(t *testing.T) {
+		prev := readinessCheckInterval
+		readinessCheckInterval = 10 * time.Millisecond
+		defer func() { readinessCheckInterval = prev }()
+
+		s := newService(t, Options{EnableClustering: true, WarmupPeriod: time.Hour})
+		s.readiness.setJoined(time.Now())
+
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		require.ErrorIs(t, s.Participate(ctx), context.DeadlineExceeded)
+
+		r := s.Data().(ReadinessReporter).Readiness()
+		require.False(t, r.Ready)
+		require.Equal(t, "warming up for 1h0m0s after joining the cluster", r.Reason)
+		require.Equal(t, time.Hour, r.WarmupPeriod)
+	})
+}
@@ -0,0 +1,176 @@
+package cluster
+
+import (
+	"math"
+	"sort"
+
+	"github.com/cespare/xxhash/v2"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+)
+
+// Ring describes how the keys of the cluster are distributed between its
+// participant nodes.
+type Ring struct {
+	TokensPerNode int         `json:"tokensPerNode"`
+	Peers         []RingPeer  `json:"peers"`
+	Ranges        []RingRange `json:"ranges"`
+}
+
+// RingPeer describes the share of the ring owned by a peer.
+type RingPeer struct {
+	Name  string  `json:"name"`
+	Self  bool    `json:"isSelf"`
+	State string  `json:"state"`
+	Zone  string  `json:"zone,omitempty"`
+	Share float64 `json:"share"` // Fraction of the keys owned by the peer.
+}
+
+// RingRange is a range of keys owned by a peer. Start and End are fractions
+// of the key space, between 0 and 1.
+type RingRange struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+	Peer  string  `json:"peer"`
+}
+
+// RingInspector inspects the ring of the cluster, to debug how keys are
+// distributed. The data of the cluster service implements RingInspector.
+type RingInspector interface {
+	// Ring returns the ring of the cluster, as if the peers named in leaving
+	// left the cluster.
+	Ring(leaving ...string) Ring
+
+	// Simulate returns a view of the cluster where the peers named in leaving
+	// left the cluster. Changes to the cluster aren't reflected in the
+	// returned view.
+	Simulate(leaving ...string) Cluster
+}
+
+// OwnershipReporter is implemented by clustered components to report how
+// their targets are distributed between the nodes of the cluster.
+type OwnershipReporter interface {
+	// TargetOwners returns the number of targets of the component owned by
+	// each peer of c, by peer name. Targets without an owner are counted under
+	// the empty name. TargetOwners returns nil if clustering is disabled for
+	// the component.
+	TargetOwners(c Cluster) map[string]int
+}
+
+func (sc *sharderCluster) Ring(leaving ...string) Ring {
+	var (
+		peers        = withoutPeers(sc.sharder.Peers(), leaving)
+		participants []string
+		ring         = Ring{TokensPerNode: tokensPerNode}
+	)
+	for _, p := range peers {
+		if p.State == peer.StateParticipant {
+			participants = append(participants, p.Name)
+		}
+	}
+
+	tokens := ringTokens(participants)
+	owned := make(map[string]uint64, len(participants))
+	for i, tok := range tokens {
+		// A token owns the keys after the previous token, up to and including
+		// itself. The first token also owns the keys after the last token.
+		if i == 0 {
+			owned[tok.node] += tok.token + (math.MaxUint64 - tokens[len(tokens)-1].token)
+			ring.Ranges = appendRange(ring.Ranges, 0, tok.token, tok.node)
+			continue
+		}
+		owned[tok.node] += tok.token - tokens[i-1].token
+		ring.Ranges = appendRange(ring.Ranges, tokens[i-1].token, tok.token, tok.node)
+	}
+	if len(tokens) > 0 {
+		ring.Ranges = appendRange(ring.Ranges, tokens[len(tokens)-1].token, math.MaxUint64, tokens[0].node)
+	}
+
+	for _, p := range peers {
+		ring.Peers = append(ring.Peers, RingPeer{
+			Name:  p.Name,
+			Self:  p.Self,
+			State: p.State.String(),
+			Zone:  sc.Topology(p.Name).Zone,
+			Share: float64(owned[p.Name]) / math.MaxUint64,
+		})
+	}
+	return ring
+}
+
+// appendRange appends the range of keys between start and end owned by node
+// to ranges, merging it with the last range if node owns it too.
+func appendRange(ranges []RingRange, start, end uint64, node string) []RingRange {
+	var (
+		startFrac = float64(start) / math.MaxUint64
+		endFrac   = float64(end) / math.MaxUint64
+	)
+	if n := len(ranges); n > 0 && ranges[n-1].Peer == node {
+		ranges[n-1].End = endFrac
+		return ranges
+	}
+	return append(ranges, RingRange{Start: startFrac, End: endFrac, Peer: node})
+}
+
+func (sc *sharderCluster) Simulate(leaving ...string) Cluster {
+	peers := withoutPeers(sc.sharder.Peers(), leaving)
+
+	sharder := shard.Ring(tokensPerNode)
+	sharder.SetPeers(peers)
+
+	zones := newZoneSharders()
+	zones.setPeers(peers, sc.zones.snapshot())
+
+	return &sharderCluster{
+		KV:          sc.KV,
+		sharder:     sharder,
+		subscribers: newPeerSubscribers(),
+		zones:       zones,
+		readiness:   sc.readiness,
+	}
+}
+
+// withoutPeers returns the peers whose name isn't in names.
+func withoutPeers(peers []peer.Peer, names []string) []peer.Peer {
+	res := make([]peer.Peer, 0, len(peers))
+outer:
+	for _, p := range peers {
+		for _, name := range names {
+			if p.Name == name {
+				continue outer
+			}
+		}
+		res = append(res, p)
+	}
+	return res
+}
+
+type ringToken struct {
+	node  string
+	token uint64
+}
+
+// ringTokens returns the sorted tokens of nodes, generated the same way as
+// the tokens of the ring sharder of ckit.
+func ringTokens(nodes []string) []ringToken {
+	tokens := make([]ringToken, 0, len(nodes)*tokensPerNode)
+	for _, node := range nodes {
+		dig := xxhash.New()
+		_, _ = dig.WriteString(node)
+
+		data := []byte{0}
+		for t := 0; t < tokensPerNode; t++ {
+			data[0] = byte(t)
+			_, _ = dig.Write(data)
+			tokens = append(tokens, ringToken{node: node, token: dig.Sum64()})
+		}
+	}
+
+	sort.Slice(tokens, func(i, j int) bool {
+		if tokens[i].token == tokens[j].token {
+			return tokens[i].node < tokens[j].node
+		}
+		return tokens[i].token < tokens[j].token
+	})
+	return tokens
+}
@@ -0,0 +1,91 @@
+package cluster
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRing(t *testing.T) {
+	peers := []peer.Peer{
+		{Name: "a", Self: true, State: peer.StateParticipant},
+		{Name: "b", State: peer.StateParticipant},
+		{Name: "c", State: peer.StateParticipant},
+		{Name: "d", State: peer.StateTerminating},
+	}
+	sc := &sharderCluster{sharder: shard.Ring(tokensPerNode), zones: newZoneSharders()}
+	sc.sharder.SetPeers(peers)
+	sc.zones.setPeers(peers, map[string]Topology{"b": {Zone: "z1"}})
+
+	t.Run("ranges match the sharder", func(t *testing.T) {
+		ring := sc.Ring()
+		for i := 0; i < 1000; i++ {
+			key := shard.StringKey(fmt.Sprintf("key-%d", i))
+			owners, err := sc.Lookup(key, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.Equal(t, owners[0].Name, rangeOwner(ring, key), "owner of key-%d", i)
+		}
+	})
+
+	t.Run("shares", func(t *testing.T) {
+		ring := sc.Ring()
+		require.Equal(t, tokensPerNode, ring.TokensPerNode)
+		require.Len(t, ring.Peers, 4)
+
+		var total float64
+		for _, p := range ring.Peers {
+			total += p.Share
+			switch p.Name {
+			case "d":
+				// Terminating peers don't own keys.
+				require.Zero(t, p.Share)
+			default:
+				require.InDelta(t, 1.0/3, p.Share, 0.1)
+			}
+		}
+		require.InDelta(t, 1, total, 1e-9)
+
+		require.Equal(t, RingPeer{Name: "b", State: "participant", Zone: "z1", Share: ring.Peers[1].Share}, ring.Peers[1])
+		require.True(t, ring.Peers[0].Self)
+	})
+
+	t.Run("simulate a peer leaving", func(t *testing.T) {
+		ring := sc.Ring("a")
+		require.Len(t, ring.Peers, 3)
+		for _, r := range ring.Ranges {
+			require.NotEqual(t, "a", r.Peer)
+		}
+
+		simulated := sc.Simulate("a")
+		require.Len(t, simulated.Peers(), 3)
+		for i := 0; i < 100; i++ {
+			key := shard.StringKey(fmt.Sprintf("key-%d", i))
+			owners, err := simulated.Lookup(key, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.NotEqual(t, "a", owners[0].Name)
+			require.Equal(t, owners[0].Name, rangeOwner(ring, key))
+		}
+
+		// The simulated cluster keeps the zones of the peers.
+		owners, err := simulated.(Zones).LookupZone(shard.StringKey("key"), "z1", ZoneFallbackNone, 1, shard.OpReadWrite)
+		require.NoError(t, err)
+		require.Equal(t, "b", owners[0].Name)
+
+		// The cluster itself is unchanged.
+		require.Len(t, sc.Peers(), 4)
+	})
+}
+
+// rangeOwner returns the owner of key in the ranges of ring.
+func rangeOwner(ring Ring, key shard.Key) string {
+	frac := float64(key) / math.MaxUint64
+	idx := sort.Search(len(ring.Ranges), func(i int) bool {
+		return ring.Ranges[i].End >= frac
+	})
+	return ring.Ranges[idx].Peer
+}
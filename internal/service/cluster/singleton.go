@@ -0,0 +1,79 @@
+package cluster
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+)
+
+// Singletons elects the nodes running singleton components, which run on
+// exactly one node of the cluster. The data of the cluster service implements
+// Singletons.
+//
+// Ownership is eventually consistent: when the peers of the cluster change,
+// the previous owner of a singleton may still run it for a short time after
+// the new owner started it.
+type Singletons interface {
+	// SingletonOwner returns the peer which runs the singleton component with
+	// the given globally unique ID.
+	SingletonOwner(id string) (peer.Peer, error)
+
+	// SubscribePeers calls notify whenever the peers of the cluster change,
+	// which may change the owners of singletons.
+	SubscribePeers(notify func()) (unsubscribe func())
+}
+
+func (sc *sharderCluster) SingletonOwner(id string) (peer.Peer, error) {
+	// Only participants own keys for shard.OpReadWrite, so that nodes leave
+	// their singletons to other nodes when they're terminating.
+	peers, err := sc.sharder.Lookup(shard.StringKey(id), 1, shard.OpReadWrite)
+	if err != nil {
+		return peer.Peer{}, err
+	}
+	if len(peers) == 0 {
+		return peer.Peer{}, fmt.Errorf("no peer owns singleton %q", id)
+	}
+	return peers[0], nil
+}
+
+func (sc *sharderCluster) SubscribePeers(notify func()) (unsubscribe func()) {
+	return sc.subscribers.subscribe(notify)
+}
+
+// peerSubscribers holds the functions to call when the peers of the cluster
+// change.
+type peerSubscribers struct {
+	mut    sync.RWMutex
+	funcs  map[int]func()
+	nextID int
+}
+
+func newPeerSubscribers() *peerSubscribers {
+	return &peerSubscribers{funcs: make(map[int]func())}
+}
+
+func (ps *peerSubscribers) subscribe(notify func()) (unsubscribe func()) {
+	ps.mut.Lock()
+	defer ps.mut.Unlock()
+
+	id := ps.nextID
+	ps.nextID++
+	ps.funcs[id] = notify
+
+	return func() {
+		ps.mut.Lock()
+		defer ps.mut.Unlock()
+		delete(ps.funcs, id)
+	}
+}
+
+func (ps *peerSubscribers) notify() {
+	ps.mut.RLock()
+	defer ps.mut.RUnlock()
+
+	for _, notify := range ps.funcs {
+		notify()
+	}
+}
@@ -0,0 +1,355 @@
+package cluster
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/grafana/dskit/backoff"
+)
+
+// Topology holds the labels describing where a node of the cluster runs.
+type Topology struct {
+	Zone string `json:"zone,omitempty"` // Availability zone of the node.
+	Rack string `json:"rack,omitempty"` // Rack of the node.
+}
+
+// ZoneFallback decides which nodes own the keys of a zone without any
+// participant node.
+type ZoneFallback string
+
+const (
+	// ZoneFallbackAny looks up the owners of keys among the nodes of all zones.
+	ZoneFallbackAny ZoneFallback = "any"
+	// ZoneFallbackNone leaves keys without an owner.
+	ZoneFallbackNone ZoneFallback = "none"
+)
+
+// MarshalText implements encoding.TextMarshaler.
+func (f ZoneFallback) MarshalText() ([]byte, error) {
+	return []byte(f), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *ZoneFallback) UnmarshalText(text []byte) error {
+	switch fallback := ZoneFallback(text); fallback {
+	case ZoneFallbackAny, ZoneFallbackNone:
+		*f = fallback
+		return nil
+	default:
+		return fmt.Errorf("unknown zone fallback %q, must be %q or %q", text, ZoneFallbackAny, ZoneFallbackNone)
+	}
+}
+
+// ErrNoZoneOwner is returned by [Zones.LookupZone] when no node of the zone
+// can own a key and the fallback is [ZoneFallbackNone].
+var ErrNoZoneOwner = errors.New("no node of the zone can own the key")
+
+// Zones looks up the owners of keys with zone-aware sharding, where keys are
+// owned by the nodes in the same zone as the key. The data of the cluster
+// service implements Zones.
+//
+// Each zone has its own hash ring, so that the keys of a zone are only
+// reassigned when the nodes of that zone change.
+type Zones interface {
+	// LookupZone is like [Cluster.Lookup], but only considers the nodes of
+	// zone. If zone is empty or has no participant node, fallback decides
+	// which nodes own the key. replicationFactor is lowered to the number of
+	// participant nodes of the zone.
+	LookupZone(key shard.Key, zone string, fallback ZoneFallback, replicationFactor int, op shard.Op) ([]peer.Peer, error)
+
+	// Topology returns the topology of the named peer. The topology of a peer
+	// is empty until it's been fetched from the peer.
+	Topology(peerName string) Topology
+}
+
+// topologyEndpoint serves the topology of the node to its peers.
+const topologyEndpoint = "/api/v1/ckit/topology"
+
+// zoneSharders tracks the topology of the peers of the cluster, and holds a
+// sharder per zone.
+type zoneSharders struct {
+	mut        sync.RWMutex
+	peers      []peer.Peer
+	topologies map[string]Topology // Topology of peers by name.
+	zones      map[string]*zone
+}
+
+type zone struct {
+	sharder      shard.Sharder
+	participants int
+}
+
+func newZoneSharders() *zoneSharders {
+	return &zoneSharders{
+		topologies: make(map[string]Topology),
+		zones:      make(map[string]*zone),
+	}
+}
+
+// topology returns the known topology of a peer.
+func (zs *zoneSharders) topology(peerName string) (Topology, bool) {
+	zs.mut.RLock()
+	defer zs.mut.RUnlock()
+	t, ok := zs.topologies[peerName]
+	return t, ok
+}
+
+// snapshot returns a copy of the known topologies of peers.
+func (zs *zoneSharders) snapshot() map[string]Topology {
+	zs.mut.RLock()
+	defer zs.mut.RUnlock()
+
+	res := make(map[string]Topology, len(zs.topologies))
+	for name, t := range zs.topologies {
+		res[name] = t
+	}
+	return res
+}
+
+// setPeers rebuilds the sharders of the zones from the peers of the cluster
+// and their topology. Peers missing from topologies keep their previously
+// known topology, and peers without a known topology aren't part of any zone.
+func (zs *zoneSharders) setPeers(peers []peer.Peer, topologies map[string]Topology) {
+	zs.mut.Lock()
+	defer zs.mut.Unlock()
+
+	known := make(map[string]Topology, len(peers))
+	for _, p := range peers {
+		if t, ok := topologies[p.Name]; ok {
+			known[p.Name] = t
+		} else if t, ok := zs.topologies[p.Name]; ok {
+			known[p.Name] = t
+		}
+	}
+
+	zs.peers = peers
+	zs.topologies = known
+	zs.rebuild()
+}
+
+// setTopology records the topology of a peer and rebuilds the sharders of the
+// zones. It returns false if the peer isn't part of the cluster anymore.
+func (zs *zoneSharders) setTopology(peerName string, t Topology) bool {
+	zs.mut.Lock()
+	defer zs.mut.Unlock()
+
+	for _, p := range zs.peers {
+		if p.Name == peerName {
+			zs.topologies[peerName] = t
+			zs.rebuild()
+			return true
+		}
+	}
+	return false
+}
+
+// rebuild rebuilds the sharders of the zones. zs.mut must be held.
+func (zs *zoneSharders) rebuild() {
+	byZone := make(map[string][]peer.Peer)
+	for _, p := range zs.peers {
+		if zone := zs.topologies[p.Name].Zone; zone != "" {
+			byZone[zone] = append(byZone[zone], p)
+		}
+	}
+
+	zones := make(map[string]*zone, len(byZone))
+	for name, zonePeers := range byZone {
+		z := &zone{sharder: shard.Ring(tokensPerNode)}
+		z.sharder.SetPeers(zonePeers)
+		for _, p := range zonePeers {
+			if p.State == peer.StateParticipant {
+				z.participants++
+			}
+		}
+		zones[name] = z
+	}
+	zs.zones = zones
+}
+
+func (sc *sharderCluster) LookupZone(key shard.Key, zone string, fallback ZoneFallback, replicationFactor int, op shard.Op) ([]peer.Peer, error) {
+	sc.zones.mut.RLock()
+	z := sc.zones.zones[zone]
+	sc.zones.mut.RUnlock()
+
+	if z != nil && z.participants > 0 {
+		if replicationFactor > z.participants {
+			replicationFactor = z.participants
+		}
+		return z.sharder.Lookup(key, replicationFactor, op)
+	}
+
+	if fallback == ZoneFallbackNone {
+		return nil, ErrNoZoneOwner
+	}
+	return sc.sharder.Lookup(key, replicationFactor, op)
+}
+
+func (sc *sharderCluster) Topology(peerName string) Topology {
+	t, _ := sc.zones.topology(peerName)
+	return t
+}
+
+// topologyHandler serves the topology of the local node.
+func (s *Service) topologyHandler(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(s.opts.Topology)
+}
+
+// topologyBackoff controls how often fetching the topology of a peer is
+// retried. Fetches are retried until they succeed or the peer leaves the
+// cluster.
+var topologyBackoff = backoff.Config{
+	MinBackoff: time.Second,
+	MaxBackoff: time.Minute,
+}
+
+// topologyFetches tracks the fetches of the topology of peers running in the
+// background.
+type topologyFetches struct {
+	mut     sync.Mutex
+	pending map[string]*topologyFetch // Pending fetches by peer name.
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+type topologyFetch struct {
+	cancel context.CancelFunc
+}
+
+func newTopologyFetches() *topologyFetches {
+	return &topologyFetches{pending: make(map[string]*topologyFetch)}
+}
+
+// updateTopologies rebuilds the sharders of the zones from the peers of the
+// cluster, and starts fetching the topology of the peers which joined the
+// cluster in the background. Fetches are retried with backoff, and onFetched
+// is called every time the topology of a peer has been fetched, so that
+// components can look up the owners of keys again.
+//
+// updateTopologies never blocks on the network, since it's called by the
+// observer of peer changes.
+func (s *Service) updateTopologies(ctx context.Context, peers []peer.Peer, onFetched func()) {
+	var (
+		topologies = make(map[string]Topology, len(peers))
+		current    = make(map[string]struct{}, len(peers))
+		missing    []peer.Peer
+	)
+	for _, p := range peers {
+		current[p.Name] = struct{}{}
+		if p.Self {
+			topologies[p.Name] = s.opts.Topology
+			continue
+		}
+		if _, ok := s.zones.topology(p.Name); !ok {
+			missing = append(missing, p)
+		}
+	}
+	s.zones.setPeers(peers, topologies)
+
+	s.fetches.mut.Lock()
+	defer s.fetches.mut.Unlock()
+
+	// Stop fetching the topology of peers which left the cluster.
+	for name, f := range s.fetches.pending {
+		if _, ok := current[name]; !ok {
+			f.cancel()
+			delete(s.fetches.pending, name)
+		}
+	}
+
+	if s.fetches.stopped {
+		return
+	}
+	for _, p := range missing {
+		if _, ok := s.fetches.pending[p.Name]; ok {
+			continue
+		}
+
+		fetchCtx, cancel := context.WithCancel(ctx)
+		f := &topologyFetch{cancel: cancel}
+		s.fetches.pending[p.Name] = f
+
+		s.fetches.wg.Add(1)
+		go func(p peer.Peer) {
+			defer s.fetches.wg.Done()
+			defer func() {
+				s.fetches.mut.Lock()
+				defer s.fetches.mut.Unlock()
+				if s.fetches.pending[p.Name] == f {
+					delete(s.fetches.pending, p.Name)
+				}
+				cancel()
+			}()
+
+			if s.fetchTopologyWithRetry(fetchCtx, p) && onFetched != nil {
+				onFetched()
+			}
+		}(p)
+	}
+}
+
+// fetchTopologyWithRetry fetches the topology of p until it succeeds or ctx is
+// canceled. It returns true if the topology was recorded.
+func (s *Service) fetchTopologyWithRetry(ctx context.Context, p peer.Peer) bool {
+	retry := backoff.New(ctx, topologyBackoff)
+	for retry.Ongoing() {
+		t, err := s.fetchTopology(ctx, p.Addr)
+		if err == nil {
+			return s.zones.setTopology(p.Name, t)
+		}
+		if ctx.Err() != nil {
+			break
+		}
+
+		level.Warn(s.log).Log("msg", "failed to fetch topology of peer, retrying", "peer", p.Name, "err", err)
+		retry.Wait()
+	}
+	return false
+}
+
+// stopTopologyFetches cancels the pending fetches of the topology of peers and
+// waits for them to exit.
+func (s *Service) stopTopologyFetches() {
+	s.fetches.mut.Lock()
+	s.fetches.stopped = true
+	for name, f := range s.fetches.pending {
+		f.cancel()
+		delete(s.fetches.pending, name)
+	}
+	s.fetches.mut.Unlock()
+
+	s.fetches.wg.Wait()
+}
+
+func (s *Service) fetchTopology(ctx context.Context, addr string) (Topology, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+addr+topologyEndpoint, nil)
+	if err != nil {
+		return Topology{}, err
+	}
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return Topology{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Topology{}, fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+
+	var t Topology
+	if err := json.NewDecoder(resp.Body).Decode(&t); err != nil {
+		return Topology{}, fmt.Errorf("decoding topology: %w", err)
+	}
+	return t, nil
+}
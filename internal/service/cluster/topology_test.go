@@ -0,0 +1,199 @@
+package cluster
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/ckit/peer"
+	"github.com/grafana/ckit/shard"
+	"github.com/grafana/dskit/backoff"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+)
+
+func TestLookupZone(t *testing.T) {
+	peers := []peer.Peer{
+		{Name: "a1", State: peer.StateParticipant},
+		{Name: "a2", State: peer.StateParticipant},
+		{Name: "b1", State: peer.StateParticipant},
+		{Name: "c1", State: peer.StateTerminating},
+	}
+	topologies := map[string]Topology{
+		"a1": {Zone: "a"},
+		"a2": {Zone: "a"},
+		"b1": {Zone: "b", Rack: "r1"},
+		"c1": {Zone: "c"},
+	}
+
+	sc := &sharderCluster{sharder: shard.Ring(tokensPerNode), zones: newZoneSharders()}
+	sc.sharder.SetPeers(peers)
+	sc.zones.setPeers(peers, topologies)
+
+	require.Equal(t, Topology{Zone: "b", Rack: "r1"}, sc.Topology("b1"))
+	require.Equal(t, Topology{}, sc.Topology("unknown"))
+
+	t.Run("owners in the same zone", func(t *testing.T) {
+		for i := 0; i < 100; i++ {
+			key := shard.StringKey(fmt.Sprintf("target-%d", i))
+
+			owners, err := sc.LookupZone(key, "a", ZoneFallbackAny, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.Len(t, owners, 1)
+			require.True(t, strings.HasPrefix(owners[0].Name, "a"), "owner %s isn't in zone a", owners[0].Name)
+		}
+	})
+
+	t.Run("replication factor lowered to the zone", func(t *testing.T) {
+		owners, err := sc.LookupZone(shard.StringKey("target"), "b", ZoneFallbackAny, 3, shard.OpReadWrite)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b1"}, peerNames(owners))
+	})
+
+	t.Run("fallback to any zone", func(t *testing.T) {
+		for _, zone := range []string{"", "c", "unknown"} {
+			key := shard.StringKey("target")
+
+			owners, err := sc.LookupZone(key, zone, ZoneFallbackAny, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+
+			expect, err := sc.Lookup(key, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.Equal(t, expect, owners)
+		}
+	})
+
+	t.Run("no fallback", func(t *testing.T) {
+		_, err := sc.LookupZone(shard.StringKey("target"), "c", ZoneFallbackNone, 1, shard.OpReadWrite)
+		require.ErrorIs(t, err, ErrNoZoneOwner)
+	})
+
+	t.Run("zone keys stable when another zone changes", func(t *testing.T) {
+		before := make(map[string]string)
+		for i := 0; i < 100; i++ {
+			key := fmt.Sprintf("target-%d", i)
+			owners, err := sc.LookupZone(shard.StringKey(key), "a", ZoneFallbackAny, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			before[key] = owners[0].Name
+		}
+
+		// Zone b goes down.
+		sc.zones.setPeers(peers[:2], topologies)
+
+		for key, owner := range before {
+			owners, err := sc.LookupZone(shard.StringKey(key), "a", ZoneFallbackAny, 1, shard.OpReadWrite)
+			require.NoError(t, err)
+			require.Equal(t, owner, owners[0].Name)
+		}
+	})
+}
+
+func TestUpdateTopologies(t *testing.T) {
+	remote := &Service{opts: Options{Topology: Topology{Zone: "b", Rack: "r2"}}}
+	srv := httptest.NewServer(http.HandlerFunc(remote.topologyHandler))
+	defer srv.Close()
+
+	s := newTopologyTestService(srv.Client(), Topology{Zone: "a"})
+	defer s.stopTopologyFetches()
+
+	peers := []peer.Peer{
+		{Name: "self", Self: true, State: peer.StateParticipant},
+		{Name: "remote", Addr: strings.TrimPrefix(srv.URL, "http://"), State: peer.StateParticipant},
+		{Name: "unreachable", Addr: "127.0.0.1:1", State: peer.StateParticipant},
+	}
+
+	var fetched atomic.Int32
+	s.updateTopologies(context.Background(), peers, func() { fetched.Inc() })
+
+	data := s.Data().(Zones)
+	require.Equal(t, Topology{Zone: "a"}, data.Topology("self"))
+
+	require.Eventually(t, func() bool { return fetched.Load() == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, Topology{Zone: "b", Rack: "r2"}, data.Topology("remote"))
+	require.Equal(t, Topology{}, data.Topology("unreachable"))
+
+	owners, err := data.LookupZone(shard.StringKey("target"), "b", ZoneFallbackNone, 1, shard.OpReadWrite)
+	require.NoError(t, err)
+	require.Equal(t, []string{"remote"}, peerNames(owners))
+
+	// Fetches stop once peers leave the cluster.
+	s.updateTopologies(context.Background(), peers[:2], nil)
+	require.Eventually(t, func() bool {
+		s.fetches.mut.Lock()
+		defer s.fetches.mut.Unlock()
+		return len(s.fetches.pending) == 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestUpdateTopologies_Retry(t *testing.T) {
+	defer func(cfg backoff.Config) { topologyBackoff = cfg }(topologyBackoff)
+	topologyBackoff = backoff.Config{MinBackoff: 10 * time.Millisecond, MaxBackoff: 10 * time.Millisecond}
+
+	var (
+		ready  atomic.Bool
+		remote = &Service{opts: Options{Topology: Topology{Zone: "b"}}}
+	)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !ready.Load() {
+			http.Error(w, "starting", http.StatusServiceUnavailable)
+			return
+		}
+		remote.topologyHandler(w, r)
+	}))
+	defer srv.Close()
+
+	s := newTopologyTestService(srv.Client(), Topology{Zone: "a"})
+	defer s.stopTopologyFetches()
+
+	peers := []peer.Peer{
+		{Name: "self", Self: true, State: peer.StateParticipant},
+		{Name: "remote", Addr: strings.TrimPrefix(srv.URL, "http://"), State: peer.StateParticipant},
+	}
+
+	notified := make(chan struct{}, 1)
+	s.updateTopologies(context.Background(), peers, func() { notified <- struct{}{} })
+
+	// The topology is unknown while the peer fails to serve it.
+	time.Sleep(50 * time.Millisecond)
+	require.Equal(t, Topology{}, s.Data().(Zones).Topology("remote"))
+
+	// Components are notified once the topology has been fetched, without
+	// waiting for the peers to change.
+	ready.Store(true)
+	select {
+	case <-notified:
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "components weren't notified")
+	}
+	require.Equal(t, Topology{Zone: "b"}, s.Data().(Zones).Topology("remote"))
+}
+
+func newTopologyTestService(client *http.Client, topology Topology) *Service {
+	return &Service{
+		log:        log.NewNopLogger(),
+		opts:       Options{Topology: topology},
+		httpClient: client,
+		zones:      newZoneSharders(),
+		fetches:    newTopologyFetches(),
+	}
+}
+
+func TestZoneFallback_UnmarshalText(t *testing.T) {
+	var f ZoneFallback
+	require.NoError(t, f.UnmarshalText([]byte("none")))
+	require.Equal(t, ZoneFallbackNone, f)
+	require.EqualError(t, f.UnmarshalText([]byte("nearest")), `unknown zone fallback "nearest", must be "any" or "none"`)
+}
+
+func peerNames(peers []peer.Peer) []string {
+	names := make([]string, len(peers))
+	for i, p := range peers {
+		names[i] = p.Name
+	}
+	return names
+}
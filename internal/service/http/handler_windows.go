@@ -12,6 +12,7 @@ func (args *TLSArguments) winTlsConfig(win *server.WinCertStoreHandler) (*tls.Co
 		MinVersion:            uint16(args.MinVersion),
 		MaxVersion:            uint16(args.MaxVersion),
 		ClientAuth:            tls.ClientAuthType(args.ClientAuth),
+		NextProtos:            []string{"h2", "http/1.1"},
 		VerifyPeerCertificate: win.VerifyPeer,
 		GetCertificate:        win.CertificateHandler,
 	}
@@ -130,6 +130,9 @@ func (args *TLSArguments) tlsConfig() (*tls.Config, error) {
 		MinVersion: uint16(args.MinVersion),
 		MaxVersion: uint16(args.MaxVersion),
 		ClientAuth: tls.ClientAuthType(args.ClientAuth),
+		// Negotiate HTTP/2 for the clients which support it, such as the nodes of
+		// a cluster.
+		NextProtos: []string{"h2", "http/1.1"},
 
 		GetCertificate: func(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
 			return args.tlsCertificate()
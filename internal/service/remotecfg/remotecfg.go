@@ -16,6 +16,7 @@ import (
 	agentv1 "github.com/grafana/agent-remote-config/api/gen/proto/go/agent/v1"
 	"github.com/grafana/agent-remote-config/api/gen/proto/go/agent/v1/agentv1connect"
 	"github.com/grafana/agent/internal/agentseed"
+	"github.com/grafana/agent/internal/component"
 	"github.com/grafana/agent/internal/component/common/config"
 	"github.com/grafana/agent/internal/featuregate"
 	"github.com/grafana/agent/internal/flow/logging/level"
@@ -31,8 +32,8 @@ func getHash(in []byte) string {
 }
 
 // Service implements a service for remote configuration.
-// The default value of ch is nil; this means it will block forever if the
-// remotecfg service is not configured. In addition, we're keeping track of
+// The ticker is reset to the maximum duration when the remotecfg service is
+// not configured, so that it never fires. In addition, we're keeping track of
 // the ticker so we can avoid leaking goroutines.
 // The datapath field is where the service looks for the local cache location.
 // It is defined as a hash of the Arguments field.
@@ -41,13 +42,14 @@ type Service struct {
 	args Arguments
 
 	ctrl service.Controller
+	host service.Host
 
 	mut               sync.RWMutex
 	asClient          agentv1connect.AgentServiceClient
-	ch                <-chan time.Time
 	ticker            *time.Ticker
 	dataPath          string
 	currentConfigHash string
+	exports           map[string]any
 }
 
 // ServiceName defines the name used for the remotecfg service.
@@ -67,6 +69,11 @@ type Arguments struct {
 	Metadata         map[string]string        `river:"metadata,attr,optional"`
 	PollFrequency    time.Duration            `river:"poll_frequency,attr,optional"`
 	HTTPClientConfig *config.HTTPClientConfig `river:",squash"`
+
+	// ModuleArguments are the values of the local config passed to the
+	// argument blocks of the remote config. They're the only local values the
+	// remote config can reference.
+	ModuleArguments map[string]any `river:"arguments,block,optional"`
 }
 
 // GetDefaultArguments populates the default values for the Arguments struct.
@@ -95,9 +102,14 @@ func (a *Arguments) Validate() error {
 	return nil
 }
 
-// Hash marshals the Arguments and returns a hash representation.
+// Hash marshals the Arguments and returns a hash representation. The module
+// arguments aren't part of the hash, so that changing them doesn't invalidate
+// the on-disk cache.
 func (a *Arguments) Hash() (string, error) {
-	b, err := river.Marshal(a)
+	withoutModuleArgs := *a
+	withoutModuleArgs.ModuleArguments = nil
+
+	b, err := river.Marshal(withoutModuleArgs)
 	if err != nil {
 		return "", fmt.Errorf("failed to marshal arguments: %w", err)
 	}
@@ -118,9 +130,51 @@ func New(opts Options) (*Service, error) {
 	}, nil
 }
 
-// Data is a no-op for the remotecfg service.
+// Data returns an instance of [Data].
 func (s *Service) Data() any {
-	return nil
+	return s
+}
+
+// Data includes the values exported by the remote config.
+type Data interface {
+	// Exports returns the values of the export blocks of the remote config.
+	Exports() map[string]any
+}
+
+// Component is a Flow component which subscribes to the exports of the remote
+// config.
+type Component interface {
+	component.Component
+
+	// NotifyRemoteExportsChange notifies the component that the values of the
+	// export blocks of the remote config have changed.
+	NotifyRemoteExportsChange()
+}
+
+// Exports implements [Data].
+func (s *Service) Exports() map[string]any {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	return s.exports
+}
+
+// setExports is called when the exports of the remote config change, and
+// notifies the components of the local config using them.
+func (s *Service) setExports(exports map[string]any) {
+	s.mut.Lock()
+	s.exports = exports
+	host := s.host
+	s.mut.Unlock()
+
+	if host == nil {
+		return
+	}
+	for _, info := range component.GetAllComponents(host, component.InfoOptions{}) {
+		if c, ok := info.Component.(Component); ok {
+			c.NotifyRemoteExportsChange()
+		}
+	}
 }
 
 // Definition returns the definition of the remotecfg service.
@@ -138,7 +192,10 @@ var _ service.Service = (*Service)(nil)
 // Run implements [service.Service] and starts the remotecfg service. It will
 // run until the provided context is canceled or there is a fatal error.
 func (s *Service) Run(ctx context.Context, host service.Host) error {
-	s.ctrl = host.NewController(ServiceName)
+	s.mut.Lock()
+	s.host = host
+	s.ctrl = host.NewController(ServiceName, s.setExports)
+	s.mut.Unlock()
 
 	s.fetch()
 
@@ -149,7 +206,7 @@ func (s *Service) Run(ctx context.Context, host service.Host) error {
 
 	for {
 		select {
-		case <-s.ch:
+		case <-s.ticker.C:
 			err := s.fetchRemote()
 			if err != nil {
 				level.Error(s.opts.Logger).Log("msg", "failed to fetch remote configuration from the API", "err", err)
@@ -169,7 +226,6 @@ func (s *Service) Update(newConfig any) error {
 	// it. Make sure we stop everything gracefully before returning.
 	if newArgs.URL == "" {
 		s.mut.Lock()
-		s.ch = nil
 		s.ticker.Reset(math.MaxInt64)
 		s.asClient = noopClient{}
 		s.args.HTTPClientConfig = config.CloneDefaultHTTPClientConfig()
@@ -180,13 +236,13 @@ func (s *Service) Update(newConfig any) error {
 	}
 
 	s.mut.Lock()
+	moduleArgsChanged := !reflect.DeepEqual(s.args.ModuleArguments, newArgs.ModuleArguments)
 	hash, err := newArgs.Hash()
 	if err != nil {
 		return err
 	}
 	s.dataPath = filepath.Join(s.opts.StoragePath, ServiceName, hash)
 	s.ticker.Reset(newArgs.PollFrequency)
-	s.ch = s.ticker.C
 	// Update the HTTP client last since it might fail.
 	if !reflect.DeepEqual(s.args.HTTPClientConfig, newArgs.HTTPClientConfig) {
 		httpClient, err := commonconfig.NewClientFromConfig(*newArgs.HTTPClientConfig.Convert(), "remoteconfig")
@@ -199,11 +255,18 @@ func (s *Service) Update(newConfig any) error {
 		)
 	}
 	s.args = newArgs // Update the args as the last step to avoid polluting any comparisons
+	ctrl := s.ctrl
 	s.mut.Unlock()
 
+	// The remote config must be loaded again to pass it the new module
+	// arguments, even if its content didn't change.
+	if moduleArgsChanged {
+		s.setCfgHash("")
+	}
+
 	// If we've already called Run, then immediately trigger an API call with
 	// the updated Arguments, and/or fall back to the updated cache location.
-	if s.ctrl != nil && s.ctrl.Ready() {
+	if ctrl != nil && ctrl.Ready() {
 		s.fetch()
 	}
 
@@ -297,13 +360,14 @@ func (s *Service) setCachedConfig(b []byte) {
 func (s *Service) parseAndLoad(b []byte) error {
 	s.mut.RLock()
 	ctrl := s.ctrl
+	args := s.args.ModuleArguments
 	s.mut.RUnlock()
 
 	if len(b) == 0 {
 		return nil
 	}
 
-	err := ctrl.LoadSource(b, nil)
+	err := ctrl.LoadSource(b, args)
 	if err != nil {
 		return err
 	}
@@ -103,6 +103,56 @@ func TestAPIResponse(t *testing.T) {
 	}, time.Second, 10*time.Millisecond)
 }
 
+func TestModuleArgumentsAndExports(t *testing.T) {
+	ctx := componenttest.TestContext(t)
+	url := "https://example.com/"
+	cfg := `
+		argument "greeting" {}
+
+		export "message" {
+			value = argument.greeting.value + ", world"
+		}
+	`
+
+	// Create a new service.
+	env := newTestEnvironment(t)
+	require.NoError(t, env.ApplyConfig(fmt.Sprintf(`
+		url = "%s"
+
+		arguments {
+			greeting = "hello"
+		}
+	`, url)))
+
+	client := &agentClient{}
+	env.svc.asClient = client
+	client.getConfigFunc = buildGetConfigHandler(cfg)
+
+	// Run the service.
+	go func() {
+		require.NoError(t, env.Run(ctx))
+	}()
+
+	// Verify that the remote config received the module arguments, and that
+	// its exports are exposed by the service.
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string]any{"message": "hello, world"}, env.svc.Exports())
+	}, time.Second, 10*time.Millisecond)
+
+	// Verify that the remote config is loaded again when the module arguments
+	// change, even though its content is the same.
+	require.NoError(t, env.ApplyConfig(fmt.Sprintf(`
+		url = "%s"
+
+		arguments {
+			greeting = "hi"
+		}
+	`, url)))
+	require.EventuallyWithT(t, func(c *assert.CollectT) {
+		assert.Equal(c, map[string]any{"message": "hi, world"}, env.svc.Exports())
+	}, time.Second, 10*time.Millisecond)
+}
+
 func buildGetConfigHandler(in string) func(context.Context, *connect.Request[agentv1.GetConfigRequest]) (*connect.Response[agentv1.GetConfigResponse], error) {
 	return func(context.Context, *connect.Request[agentv1.GetConfigRequest]) (*connect.Response[agentv1.GetConfigResponse], error) {
 		rsp := &connect.Response[agentv1.GetConfigResponse]{
@@ -163,7 +213,7 @@ func (fakeHost) ListComponents(moduleID string, opts component.InfoOptions) ([]*
 func (fakeHost) GetServiceConsumers(_ string) []service.Consumer { return nil }
 func (fakeHost) GetService(_ string) (service.Service, bool)     { return nil, false }
 
-func (f fakeHost) NewController(id string) service.Controller {
+func (f fakeHost) NewController(id string, onExportsChange func(map[string]any)) service.Controller {
 	logger, _ := logging.New(io.Discard, logging.DefaultOptions)
 	ctrl := flow.New(flow.Options{
 		ControllerID:    ServiceName,
@@ -172,7 +222,7 @@ func (f fakeHost) NewController(id string) service.Controller {
 		DataPath:        "",
 		MinStability:    featuregate.StabilityStable,
 		Reg:             prometheus.NewRegistry(),
-		OnExportsChange: func(map[string]interface{}) {},
+		OnExportsChange: onExportsChange,
 		Services:        []service.Service{},
 	})
 
@@ -0,0 +1,309 @@
+package secrets
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	aws_config "github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component/common/kubernetes"
+	"github.com/grafana/river/rivertypes"
+	vault "github.com/hashicorp/vault/api"
+	v1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	client_go "k8s.io/client-go/kubernetes"
+)
+
+// Provider fetches the values of secrets.
+type Provider interface {
+	// Fetch returns the value of the secret at path. The format of path
+	// depends on the provider.
+	Fetch(ctx context.Context, path string) (string, error)
+}
+
+// providerNames returns the names of all the providers of the arguments.
+func (a *Arguments) providerNames() []string {
+	var names []string
+	for _, p := range a.File {
+		names = append(names, p.Name)
+	}
+	for _, p := range a.Env {
+		names = append(names, p.Name)
+	}
+	for _, p := range a.Vault {
+		names = append(names, p.Name)
+	}
+	for _, p := range a.Kubernetes {
+		names = append(names, p.Name)
+	}
+	for _, p := range a.AWSSecretsManager {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// buildProviders returns the providers of the arguments by name.
+func (a *Arguments) buildProviders(l log.Logger) (map[string]Provider, error) {
+	providers := make(map[string]Provider)
+	for _, p := range a.File {
+		providers[p.Name] = p
+	}
+	for _, p := range a.Env {
+		providers[p.Name] = p
+	}
+	for _, p := range a.Vault {
+		client, err := p.client(a.FetchTimeout)
+		if err != nil {
+			return nil, fmt.Errorf("building client of Vault secret provider %q: %w", p.Name, err)
+		}
+		providers[p.Name] = &vaultProvider{client: client}
+	}
+	for _, p := range a.Kubernetes {
+		providers[p.Name] = &kubernetesProvider{args: p.Client, timeout: a.FetchTimeout, log: l}
+	}
+	for _, p := range a.AWSSecretsManager {
+		providers[p.Name] = &awsSecretsManagerProvider{region: p.Region, timeout: a.FetchTimeout}
+	}
+	return providers, nil
+}
+
+// splitKey splits a path of the form "PATH#KEY" into the path and the key of
+// a structured secret. key is empty if path doesn't have a key.
+func splitKey(path string) (string, string) {
+	if idx := strings.LastIndex(path, "#"); idx >= 0 {
+		return path[:idx], path[idx+1:]
+	}
+	return path, ""
+}
+
+// FileProvider reads secrets from files. The path of a secret is the path of
+// its file, relative to the directory of the provider.
+type FileProvider struct {
+	Name      string `river:",label"`
+	Directory string `river:"directory,attr"`
+}
+
+// Fetch implements Provider.
+func (p FileProvider) Fetch(_ context.Context, path string) (string, error) {
+	full := filepath.Join(p.Directory, filepath.FromSlash(path))
+	if rel, err := filepath.Rel(p.Directory, full); err != nil || strings.HasPrefix(rel, "..") {
+		return "", fmt.Errorf("path %q is outside of the directory of the provider", path)
+	}
+
+	bb, err := os.ReadFile(full)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(bb), "\r\n"), nil
+}
+
+// EnvProvider reads secrets from environment variables. The path of a secret
+// is the name of its environment variable, without the prefix of the
+// provider.
+type EnvProvider struct {
+	Name   string `river:",label"`
+	Prefix string `river:"prefix,attr,optional"`
+}
+
+// Fetch implements Provider.
+func (p EnvProvider) Fetch(_ context.Context, path string) (string, error) {
+	value, ok := os.LookupEnv(p.Prefix + path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %q is not set", p.Prefix+path)
+	}
+	return value, nil
+}
+
+// VaultProvider reads secrets from the KV v2 secrets engine of Vault. The path
+// of a secret has the form "MOUNT/PATH#KEY".
+type VaultProvider struct {
+	Name      string            `river:",label"`
+	Server    string            `river:"server,attr"`
+	Namespace string            `river:"namespace,attr,optional"`
+	Token     rivertypes.Secret `river:"token,attr"`
+}
+
+func (p VaultProvider) client(timeout time.Duration) (*vault.Client, error) {
+	cfg := vault.DefaultConfig()
+	cfg.Address = p.Server
+	cfg.Timeout = timeout
+	// Requests are retried within the timeout of the fetch instead.
+	cfg.MaxRetries = 0
+
+	cli, err := vault.NewClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if p.Namespace != "" {
+		cli.SetNamespace(p.Namespace)
+	}
+	cli.SetToken(string(p.Token))
+	return cli, nil
+}
+
+type vaultProvider struct {
+	client *vault.Client
+}
+
+// Fetch implements Provider.
+func (p *vaultProvider) Fetch(ctx context.Context, path string) (string, error) {
+	path, key := splitKey(path)
+	mount, secretPath, found := strings.Cut(path, "/")
+	if !found || key == "" {
+		return "", fmt.Errorf("path %q must have the form MOUNT/PATH#KEY", path)
+	}
+
+	secret, err := p.client.KVv2(mount).Get(ctx, secretPath)
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret %q", key, path)
+	}
+	return fmt.Sprint(value), nil
+}
+
+// KubernetesProvider reads secrets from Kubernetes Secrets. The path of a
+// secret has the form "NAMESPACE/NAME#KEY".
+type KubernetesProvider struct {
+	Name   string                     `river:",label"`
+	Client kubernetes.ClientArguments `river:"client,block,optional"`
+}
+
+type kubernetesProvider struct {
+	args    kubernetes.ClientArguments
+	timeout time.Duration
+	log     log.Logger
+
+	// The client is built when the first secret is fetched, so that the
+	// provider can be defined without access to a cluster. Building it again
+	// is attempted on every fetch until it succeeds.
+	mut    sync.Mutex
+	client client_go.Interface
+}
+
+// Fetch implements Provider.
+func (p *kubernetesProvider) Fetch(ctx context.Context, path string) (string, error) {
+	path, key := splitKey(path)
+	namespace, name, found := strings.Cut(path, "/")
+	if !found || key == "" {
+		return "", fmt.Errorf("path %q must have the form NAMESPACE/NAME#KEY", path)
+	}
+
+	client, err := p.getClient()
+	if err != nil {
+		return "", fmt.Errorf("building Kubernetes client: %w", err)
+	}
+
+	secret, err := client.CoreV1().Secrets(namespace).Get(ctx, name, v1.GetOptions{})
+	if err != nil {
+		return "", err
+	}
+	value, ok := secret.Data[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret %q", key, path)
+	}
+	return string(value), nil
+}
+
+// getClient returns the client of the provider, building it if it wasn't
+// built yet.
+func (p *kubernetesProvider) getClient() (client_go.Interface, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+	restConfig, err := p.args.BuildRESTConfig(p.log)
+	if err != nil {
+		return nil, err
+	}
+	restConfig.Timeout = p.timeout
+	client, err := client_go.NewForConfig(restConfig)
+	if err != nil {
+		return nil, err
+	}
+	p.client = client
+	return client, nil
+}
+
+// AWSSecretsManagerProvider reads secrets from AWS Secrets Manager. The path
+// of a secret is its name or ARN, optionally followed by "#KEY" to read a key
+// of a secret whose value is a JSON object. Credentials are read from the
+// default credential chain of AWS.
+type AWSSecretsManagerProvider struct {
+	Name   string `river:",label"`
+	Region string `river:"region,attr,optional"`
+}
+
+type awsSecretsManagerProvider struct {
+	region  string
+	timeout time.Duration
+
+	// The client is built when the first secret is fetched, and again on
+	// every fetch until it succeeds.
+	mut    sync.Mutex
+	client *secretsmanager.Client
+}
+
+// Fetch implements Provider.
+func (p *awsSecretsManagerProvider) Fetch(ctx context.Context, path string) (string, error) {
+	id, key := splitKey(path)
+
+	client, err := p.getClient(ctx)
+	if err != nil {
+		return "", fmt.Errorf("loading AWS config: %w", err)
+	}
+
+	out, err := client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{SecretId: aws.String(id)})
+	if err != nil {
+		return "", err
+	}
+	value := aws.ToString(out.SecretString)
+	if key == "" {
+		return value, nil
+	}
+
+	var fields map[string]any
+	if err := json.Unmarshal([]byte(value), &fields); err != nil {
+		return "", fmt.Errorf("secret %q isn't a JSON object: %w", id, err)
+	}
+	field, ok := fields[key]
+	if !ok {
+		return "", fmt.Errorf("key %q not found in secret %q", key, id)
+	}
+	return fmt.Sprint(field), nil
+}
+
+// getClient returns the client of the provider, building it if it wasn't
+// built yet. ctx only bounds loading the config; the client doesn't keep it.
+func (p *awsSecretsManagerProvider) getClient(ctx context.Context) (*secretsmanager.Client, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	if p.client != nil {
+		return p.client, nil
+	}
+	opts := []func(*aws_config.LoadOptions) error{
+		aws_config.WithHTTPClient(awshttp.NewBuildableClient().WithTimeout(p.timeout)),
+	}
+	if p.region != "" {
+		opts = append(opts, aws_config.WithRegion(p.region))
+	}
+	cfg, err := aws_config.LoadDefaultConfig(ctx, opts...)
+	if err != nil {
+		return nil, err
+	}
+	p.client = secretsmanager.NewFromConfig(cfg)
+	return p.client, nil
+}
@@ -0,0 +1,323 @@
+// Package secrets implements a service which fetches secrets from providers
+// such as files, environment variables, Vault, Kubernetes, or cloud secret
+// managers, and exposes them to the config.
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/featuregate"
+	"github.com/grafana/agent/internal/flow/logging/level"
+	"github.com/grafana/agent/internal/service"
+	"github.com/grafana/river/rivertypes"
+)
+
+// ServiceName defines the name used for the secrets service.
+const ServiceName = "secrets"
+
+// Options are used to configure the secrets service. Options are constant
+// for the lifetime of the secrets service.
+type Options struct {
+	Logger log.Logger // Where to send logs, including the audit logs.
+
+	// DryRun only checks that the providers of secrets are defined, without
+	// fetching secrets. The value of every secret is empty.
+	DryRun bool
+}
+
+// Arguments holds runtime settings for the secrets service.
+type Arguments struct {
+	// CacheTTL is how long fetched secrets are cached. Cached secrets are
+	// fetched again when they expire, and components referencing them are
+	// evaluated again if their value changed.
+	CacheTTL time.Duration `river:"cache_ttl,attr,optional"`
+
+	// FetchTimeout is how long fetching a secret from its provider may take.
+	// Secrets are fetched while the config is evaluated, so an unreachable
+	// provider must not block the evaluation.
+	FetchTimeout time.Duration `river:"fetch_timeout,attr,optional"`
+
+	File              []FileProvider              `river:"file,block,optional"`
+	Env               []EnvProvider               `river:"env,block,optional"`
+	Vault             []VaultProvider             `river:"vault,block,optional"`
+	Kubernetes        []KubernetesProvider        `river:"kubernetes,block,optional"`
+	AWSSecretsManager []AWSSecretsManagerProvider `river:"aws_secrets_manager,block,optional"`
+}
+
+// DefaultArguments holds the default settings for the secrets service.
+var DefaultArguments = Arguments{
+	CacheTTL:     5 * time.Minute,
+	FetchTimeout: 10 * time.Second,
+}
+
+// SetToDefault implements river.Defaulter.
+func (a *Arguments) SetToDefault() {
+	*a = DefaultArguments
+}
+
+// Validate implements river.Validator.
+func (a *Arguments) Validate() error {
+	if a.CacheTTL <= 0 {
+		return fmt.Errorf("cache_ttl must be greater than 0")
+	}
+	if a.FetchTimeout <= 0 {
+		return fmt.Errorf("fetch_timeout must be greater than 0")
+	}
+
+	names := make(map[string]struct{})
+	for _, name := range a.providerNames() {
+		if _, exist := names[name]; exist {
+			return fmt.Errorf("secret provider %q is defined more than once", name)
+		}
+		names[name] = struct{}{}
+	}
+	return nil
+}
+
+// Service implements the secrets service.
+type Service struct {
+	opts Options
+
+	// ticker fires when the cached secrets must be refreshed. It's reset to the
+	// maximum duration until the service is updated.
+	ticker *time.Ticker
+
+	mut         sync.RWMutex
+	args        Arguments
+	providers   map[string]Provider
+	cache       map[secretKey]*cachedSecret
+	subscribers map[int]func()
+	nextID      int
+}
+
+// secretKey identifies a secret by the name of its provider and its path.
+type secretKey struct {
+	provider, path string
+}
+
+type cachedSecret struct {
+	value     string
+	fetchedAt time.Time
+}
+
+var (
+	_ service.Service  = (*Service)(nil)
+	_ service.Exporter = (*Service)(nil)
+)
+
+// New returns a new, unstarted instance of the secrets service.
+func New(opts Options) *Service {
+	if opts.Logger == nil {
+		opts.Logger = log.NewNopLogger()
+	}
+
+	return &Service{
+		opts:        opts,
+		ticker:      time.NewTicker(math.MaxInt64),
+		providers:   make(map[string]Provider),
+		cache:       make(map[secretKey]*cachedSecret),
+		subscribers: make(map[int]func()),
+	}
+}
+
+// Definition returns the definition of the secrets service.
+func (s *Service) Definition() service.Definition {
+	return service.Definition{
+		Name:       ServiceName,
+		ConfigType: Arguments{},
+		DependsOn:  nil, // secrets has no dependencies.
+		Stability:  featuregate.StabilityExperimental,
+	}
+}
+
+// Run implements [service.Service] and refreshes the cached secrets until
+// ctx is canceled.
+func (s *Service) Run(ctx context.Context, _ service.Host) error {
+	defer s.ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-s.ticker.C:
+			s.refresh(ctx)
+		}
+	}
+}
+
+// Update implements [service.Service] and applies settings.
+func (s *Service) Update(newConfig any) error {
+	newArgs := newConfig.(Arguments)
+
+	providers := make(map[string]Provider)
+	if !s.opts.DryRun {
+		var err error
+		if providers, err = newArgs.buildProviders(s.opts.Logger); err != nil {
+			return err
+		}
+	} else {
+		for _, name := range newArgs.providerNames() {
+			providers[name] = nil
+		}
+	}
+
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	s.args = newArgs
+	s.providers = providers
+	// Secrets are fetched again from the updated providers by the components
+	// referencing them, which are evaluated after the service.
+	s.cache = make(map[secretKey]*cachedSecret)
+	s.ticker.Reset(newArgs.CacheTTL)
+	return nil
+}
+
+// Data returns nil; components reference secrets through the exports of the
+// service.
+func (s *Service) Data() any {
+	return nil
+}
+
+// Exports implements [service.Exporter]. Secrets are referenced in the config
+// with the get function:
+//
+//	password = secrets.get("PROVIDER", "PATH")
+func (s *Service) Exports() any {
+	return map[string]any{
+		"get": s.get,
+	}
+}
+
+// SubscribeExports implements [service.Exporter]. notify is called when the
+// value of a cached secret changed.
+func (s *Service) SubscribeExports(notify func()) (unsubscribe func()) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	id := s.nextID
+	s.nextID++
+	s.subscribers[id] = notify
+
+	return func() {
+		s.mut.Lock()
+		defer s.mut.Unlock()
+		delete(s.subscribers, id)
+	}
+}
+
+// get returns the secret at path from the named provider. Cached secrets are
+// returned until they expire.
+func (s *Service) get(provider, path string) (rivertypes.Secret, error) {
+	key := secretKey{provider: provider, path: path}
+
+	s.mut.RLock()
+	p, found := s.providers[provider]
+	cached := s.cache[key]
+	ttl := s.args.CacheTTL
+	timeout := s.args.FetchTimeout
+	s.mut.RUnlock()
+
+	if !found {
+		return "", fmt.Errorf("secret provider %q is not defined in the secrets block", provider)
+	}
+	if s.opts.DryRun {
+		return "", nil
+	}
+	if cached != nil && time.Since(cached.fetchedAt) < ttl {
+		s.audit(key, "cache", nil)
+		return rivertypes.Secret(cached.value), nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	value, err := p.Fetch(ctx, path)
+	s.audit(key, "provider", err)
+	if err != nil {
+		return "", fmt.Errorf("fetching secret %q from provider %q: %w", path, provider, err)
+	}
+
+	if s.store(key, value) {
+		// Other components may reference the previous value of the secret.
+		s.notify()
+	}
+	return rivertypes.Secret(value), nil
+}
+
+// store caches the value of a secret, and returns whether it replaced a
+// different value.
+func (s *Service) store(key secretKey, value string) (changed bool) {
+	s.mut.Lock()
+	defer s.mut.Unlock()
+
+	prev := s.cache[key]
+	s.cache[key] = &cachedSecret{value: value, fetchedAt: time.Now()}
+	return prev != nil && prev.value != value
+}
+
+// refresh fetches the expired secrets of the cache again, and notifies the
+// subscribers if any of them changed.
+func (s *Service) refresh(ctx context.Context) {
+	s.mut.RLock()
+	var (
+		ttl     = s.args.CacheTTL
+		timeout = s.args.FetchTimeout
+		expired = make(map[secretKey]Provider)
+	)
+	for key, cached := range s.cache {
+		if time.Since(cached.fetchedAt) >= ttl {
+			expired[key] = s.providers[key.provider]
+		}
+	}
+	s.mut.RUnlock()
+
+	var changed bool
+	for key, p := range expired {
+		fetchCtx, cancel := context.WithTimeout(ctx, timeout)
+		value, err := p.Fetch(fetchCtx, key.path)
+		cancel()
+		s.audit(key, "refresh", err)
+		if err != nil {
+			// Keep the cached value until the provider is available again.
+			level.Warn(s.opts.Logger).Log("msg", "failed to refresh secret", "provider", key.provider, "path", key.path, "err", err)
+			continue
+		}
+		if s.store(key, value) {
+			changed = true
+		}
+	}
+
+	if changed {
+		s.notify()
+	}
+}
+
+// notify calls the subscribers of the service.
+func (s *Service) notify() {
+	s.mut.RLock()
+	defer s.mut.RUnlock()
+
+	for _, notify := range s.subscribers {
+		notify()
+	}
+}
+
+// audit logs an access to a secret. source is where the value of the secret
+// comes from. Fetches from providers and failures are logged at info level
+// and above; cache hits, which happen on every evaluation, at debug level.
+func (s *Service) audit(key secretKey, source string, err error) {
+	logger := log.With(s.opts.Logger, "msg", "secret accessed", "provider", key.provider, "path", key.path, "source", source)
+	switch {
+	case err != nil:
+		level.Warn(logger).Log("err", err)
+	case source == "cache":
+		level.Debug(logger).Log()
+	default:
+		level.Info(logger).Log()
+	}
+}
@@ -0,0 +1,225 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/agent/internal/component/common/kubernetes"
+	"github.com/grafana/agent/internal/util"
+	"github.com/grafana/river"
+	"github.com/grafana/river/rivertypes"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeProvider returns the values of its map, and counts the fetches.
+type fakeProvider struct {
+	mut     sync.Mutex
+	values  map[string]string
+	fetches int
+}
+
+func (p *fakeProvider) Fetch(_ context.Context, path string) (string, error) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+
+	p.fetches++
+	value, ok := p.values[path]
+	if !ok {
+		return "", fmt.Errorf("secret %q not found", path)
+	}
+	return value, nil
+}
+
+func (p *fakeProvider) set(path, value string) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.values[path] = value
+}
+
+// newTestService returns a secrets service using the given provider under the
+// name "fake".
+func newTestService(t *testing.T, ttl time.Duration, p Provider) *Service {
+	s := New(Options{Logger: util.TestLogger(t)})
+	args := DefaultArguments
+	args.CacheTTL = ttl
+	require.NoError(t, s.Update(args))
+	s.providers["fake"] = p
+	return s
+}
+
+func TestGet(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"db/password": "secret"}}
+	s := newTestService(t, time.Hour, p)
+
+	get := s.Exports().(map[string]any)["get"].(func(string, string) (rivertypes.Secret, error))
+
+	value, err := get("fake", "db/password")
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("secret"), value)
+
+	// Secrets are cached until they expire.
+	p.set("db/password", "rotated")
+	value, err = get("fake", "db/password")
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("secret"), value)
+	require.Equal(t, 1, p.fetches)
+
+	_, err = get("fake", "missing")
+	require.ErrorContains(t, err, `fetching secret "missing" from provider "fake": secret "missing" not found`)
+
+	_, err = get("undefined", "db/password")
+	require.ErrorContains(t, err, `secret provider "undefined" is not defined in the secrets block`)
+}
+
+// blockingProvider never returns a secret before its context is canceled,
+// like an unreachable provider.
+type blockingProvider struct{}
+
+func (blockingProvider) Fetch(ctx context.Context, _ string) (string, error) {
+	<-ctx.Done()
+	return "", ctx.Err()
+}
+
+func TestGet_Timeout(t *testing.T) {
+	s := New(Options{Logger: util.TestLogger(t)})
+	args := DefaultArguments
+	args.FetchTimeout = 50 * time.Millisecond
+	require.NoError(t, s.Update(args))
+	s.providers["fake"] = blockingProvider{}
+
+	start := time.Now()
+	_, err := s.get("fake", "db/password")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Less(t, time.Since(start), 5*time.Second)
+}
+
+func TestRotation(t *testing.T) {
+	p := &fakeProvider{values: map[string]string{"db/password": "secret"}}
+	s := newTestService(t, 10*time.Millisecond, p)
+
+	notified := make(chan struct{}, 10)
+	unsubscribe := s.SubscribeExports(func() { notified <- struct{}{} })
+	defer unsubscribe()
+
+	value, err := s.get("fake", "db/password")
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("secret"), value)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { require.NoError(t, s.Run(ctx, nil)) }()
+
+	// Secrets whose value didn't change don't notify the subscribers.
+	require.Eventually(t, func() bool {
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		return p.fetches >= 3
+	}, time.Second, 5*time.Millisecond)
+	require.Empty(t, notified)
+
+	p.set("db/password", "rotated")
+	select {
+	case <-notified:
+	case <-time.After(time.Second):
+		t.Fatal("subscribers weren't notified of the rotation")
+	}
+
+	value, err = s.get("fake", "db/password")
+	require.NoError(t, err)
+	require.Equal(t, rivertypes.Secret("rotated"), value)
+}
+
+func TestDryRun(t *testing.T) {
+	s := New(Options{Logger: util.TestLogger(t), DryRun: true})
+
+	var args Arguments
+	require.NoError(t, river.Unmarshal([]byte(`
+		vault "prod" {
+			server = "https://vault.example.com"
+			token  = "token"
+		}
+	`), &args))
+	require.NoError(t, s.Update(args))
+
+	value, err := s.get("prod", "secret/db#password")
+	require.NoError(t, err)
+	require.Empty(t, value)
+
+	_, err = s.get("undefined", "secret/db#password")
+	require.ErrorContains(t, err, `secret provider "undefined" is not defined in the secrets block`)
+}
+
+func TestArguments_Validate(t *testing.T) {
+	var args Arguments
+	err := river.Unmarshal([]byte(`
+		env "a" { }
+		file "a" {
+			directory = "/etc/secrets"
+		}
+	`), &args)
+	require.ErrorContains(t, err, `secret provider "a" is defined more than once`)
+}
+
+func TestFileProvider(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "db"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "db", "password"), []byte("secret\n"), 0o600))
+
+	p := FileProvider{Name: "files", Directory: dir}
+
+	value, err := p.Fetch(context.Background(), "db/password")
+	require.NoError(t, err)
+	require.Equal(t, "secret", value)
+
+	_, err = p.Fetch(context.Background(), "../outside")
+	require.ErrorContains(t, err, `path "../outside" is outside of the directory of the provider`)
+}
+
+func TestEnvProvider(t *testing.T) {
+	t.Setenv("TEST_SECRET_PASSWORD", "secret")
+
+	p := EnvProvider{Name: "env", Prefix: "TEST_SECRET_"}
+
+	value, err := p.Fetch(context.Background(), "PASSWORD")
+	require.NoError(t, err)
+	require.Equal(t, "secret", value)
+
+	_, err = p.Fetch(context.Background(), "MISSING")
+	require.ErrorContains(t, err, `environment variable "TEST_SECRET_MISSING" is not set`)
+}
+
+func TestKubernetesProvider_RetriesClient(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "kubeconfig")
+	p := &kubernetesProvider{
+		args:    kubernetes.ClientArguments{KubeConfig: kubeconfig},
+		timeout: time.Second,
+		log:     log.NewNopLogger(),
+	}
+
+	_, err := p.Fetch(context.Background(), "default/db#password")
+	require.ErrorContains(t, err, "building Kubernetes client")
+
+	// The client is built once the kubeconfig is available.
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`
+apiVersion: v1
+kind: Config
+clusters:
+- name: test
+  cluster:
+    server: http://127.0.0.1:1
+contexts:
+- name: test
+  context:
+    cluster: test
+current-context: test
+`), 0o600))
+	_, err = p.Fetch(context.Background(), "default/db#password")
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "building Kubernetes client")
+}
@@ -68,8 +68,10 @@ type Host interface {
 	GetServiceConsumers(serviceName string) []Consumer
 
 	// NewController returns an unstarted, isolated Controller that a Service
-	// can use to instantiate its own components.
-	NewController(id string) Controller
+	// can use to instantiate its own components. onExportsChange is called
+	// when the values of the export blocks of the loaded source change. If
+	// onExportsChange is nil, export blocks aren't allowed in the loaded source.
+	NewController(id string, onExportsChange func(exports map[string]any)) Controller
 }
 
 // Controller is implemented by flow.Flow.
@@ -143,3 +145,22 @@ type Service interface {
 	// Data may be invoked before Run.
 	Data() any
 }
+
+// Exporter is a Service which exposes a value to the config of the root Flow
+// module, where it's referenced by the name of the service. Components
+// referencing the value depend on the service, like they would depend on
+// another component.
+type Exporter interface {
+	Service
+
+	// Exports returns the value exposed to the config. Like Data, Exports must
+	// always return the same value across multiple calls. Values which change
+	// at runtime must be exposed through functions.
+	Exports() any
+
+	// SubscribeExports registers a function which is called when the
+	// expressions referencing the exported value must be evaluated again, such
+	// as when values returned by its functions changed. The returned function
+	// removes the subscription.
+	SubscribeExports(notify func()) (unsubscribe func())
+}
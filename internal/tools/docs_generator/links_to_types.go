@@ -8,6 +8,10 @@ import (
 	"github.com/grafana/agent/internal/component/metadata"
 )
 
+// compatibilityPagePath is the path to the compatibility page from the
+// reference page of a component.
+const compatibilityPagePath = "../../compatibility/"
+
 type LinksToTypesGenerator struct {
 	component string
 }
@@ -30,8 +34,8 @@ func (l *LinksToTypesGenerator) Generate() (string, error) {
 	}
 
 	heading := "\n## Compatible components\n\n"
-	acceptingSection := acceptingComponentsSection(l.component, meta)
-	outputSection := outputComponentsSection(l.component, meta)
+	acceptingSection := acceptingComponentsSection(l.component, meta, compatibilityPagePath)
+	outputSection := outputComponentsSection(l.component, meta, compatibilityPagePath)
 
 	if acceptingSection == "" && outputSection == "" {
 		return "", nil
@@ -79,13 +83,25 @@ func (l *LinksToTypesGenerator) pathToComponentMarkdown() string {
 	return fmt.Sprintf("../../../docs/sources/flow/reference/components/%s.md", l.component)
 }
 
-func outputComponentsSection(name string, meta metadata.Metadata) string {
+// CompatibleComponents returns the sections listing the components compatible
+// with the given component, as they appear in its reference page. Links point
+// to the compatibility page at compatibilityURL.
+func CompatibleComponents(name string, compatibilityURL string) (string, error) {
+	meta, err := metadata.ForComponent(name)
+	if err != nil {
+		return "", err
+	}
+	return acceptingComponentsSection(name, meta, compatibilityURL) + outputComponentsSection(name, meta, compatibilityURL), nil
+}
+
+func outputComponentsSection(name string, meta metadata.Metadata, compatibilityURL string) string {
 	section := ""
 	for _, outputDataType := range meta.AllTypesExported() {
 		if list := allComponentsThatAccept(outputDataType); len(list) > 0 {
 			section += fmt.Sprintf(
-				"- Components that consume [%s](../../compatibility/%s)\n",
+				"- Components that consume [%s](%s%s)\n",
 				outputDataType.Name,
+				compatibilityURL,
 				anchorFor(outputDataType.Name, "consumers"),
 			)
 		}
@@ -96,13 +112,14 @@ func outputComponentsSection(name string, meta metadata.Metadata) string {
 	return section
 }
 
-func acceptingComponentsSection(componentName string, meta metadata.Metadata) string {
+func acceptingComponentsSection(componentName string, meta metadata.Metadata, compatibilityURL string) string {
 	section := ""
 	for _, acceptedDataType := range meta.AllTypesAccepted() {
 		if list := allComponentsThatExport(acceptedDataType); len(list) > 0 {
 			section += fmt.Sprintf(
-				"- Components that export [%s](../../compatibility/%s)\n",
+				"- Components that export [%s](%s%s)\n",
 				acceptedDataType.Name,
+				compatibilityURL,
 				anchorFor(acceptedDataType.Name, "exporters"),
 			)
 		}
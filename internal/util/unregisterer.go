@@ -1,12 +1,18 @@
 package util
 
-import "github.com/prometheus/client_golang/prometheus"
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
 
 // Unregisterer is a Prometheus Registerer that can unregister all collectors
-// passed to it.
+// passed to it. Unregisterer is safe for concurrent use.
 type Unregisterer struct {
 	wrap prometheus.Registerer
-	cs   map[prometheus.Collector]struct{}
+
+	mut sync.Mutex
+	cs  map[prometheus.Collector]struct{}
 }
 
 // WrapWithUnregisterer wraps a prometheus Registerer with capabilities to
@@ -65,6 +71,8 @@ func (u *Unregisterer) Register(c prometheus.Collector) error {
 		return nil
 	}
 
+	u.mut.Lock()
+	defer u.mut.Unlock()
 	u.cs[c] = struct{}{}
 	return nil
 }
@@ -85,6 +93,8 @@ func (u *Unregisterer) Unregister(c prometheus.Collector) bool {
 	}
 
 	if u.wrap != nil && u.wrap.Unregister(c) {
+		u.mut.Lock()
+		defer u.mut.Unlock()
 		delete(u.cs, c)
 		return true
 	}
@@ -94,8 +104,15 @@ func (u *Unregisterer) Unregister(c prometheus.Collector) bool {
 // UnregisterAll unregisters all collectors that were registered through the
 // Registerer.
 func (u *Unregisterer) UnregisterAll() bool {
-	success := true
+	u.mut.Lock()
+	cs := make([]prometheus.Collector, 0, len(u.cs))
 	for c := range u.cs {
+		cs = append(cs, c)
+	}
+	u.mut.Unlock()
+
+	success := true
+	for _, c := range cs {
 		if !u.Unregister(c) {
 			success = false
 		}
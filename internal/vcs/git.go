@@ -16,6 +16,11 @@ type GitRepoOptions struct {
 	Repository string
 	Revision   string
 	Auth       GitAuthConfig
+
+	// Version is a semantic version constraint. If set, the repository is
+	// checked out to the highest tag satisfying the constraint instead of
+	// Revision.
+	Version string
 }
 
 // GitRepo manages a Git repository for the purposes of retrieving a file from
@@ -24,6 +29,7 @@ type GitRepo struct {
 	opts     GitRepoOptions
 	repo     *git.Repository
 	workTree *git.Worktree
+	version  string // Tag checked out when opts.Version is set.
 }
 
 // NewGitRepo creates a new instance of a GitRepo, where the Git repository is
@@ -86,7 +92,14 @@ func NewGitRepo(ctx context.Context, storagePath string, opts GitRepoOptions) (*
 			}
 	}
 
-	checkoutErr := checkout(opts.Revision, repo)
+	rev, err := resolveRevision(ctx, repo, opts)
+	if err != nil {
+		return nil, UpdateFailedError{
+			Repository: opts.Repository,
+			Inner:      err,
+		}
+	}
+	checkoutErr := checkout(rev, repo)
 	if checkoutErr != nil {
 		return nil, UpdateFailedError{
 			Repository: opts.Repository,
@@ -94,11 +107,15 @@ func NewGitRepo(ctx context.Context, storagePath string, opts GitRepoOptions) (*
 		}
 	}
 
-	return &GitRepo{
+	gitRepo := &GitRepo{
 		opts:     opts,
 		repo:     repo,
 		workTree: wt,
-	}, err
+	}
+	if opts.Version != "" {
+		gitRepo.version = rev
+	}
+	return gitRepo, nil
 }
 
 func isRepoCloned(dir string) bool {
@@ -121,13 +138,23 @@ func (repo *GitRepo) Update(ctx context.Context) error {
 		}
 	}
 
-	checkoutErr := checkout(repo.opts.Revision, repo.repo)
+	rev, err := resolveRevision(ctx, repo.repo, repo.opts)
+	if err != nil {
+		return UpdateFailedError{
+			Repository: repo.opts.Repository,
+			Inner:      err,
+		}
+	}
+	checkoutErr := checkout(rev, repo.repo)
 	if checkoutErr != nil {
 		return UpdateFailedError{
 			Repository: repo.opts.Repository,
 			Inner:      checkoutErr,
 		}
 	}
+	if repo.opts.Version != "" {
+		repo.version = rev
+	}
 
 	return nil
 }
@@ -170,6 +197,12 @@ func (repo *GitRepo) CurrentRevision() (string, error) {
 	return ref.Hash().String(), nil
 }
 
+// CurrentVersion returns the tag checked out to satisfy the Version
+// constraint. It returns an empty string if no Version constraint is set.
+func (repo *GitRepo) CurrentVersion() string {
+	return repo.version
+}
+
 // Depending on the type of revision we need to handle checkout differently.
 // Tags are checked out as branches
 // Branches as branches
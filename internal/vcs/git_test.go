@@ -59,6 +59,54 @@ func Test_GitRepo(t *testing.T) {
 	require.Equal(t, "See you later!", string(bb))
 }
 
+func Test_GitRepo_Version(t *testing.T) {
+	origRepo := initRepository(t)
+
+	// Commit a file for every version and tag the commit.
+	commitVersion := func(version string) {
+		err := origRepo.WriteFile("version.txt", []byte(version))
+		require.NoError(t, err)
+
+		_, err = origRepo.Worktree.Add(".")
+		require.NoError(t, err)
+
+		hash, err := origRepo.Worktree.Commit(version, &git.CommitOptions{})
+		require.NoError(t, err)
+
+		_, err = origRepo.Repo.CreateTag(version, hash, nil)
+		require.NoError(t, err)
+	}
+	for _, version := range []string{"v1.3.0", "v1.4.0", "v1.4.1", "v1.5.0", "v2.0.0-rc.1"} {
+		commitVersion(version)
+	}
+
+	newRepo, err := vcs.NewGitRepo(context.Background(), t.TempDir(), vcs.GitRepoOptions{
+		Repository: origRepo.Directory,
+		Version:    "~1.4",
+	})
+	require.NoError(t, err)
+	require.Equal(t, "v1.4.1", newRepo.CurrentVersion())
+
+	bb, err := newRepo.ReadFile("version.txt")
+	require.NoError(t, err)
+	require.Equal(t, "v1.4.1", string(bb))
+
+	// New versions are checked out on update.
+	commitVersion("v1.4.2")
+	require.NoError(t, newRepo.Update(context.Background()))
+	require.Equal(t, "v1.4.2", newRepo.CurrentVersion())
+
+	bb, err = newRepo.ReadFile("version.txt")
+	require.NoError(t, err)
+	require.Equal(t, "v1.4.2", string(bb))
+
+	_, err = vcs.NewGitRepo(context.Background(), t.TempDir(), vcs.GitRepoOptions{
+		Repository: origRepo.Directory,
+		Version:    "^3",
+	})
+	require.ErrorAs(t, err, &vcs.NoMatchingVersionError{})
+}
+
 type testRepository struct {
 	Directory string
 	Repo      *git.Repository
@@ -0,0 +1,90 @@
+package vcs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/Masterminds/semver/v3"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+)
+
+// NoMatchingVersionError is returned when no tag of a repository satisfies a
+// version constraint.
+type NoMatchingVersionError struct {
+	Constraint string
+}
+
+// Error returns the error string, denoting the unsatisfied constraint.
+func (err NoMatchingVersionError) Error() string {
+	return fmt.Sprintf("no tag matches version %q", err.Constraint)
+}
+
+// ValidateVersionConstraint returns an error if constraint isn't a valid
+// semantic version constraint, such as "~1.4" or ">= 1.2, < 2".
+func ValidateVersionConstraint(constraint string) error {
+	_, err := semver.NewConstraint(constraint)
+	return err
+}
+
+// resolveRevision returns the revision to check out for opts. If opts.Version
+// is set, the tags of the repository are fetched to find the highest version
+// satisfying the constraint.
+func resolveRevision(ctx context.Context, repo *git.Repository, opts GitRepoOptions) (string, error) {
+	if opts.Version == "" {
+		return opts.Revision, nil
+	}
+
+	// Pulling only retrieves the tags pointing to pulled commits, so tags are
+	// fetched explicitly to find new versions.
+	err := repo.FetchContext(ctx, &git.FetchOptions{
+		RemoteName: "origin",
+		Auth:       opts.Auth.Convert(),
+		Tags:       git.AllTags,
+	})
+	if err != nil && !errors.Is(err, git.NoErrAlreadyUpToDate) {
+		return "", err
+	}
+
+	iter, err := repo.Tags()
+	if err != nil {
+		return "", err
+	}
+	var tags []string
+	err = iter.ForEach(func(ref *plumbing.Reference) error {
+		tags = append(tags, ref.Name().Short())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	return resolveVersion(tags, opts.Version)
+}
+
+// resolveVersion returns the tag with the highest semantic version satisfying
+// constraint. Tags which aren't semantic versions are ignored.
+func resolveVersion(tags []string, constraint string) (string, error) {
+	c, err := semver.NewConstraint(constraint)
+	if err != nil {
+		return "", fmt.Errorf("invalid version constraint %q: %w", constraint, err)
+	}
+
+	var (
+		best    *semver.Version
+		bestTag string
+	)
+	for _, tag := range tags {
+		v, err := semver.NewVersion(tag)
+		if err != nil || !c.Check(v) {
+			continue
+		}
+		if best == nil || v.GreaterThan(best) {
+			best, bestTag = v, tag
+		}
+	}
+	if best == nil {
+		return "", NoMatchingVersionError{Constraint: constraint}
+	}
+	return bestTag, nil
+}
@@ -6,6 +6,8 @@ package api
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"path"
 
@@ -36,6 +38,11 @@ func (f *FlowAPI) RegisterRoutes(urlPrefix string, r *mux.Router) {
 	r.Handle(path.Join(urlPrefix, "/components"), httputil.CompressionHandler{Handler: f.listComponentsHandler()})
 	r.Handle(path.Join(urlPrefix, "/components/{id:.+}"), httputil.CompressionHandler{Handler: f.getComponentHandler()})
 	r.Handle(path.Join(urlPrefix, "/peers"), httputil.CompressionHandler{Handler: f.getClusteringPeersHandler()})
+	r.Handle(path.Join(urlPrefix, "/peers/readiness"), httputil.CompressionHandler{Handler: f.getClusteringReadinessHandler()})
+	r.Handle(path.Join(urlPrefix, "/peers/kv"), httputil.CompressionHandler{Handler: f.getClusteringKVHandler()})
+	r.Handle(path.Join(urlPrefix, "/peers/ring"), httputil.CompressionHandler{Handler: f.getClusteringRingHandler()})
+	r.Handle(path.Join(urlPrefix, "/peers/ownership"), httputil.CompressionHandler{Handler: f.getClusteringOwnershipHandler()})
+	r.Handle(path.Join(urlPrefix, "/config"), httputil.CompressionHandler{Handler: f.getConfigHandler()})
 }
 
 func (f *FlowAPI) listComponentsHandler() http.HandlerFunc {
@@ -107,3 +114,188 @@ func (f *FlowAPI) getClusteringPeersHandler() http.HandlerFunc {
 		_, _ = w.Write(bb)
 	}
 }
+
+// getClusteringReadinessHandler returns whether the local node is ready to
+// participate in the cluster, and why it isn't.
+func (f *FlowAPI) getClusteringReadinessHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		svc, found := f.flow.GetService(cluster.ServiceName)
+		if !found {
+			http.Error(w, "cluster service not running", http.StatusInternalServerError)
+			return
+		}
+		reporter, ok := svc.Data().(cluster.ReadinessReporter)
+		if !ok {
+			http.Error(w, "cluster service doesn't report readiness", http.StatusInternalServerError)
+			return
+		}
+		bb, err := json.Marshal(reporter.Readiness())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// getClusteringKVHandler lists the keys of the key/value store shared by the
+// nodes of the cluster, as seen by the local node.
+func (f *FlowAPI) getClusteringKVHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		svc, found := f.flow.GetService(cluster.ServiceName)
+		if !found {
+			http.Error(w, "cluster service not running", http.StatusInternalServerError)
+			return
+		}
+		kv, ok := svc.Data().(cluster.KV)
+		if !ok {
+			http.Error(w, "cluster service doesn't provide a key/value store", http.StatusInternalServerError)
+			return
+		}
+		bb, err := json.Marshal(kv.Entries())
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// getClusteringRingHandler returns the ring of the cluster. Peers named by
+// the leaving query parameter are removed from the ring, to simulate them
+// leaving the cluster.
+func (f *FlowAPI) getClusteringRingHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector, err := f.ringInspector()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		bb, err := json.Marshal(inspector.Ring(r.URL.Query()["leaving"]...))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// componentOwnership describes how the targets of a clustered component are
+// distributed between peers.
+type componentOwnership struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+
+	// Owners is the number of targets owned by each peer.
+	Owners map[string]int `json:"owners"`
+	// SimulatedOwners is the number of targets owned by each peer once the
+	// peers named by the leaving query parameter left the cluster.
+	SimulatedOwners map[string]int `json:"simulatedOwners,omitempty"`
+}
+
+// getClusteringOwnershipHandler returns how the targets of the clustered
+// components of all modules are distributed between peers.
+func (f *FlowAPI) getClusteringOwnershipHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		inspector, err := f.ringInspector()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var (
+			current   = inspector.Simulate()
+			simulated cluster.Cluster
+			res       = []componentOwnership{}
+		)
+		if leaving := r.URL.Query()["leaving"]; len(leaving) > 0 {
+			simulated = inspector.Simulate(leaving...)
+		}
+
+		err = f.walkComponents("", func(info *component.Info) {
+			reporter, ok := info.Component.(cluster.OwnershipReporter)
+			if !ok {
+				return
+			}
+			owners := reporter.TargetOwners(current)
+			if owners == nil {
+				return
+			}
+
+			ownership := componentOwnership{
+				ID:     info.ID.String(),
+				Name:   info.ComponentName,
+				Owners: owners,
+			}
+			if simulated != nil {
+				ownership.SimulatedOwners = reporter.TargetOwners(simulated)
+			}
+			res = append(res, ownership)
+		})
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		bb, err := json.Marshal(res)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		_, _ = w.Write(bb)
+	}
+}
+
+// ringInspector returns the ring inspector of the cluster service.
+func (f *FlowAPI) ringInspector() (cluster.RingInspector, error) {
+	svc, found := f.flow.GetService(cluster.ServiceName)
+	if !found {
+		return nil, fmt.Errorf("cluster service not running")
+	}
+	inspector, ok := svc.Data().(cluster.RingInspector)
+	if !ok {
+		return nil, fmt.Errorf("cluster service doesn't provide a ring inspector")
+	}
+	return inspector, nil
+}
+
+// walkComponents calls fn for every component of moduleID and of the modules
+// created by its components.
+func (f *FlowAPI) walkComponents(moduleID string, fn func(*component.Info)) error {
+	infos, err := f.flow.ListComponents(moduleID, component.InfoOptions{})
+	if err != nil {
+		return err
+	}
+	for _, info := range infos {
+		fn(info)
+		for _, child := range info.ModuleIDs {
+			if err := f.walkComponents(child, fn); err != nil && !errors.Is(err, component.ErrModuleNotFound) {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// configRenderer is implemented by hosts which can render the config they
+// loaded, such as the root Flow controller.
+type configRenderer interface {
+	RenderedConfig() ([]byte, error)
+}
+
+func (f *FlowAPI) getConfigHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, _ *http.Request) {
+		renderer, ok := f.flow.(configRenderer)
+		if !ok {
+			http.Error(w, "config not available", http.StatusNotFound)
+			return
+		}
+		bb, err := renderer.RenderedConfig()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write(bb)
+	}
+}
@@ -3,7 +3,9 @@ import { BrowserRouter, Route, Routes } from 'react-router-dom';
 
 import Navbar from './features/layout/Navbar';
 import PageClusteringPeers from './pages/Clustering';
+import PageClusteringRing from './pages/ClusteringRing';
 import ComponentDetailPage from './pages/ComponentDetailPage';
+import PageConfig from './pages/Config';
 import Graph from './pages/Graph';
 import PageComponentList from './pages/PageComponentList';
 
@@ -21,6 +23,8 @@ const Router = ({ basePath }: Props) => {
           <Route path="/component/*" element={<ComponentDetailPage />} />
           <Route path="/graph" element={<Graph />} />
           <Route path="/clustering" element={<PageClusteringPeers />} />
+          <Route path="/clustering/ring" element={<PageClusteringRing />} />
+          <Route path="/config" element={<PageConfig />} />
         </Routes>
       </main>
     </BrowserRouter>
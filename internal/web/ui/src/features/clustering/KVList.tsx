@@ -0,0 +1,48 @@
+import { KVEntry } from '../clustering/types';
+
+import Table from './Table';
+
+import styles from './PeerList.module.css';
+
+interface KVListProps {
+  entries: KVEntry[];
+}
+
+const TABLEHEADERS = ['Key', 'Type', 'Value', 'Updated By', 'Updated At'];
+
+const KVList = ({ entries }: KVListProps) => {
+  const tableStyles = { width: '130px' };
+
+  /**
+   * Custom renderer for table data
+   */
+  const renderTableData = () => {
+    return entries.map(({ key, type, value, updatedBy, updatedAt }) => (
+      <tr key={`${type}/${key}`} style={{ lineHeight: '2.5' }}>
+        <td>
+          <span className={styles.idName}>{key}</span>
+        </td>
+        <td>
+          <span className={styles.idName}>{type}</span>
+        </td>
+        <td>
+          <span className={styles.idName}>{value}</span>
+        </td>
+        <td>
+          <span className={styles.idName}>{updatedBy}</span>
+        </td>
+        <td>
+          <span className={styles.idName}>{updatedAt}</span>
+        </td>
+      </tr>
+    ));
+  };
+
+  return (
+    <div className={styles.list}>
+      <Table tableHeaders={TABLEHEADERS} renderTableData={renderTableData} style={tableStyles} />
+    </div>
+  );
+};
+
+export default KVList;
@@ -0,0 +1,53 @@
+import { ComponentOwnership } from './types';
+import Table from './Table';
+
+import styles from './Ring.module.css';
+
+interface OwnershipListProps {
+  peers: string[];
+  ownership: ComponentOwnership[];
+}
+
+/**
+ * OwnershipList shows how many targets of each clustered component are owned
+ * by each peer, and how many they would own in the simulated cluster.
+ */
+const OwnershipList = ({ peers, ownership }: OwnershipListProps) => {
+  const tableStyles = { width: '130px' };
+  const headers = ['Component', ...peers, 'No Owner'];
+
+  const renderCount = (name: string, owners: Record<string, number>, simulatedOwners?: Record<string, number>) => {
+    const count = owners[name] ?? 0;
+    if (!simulatedOwners) {
+      return <span>{count}</span>;
+    }
+    const simulated = simulatedOwners[name] ?? 0;
+    if (simulated === count) {
+      return <span>{count}</span>;
+    }
+    return (
+      <span className={styles.changed}>
+        {count} → {simulated}
+      </span>
+    );
+  };
+
+  /**
+   * Custom renderer for table data
+   */
+  const renderTableData = () => {
+    return ownership.map(({ id, owners, simulatedOwners }) => (
+      <tr key={id} style={{ lineHeight: '2.5' }}>
+        <td>{id}</td>
+        {peers.map((peer) => (
+          <td key={peer}>{renderCount(peer, owners, simulatedOwners)}</td>
+        ))}
+        <td>{renderCount('', owners, simulatedOwners)}</td>
+      </tr>
+    ));
+  };
+
+  return <Table tableHeaders={headers} renderTableData={renderTableData} style={tableStyles} />;
+};
+
+export default OwnershipList;
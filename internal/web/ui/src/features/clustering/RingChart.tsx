@@ -0,0 +1,67 @@
+import { RingRange } from './types';
+import { peerColor } from './colors';
+
+interface RingChartProps {
+  peers: string[];
+  ranges: RingRange[];
+  size?: number;
+}
+
+/**
+ * arcPath returns the SVG path of the slice of a donut between the start
+ * and end fractions of a circle.
+ */
+const arcPath = (start: number, end: number, outer: number, inner: number, center: number): string => {
+  const point = (fraction: number, radius: number) => {
+    // Start at 12 o'clock and go clockwise.
+    const angle = 2 * Math.PI * fraction - Math.PI / 2;
+    return `${center + radius * Math.cos(angle)} ${center + radius * Math.sin(angle)}`;
+  };
+  const largeArc = end - start > 0.5 ? 1 : 0;
+
+  return [
+    `M ${point(start, outer)}`,
+    `A ${outer} ${outer} 0 ${largeArc} 1 ${point(end, outer)}`,
+    `L ${point(end, inner)}`,
+    `A ${inner} ${inner} 0 ${largeArc} 0 ${point(start, inner)}`,
+    'Z',
+  ].join(' ');
+};
+
+/**
+ * splitRanges splits the ranges covering half of the circle or more, since a
+ * single arc can't be drawn between the same start and end points.
+ */
+const splitRanges = (ranges: RingRange[]): RingRange[] => {
+  return ranges.flatMap((r) => {
+    if (r.end - r.start < 0.5) {
+      return [r];
+    }
+    const middle = (r.start + r.end) / 2;
+    return [
+      { ...r, end: middle },
+      { ...r, start: middle },
+    ];
+  });
+};
+
+/**
+ * RingChart draws the ranges of keys owned by each peer around a circle.
+ */
+const RingChart = ({ peers, ranges, size = 320 }: RingChartProps) => {
+  const center = size / 2;
+  const outer = center - 4;
+  const inner = outer * 0.6;
+
+  return (
+    <svg width={size} height={size} viewBox={`0 0 ${size} ${size}`}>
+      {splitRanges(ranges).map(({ start, end, peer }) => (
+        <path key={start} d={arcPath(start, end, outer, inner, center)} fill={peerColor(peers, peer)}>
+          <title>{peer}</title>
+        </path>
+      ))}
+    </svg>
+  );
+};
+
+export default RingChart;
@@ -0,0 +1,24 @@
+const PALETTE = [
+  '#3885dc',
+  '#f2a33a',
+  '#56a64b',
+  '#e02f44',
+  '#8f3bb8',
+  '#1fa5a5',
+  '#c4162a',
+  '#8ab8ff',
+  '#fade2a',
+  '#a352cc',
+];
+
+/**
+ * peerColor returns the color of a peer, given the sorted names of all
+ * peers.
+ */
+export const peerColor = (peers: string[], name: string): string => {
+  const idx = peers.indexOf(name);
+  if (idx === -1) {
+    return '#ccccdc';
+  }
+  return PALETTE[idx % PALETTE.length];
+};
@@ -7,3 +7,63 @@ export interface PeerInfo {
 
   isSelf: boolean;
 }
+
+export interface KVEntry {
+  key: string;
+
+  // Either "register" or "counter".
+  type: string;
+
+  value: string;
+
+  // Node which last set a register.
+  updatedBy?: string;
+
+  // Time a register was last set.
+  updatedAt?: string;
+}
+
+export interface Ring {
+  tokensPerNode: number;
+
+  peers: RingPeer[];
+
+  ranges: RingRange[];
+}
+
+export interface RingPeer {
+  name: string;
+
+  isSelf: boolean;
+
+  state: string;
+
+  zone?: string;
+
+  // Fraction of the keys owned by the peer.
+  share: number;
+}
+
+// RingRange is a range of keys owned by a peer. start and end are fractions
+// of the key space, between 0 and 1.
+export interface RingRange {
+  start: number;
+
+  end: number;
+
+  peer: string;
+}
+
+export interface ComponentOwnership {
+  id: string;
+
+  name: string;
+
+  // Number of targets owned by each peer. Targets without an owner are
+  // counted under the empty name.
+  owners: Record<string, number>;
+
+  // Number of targets owned by each peer once the simulated peers left the
+  // cluster.
+  simulatedOwners?: Record<string, number>;
+}
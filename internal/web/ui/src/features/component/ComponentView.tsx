@@ -100,6 +100,12 @@ export const ComponentView: FC<ComponentViewProps> = (props) => {
           </a>
         </div>
 
+        {props.component.singletonOwner && (
+          <p className={styles.singletonOwner}>
+            Singleton component running on node <code>{props.component.singletonOwner}</code>
+          </p>
+        )}
+
         {props.component.health.message && (
           <blockquote>
             <h1>
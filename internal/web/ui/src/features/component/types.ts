@@ -42,6 +42,12 @@ export interface ComponentInfo {
    * IDs of components which this component is referencing.
    */
   referencesTo: string[];
+
+  /**
+   * Name of the node of the cluster running the component, if the component
+   * is a singleton component.
+   */
+  singletonOwner?: string;
 }
 
 /**
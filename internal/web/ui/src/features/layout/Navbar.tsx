@@ -23,6 +23,11 @@ function Navbar() {
             Clustering
           </NavLink>
         </li>
+        <li>
+          <NavLink to="/config" className="nav-link">
+            Config
+          </NavLink>
+        </li>
         <li>
           <a href="https://grafana.com/docs/agent/latest">Help</a>
         </li>
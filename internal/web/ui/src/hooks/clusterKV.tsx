@@ -0,0 +1,29 @@
+import { useEffect, useState } from 'react';
+
+import { KVEntry } from '../features/clustering/types';
+
+/**
+ * useClusterKV retrieves the keys of the key/value store shared by the nodes
+ * of the cluster from the API.
+ */
+export const useClusterKV = (): KVEntry[] => {
+  const [entries, setEntries] = useState<KVEntry[]>([]);
+
+  useEffect(function () {
+    const worker = async () => {
+      // Request is relative to the <base> tag inside of <head>.
+      const resp = await fetch('./api/v0/web/peers/kv', {
+        cache: 'no-cache',
+        credentials: 'same-origin',
+      });
+      if (!resp.ok) {
+        return;
+      }
+      setEntries(await resp.json());
+    };
+
+    worker().catch(console.error);
+  }, []);
+
+  return entries;
+};
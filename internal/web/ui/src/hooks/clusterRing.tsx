@@ -0,0 +1,72 @@
+import { useEffect, useState } from 'react';
+
+import { ComponentOwnership, Ring } from '../features/clustering/types';
+
+/**
+ * leavingQuery returns the query string simulating the named peer leaving
+ * the cluster.
+ */
+const leavingQuery = (leaving?: string): string => {
+  return leaving ? '?leaving=' + encodeURIComponent(leaving) : '';
+};
+
+/**
+ * useClusterRing retrieves the ring of the cluster from the API.
+ *
+ * @param leaving The name of a peer to simulate leaving the cluster.
+ */
+export const useClusterRing = (leaving?: string): Ring | undefined => {
+  const [ring, setRing] = useState<Ring | undefined>(undefined);
+
+  useEffect(
+    function () {
+      const worker = async () => {
+        // Request is relative to the <base> tag inside of <head>.
+        const resp = await fetch('./api/v0/web/peers/ring' + leavingQuery(leaving), {
+          cache: 'no-cache',
+          credentials: 'same-origin',
+        });
+        if (!resp.ok) {
+          return;
+        }
+        setRing(await resp.json());
+      };
+
+      worker().catch(console.error);
+    },
+    [leaving]
+  );
+
+  return ring;
+};
+
+/**
+ * useClusterOwnership retrieves how the targets of clustered components are
+ * distributed between peers from the API.
+ *
+ * @param leaving The name of a peer to simulate leaving the cluster.
+ */
+export const useClusterOwnership = (leaving?: string): ComponentOwnership[] => {
+  const [ownership, setOwnership] = useState<ComponentOwnership[]>([]);
+
+  useEffect(
+    function () {
+      const worker = async () => {
+        // Request is relative to the <base> tag inside of <head>.
+        const resp = await fetch('./api/v0/web/peers/ownership' + leavingQuery(leaving), {
+          cache: 'no-cache',
+          credentials: 'same-origin',
+        });
+        if (!resp.ok) {
+          return;
+        }
+        setOwnership(await resp.json());
+      };
+
+      worker().catch(console.error);
+    },
+    [leaving]
+  );
+
+  return ownership;
+};
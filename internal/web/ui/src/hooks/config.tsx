@@ -0,0 +1,26 @@
+import { useEffect, useState } from 'react';
+
+/**
+ * useConfig retrieves the config loaded by the agent from the API. Overlays of
+ * the selected profile are merged into the returned config.
+ */
+export const useConfig = (): string => {
+  const [config, setConfig] = useState<string>('');
+
+  useEffect(function () {
+    const worker = async () => {
+      const configPath = './api/v0/web/config';
+
+      // Request is relative to the <base> tag inside of <head>.
+      const resp = await fetch(configPath, {
+        cache: 'no-cache',
+        credentials: 'same-origin',
+      });
+      setConfig(await resp.text());
+    };
+
+    worker().catch(console.error);
+  }, []);
+
+  return config;
+};
@@ -1,15 +1,24 @@
+import { Link } from 'react-router-dom';
 import { faNetworkWired } from '@fortawesome/free-solid-svg-icons';
 
+import KVList from '../features/clustering/KVList';
 import PeerList from '../features/clustering/PeerList';
 import Page from '../features/layout/Page';
+import { useClusterKV } from '../hooks/clusterKV';
 import { usePeerInfo } from '../hooks/peerInfo';
 
 function PageClusteringPeers() {
   const peers = usePeerInfo();
+  const entries = useClusterKV();
 
   return (
     <Page name="Clustering" desc="List of clustering peers" icon={faNetworkWired}>
       <PeerList peers={peers} />
+      <p>
+        <Link to="/clustering/ring">View how keys and targets are distributed between peers</Link>
+      </p>
+      <h2>Shared state</h2>
+      <KVList entries={entries} />
     </Page>
   );
 }
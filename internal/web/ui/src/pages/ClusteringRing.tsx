@@ -0,0 +1,79 @@
+import { useState } from 'react';
+import { faCircleNotch } from '@fortawesome/free-solid-svg-icons';
+
+import { peerColor } from '../features/clustering/colors';
+import OwnershipList from '../features/clustering/OwnershipList';
+import RingChart from '../features/clustering/RingChart';
+import Table from '../features/clustering/Table';
+import Page from '../features/layout/Page';
+import { useClusterOwnership, useClusterRing } from '../hooks/clusterRing';
+
+import styles from '../features/clustering/Ring.module.css';
+
+const formatShare = (share?: number): string => {
+  return share === undefined ? '-' : (share * 100).toFixed(1) + '%';
+};
+
+function PageClusteringRing() {
+  const [leaving, setLeaving] = useState('');
+
+  const ring = useClusterRing();
+  const simulatedRing = useClusterRing(leaving || undefined);
+  const ownership = useClusterOwnership(leaving || undefined);
+
+  const peers = (ring?.peers ?? []).map((p) => p.name);
+  const simulatedShares = new Map((simulatedRing?.peers ?? []).map((p) => [p.name, p.share]));
+
+  const headers = ['Node Name', 'Zone', 'Current State', 'Share of Keys'];
+  if (leaving) {
+    headers.push(`Share Without ${leaving}`);
+  }
+
+  /**
+   * Custom renderer for table data
+   */
+  const renderShares = () => {
+    return (ring?.peers ?? []).map(({ name, zone, state, share, isSelf }) => (
+      <tr key={name} style={{ lineHeight: '2.5' }}>
+        <td>
+          <span className={styles.swatch} style={{ backgroundColor: peerColor(peers, name) }} />
+          {name} {isSelf ? '(local)' : ''}
+        </td>
+        <td>{zone}</td>
+        <td>{state}</td>
+        <td>{formatShare(share)}</td>
+        {leaving && <td>{name === leaving ? '-' : formatShare(simulatedShares.get(name))}</td>}
+      </tr>
+    ));
+  };
+
+  return (
+    <Page name="Cluster Ring" desc="Distribution of keys between clustering peers" icon={faCircleNotch}>
+      <div className={styles.simulation}>
+        <label>
+          Simulate a node leaving the cluster:
+          <select value={leaving} onChange={(e) => setLeaving(e.target.value)}>
+            <option value="">None</option>
+            {peers.map((name) => (
+              <option key={name} value={name}>
+                {name}
+              </option>
+            ))}
+          </select>
+        </label>
+      </div>
+
+      <div className={styles.ring}>
+        <RingChart peers={peers} ranges={(leaving ? simulatedRing : ring)?.ranges ?? []} />
+        <div className={styles.shares}>
+          <Table tableHeaders={headers} renderTableData={renderShares} style={{ width: '130px' }} />
+        </div>
+      </div>
+
+      <h2>Targets of clustered components</h2>
+      <OwnershipList peers={peers} ownership={ownership} />
+    </Page>
+  );
+}
+
+export default PageClusteringRing;
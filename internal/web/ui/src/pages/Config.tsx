@@ -0,0 +1,20 @@
+import { Prism as SyntaxHighlighter } from 'react-syntax-highlighter';
+import { faFileCode } from '@fortawesome/free-solid-svg-icons';
+
+import { style } from '../features/component/style';
+import Page from '../features/layout/Page';
+import { useConfig } from '../hooks/config';
+
+function PageConfig() {
+  const config = useConfig();
+
+  return (
+    <Page name="Config" desc="Loaded configuration, with the overlays of the selected profile" icon={faFileCode}>
+      <SyntaxHighlighter language="javascript" style={style}>
+        {config}
+      </SyntaxHighlighter>
+    </Page>
+  );
+}
+
+export default PageConfig;